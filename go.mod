@@ -4,11 +4,13 @@ go 1.15
 
 require (
 	github.com/boltdb/bolt v1.3.1
+	github.com/btcsuite/btcd/btcec/v2 v2.3.4
+	github.com/gorilla/websocket v1.4.2
 	github.com/jiuzhou-zhao/bolt-client v0.0.0-20210309042928-db9393c156e1
 	github.com/jiuzhou-zhao/go-fundamental v0.0.5
 	github.com/satori/go.uuid v1.2.0
 	github.com/sgostarter/liblog v0.0.0-20210204094833-500d17ae3c96
-	github.com/stretchr/testify v1.7.0
+	github.com/stretchr/testify v1.8.0
 	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 	golang.org/x/sys v0.0.0-20210223212115-eede4237b368 // indirect
 )
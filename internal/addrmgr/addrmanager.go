@@ -0,0 +1,451 @@
+package addrmgr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	mrand "math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+)
+
+const (
+	serializationVersion = 1
+
+	newBucketCount       = 64
+	triedBucketCount     = 16
+	bucketSize           = 64
+	newBucketsPerGroup   = 8
+	triedBucketsPerGroup = 4
+
+	// needAddressThreshold is the book size under which peers are asked for more addresses.
+	needAddressThreshold = 1000
+
+	getAddrMin     = 25
+	getAddrMax     = 1000
+	getAddrPercent = 23
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// AddrManager is the address book of known peers. Addresses heard of live in the new buckets, the ones we
+// connected to in the tried buckets. The bucket of an address is chosen by a keyed hash of its network group
+// and of the group it was heard from, so that a single source fills only a few buckets and can not crowd
+// honest addresses out of the book.
+type AddrManager struct {
+	lock      sync.Mutex
+	dataFile  string
+	key       [32]byte
+	rand      *mrand.Rand
+	addrIndex map[string]*KnownAddress
+	addrNew   [newBucketCount]map[string]*KnownAddress
+	addrTried [triedBucketCount]map[string]*KnownAddress
+	nNew      int
+	nTried    int
+}
+
+type serializedAddrManager struct {
+	Version      int
+	Key          [32]byte
+	Addresses    []*KnownAddress
+	NewBuckets   [newBucketCount][]string
+	TriedBuckets [triedBucketCount][]string
+}
+
+// New returns an empty address book saved to dataFile, an empty dataFile keeps it in memory only.
+func New(dataFile string) *AddrManager {
+	am := &AddrManager{
+		dataFile: dataFile,
+		rand:     mrand.New(mrand.NewSource(time.Now().UnixNano())), // nolint: gosec
+	}
+	_, _ = rand.Read(am.key[:])
+	am.reset()
+	return am
+}
+
+func (am *AddrManager) reset() {
+	am.addrIndex = make(map[string]*KnownAddress)
+	for idx := range am.addrNew {
+		am.addrNew[idx] = make(map[string]*KnownAddress)
+	}
+	for idx := range am.addrTried {
+		am.addrTried[idx] = make(map[string]*KnownAddress)
+	}
+	am.nNew = 0
+	am.nTried = 0
+}
+
+// Load reads the address book from its file, a missing file leaves the book empty.
+func (am *AddrManager) Load() error {
+	if am.dataFile == "" {
+		return nil
+	}
+	fileContent, err := ioutil.ReadFile(am.dataFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var sam serializedAddrManager
+	err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&sam)
+	if err != nil {
+		return fmt.Errorf("decode %s failed: %w", am.dataFile, err)
+	}
+	if sam.Version != serializationVersion {
+		return fmt.Errorf("unknown address book version %d", sam.Version)
+	}
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	am.reset()
+	am.key = sam.Key
+	for _, ka := range sam.Addresses {
+		am.addrIndex[ka.Addr] = ka
+	}
+	for idx, addrs := range sam.NewBuckets {
+		for _, addr := range addrs {
+			ka, ok := am.addrIndex[addr]
+			if !ok || ka.Tried {
+				continue
+			}
+			if ka.refs == 0 {
+				am.nNew++
+			}
+			ka.refs++
+			am.addrNew[idx][addr] = ka
+		}
+	}
+	for idx, addrs := range sam.TriedBuckets {
+		for _, addr := range addrs {
+			ka, ok := am.addrIndex[addr]
+			if !ok || !ka.Tried {
+				continue
+			}
+			am.nTried++
+			am.addrTried[idx][addr] = ka
+		}
+	}
+	for addr, ka := range am.addrIndex {
+		if !ka.Tried && ka.refs == 0 {
+			delete(am.addrIndex, addr)
+		}
+	}
+	return nil
+}
+
+// Save writes the address book to its file.
+func (am *AddrManager) Save() error {
+	if am.dataFile == "" {
+		return nil
+	}
+
+	am.lock.Lock()
+	sam := serializedAddrManager{
+		Version:   serializationVersion,
+		Key:       am.key,
+		Addresses: make([]*KnownAddress, 0, len(am.addrIndex)),
+	}
+	for _, ka := range am.addrIndex {
+		kaCopy := *ka
+		sam.Addresses = append(sam.Addresses, &kaCopy)
+	}
+	for idx, bucket := range am.addrNew {
+		for addr := range bucket {
+			sam.NewBuckets[idx] = append(sam.NewBuckets[idx], addr)
+		}
+	}
+	for idx, bucket := range am.addrTried {
+		for addr := range bucket {
+			sam.TriedBuckets[idx] = append(sam.TriedBuckets[idx], addr)
+		}
+	}
+	am.lock.Unlock()
+
+	var content bytes.Buffer
+	err := gob.NewEncoder(&content).Encode(&sam)
+	if err != nil {
+		return err
+	}
+	tmpFile := am.dataFile + ".tmp"
+	err = ioutil.WriteFile(tmpFile, content.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, am.dataFile)
+}
+
+// CheckAddress accepts host:port addresses with a numeric port.
+func CheckAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || p == 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+	}
+	return nil
+}
+
+func (am *AddrManager) hash(parts ...string) uint64 {
+	var buf bytes.Buffer
+	buf.Write(am.key[:])
+	for _, part := range parts {
+		buf.WriteString(part)
+		buf.WriteByte(0)
+	}
+	return binary.BigEndian.Uint64(chainhash.DoubleHashB(buf.Bytes())[:8])
+}
+
+func (am *AddrManager) getNewBucket(addr, source string) int {
+	srcGroup := GroupKey(source)
+	h := am.hash(GroupKey(addr), srcGroup) % newBucketsPerGroup
+	return int(am.hash(srcGroup, strconv.FormatUint(h, 10)) % newBucketCount)
+}
+
+func (am *AddrManager) getTriedBucket(addr string) int {
+	h := am.hash(addr) % triedBucketsPerGroup
+	return int(am.hash(GroupKey(addr), strconv.FormatUint(h, 10)) % triedBucketCount)
+}
+
+// AddAddress records an address heard of from source.
+func (am *AddrManager) AddAddress(addr, source string) error {
+	err := CheckAddress(addr)
+	if err != nil {
+		return err
+	}
+
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	am.addAddress(addr, source, time.Now())
+	return nil
+}
+
+// AddAddresses records addresses heard of from source, the invalid ones are skipped.
+func (am *AddrManager) AddAddresses(addrs []string, source string) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	now := time.Now()
+	for _, addr := range addrs {
+		if CheckAddress(addr) != nil {
+			continue
+		}
+		am.addAddress(addr, source, now)
+	}
+}
+
+func (am *AddrManager) addAddress(addr, source string, now time.Time) {
+	ka, ok := am.addrIndex[addr]
+	if ok {
+		if now.After(ka.LastSeen) {
+			ka.LastSeen = now
+		}
+		return
+	}
+
+	ka = &KnownAddress{
+		Addr:     addr,
+		Source:   source,
+		LastSeen: now,
+	}
+	bucket := am.getNewBucket(addr, source)
+	if len(am.addrNew[bucket]) >= bucketSize {
+		am.expireNew(bucket, now)
+	}
+	am.addrIndex[addr] = ka
+	am.addrNew[bucket][addr] = ka
+	ka.refs++
+	am.nNew++
+}
+
+// expireNew makes room in a full new bucket, bad addresses go first, then the one seen the longest ago.
+func (am *AddrManager) expireNew(bucket int, now time.Time) {
+	var oldest *KnownAddress
+	for _, ka := range am.addrNew[bucket] {
+		if ka.isBad(now) {
+			am.removeFromNew(bucket, ka)
+			continue
+		}
+		if oldest == nil || ka.LastSeen.Before(oldest.LastSeen) {
+			oldest = ka
+		}
+	}
+	if len(am.addrNew[bucket]) >= bucketSize && oldest != nil {
+		am.removeFromNew(bucket, oldest)
+	}
+}
+
+func (am *AddrManager) removeFromNew(bucket int, ka *KnownAddress) {
+	delete(am.addrNew[bucket], ka.Addr)
+	ka.refs--
+	if ka.refs == 0 {
+		am.nNew--
+		delete(am.addrIndex, ka.Addr)
+	}
+}
+
+// Attempt records a connection attempt to the address.
+func (am *AddrManager) Attempt(addr string) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	if ka, ok := am.addrIndex[addr]; ok {
+		ka.LastAttempt = time.Now()
+		ka.Attempts++
+	}
+}
+
+// Connected records that the address is alive.
+func (am *AddrManager) Connected(addr string) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	if ka, ok := am.addrIndex[addr]; ok {
+		ka.LastSeen = time.Now()
+	}
+}
+
+// Good records a successful connection, the address moves to the tried buckets.
+// The address a tried bucket gives room for goes back to the new buckets.
+func (am *AddrManager) Good(addr string) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	ka, ok := am.addrIndex[addr]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	ka.LastSeen = now
+	ka.LastSuccess = now
+	ka.LastAttempt = now
+	ka.Attempts = 0
+	if ka.Tried {
+		return
+	}
+
+	for idx := range am.addrNew {
+		delete(am.addrNew[idx], addr)
+	}
+	ka.refs = 0
+	am.nNew--
+
+	bucket := am.getTriedBucket(addr)
+	if len(am.addrTried[bucket]) >= bucketSize {
+		var oldest *KnownAddress
+		for _, tka := range am.addrTried[bucket] {
+			if oldest == nil || tka.LastSuccess.Before(oldest.LastSuccess) {
+				oldest = tka
+			}
+		}
+		delete(am.addrTried[bucket], oldest.Addr)
+		am.nTried--
+		oldest.Tried = false
+
+		newBucket := am.getNewBucket(oldest.Addr, oldest.Source)
+		if len(am.addrNew[newBucket]) >= bucketSize {
+			am.expireNew(newBucket, now)
+		}
+		am.addrNew[newBucket][oldest.Addr] = oldest
+		oldest.refs++
+		am.nNew++
+	}
+	ka.Tried = true
+	am.addrTried[bucket][addr] = ka
+	am.nTried++
+}
+
+// GetAddress picks an address to connect to, from the tried or the new buckets with even odds.
+// It returns nil when the book is empty.
+func (am *AddrManager) GetAddress() *KnownAddress {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	if am.nNew+am.nTried == 0 {
+		return nil
+	}
+
+	var buckets []map[string]*KnownAddress
+	if am.nTried > 0 && (am.nNew == 0 || am.rand.Intn(2) == 0) {
+		buckets = am.addrTried[:]
+	} else {
+		buckets = am.addrNew[:]
+	}
+
+	candidates := make([]*KnownAddress, 0)
+	for _, bucket := range buckets {
+		for _, ka := range bucket {
+			candidates = append(candidates, ka)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	ka := *candidates[am.rand.Intn(len(candidates))]
+	return &ka
+}
+
+// AddressCache returns a random share of the good addresses, the reply to a getaddr.
+func (am *AddrManager) AddressCache() []string {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	now := time.Now()
+	addrs := make([]string, 0, len(am.addrIndex))
+	for addr, ka := range am.addrIndex {
+		if !ka.isBad(now) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	num := len(addrs) * getAddrPercent / 100
+	if num < getAddrMin {
+		num = getAddrMin
+	}
+	if num > getAddrMax {
+		num = getAddrMax
+	}
+	if num > len(addrs) {
+		num = len(addrs)
+	}
+	am.rand.Shuffle(len(addrs), func(i, j int) {
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	})
+	return addrs[:num]
+}
+
+func (am *AddrManager) NumAddresses() int {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	return am.nNew + am.nTried
+}
+
+// NeedMoreAddresses reports whether peers should be asked for addresses.
+func (am *AddrManager) NeedMoreAddresses() bool {
+	return am.NumAddresses() < needAddressThreshold
+}
+
+// Addresses returns every known address.
+func (am *AddrManager) Addresses() []KnownAddress {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	addrs := make([]KnownAddress, 0, len(am.addrIndex))
+	for _, ka := range am.addrIndex {
+		addrs = append(addrs, *ka)
+	}
+	return addrs
+}
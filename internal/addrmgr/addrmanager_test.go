@@ -0,0 +1,83 @@
+package addrmgr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddrManager_AddAndGood(t *testing.T) {
+	am := New("")
+	assert.Nil(t, am.GetAddress())
+	assert.ErrorIs(t, am.AddAddress("no-port", "seed"), ErrInvalidAddress)
+	assert.ErrorIs(t, am.AddAddress("10.0.0.1:0", "seed"), ErrInvalidAddress)
+
+	assert.Nil(t, am.AddAddress("10.0.0.1:8333", "seed:1"))
+	assert.Nil(t, am.AddAddress("10.0.0.1:8333", "seed:1"))
+	assert.Equal(t, 1, am.NumAddresses())
+	assert.Equal(t, "10.0.0.1:8333", am.GetAddress().Addr)
+
+	am.Attempt("10.0.0.1:8333")
+	am.Good("10.0.0.1:8333")
+	addrs := am.Addresses()
+	assert.Len(t, addrs, 1)
+	assert.True(t, addrs[0].Tried)
+	assert.Equal(t, 0, addrs[0].Attempts)
+	assert.Equal(t, 1, am.NumAddresses())
+	assert.Equal(t, []string{"10.0.0.1:8333"}, am.AddressCache())
+}
+
+func TestAddrManager_SourceConfined(t *testing.T) {
+	am := New("")
+	addrs := make([]string, 0)
+	for idx := 0; idx < 5000; idx++ {
+		addrs = append(addrs, fmt.Sprintf("10.%d.%d.1:8333", idx/256, idx%256))
+	}
+	am.AddAddresses(addrs, "192.168.1.1:8333")
+
+	// the attacker only fills the new buckets its source group maps to, the rest of the table is left to others
+	buckets := make(map[int]bool)
+	for idx, bucket := range am.addrNew {
+		if len(bucket) > 0 {
+			buckets[idx] = true
+		}
+	}
+	attackerBuckets := make(map[int]bool)
+	for _, addr := range addrs {
+		attackerBuckets[am.getNewBucket(addr, "192.168.1.1:8333")] = true
+	}
+	assert.Equal(t, attackerBuckets, buckets)
+	assert.LessOrEqual(t, len(buckets), newBucketsPerGroup)
+	assert.Equal(t, len(buckets)*bucketSize, am.NumAddresses())
+
+	// flooding again fills no more
+	am.AddAddresses(addrs, "192.168.1.1:8333")
+	assert.Equal(t, len(buckets)*bucketSize, am.NumAddresses())
+}
+
+func TestAddrManager_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addrmgr")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dataFile := filepath.Join(dir, "peers.dat")
+
+	am := New(dataFile)
+	assert.Nil(t, am.Load())
+	assert.Nil(t, am.AddAddress("10.0.0.1:8333", "seed:1"))
+	assert.Nil(t, am.AddAddress("10.1.0.1:8333", "seed:1"))
+	am.Good("10.1.0.1:8333")
+	assert.Nil(t, am.Save())
+
+	loaded := New(dataFile)
+	assert.Nil(t, loaded.Load())
+	assert.Equal(t, am.key, loaded.key)
+	assert.Equal(t, 1, loaded.nNew)
+	assert.Equal(t, 1, loaded.nTried)
+	for _, ka := range loaded.Addresses() {
+		assert.Equal(t, ka.Addr == "10.1.0.1:8333", ka.Tried)
+	}
+}
@@ -0,0 +1,53 @@
+package addrmgr
+
+import (
+	"net"
+	"time"
+)
+
+// KnownAddress is a peer address with what we know about reaching it.
+type KnownAddress struct {
+	Addr        string
+	Source      string
+	LastSeen    time.Time
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Attempts    int
+	Tried       bool
+
+	refs int // new buckets holding it
+}
+
+// isBad tells an address not worth keeping: gone for a month or failing without ever succeeding.
+func (ka *KnownAddress) isBad(now time.Time) bool {
+	if now.Sub(ka.LastAttempt) < time.Minute {
+		return false
+	}
+	if now.Sub(ka.LastSeen) > 30*24*time.Hour {
+		return true
+	}
+	if ka.LastSuccess.IsZero() && ka.Attempts >= 3 {
+		return true
+	}
+	if now.Sub(ka.LastSuccess) > 7*24*time.Hour && ka.Attempts >= 10 {
+		return true
+	}
+	return false
+}
+
+// GroupKey returns the network group of an address: the /16 of IPv4, the /32 of IPv6, the host otherwise.
+// Addresses of one group are assumed to be under one operator's control.
+func GroupKey(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String()
+}
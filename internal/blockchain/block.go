@@ -3,7 +3,6 @@ package blockchain
 import (
 	"bytes"
 	"encoding/gob"
-	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -62,31 +61,55 @@ func (b *Block) HashTransactions() *chainhash.Hash {
 	return merkletree.CalcMerkleTreeRootHash(transactionHashes)
 }
 
-func (b *Block) Check() error {
+// Check verifies the block against the limits of params without looking up the outputs its transactions spend.
+func (b *Block) Check(params *Params) error {
 	if b == nil {
-		return errors.New("empty block")
+		return ruleError(ErrEmptyBlock, "empty block")
 	}
 	if len(b.Transactions) == 0 {
-		return errors.New("no transactions")
+		return ruleError(ErrNoTransactions, "no transactions")
 	}
 	if !b.Transactions[0].IsCoinbase() {
-		return errors.New("not start with coin base")
+		return ruleError(ErrFirstTxNotCoinbase, "not start with coin base")
 	}
 
+	if size := len(b.Serialize()); size > params.MaxBlockSize {
+		return ruleError(ErrBlockTooBig, fmt.Sprintf("block of %d bytes, the most is %d", size, params.MaxBlockSize))
+	}
+
+	sigOps := 0
 	for _, transaction := range b.Transactions {
 		err := transaction.simpleVerify()
 		if err != nil {
 			return fmt.Errorf("%w", err)
 		}
+		err = transaction.CheckLimits(params)
+		if err != nil {
+			return err
+		}
+		sigOps += transaction.SigOpCount()
+	}
+	err := b.checkSigOps(sigOps, params)
+	if err != nil {
+		return err
 	}
 
 	if !NewProofOfWork(b).Validate() {
-		return errors.New("pow error")
+		return ruleError(ErrHighHash, "pow error")
 	}
 
 	return nil
 }
 
+// checkSigOps checks sigOps, the signature operations of the transactions of the block, against params.
+func (b *Block) checkSigOps(sigOps int, params *Params) error {
+	if sigOps > params.MaxBlockSigOps {
+		return ruleError(ErrTooManySigOps, fmt.Sprintf("block %s of %d signature operations, the most is %d",
+			b.Hash, sigOps, params.MaxBlockSigOps))
+	}
+	return nil
+}
+
 // Serialize serializes the block.
 func (b *Block) Serialize() []byte {
 	var result bytes.Buffer
@@ -8,14 +8,14 @@ import (
 
 func TestBLockCheck(t *testing.T) {
 	var block *Block
-	assert.NotNil(t, block.Check())
+	assert.NotNil(t, block.Check(&MainNetParams))
 
 	block = &Block{}
-	assert.NotNil(t, block.Check())
+	assert.NotNil(t, block.Check(&MainNetParams))
 
 	block.Transactions = append(block.Transactions, &Transaction{})
-	assert.NotNil(t, block.Check())
+	assert.NotNil(t, block.Check(&MainNetParams))
 
 	block.Transactions = []*Transaction{NewCoinbaseTX("1EhHbToNa5vkBZrGoD97ThNTffqVQNS9cd", "")}
-	assert.NotNil(t, block.Check())
+	assert.NotNil(t, block.Check(&MainNetParams))
 }
@@ -5,14 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
-	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
 	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
 	"github.com/jiuzhou-zhao/go-fundamental/loge"
 )
 
 const (
+	maxOrphanBlocks = 100
+	// genesisHeight is the height of the genesis block, the first of every chain.
+	genesisHeight = 1
+	// locatorDenseHashes is how many of the latest blocks a locator lists before skipping farther back.
+	locatorDenseHashes = 10
+
 	// blockChainDataFile = "blockchain.data" .
 	genesisBlockHash = "0000924ddc0e3c989c22ec6a63bc528267d866111322537ccdddda95126445ca"
 	// nolint: lll
@@ -34,6 +41,12 @@ type BlockChains struct {
 	orphanedBlocks    map[chainhash.Hash]*Block
 	orphanedPreHashes map[chainhash.Hash]chainhash.Hash
 	sideChains        *SideBlockChains
+	sigCache          *SigCache
+	params            *Params
+
+	notificationsLock    sync.RWMutex
+	notifications        []NotificationCallback
+	pendingNotifications []*Notification
 }
 
 func NewBlockChains() (chains *BlockChains, err error) {
@@ -41,10 +54,22 @@ func NewBlockChains() (chains *BlockChains, err error) {
 	if err != nil {
 		return
 	}
+	return NewBlockChainsWithDB(stg)
+}
+
+// NewBlockChainsWithDB opens the chains on the given storage, the genesis block is created when it is empty.
+func NewBlockChainsWithDB(stg db.DB) (chains *BlockChains, err error) {
+	return NewBlockChainsWithParams(stg, &MainNetParams)
+}
+
+// NewBlockChainsWithParams opens the chains on the given storage with the limits of params.
+func NewBlockChainsWithParams(stg db.DB, params *Params) (chains *BlockChains, err error) {
 	chains = &BlockChains{
 		db:                stg,
 		orphanedBlocks:    make(map[chainhash.Hash]*Block),
 		orphanedPreHashes: make(map[chainhash.Hash]chainhash.Hash),
+		sigCache:          NewSigCache(DefaultSigCacheSize),
+		params:            params,
 	}
 	chains.sideChains = NewSideBlockChains(chains)
 	err = chains.init()
@@ -54,6 +79,11 @@ func NewBlockChains() (chains *BlockChains, err error) {
 	return
 }
 
+// Params returns the consensus limits of the chains.
+func (bcs *BlockChains) Params() *Params {
+	return bcs.params
+}
+
 func (bcs *BlockChains) Close() {
 	_ = bcs.db.Close()
 }
@@ -69,6 +99,7 @@ func (bcs *BlockChains) init() error {
 			_ = tx.DeleteBucket(blockBucketName)
 			_ = tx.DeleteBucket(heightBucketName)
 			_ = tx.DeleteBucket(txBucketName)
+			_ = tx.DeleteBucket(dataBucketName)
 
 			blockBucket = nil
 			heightBucket = nil
@@ -112,6 +143,9 @@ func (bcs *BlockChains) init() error {
 		if bcs.latestBlock == nil {
 			return errors.New("no genesis block")
 		}
+		if tx.Bucket(dataBucketName) == nil {
+			return bcs.reindexDataOnTx(tx)
+		}
 
 		return nil
 	})
@@ -180,13 +214,15 @@ func (bcs *BlockChains) GetLatestBlock() *Block {
 
 func (bcs *BlockChains) AddBlock(block *Block) error {
 	if bcs.blockExists(block.Hash) {
-		return errors.New("block exists")
+		return ruleError(ErrDuplicateBlock, "block exists")
 	}
-	err := block.Check()
+	err := block.Check(bcs.params)
 	if err != nil {
 		return err
 	}
-	return bcs.processNewBlock(block)
+	err = bcs.processNewBlock(block)
+	bcs.flushNotifications(err == nil)
+	return err
 }
 
 func (bcs *BlockChains) addSortedBlocks(blocks []*Block) error {
@@ -194,7 +230,10 @@ func (bcs *BlockChains) addSortedBlocks(blocks []*Block) error {
 		return bcs.add2MainBlocks(blocks)
 	}
 
-	h, _ := bcs.sideChains.NewSortedBlocks(blocks, bcs.getBlockOnMainChain(&blocks[0].PrevBlockHash))
+	h, err := bcs.sideChains.NewSortedBlocks(blocks, bcs.getBlockOnMainChain(&blocks[0].PrevBlockHash))
+	if err != nil {
+		return err
+	}
 	if h <= bcs.latestBlock.Height {
 		return nil
 	}
@@ -229,18 +268,33 @@ func (bcs *BlockChains) switchChain(block *Block) error {
 
 		h := preBlock.Height + 1
 		for ; h <= bcs.latestBlock.Height; h++ {
-			hKey := []byte(strconv.FormatInt(h, 10))
-			key := heightBucket.Get(hKey)
-			block := DeserializeBlock(blockBucket.Get(key))
+			block := DeserializeBlock(blockBucket.Get(heightBucket.Get([]byte(strconv.FormatInt(h, 10)))))
 			if block == nil {
 				return errors.New("switch failed")
 			}
 			switchedBlocks = append(switchedBlocks, block)
-			errDB = blockBucket.Delete(key)
+		}
+
+		// the outputs the blocks spent are read before their transactions leave the index
+		spentOutputs := make([][]UTXOEntry, len(switchedBlocks))
+		readBlocks := make(map[int64]*Block)
+		switchedTxs := make(map[string]bool)
+		for idx, block := range switchedBlocks {
+			spentOutputs[idx], errDB = bcs.spentOutputsOnTx(tx, block, readBlocks)
+			if errDB != nil {
+				return errDB
+			}
+			for _, transaction := range block.Transactions {
+				switchedTxs[transaction.TxID] = true
+			}
+		}
+
+		for _, block := range switchedBlocks {
+			errDB = blockBucket.Delete([]byte(block.Hash.String()))
 			if errDB != nil {
 				return errDB
 			}
-			errDB = heightBucket.Delete(hKey)
+			errDB = heightBucket.Delete(block.HeightS())
 			if errDB != nil {
 				return errDB
 			}
@@ -249,6 +303,10 @@ func (bcs *BlockChains) switchChain(block *Block) error {
 				if errDB != nil {
 					return errDB
 				}
+				errDB = unindexDataOnTx(tx, transaction)
+				if errDB != nil {
+					return errDB
+				}
 			}
 		}
 
@@ -259,28 +317,29 @@ func (bcs *BlockChains) switchChain(block *Block) error {
 				if errDB != nil {
 					return errDB
 				}
-				if transaction.IsCoinbase() {
+			}
+			// the outputs of the switched transactions do not come back
+			for _, entry := range spentOutputs[idx] {
+				if switchedTxs[entry.TxID] {
 					continue
 				}
-				for _, input := range transaction.Vin {
-					uTXOs, _ := DeserializeOutputs(uTXOBucket.Get([]byte(input.Txid)))
-					if uTXOs == nil {
-						uTXOs = &TXOutputs{}
-					}
-					uTXOs.Outputs = append(uTXOs.Outputs, TXOutput{
-						Index:      input.Vout,
-						Value:      input.Amount,
-						PubKeyHash: utils.HashPubKey(input.PubKey),
-					})
-
-					errDB = uTXOBucket.Put([]byte(input.Txid), uTXOs.Serialize())
-					if errDB != nil {
-						return errDB
-					}
+				uTXOs, _ := DeserializeOutputs(uTXOBucket.Get([]byte(entry.TxID)))
+				if uTXOs == nil {
+					uTXOs = &TXOutputs{}
+				}
+				uTXOs.Outputs = append(uTXOs.Outputs, entry.Output)
+
+				errDB = uTXOBucket.Put([]byte(entry.TxID), uTXOs.Serialize())
+				if errDB != nil {
+					return errDB
 				}
 			}
 		}
 
+		for idx := len(switchedBlocks) - 1; idx >= 0; idx-- {
+			bcs.queueBlockDisconnected(switchedBlocks[idx], spentOutputs[idx])
+		}
+
 		bcs.latestBlock = preBlock
 		errDB = heightBucket.Put(currentHeightKeyOnBucket, preBlock.HeightS())
 		if errDB != nil {
@@ -309,6 +368,17 @@ func (bcs *BlockChains) blockExists(h chainhash.Hash) bool {
 	return false
 }
 
+// HasBlock reports whether the block is known, on the main chain, a side chain or as an orphan.
+func (bcs *BlockChains) HasBlock(h chainhash.Hash) bool {
+	return bcs.blockExists(h)
+}
+
+// IsOrphan reports whether the block is waiting for its ancestors.
+func (bcs *BlockChains) IsOrphan(h chainhash.Hash) bool {
+	_, ok := bcs.orphanedBlocks[h]
+	return ok
+}
+
 func (bcs *BlockChains) getBlockOnMainChain(hash *chainhash.Hash) (block *Block) {
 	_ = bcs.db.View(func(tx db.Tx) error {
 		bucket := tx.Bucket(blockBucketName)
@@ -318,17 +388,39 @@ func (bcs *BlockChains) getBlockOnMainChain(hash *chainhash.Hash) (block *Block)
 	return
 }
 
+// verifyBlockTransactionsOnMainChain checks the locks of the transactions of blocks, then looks up the outputs
+// they spend in one read and verifies their scripts in parallel.
 func (bcs *BlockChains) verifyBlockTransactionsOnMainChain(blocks []*Block) error {
-	for _, block := range blocks {
+	view := &mainChainView{bcs: bcs, pending: blocks}
+	txs := make([]*Transaction, 0)
+	for idx, block := range blocks {
+		height := bcs.latestBlock.Height + 1 + int64(idx)
 		for _, transaction := range block.Transactions {
-			vc, err := bcs.GetCond4TransactionVerify(transaction)
-			if err != nil {
-				return err
-			}
-			err = transaction.Verify(vc)
+			err := checkTransactionLocks(view, transaction, height)
 			if err != nil {
 				return err
 			}
+			txs = append(txs, transaction)
+		}
+	}
+	conds, err := bcs.GetConds4TransactionsVerify(txs)
+	if err != nil {
+		return err
+	}
+	sigOps, err := bcs.verifyTransactions(txs, conds)
+	if err != nil {
+		return err
+	}
+	// the redeem scripts of the transactions count toward the limit of their block
+	for _, block := range blocks {
+		blockSigOps := 0
+		for _, count := range sigOps[:len(block.Transactions)] {
+			blockSigOps += count
+		}
+		sigOps = sigOps[len(block.Transactions):]
+		err = block.checkSigOps(blockSigOps, bcs.params)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -365,6 +457,7 @@ func (bcs *BlockChains) add2MainBlocksOnTx(tx db.Tx, blocks []*Block, preBlock *
 	txBucket := tx.Bucket(txBucketName)
 
 	var errDB error
+	readBlocks := make(map[int64]*Block)
 	for _, block := range blocks {
 		if !block.PrevBlockHash.IsEqual(&lastHash) {
 			return errors.New("add to main chain: invalid block")
@@ -375,17 +468,21 @@ func (bcs *BlockChains) add2MainBlocksOnTx(tx db.Tx, blocks []*Block, preBlock *
 
 		for _, transaction := range block.Transactions {
 			if transaction.TxID == "" {
-				loge.Fatal(nil, "no tx id")
+				return ruleError(ErrBadTxHeader, "no tx id")
 			}
 		}
 		for _, transaction := range block.Transactions {
 			if txBucket.Get([]byte(transaction.TxID)) != nil {
-				loge.Fatalf(nil, "exists tx: %s", transaction.TxID)
+				return ruleError(ErrDuplicateTx, fmt.Sprintf("exists tx: %s", transaction.TxID))
 			}
 			errDB = txBucket.Put([]byte(transaction.TxID), block.HeightS())
 			if errDB != nil {
 				return fmt.Errorf("%w", errDB)
 			}
+			errDB = indexDataOnTx(tx, transaction)
+			if errDB != nil {
+				return fmt.Errorf("%w", errDB)
+			}
 		}
 		errDB = blockBucket.Put([]byte(block.Hash.String()), block.Serialize())
 		if errDB != nil {
@@ -395,10 +492,16 @@ func (bcs *BlockChains) add2MainBlocksOnTx(tx db.Tx, blocks []*Block, preBlock *
 		if errDB != nil {
 			return fmt.Errorf("%w", errDB)
 		}
+		var spent []UTXOEntry
+		spent, errDB = bcs.spentOutputsOnTx(tx, block, readBlocks)
+		if errDB != nil {
+			return fmt.Errorf("%w", errDB)
+		}
 		errDB = bcs.UpdateUTXOInTx(block, tx)
 		if errDB != nil {
 			return fmt.Errorf("%w", errDB)
 		}
+		bcs.queueBlockConnected(block, spent)
 	}
 
 	latestBlock := blocks[len(blocks)-1]
@@ -431,8 +534,12 @@ func (bcs *BlockChains) processNewBlock(block *Block) error {
 			break
 		}
 
+		if len(bcs.orphanedBlocks) >= maxOrphanBlocks {
+			bcs.evictOrphan()
+		}
 		bcs.orphanedBlocks[block.Hash] = block
 		bcs.orphanedPreHashes[block.PrevBlockHash] = block.Hash
+		bcs.queueNotification(NTOrphanAdded, block)
 		return nil
 	}
 
@@ -457,6 +564,17 @@ func (bcs *BlockChains) processNewBlock(block *Block) error {
 	return bcs.addSortedBlocks(blocks)
 }
 
+// evictOrphan drops an arbitrary orphan so that peers can not grow the pool without bound.
+func (bcs *BlockChains) evictOrphan() {
+	for h, block := range bcs.orphanedBlocks {
+		delete(bcs.orphanedBlocks, h)
+		if bcs.orphanedPreHashes[block.PrevBlockHash] == h {
+			delete(bcs.orphanedPreHashes, block.PrevBlockHash)
+		}
+		return
+	}
+}
+
 func (bcs *BlockChains) ScanBlocks(fnOb func(*Block) error) (err error) {
 	if fnOb == nil {
 		return errors.New("no ob")
@@ -527,6 +645,9 @@ func (bcs *BlockChains) FindUTXOOnTX(tx db.Tx) map[string]TXOutputs {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if script.IsUnspendable(out.LockingScript()) {
+					continue
+				}
 				if sTXOs[txID] != nil {
 					for _, spentOutIdx := range sTXOs[txID] {
 						if spentOutIdx == outIdx {
@@ -559,6 +680,15 @@ func (bcs *BlockChains) GetBlock(hash *chainhash.Hash) *Block {
 	return bcs.getBlockOnMainChain(hash)
 }
 
+// GetBlockByHeight returns the main chain block at the height, nil when there is none.
+func (bcs *BlockChains) GetBlockByHeight(height int64) (block *Block) {
+	_ = bcs.db.View(func(tx db.Tx) error {
+		block = bcs.getBlockByHeightOnTX(tx, height)
+		return nil
+	})
+	return
+}
+
 func (bcs *BlockChains) GetBlockHashes() []chainhash.Hash {
 	var blocks []chainhash.Hash
 
@@ -579,6 +709,61 @@ func (bcs *BlockChains) GetBlockHashes() []chainhash.Hash {
 	return blocks
 }
 
+// BlockLocator returns hashes of the main chain from the tip back, the latest ones then twice farther apart each
+// time, and the genesis block last. A peer answers it with the blocks after the latest of them it has.
+func (bcs *BlockChains) BlockLocator() []chainhash.Hash {
+	locator := make([]chainhash.Hash, 0, locatorDenseHashes+16)
+	_ = bcs.db.View(func(tx db.Tx) error {
+		step := int64(1)
+		for height := bcs.latestBlock.Height; height > genesisHeight; height -= step {
+			h, err := chainhash.NewHashFromStr(bcs.getKeyByHeightOnTX(tx, height))
+			if err != nil {
+				return err
+			}
+			locator = append(locator, *h)
+			if len(locator) >= locatorDenseHashes {
+				step *= 2
+			}
+		}
+		h, err := chainhash.NewHashFromStr(bcs.getKeyByHeightOnTX(tx, genesisHeight))
+		if err != nil {
+			return err
+		}
+		locator = append(locator, *h)
+		return nil
+	})
+	return locator
+}
+
+// LocateBlocks returns the hashes of the main chain blocks after the first block of locator on it, after the
+// genesis block when none is, at most max of them and the tip first as inventories list them.
+func (bcs *BlockChains) LocateBlocks(locator []chainhash.Hash, max int) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0)
+	_ = bcs.db.View(func(tx db.Tx) error {
+		start := int64(genesisHeight)
+		blockBucket := tx.Bucket(blockBucketName)
+		for idx := range locator {
+			block := DeserializeBlock(blockBucket.Get([]byte(locator[idx].String())))
+			if block != nil {
+				start = block.Height
+				break
+			}
+		}
+		for height := start + 1; height <= bcs.latestBlock.Height && len(hashes) < max; height++ {
+			h, err := chainhash.NewHashFromStr(bcs.getKeyByHeightOnTX(tx, height))
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, *h)
+		}
+		return nil
+	})
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+	return hashes
+}
+
 func (bcs *BlockChains) FindTransactions(txIDs []string) (map[string]Transaction, error) {
 	prevTXs := make(map[string]Transaction)
 	txIDMap := make(map[string]interface{})
@@ -615,15 +800,9 @@ func (bcs *BlockChains) GetCond4TransactionVerify(transaction *Transaction) (*Tr
 	if transaction == nil {
 		return nil, nil
 	}
-	outputs := make(map[string][]TXOutput)
-	if !transaction.IsCoinbase() {
-		for _, input := range transaction.Vin {
-			op := bcs.GetUTXO(input.Txid, input.Vout)
-			if op == nil {
-				return nil, fmt.Errorf("no input: %s,%d", input.Txid, input.Vout)
-			}
-			outputs[input.Txid] = append(outputs[input.Txid], *op)
-		}
+	conds, err := bcs.GetConds4TransactionsVerify([]*Transaction{transaction})
+	if err != nil {
+		return nil, err
 	}
-	return &TransactionVerifyCond{Outputs: outputs}, nil
+	return conds[0], nil
 }
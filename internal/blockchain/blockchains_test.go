@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -101,6 +103,25 @@ func TestBlockChains_AddBlock(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+// a block repeating a transaction of the chain is refused, the node goes on
+func TestBlockChains_AddBlock_DuplicateTx(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+	address := NewWallet().GetAddress()
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	b2 := MineBlock([]*Transaction{b1.Transactions[0]}, b1.Hash)
+	assert.True(t, IsErrorCode(bcs.AddBlock(b2), ErrDuplicateTx))
+	assert.Equal(t, b1.Hash, bcs.GetLatestBlock().Hash)
+	assert.Equal(t, Subsidy, bcs.GetBalance(address))
+
+	b2 = MineBlock([]*Transaction{NewCoinbaseTX(address, "b2")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	assert.Equal(t, b2.Hash, bcs.GetLatestBlock().Hash)
+}
+
 func TestBlockChains_AddBlock_Orphaned(t *testing.T) {
 	bcs, wallet := reInitBlockWithNewWallet(t)
 	defer bcs.Close()
@@ -129,15 +150,9 @@ func TestBlockChains_AddBlock_Orphaned(t *testing.T) {
 	//
 	//
 	//
-	tx2, err := NewTransaction(wallet.pubKey, wallet.Address(), 1, wallet2.Address(),
-		func(txID string, output TXOutput) bool {
-			for _, input := range tx.Vin {
-				if input.Txid == txID {
-					return true
-				}
-			}
-			return false
-		}, bcs)
+	locks := NewCoinLocks()
+	locks.LockTx(tx)
+	tx2, err := NewTransaction(wallet.pubKey, wallet.Address(), 1, wallet2.Address(), &SpendOptions{Locks: locks}, bcs)
 	assert.Nil(t, err)
 	err = tx2.DefSign(bcs, wallet.priKey)
 	assert.Nil(t, err)
@@ -147,20 +162,8 @@ func TestBlockChains_AddBlock_Orphaned(t *testing.T) {
 	//
 	//
 	//
-	tx3, err := NewTransaction(wallet.pubKey, wallet.Address(), 1, wallet2.Address(),
-		func(txID string, output TXOutput) bool {
-			for _, input := range tx.Vin {
-				if input.Txid == txID {
-					return true
-				}
-			}
-			for _, input := range tx2.Vin {
-				if input.Txid == txID {
-					return true
-				}
-			}
-			return false
-		}, bcs)
+	locks.LockTx(tx2)
+	tx3, err := NewTransaction(wallet.pubKey, wallet.Address(), 1, wallet2.Address(), &SpendOptions{Locks: locks}, bcs)
 	assert.Nil(t, err)
 	err = tx3.DefSign(bcs, wallet.priKey)
 	assert.Nil(t, err)
@@ -467,3 +470,32 @@ func TestBlockChains_AddBlock_SideChain2(t *testing.T) {
 
 	t.Log(h01, h02, h03, h04, h11, h12, h13, h21, h41, h42, h31, h32, h14, h15)
 }
+
+func TestBlockChains_BlockLocator(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+	address := NewWallet().GetAddress()
+
+	for idx := 0; idx < 14; idx++ {
+		block := MineBlock([]*Transaction{NewCoinbaseTX(address, "")}, bcs.GetLatestBlock().Hash)
+		assert.Nil(t, bcs.AddBlock(block))
+	}
+	assert.EqualValues(t, 15, bcs.GetBestHeight())
+	hashOf := func(heights ...int64) []chainhash.Hash {
+		hashes := make([]chainhash.Hash, 0, len(heights))
+		for _, height := range heights {
+			hashes = append(hashes, bcs.GetBlockByHeight(height).Hash)
+		}
+		return hashes
+	}
+
+	// ten latest blocks, then twice farther apart each time, the genesis block last
+	assert.Equal(t, hashOf(15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 4, 1), bcs.BlockLocator())
+
+	unknown := chainhash.HashH([]byte("unknown"))
+	assert.Equal(t, hashOf(15, 14, 13), bcs.LocateBlocks([]chainhash.Hash{unknown, hashOf(12)[0]}, 10))
+	assert.Equal(t, hashOf(7, 6, 5), bcs.LocateBlocks(hashOf(4, 1), 3))
+	assert.Equal(t, hashOf(3, 2), bcs.LocateBlocks([]chainhash.Hash{unknown}, 2))
+	assert.Empty(t, bcs.LocateBlocks(bcs.BlockLocator(), 10))
+}
@@ -0,0 +1,421 @@
+package blockchain
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// The serialized sizes of transactions the fees are estimated from, gob encoded with a signature and a
+// public key on each input.
+const (
+	txOverheadSize = 380
+	txInputSize    = 200
+	txOutputSize   = 30
+	// p2pkhScriptSize is the size of the locking script of the change, paid to a public key hash.
+	p2pkhScriptSize = 25
+
+	// DustRelayFeeMultiplier is how many times what spending it costs an output is worth at least not to be
+	// dust.
+	DustRelayFeeMultiplier = 3
+
+	bnbMaxTries = 100000
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrNoChangelessSolution is returned by BranchAndBound when no coins pay the target without change.
+	ErrNoChangelessSolution = errors.New("no changeless coin selection")
+)
+
+// FeeRate is a fee in coins per 1000 bytes of serialized transaction.
+type FeeRate int
+
+// Fee is the fee of size bytes, rounded up.
+func (r FeeRate) Fee(size int) int {
+	return (int(r)*size + 999) / 1000
+}
+
+// EstimateTxSize estimates the serialized size of a signed transaction.
+func EstimateTxSize(inputs, outputs int) int {
+	return txOverheadSize + inputs*txInputSize + outputs*txOutputSize
+}
+
+// DustThreshold returns the lowest value output is not dust at feeRate, DustRelayFeeMultiplier times the fee of
+// relaying output with the input spending it.
+func DustThreshold(output *TXOutput, feeRate FeeRate) int {
+	return dustThreshold(len(output.LockingScript()), feeRate)
+}
+
+func dustThreshold(scriptSize int, feeRate FeeRate) int {
+	size := EstimateTxSize(1, 1) - EstimateTxSize(0, 0) + scriptSize
+	return DustRelayFeeMultiplier * feeRate.Fee(size)
+}
+
+// OutPoint names a transaction output.
+type OutPoint struct {
+	TxID  string
+	Index int
+}
+
+// Coin is an unspent output the wallet may spend, Height is the one of its block.
+type Coin struct {
+	TxID   string
+	Output TXOutput
+	Height int64
+}
+
+// OutPoint returns the outpoint of the coin.
+func (c *Coin) OutPoint() OutPoint {
+	return OutPoint{c.TxID, c.Output.Index}
+}
+
+// SelectionParams describe the payment coins are selected for.
+type SelectionParams struct {
+	// Target is the sum of the payment outputs.
+	Target int
+	// Outputs is the number of payment outputs, change excluded.
+	Outputs int
+	FeeRate FeeRate
+}
+
+// fee is the fee of a transaction with inputs inputs and the payment outputs, plus change when it has some.
+func (p *SelectionParams) fee(inputs int, change bool) int {
+	outputs := p.Outputs
+	if change {
+		outputs++
+	}
+	return p.FeeRate.Fee(EstimateTxSize(inputs, outputs))
+}
+
+// effectiveValue is what the coin adds to the payment once its input is paid for.
+func (p *SelectionParams) effectiveValue(c *Coin) int {
+	return c.Output.Value - p.FeeRate.Fee(txInputSize)
+}
+
+// CoinSelection is the outcome of a CoinSelector, Total is the value of the coins, which pays the target,
+// the fee and the change.
+type CoinSelection struct {
+	Coins  []Coin
+	Total  int
+	Fee    int
+	Change int
+}
+
+// CoinSelector picks the coins paying a transaction and its fee.
+type CoinSelector interface {
+	Select(coins []Coin, params SelectionParams) (*CoinSelection, error)
+}
+
+// newCoinSelection settles the fee and the change of coins, the change is dropped into the fee when it would
+// be dust at the fee rate, so not worth the input spending it later. It returns ErrInsufficientFunds when the coins do not pay params, or
+// when there are none: a transaction spends at least one output, even when it pays nothing.
+func newCoinSelection(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	if len(coins) == 0 {
+		return nil, ErrInsufficientFunds
+	}
+	s := &CoinSelection{Coins: coins}
+	for _, c := range coins {
+		s.Total += c.Output.Value
+	}
+	if s.Total < params.Target+params.fee(len(coins), false) {
+		return nil, ErrInsufficientFunds
+	}
+	change := s.Total - params.Target - params.fee(len(coins), true)
+	if change > 0 && change >= dustThreshold(p2pkhScriptSize, params.FeeRate) {
+		s.Change = change
+		s.Fee = params.fee(len(coins), true)
+	} else {
+		s.Fee = s.Total - params.Target
+	}
+	return s, nil
+}
+
+// greedySelect takes the coins in order until they pay params.
+func greedySelect(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	total := 0
+	for idx := range coins {
+		total += coins[idx].Output.Value
+		if total >= params.Target+params.fee(idx+1, false) {
+			return newCoinSelection(coins[:idx+1], params)
+		}
+	}
+	return nil, ErrInsufficientFunds
+}
+
+// usefulCoins copies the coins worth more than their input fee.
+func usefulCoins(coins []Coin, params SelectionParams) []Coin {
+	useful := make([]Coin, 0, len(coins))
+	for _, c := range coins {
+		if params.effectiveValue(&c) > 0 {
+			useful = append(useful, c)
+		}
+	}
+	return useful
+}
+
+type largestFirst struct{}
+
+// LargestFirst spends the largest coins first, which keeps the inputs and so the fee low.
+var LargestFirst CoinSelector = largestFirst{}
+
+func (largestFirst) Select(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	sorted := usefulCoins(coins, params)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Value > sorted[j].Output.Value
+	})
+	return greedySelect(sorted, params)
+}
+
+type oldestFirst struct{}
+
+// OldestFirst spends the coins of the oldest blocks first.
+var OldestFirst CoinSelector = oldestFirst{}
+
+func (oldestFirst) Select(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	sorted := usefulCoins(coins, params)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Height < sorted[j].Height
+	})
+	return greedySelect(sorted, params)
+}
+
+type branchAndBound struct{}
+
+// BranchAndBound searches the coins paying the target without change, wasting at most what the change
+// would cost, and returns ErrNoChangelessSolution when there are none.
+var BranchAndBound CoinSelector = branchAndBound{}
+
+func (branchAndBound) Select(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	sorted := usefulCoins(coins, params)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Value > sorted[j].Output.Value
+	})
+	values := make([]int, len(sorted))
+	remaining := 0
+	for idx := range sorted {
+		values[idx] = params.effectiveValue(&sorted[idx])
+		remaining += values[idx]
+	}
+
+	// the inputs pay their fee through the effective values, the rest of the transaction through target
+	target := params.Target + params.fee(0, false)
+	costOfChange := params.FeeRate.Fee(txOutputSize) + params.FeeRate.Fee(txInputSize)
+	if remaining < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	var best []bool
+	bestWaste := 0
+	selected := make([]bool, len(sorted))
+	tries := 0
+	var search func(depth, value, remaining int)
+	search = func(depth, value, remaining int) {
+		tries++
+		if tries > bnbMaxTries || value+remaining < target || value > target+costOfChange {
+			return
+		}
+		if value >= target {
+			if waste := value - target; best == nil || waste < bestWaste {
+				best = append([]bool(nil), selected...)
+				bestWaste = waste
+			}
+			return
+		}
+		if depth == len(sorted) {
+			return
+		}
+		remaining -= values[depth]
+		// taking a coin equal to the one left out right before gives selections tried already
+		if depth == 0 || selected[depth-1] || values[depth] != values[depth-1] {
+			selected[depth] = true
+			search(depth+1, value+values[depth], remaining)
+			selected[depth] = false
+		}
+		search(depth+1, value, remaining)
+	}
+	search(0, 0, remaining)
+	if best == nil {
+		return nil, ErrNoChangelessSolution
+	}
+
+	chosen := make([]Coin, 0)
+	for idx, ok := range best {
+		if ok {
+			chosen = append(chosen, sorted[idx])
+		}
+	}
+	s, err := newCoinSelection(chosen, params)
+	if err != nil {
+		return nil, err
+	}
+	s.Fee += s.Change
+	s.Change = 0
+	return s, nil
+}
+
+// RandomImprove picks random coins until they pay the target, then adds random coins while they bring the
+// total closer to twice the target without going beyond three times it, so the change looks like the
+// payment. Rand may be set for reproducible selections.
+type RandomImprove struct {
+	Rand *rand.Rand
+}
+
+func (ri *RandomImprove) Select(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	shuffled := usefulCoins(coins, params)
+	shuffle := rand.Shuffle
+	if ri.Rand != nil {
+		shuffle = ri.Rand.Shuffle
+	}
+	shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	s, err := greedySelect(shuffled, params)
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := append([]Coin(nil), s.Coins...)
+	total := s.Total
+	ideal := 2 * params.Target
+	for _, c := range shuffled[len(chosen):] {
+		next := total + c.Output.Value
+		if next > 3*params.Target || abs(ideal-next) >= abs(ideal-total) {
+			continue
+		}
+		chosen = append(chosen, c)
+		total = next
+	}
+	return newCoinSelection(chosen, params)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+type fallbackSelector struct {
+	primary, fallback CoinSelector
+}
+
+// WithFallback selects with primary, then with fallback when primary finds no coins.
+func WithFallback(primary, fallback CoinSelector) CoinSelector {
+	return &fallbackSelector{primary, fallback}
+}
+
+func (fs *fallbackSelector) Select(coins []Coin, params SelectionParams) (*CoinSelection, error) {
+	s, err := fs.primary.Select(coins, params)
+	if err == nil {
+		return s, nil
+	}
+	return fs.fallback.Select(coins, params)
+}
+
+// DefaultCoinSelector avoids change when it can and picks random coins otherwise.
+var DefaultCoinSelector = WithFallback(BranchAndBound, &RandomImprove{})
+
+// CoinSelectorByName returns the selectors of the names largestfirst, oldestfirst, branchandbound,
+// randomimprove and default.
+func CoinSelectorByName(name string) (CoinSelector, error) {
+	switch name {
+	case "", "default":
+		return DefaultCoinSelector, nil
+	case "largestfirst":
+		return LargestFirst, nil
+	case "oldestfirst":
+		return OldestFirst, nil
+	case "branchandbound":
+		return BranchAndBound, nil
+	case "randomimprove":
+		return &RandomImprove{}, nil
+	}
+	return nil, errors.New("unknown coin selection strategy " + name)
+}
+
+// CoinLocks holds outputs which are not to be selected, those spent by pending transactions and those
+// locked by hand.
+type CoinLocks struct {
+	lock sync.Mutex
+	// locked maps the outputs to the transaction spending them, empty for the ones locked by hand
+	locked map[OutPoint]string
+}
+
+func NewCoinLocks() *CoinLocks {
+	return &CoinLocks{locked: make(map[OutPoint]string)}
+}
+
+// Lock locks the outputs by hand.
+func (l *CoinLocks) Lock(ops ...OutPoint) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, op := range ops {
+		if _, ok := l.locked[op]; !ok {
+			l.locked[op] = ""
+		}
+	}
+}
+
+// Unlock unlocks the outputs, whoever locked them.
+func (l *CoinLocks) Unlock(ops ...OutPoint) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, op := range ops {
+		delete(l.locked, op)
+	}
+}
+
+// LockTx locks the outputs the pending tx spends.
+func (l *CoinLocks) LockTx(tx *Transaction) {
+	if tx.IsCoinbase() {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, input := range tx.Vin {
+		l.locked[OutPoint{input.Txid, input.Vout}] = tx.TxID
+	}
+}
+
+// UnlockTx unlocks the outputs tx locked, once it is mined or dropped.
+func (l *CoinLocks) UnlockTx(tx *Transaction) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, input := range tx.Vin {
+		op := OutPoint{input.Txid, input.Vout}
+		if l.locked[op] == tx.TxID {
+			delete(l.locked, op)
+		}
+	}
+}
+
+// IsLocked tells if the output is locked, a nil CoinLocks locks nothing.
+func (l *CoinLocks) IsLocked(op OutPoint) bool {
+	if l == nil {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, ok := l.locked[op]
+	return ok
+}
+
+// List returns the locked outputs.
+func (l *CoinLocks) List() []OutPoint {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ops := make([]OutPoint, 0, len(l.locked))
+	for op := range l.locked {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].TxID != ops[j].TxID {
+			return ops[i].TxID < ops[j].TxID
+		}
+		return ops[i].Index < ops[j].Index
+	})
+	return ops
+}
@@ -0,0 +1,197 @@
+package blockchain
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCoins(values ...int) []Coin {
+	coins := make([]Coin, 0, len(values))
+	for idx, value := range values {
+		coins = append(coins, Coin{
+			TxID:   string(rune('a' + idx)),
+			Output: TXOutput{Value: value},
+			Height: int64(len(values) - idx),
+		})
+	}
+	return coins
+}
+
+func coinValues(s *CoinSelection) []int {
+	values := make([]int, 0, len(s.Coins))
+	for _, c := range s.Coins {
+		values = append(values, c.Output.Value)
+	}
+	return values
+}
+
+func TestFeeRate(t *testing.T) {
+	assert.Equal(t, 0, FeeRate(0).Fee(1000))
+	assert.Equal(t, 1, FeeRate(1).Fee(1))
+	assert.Equal(t, 5, FeeRate(10).Fee(500))
+	assert.Equal(t, 610, EstimateTxSize(1, 1))
+}
+
+func TestCoinSelection_Greedy(t *testing.T) {
+	coins := testCoins(3, 10, 5, 1)
+
+	s, err := LargestFirst.Select(coins, SelectionParams{Target: 12, Outputs: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 5}, coinValues(s))
+	assert.Equal(t, 15, s.Total)
+	assert.Equal(t, 3, s.Change)
+	assert.Equal(t, 0, s.Fee)
+
+	// the last coin is the oldest
+	s, err = OldestFirst.Select(coins, SelectionParams{Target: 6, Outputs: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 5}, coinValues(s))
+
+	_, err = LargestFirst.Select(coins, SelectionParams{Target: 20, Outputs: 1})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestCoinSelection_Fee(t *testing.T) {
+	params := SelectionParams{Target: 1000, Outputs: 1, FeeRate: 100}
+	// an input costs 20, one output 3 and the rest of the transaction 38
+	s, err := LargestFirst.Select(testCoins(2000, 10), params)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2000}, coinValues(s))
+	assert.Equal(t, params.fee(1, true), s.Fee)
+	assert.Equal(t, 2000-1000-s.Fee, s.Change)
+
+	// change not worth its input goes to the fee, and so does the dust worth it
+	s, err = LargestFirst.Select(testCoins(1080), params)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, s.Change)
+	assert.Equal(t, 80, s.Fee)
+	assert.Equal(t, p2pkhScriptSize, len(NewTXOutput(0, 1, NewWallet().GetAddress()).LockingScript()))
+	assert.Equal(t, 78, dustThreshold(p2pkhScriptSize, params.FeeRate))
+	s, err = LargestFirst.Select(testCoins(1141), params)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, s.Change)
+	assert.Equal(t, 141, s.Fee)
+	s, err = LargestFirst.Select(testCoins(1142), params)
+	assert.Nil(t, err)
+	assert.Equal(t, 78, s.Change)
+
+	_, err = LargestFirst.Select(testCoins(1050), params)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestCoinSelection_BranchAndBound(t *testing.T) {
+	coins := testCoins(7, 5, 4, 3, 3, 1)
+
+	s, err := BranchAndBound.Select(coins, SelectionParams{Target: 10, Outputs: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, s.Total)
+	assert.Equal(t, 0, s.Change)
+	assert.Equal(t, 0, s.Fee)
+
+	_, err = BranchAndBound.Select(testCoins(6, 6), SelectionParams{Target: 10, Outputs: 1})
+	assert.ErrorIs(t, err, ErrNoChangelessSolution)
+	_, err = BranchAndBound.Select(coins, SelectionParams{Target: 30, Outputs: 1})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the surplus below the cost of change is paid as fee
+	params := SelectionParams{Target: 1000, Outputs: 1, FeeRate: 100}
+	s, err = BranchAndBound.Select(testCoins(5000, 1070), params)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1070}, coinValues(s))
+	assert.Equal(t, 0, s.Change)
+	assert.Equal(t, 70, s.Fee)
+
+	s, err = DefaultCoinSelector.Select(testCoins(6, 6), SelectionParams{Target: 10, Outputs: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 12, s.Total)
+	assert.Equal(t, 2, s.Change)
+}
+
+func TestCoinSelection_RandomImprove(t *testing.T) {
+	coins := testCoins(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	params := SelectionParams{Target: 10, Outputs: 1}
+
+	first, err := (&RandomImprove{Rand: rand.New(rand.NewSource(1))}).Select(coins, params)
+	assert.Nil(t, err)
+	second, err := (&RandomImprove{Rand: rand.New(rand.NewSource(1))}).Select(coins, params)
+	assert.Nil(t, err)
+	assert.Equal(t, coinValues(first), coinValues(second))
+
+	for seed := int64(0); seed < 20; seed++ {
+		s, err := (&RandomImprove{Rand: rand.New(rand.NewSource(seed))}).Select(coins, params)
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, s.Total, 10)
+		assert.LessOrEqual(t, s.Total, 30)
+		assert.Equal(t, s.Total-10, s.Change)
+	}
+
+	_, err = (&RandomImprove{}).Select(coins, SelectionParams{Target: 100, Outputs: 1})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestCoinSelectorByName(t *testing.T) {
+	for _, name := range []string{"", "default", "largestfirst", "oldestfirst", "branchandbound", "randomimprove"} {
+		selector, err := CoinSelectorByName(name)
+		assert.Nil(t, err)
+		assert.NotNil(t, selector)
+	}
+	_, err := CoinSelectorByName("smallestfirst")
+	assert.NotNil(t, err)
+}
+
+func TestCoinLocks(t *testing.T) {
+	var none *CoinLocks
+	assert.False(t, none.IsLocked(OutPoint{"a", 0}))
+
+	locks := NewCoinLocks()
+	tx := &Transaction{TxID: "t", Vin: []TXInput{{Txid: "a", Vout: 0}, {Txid: "b", Vout: 1}}}
+	locks.LockTx(tx)
+	locks.Lock(OutPoint{"c", 0})
+	assert.True(t, locks.IsLocked(OutPoint{"a", 0}))
+	assert.False(t, locks.IsLocked(OutPoint{"a", 1}))
+	assert.Equal(t, []OutPoint{{"a", 0}, {"b", 1}, {"c", 0}}, locks.List())
+
+	// an output spent by another transaction stays locked
+	locks.LockTx(&Transaction{TxID: "u", Vin: []TXInput{{Txid: "b", Vout: 1}}})
+	locks.UnlockTx(tx)
+	assert.Equal(t, []OutPoint{{"b", 1}, {"c", 0}}, locks.List())
+
+	locks.Unlock(OutPoint{"b", 1}, OutPoint{"c", 0})
+	assert.Empty(t, locks.List())
+}
+
+func TestBlockChains_ListCoins(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	wallet := NewWallet()
+	address := wallet.GetAddress()
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b2")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+
+	pubKeyHash := b1.Transactions[0].Vout[0].PubKeyHash
+	coins := bcs.ListCoins(pubKeyHash, nil)
+	assert.Len(t, coins, 2)
+	heights := map[string]int64{}
+	for _, c := range coins {
+		heights[c.TxID] = c.Height
+	}
+	assert.Positive(t, heights[b1.Transactions[0].TxID])
+	assert.Equal(t, heights[b1.Transactions[0].TxID]+1, heights[b2.Transactions[0].TxID])
+
+	locks := NewCoinLocks()
+	locks.Lock(OutPoint{b1.Transactions[0].TxID, 0})
+	coins = bcs.ListCoins(pubKeyHash, locks)
+	assert.Len(t, coins, 1)
+	assert.Equal(t, b2.Transactions[0].TxID, coins[0].TxID)
+
+	s, err := bcs.FindSpendableOutputs([][]byte{pubKeyHash}, SelectionParams{Target: 1, Outputs: 1}, OldestFirst, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, b1.Transactions[0].TxID, s.Coins[0].TxID)
+}
@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+// dataBucketName indexes the data the main chain carries, data -> the gob encoded IDs of its transactions.
+var dataBucketName = []byte("data")
+
+// DataAnchor is a main chain transaction carrying some data.
+type DataAnchor struct {
+	TxID      string
+	BlockHash chainhash.Hash
+	Height    int64
+	Timestamp int64
+}
+
+// carriedData returns the data the data carrier outputs of tx hold, once each.
+func carriedData(tx *Transaction) [][]byte {
+	carried := make([][]byte, 0)
+	for _, output := range tx.Vout {
+		data, err := script.ExtractNullData(output.LockingScript())
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		seen := false
+		for _, d := range carried {
+			seen = seen || bytes.Equal(d, data)
+		}
+		if !seen {
+			carried = append(carried, data)
+		}
+	}
+	return carried
+}
+
+func getDataTxIDs(b db.Bucket, data []byte) []string {
+	var txIDs []string
+	if v := b.Get(data); v != nil {
+		_ = gob.NewDecoder(bytes.NewReader(v)).Decode(&txIDs)
+	}
+	return txIDs
+}
+
+func putDataTxIDs(b db.Bucket, data []byte, txIDs []string) error {
+	if len(txIDs) == 0 {
+		return b.Delete(data)
+	}
+	var buff bytes.Buffer
+	err := gob.NewEncoder(&buff).Encode(txIDs)
+	if err != nil {
+		return err
+	}
+	return b.Put(data, buff.Bytes())
+}
+
+// indexDataOnTx adds the data tx carries to the index.
+func indexDataOnTx(dbTx db.Tx, tx *Transaction) error {
+	b := dbTx.Bucket(dataBucketName)
+	for _, data := range carriedData(tx) {
+		err := putDataTxIDs(b, data, append(getDataTxIDs(b, data), tx.TxID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexDataOnTx drops tx, which left the main chain, from the index.
+func unindexDataOnTx(dbTx db.Tx, tx *Transaction) error {
+	b := dbTx.Bucket(dataBucketName)
+	for _, data := range carriedData(tx) {
+		txIDs := getDataTxIDs(b, data)
+		for idx, txID := range txIDs {
+			if txID == tx.TxID {
+				txIDs = append(txIDs[:idx], txIDs[idx+1:]...)
+				break
+			}
+		}
+		err := putDataTxIDs(b, data, txIDs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindexDataOnTx rebuilds the data index from the main chain.
+func (bcs *BlockChains) reindexDataOnTx(dbTx db.Tx) error {
+	_ = dbTx.DeleteBucket(dataBucketName)
+	_, err := dbTx.CreateBucket(dataBucketName)
+	if err != nil {
+		return err
+	}
+
+	blocks := make([]*Block, 0)
+	bci := bcs.IteratorOnTx(dbTx)
+	for block := bci.Next(); block != nil; block = bci.Next() {
+		blocks = append(blocks, block)
+	}
+	// oldest first, so that each data lists its transactions in chain order
+	for idx := len(blocks) - 1; idx >= 0; idx-- {
+		for _, tx := range blocks[idx].Transactions {
+			err = indexDataOnTx(dbTx, tx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindDataAnchors returns the main chain transactions carrying data, the oldest first.
+func (bcs *BlockChains) FindDataAnchors(data []byte) []DataAnchor {
+	anchors := make([]DataAnchor, 0)
+	if len(data) == 0 {
+		return anchors
+	}
+	_ = bcs.db.View(func(dbTx db.Tx) error {
+		txBucket := dbTx.Bucket(txBucketName)
+		for _, txID := range getDataTxIDs(dbTx.Bucket(dataBucketName), data) {
+			height, err := strconv.ParseInt(string(txBucket.Get([]byte(txID))), 10, 64)
+			if err != nil {
+				continue
+			}
+			block := bcs.getBlockByHeightOnTX(dbTx, height)
+			if block == nil {
+				continue
+			}
+			anchors = append(anchors, DataAnchor{
+				TxID:      txID,
+				BlockHash: block.Hash,
+				Height:    block.Height,
+				Timestamp: block.Timestamp,
+			})
+		}
+		return nil
+	})
+	return anchors
+}
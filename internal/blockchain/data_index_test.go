@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint: funlen
+func TestWallets_AnchorData(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	from, err := ws.CreateWallet()
+	assert.Nil(t, err)
+	data := bytes.Repeat([]byte{0xab}, 32)
+
+	_, err = ws.AnchorData(bcs, []string{from}, data, nil)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	_, err = ws.AnchorData(bcs, []string{from}, make([]byte, script.MaxDataCarrierSize+1), nil)
+	assert.NotNil(t, err)
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(from, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	tx, err := ws.AnchorData(bcs, []string{from}, data, nil)
+	assert.Nil(t, err)
+	assert.Len(t, tx.Vout, 2)
+	assert.Equal(t, 0, tx.Vout[0].Value)
+	carried, err := script.ExtractNullData(tx.Vout[0].LockingScript())
+	assert.Nil(t, err)
+	assert.Equal(t, data, carried)
+	assert.Nil(t, tx.Check())
+	cond, err := bcs.GetCond4TransactionVerify(tx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify(cond))
+
+	assert.Empty(t, bcs.FindDataAnchors(data))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(from, "b2"), tx}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	anchors := bcs.FindDataAnchors(data)
+	assert.Equal(t, []DataAnchor{{TxID: tx.TxID, BlockHash: b2.Hash, Height: b2.Height, Timestamp: b2.Timestamp}}, anchors)
+	assert.Empty(t, bcs.FindDataAnchors(data[1:]))
+
+	// the data output is left out of the UTXO set, the change is not
+	assert.Nil(t, bcs.GetUTXO(tx.TxID, 0))
+	assert.NotNil(t, bcs.GetUTXO(tx.TxID, 1))
+
+	// the index is rebuilt along with the UTXO set
+	assert.Nil(t, bcs.ReindexUTXO())
+	assert.Equal(t, anchors, bcs.FindDataAnchors(data))
+
+	// a longer side chain without the transaction drops the anchor
+	s2 := MineBlock([]*Transaction{NewCoinbaseTX(from, "s2")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(s2))
+	s3 := MineBlock([]*Transaction{NewCoinbaseTX(from, "s3")}, s2.Hash)
+	assert.Nil(t, bcs.AddBlock(s3))
+	assert.Equal(t, s3.Hash, bcs.GetLatestBlock().Hash)
+	assert.Empty(t, bcs.FindDataAnchors(data))
+}
+
+func TestTransaction_Check_DataCarrier(t *testing.T) {
+	wallet := NewWallet()
+	prev := NewCoinbaseTX(wallet.GetAddress(), "prev")
+	newDataTx := func(lockingScript []byte, value int) *Transaction {
+		tx := newSpendTx(prev, 0, Subsidy, wallet.GetAddress())
+		tx.Vout = append(tx.Vout, *NewScriptTXOutput(1, value, lockingScript))
+		tx.TxID = hex.EncodeToString(tx.Hash()[:])
+		assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(prev)))
+		return tx
+	}
+
+	dataScript, err := script.NullDataScript([]byte("anchor"))
+	assert.Nil(t, err)
+	assert.Nil(t, newDataTx(dataScript, 0).Check())
+	assert.True(t, IsErrorCode(newDataTx(dataScript, -1).Check(), ErrBadTxOutValue))
+
+	tooLarge, err := script.NewBuilder().AddOp(script.OpReturn).AddData(make([]byte, script.MaxDataCarrierSize+1)).Script()
+	assert.Nil(t, err)
+	assert.True(t, IsErrorCode(newDataTx(tooLarge, 0).Check(), ErrBadTxOutScript))
+	assert.True(t, IsErrorCode(newDataTx(tooLarge, 1).Check(), ErrBadTxOutScript))
+}
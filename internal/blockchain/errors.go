@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode identifies why a block or transaction was rejected.
+type ErrorCode int
+
+const (
+	ErrDuplicateBlock ErrorCode = iota
+	ErrEmptyBlock
+	ErrNoTransactions
+	ErrFirstTxNotCoinbase
+	ErrHighHash
+	ErrBadTxHeader
+	ErrNoTxInputs
+	ErrNoTxSignature
+	ErrBadTxOutValue
+	ErrNoTxOutPubKeyHash
+	ErrMissingTxOut
+	ErrSpentTxOut
+	ErrAmountMismatch
+	ErrSpendTooHigh
+	ErrBadSignature
+	ErrBadTxOutScript
+	ErrScriptFailed
+	ErrUnfinalizedTx
+	ErrBlockTooBig
+	ErrTxTooBig
+	ErrTooManyTxInputs
+	ErrTooManyTxOutputs
+	ErrTooManySigOps
+	ErrDuplicateTx
+)
+
+var errorCodeStrings = map[ErrorCode]string{
+	ErrDuplicateBlock:     "ErrDuplicateBlock",
+	ErrEmptyBlock:         "ErrEmptyBlock",
+	ErrNoTransactions:     "ErrNoTransactions",
+	ErrFirstTxNotCoinbase: "ErrFirstTxNotCoinbase",
+	ErrHighHash:           "ErrHighHash",
+	ErrBadTxHeader:        "ErrBadTxHeader",
+	ErrNoTxInputs:         "ErrNoTxInputs",
+	ErrNoTxSignature:      "ErrNoTxSignature",
+	ErrBadTxOutValue:      "ErrBadTxOutValue",
+	ErrNoTxOutPubKeyHash:  "ErrNoTxOutPubKeyHash",
+	ErrMissingTxOut:       "ErrMissingTxOut",
+	ErrSpentTxOut:         "ErrSpentTxOut",
+	ErrAmountMismatch:     "ErrAmountMismatch",
+	ErrSpendTooHigh:       "ErrSpendTooHigh",
+	ErrBadSignature:       "ErrBadSignature",
+	ErrBadTxOutScript:     "ErrBadTxOutScript",
+	ErrScriptFailed:       "ErrScriptFailed",
+	ErrUnfinalizedTx:      "ErrUnfinalizedTx",
+	ErrBlockTooBig:        "ErrBlockTooBig",
+	ErrTxTooBig:           "ErrTxTooBig",
+	ErrTooManyTxInputs:    "ErrTooManyTxInputs",
+	ErrTooManyTxOutputs:   "ErrTooManyTxOutputs",
+	ErrTooManySigOps:      "ErrTooManySigOps",
+	ErrDuplicateTx:        "ErrDuplicateTx",
+}
+
+func (e ErrorCode) String() string {
+	if s := errorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ErrorCode (%d)", int(e))
+}
+
+// RuleError is returned when a block or transaction breaks a consensus rule.
+type RuleError struct {
+	ErrorCode   ErrorCode
+	Description string
+}
+
+func (e RuleError) Error() string {
+	return e.Description
+}
+
+func ruleError(c ErrorCode, desc string) RuleError {
+	return RuleError{ErrorCode: c, Description: desc}
+}
+
+// IsErrorCode reports whether err is, or wraps, a RuleError with the code.
+func IsErrorCode(err error, c ErrorCode) bool {
+	var ruleErr RuleError
+	return errors.As(err, &ruleErr) && ruleErr.ErrorCode == c
+}
@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction_CheckLimits(t *testing.T) {
+	params := MainNetParams
+	params.MaxTxInputs = 2
+	params.MaxTxOutputs = 2
+	params.MaxTxSigOps = 2
+	to := NewWallet().GetAddress()
+	prev := NewCoinbaseTX(to, "prev")
+
+	tx := newSpendTx(prev, 0, 1, to)
+	assert.Nil(t, tx.CheckLimits(&params))
+	assert.Equal(t, 1, tx.SigOpCount())
+
+	tx.Vin = append(tx.Vin, tx.Vin[0], tx.Vin[0])
+	assert.True(t, IsErrorCode(tx.CheckLimits(&params), ErrTooManyTxInputs))
+	tx.Vin = tx.Vin[:1]
+	tx.Vout = append(tx.Vout, *NewTXOutput(1, 1, to))
+	assert.Equal(t, 2, tx.SigOpCount())
+	assert.Nil(t, tx.CheckLimits(&params))
+	tx.Vout = append(tx.Vout, *NewTXOutput(2, 1, to))
+	assert.True(t, IsErrorCode(tx.CheckLimits(&params), ErrTooManyTxOutputs))
+
+	// a bare multisig output counts the most keys
+	_, pubKey := utils.NewKeyPair()
+	multiSig, err := script.MultiSigScript(1, [][]byte{pubKey})
+	assert.Nil(t, err)
+	tx.Vout = []TXOutput{*NewScriptTXOutput(0, 1, multiSig)}
+	assert.Equal(t, script.MaxPubKeysPerMultiSig, tx.SigOpCount())
+	assert.True(t, IsErrorCode(tx.CheckLimits(&params), ErrTooManySigOps))
+
+	params.MaxTxSize = len(tx.Serialize()) - 1
+	assert.True(t, IsErrorCode(tx.CheckLimits(&params), ErrTxTooBig))
+}
+
+func TestBlock_CheckLimits(t *testing.T) {
+	params := MainNetParams
+	to := NewWallet().GetAddress()
+	coinbase := NewCoinbaseTX(to, "coinbase")
+	txs := []*Transaction{coinbase}
+	for i := 0; i < 3; i++ {
+		tx := newSpendTx(coinbase, 0, 1, to)
+		tx.Vin[0].Signature = []byte{1}
+		txs = append(txs, tx)
+	}
+	block := MineBlock(txs, MineBlock([]*Transaction{NewCoinbaseTX(to, "")}, [32]byte{}).Hash)
+	assert.Nil(t, block.Check(&params))
+
+	params.MaxBlockSigOps = len(txs) - 1
+	assert.True(t, IsErrorCode(block.Check(&params), ErrTooManySigOps))
+	params.MaxBlockSigOps = len(txs)
+	assert.Nil(t, block.Check(&params))
+
+	params.MaxTxSigOps = 0
+	assert.True(t, IsErrorCode(block.Check(&params), ErrTooManySigOps))
+	params.MaxTxSigOps = MainNetParams.MaxTxSigOps
+
+	params.MaxBlockSize = len(block.Serialize()) - 1
+	assert.True(t, IsErrorCode(block.Check(&params), ErrBlockTooBig))
+}
+
+// newP2SHSigOpsTx mines a block paying to a redeem script of checkSigs signature operations it never runs, and
+// returns the transaction spending it.
+func newP2SHSigOpsTx(t *testing.T, bcs *BlockChains, checkSigs int) *Transaction {
+	b := script.NewBuilder().AddOp(script.Op0).AddOp(script.OpIf)
+	for i := 0; i < checkSigs; i++ {
+		b.AddOp(script.OpCheckSig)
+	}
+	redeem, err := b.AddOp(script.OpEndIf).AddOp(script.OpTrue).Script()
+	assert.Nil(t, err)
+	p2sh, err := script.PayToScriptHashScript(utils.HashPubKey(redeem))
+	assert.Nil(t, err)
+	sigScript, err := script.NewBuilder().AddData(redeem).Script()
+	assert.Nil(t, err)
+
+	coinbase := NewCoinbaseTX(NewWallet().GetAddress(), "")
+	coinbase.Vout[0] = *NewScriptTXOutput(0, Subsidy, p2sh)
+	coinbase.TxID = hex.EncodeToString(coinbase.Hash()[:])
+	assert.Nil(t, bcs.AddBlock(MineBlock([]*Transaction{coinbase}, bcs.GetLatestBlock().Hash)))
+
+	tx := newSpendTx(coinbase, 0, Subsidy, NewWallet().GetAddress())
+	tx.Vin[0].Amount = Subsidy
+	tx.Vin[0].SigScript = sigScript
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	return tx
+}
+
+func TestBlockChains_P2SHSigOps(t *testing.T) {
+	params := MainNetParams
+	params.MaxTxSigOps = 4
+	params.MaxBlockSigOps = 8
+	bcs, err := NewBlockChainsWithParams(memdb.NewDB(), &params)
+	assert.Nil(t, err)
+	defer bcs.Close()
+	assert.Equal(t, &params, bcs.Params())
+
+	// the redeem script counts toward the limit of the transaction, not the script it spends
+	tooMany := newP2SHSigOpsTx(t, bcs, 4)
+	assert.Nil(t, tooMany.CheckLimits(&params))
+	assert.True(t, IsErrorCode(bcs.VerifyTransaction(tooMany), ErrTooManySigOps))
+	block := MineBlock([]*Transaction{NewCoinbaseTX(NewWallet().GetAddress(), ""), tooMany}, bcs.GetLatestBlock().Hash)
+	assert.True(t, IsErrorCode(bcs.AddBlock(block), ErrTooManySigOps))
+
+	// each transaction is within its limit, the block is not
+	first, second := newP2SHSigOpsTx(t, bcs, 3), newP2SHSigOpsTx(t, bcs, 3)
+	assert.Nil(t, bcs.VerifyTransaction(first))
+	assert.Nil(t, bcs.VerifyTransaction(second))
+	block = MineBlock([]*Transaction{NewCoinbaseTX(NewWallet().GetAddress(), ""), first, second}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, block.Check(&params))
+	assert.True(t, IsErrorCode(bcs.AddBlock(block), ErrTooManySigOps))
+
+	block = MineBlock([]*Transaction{NewCoinbaseTX(NewWallet().GetAddress(), ""), first}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(block))
+	assert.Equal(t, block.Hash, bcs.GetLatestBlock().Hash)
+}
+
+func TestBlockChains_SelectBlockTxs(t *testing.T) {
+	params := MainNetParams
+	params.MaxBlockSigOps = blockTemplateReserveSigOps + 5
+	bcs, err := NewBlockChainsWithParams(memdb.NewDB(), &params)
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	// the second transaction of 4 signature operations is over the limit of the block, the small one fits
+	first, second := newP2SHSigOpsTx(t, bcs, 3), newP2SHSigOpsTx(t, bcs, 3)
+	small := fundedTxs(t, bcs, 1)[0]
+	missing := newSpendTx(small, 0, 1, small.Vout[0].Address())
+	missing.Vin[0].Signature = []byte{1}
+	assert.Equal(t, []*Transaction{first, small}, bcs.SelectBlockTxs([]*Transaction{first, missing, second, small}))
+
+	// the size of the block is bounded too
+	params.MaxBlockSigOps = MainNetParams.MaxBlockSigOps
+	params.MaxBlockSize = blockTemplateReserveSize + len(first.Serialize()) + len(small.Serialize())
+	assert.Equal(t, []*Transaction{first, small}, bcs.SelectBlockTxs([]*Transaction{first, small}))
+	params.MaxBlockSize--
+	assert.Equal(t, []*Transaction{first}, bcs.SelectBlockTxs([]*Transaction{first, small}))
+
+	block := MineBlock(append([]*Transaction{NewCoinbaseTX(NewWallet().GetAddress(), "")}, first), bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(block))
+}
@@ -0,0 +1,235 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+const (
+	// LockTimeThreshold splits the lock times: below it they are block heights, from it unix times.
+	LockTimeThreshold = 500000000
+
+	// MaxTxInSequenceNum is the sequence of a final input, the lock time of a transaction whose inputs are
+	// all final is not enforced.
+	MaxTxInSequenceNum uint32 = 0xffffffff
+	// SequenceLockTimeDisabled set in a sequence turns its relative lock off.
+	SequenceLockTimeDisabled uint32 = 1 << 31
+	// SequenceLockTimeIsSeconds set in a sequence makes its relative lock a time instead of blocks.
+	SequenceLockTimeIsSeconds uint32 = 1 << 22
+	// SequenceLockTimeMask masks the relative lock out of a sequence.
+	SequenceLockTimeMask uint32 = 0x0000ffff
+	// SequenceLockTimeGranularity is the shift turning relative time locks into seconds, units of 512s.
+	SequenceLockTimeGranularity = 9
+
+	// medianTimeBlocks is the number of blocks whose median timestamp is the median time past.
+	medianTimeBlocks = 11
+)
+
+// IsFinalized tells if tx may be in a block at height, whose previous blocks have the median time past
+// medianTime.
+func (tx *Transaction) IsFinalized(height, medianTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+	limit := height
+	if tx.LockTime >= LockTimeThreshold {
+		limit = medianTime
+	}
+	if tx.LockTime < limit {
+		return true
+	}
+	for _, input := range tx.Vin {
+		if input.Sequence != MaxTxInSequenceNum {
+			return false
+		}
+	}
+	return true
+}
+
+// SequenceLock is the last height and median time past at which the inputs of a transaction are still
+// locked, -1 when they are not.
+type SequenceLock struct {
+	Seconds     int64
+	BlockHeight int64
+}
+
+// IsActive tells if the lock still holds for a block at height whose previous blocks have the median time
+// past medianTime.
+func (lock *SequenceLock) IsActive(height, medianTime int64) bool {
+	return lock.Seconds >= medianTime || lock.BlockHeight >= height
+}
+
+// chainView looks up the blocks and transactions of one chain, the locks are checked against it.
+type chainView interface {
+	// blockAt returns the block of the chain at height, nil when there is none.
+	blockAt(height int64) *Block
+	// txHeight returns the height of the block of the chain holding the transaction.
+	txHeight(txID string) (int64, bool)
+}
+
+// medianTimePast returns the median timestamp of the block at height and the ones before it.
+func medianTimePast(view chainView, height int64) int64 {
+	timestamps := make([]int64, 0, medianTimeBlocks)
+	for h := height; h >= 0 && len(timestamps) < medianTimeBlocks; h-- {
+		block := view.blockAt(h)
+		if block == nil {
+			break
+		}
+		timestamps = append(timestamps, block.Timestamp)
+	}
+	if len(timestamps) == 0 {
+		return 0
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+// calcSequenceLock returns the relative lock of the inputs of tx, the inputs not on the chain are taken as
+// confirmed at height.
+func calcSequenceLock(view chainView, tx *Transaction, height int64) *SequenceLock {
+	lock := &SequenceLock{Seconds: -1, BlockHeight: -1}
+	if tx.IsCoinbase() {
+		return lock
+	}
+	for _, input := range tx.Vin {
+		if input.Sequence&SequenceLockTimeDisabled != 0 {
+			continue
+		}
+		inputHeight, ok := view.txHeight(input.Txid)
+		if !ok {
+			inputHeight = height
+		}
+		relativeLock := int64(input.Sequence & SequenceLockTimeMask)
+		if input.Sequence&SequenceLockTimeIsSeconds != 0 {
+			// the time counts from the median time past of the block before the one of the input
+			prevHeight := inputHeight - 1
+			if prevHeight < 0 {
+				prevHeight = 0
+			}
+			seconds := medianTimePast(view, prevHeight) + relativeLock<<SequenceLockTimeGranularity - 1
+			if seconds > lock.Seconds {
+				lock.Seconds = seconds
+			}
+		} else if blockHeight := inputHeight + relativeLock - 1; blockHeight > lock.BlockHeight {
+			lock.BlockHeight = blockHeight
+		}
+	}
+	return lock
+}
+
+// checkTransactionLocks checks the lock time and the relative locks of tx, in a block at height of view.
+func checkTransactionLocks(view chainView, tx *Transaction, height int64) error {
+	medianTime := medianTimePast(view, height-1)
+	if !tx.IsFinalized(height, medianTime) {
+		return ruleError(ErrUnfinalizedTx, fmt.Sprintf("transaction %s is locked until %d", tx.TxID, tx.LockTime))
+	}
+	if calcSequenceLock(view, tx, height).IsActive(height, medianTime) {
+		return ruleError(ErrUnfinalizedTx, fmt.Sprintf("transaction %s inputs are relative locked", tx.TxID))
+	}
+	return nil
+}
+
+// mainChainView is the main chain followed by the blocks about to be added to it.
+type mainChainView struct {
+	bcs     *BlockChains
+	pending []*Block
+}
+
+func (v *mainChainView) blockAt(height int64) *Block {
+	if idx := height - v.bcs.latestBlock.Height - 1; idx >= 0 {
+		if idx < int64(len(v.pending)) {
+			return v.pending[idx]
+		}
+		return nil
+	}
+	return v.bcs.GetBlockByHeight(height)
+}
+
+func (v *mainChainView) txHeight(txID string) (int64, bool) {
+	for idx, block := range v.pending {
+		for _, transaction := range block.Transactions {
+			if transaction.TxID == txID {
+				return v.bcs.latestBlock.Height + 1 + int64(idx), true
+			}
+		}
+	}
+	return v.bcs.GetTxHeight(txID)
+}
+
+// GetTxHeight returns the height of the main chain block holding the transaction.
+func (bcs *BlockChains) GetTxHeight(txID string) (height int64, ok bool) {
+	_ = bcs.db.View(func(tx db.Tx) error {
+		v := tx.Bucket(txBucketName).Get([]byte(txID))
+		if v == nil {
+			return nil
+		}
+		var err error
+		height, err = strconv.ParseInt(string(v), 10, 64)
+		ok = err == nil
+		return nil
+	})
+	return
+}
+
+// CheckTransactionLocks checks the lock time and the relative locks of tx for the next block of the main
+// chain, the inputs not on the chain are taken as confirmed by it.
+func (bcs *BlockChains) CheckTransactionLocks(tx *Transaction) error {
+	return checkTransactionLocks(&mainChainView{bcs: bcs}, tx, bcs.latestBlock.Height+1)
+}
+
+// sideChainView is a side chain, the main chain up to mainHeight then the side blocks.
+type sideChainView struct {
+	cl         ChainLooker
+	mainHeight int64
+	blocks     []*Block
+}
+
+func (v *sideChainView) blockAt(height int64) *Block {
+	if height <= v.mainHeight {
+		return v.cl.GetBlockByHeight(height)
+	}
+	if idx := height - v.mainHeight - 1; idx < int64(len(v.blocks)) {
+		return v.blocks[idx]
+	}
+	return nil
+}
+
+func (v *sideChainView) txHeight(txID string) (int64, bool) {
+	for _, block := range v.blocks {
+		for _, transaction := range block.Transactions {
+			if transaction.TxID == txID {
+				return block.Height, true
+			}
+		}
+	}
+	height, ok := v.cl.GetTxHeight(txID)
+	if !ok || height > v.mainHeight {
+		return 0, false
+	}
+	return height, true
+}
+
+// newSideChainView returns the view of the side chain ending with the block of hash top, which forks the
+// main chain at mainHeight.
+func (sbs *SideBlockChains) newSideChainView(top chainhash.Hash, mainHeight int64) *sideChainView {
+	view := &sideChainView{cl: sbs.cl, mainHeight: mainHeight}
+	block := sbs.findBlock(&top)
+	for block != nil && block.Height > mainHeight {
+		view.blocks = append([]*Block{block}, view.blocks...)
+		block = sbs.findBlock(&block.PrevBlockHash)
+	}
+	return view
+}
+
+func (sbs *SideBlockChains) findBlock(h *chainhash.Hash) *Block {
+	for _, chain := range sbs.blockChains {
+		if block, _ := chain.GetBlockByHash(h); block != nil {
+			return block
+		}
+	}
+	return nil
+}
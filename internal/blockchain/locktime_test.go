@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction_IsFinalized(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name     string
+		lockTime int64
+		sequence uint32
+		final    bool
+	}{
+		{"no lock time", 0, 0, true},
+		{"height passed", 9, 0, true},
+		{"height reached", 10, 0, false},
+		{"final inputs", 10, MaxTxInSequenceNum, true},
+		{"time passed", now - 1, 0, true},
+		{"time reached", now, 0, false},
+	}
+	for _, test := range tests {
+		tx := &Transaction{LockTime: test.lockTime, Vin: []TXInput{{Txid: "a", Sequence: test.sequence}}}
+		assert.Equal(t, test.final, tx.IsFinalized(10, now), test.name)
+	}
+}
+
+// newLockedSpendTx spends the output idx of prev with the lock time and the input sequence, signed by wallet.
+func newLockedSpendTx(t *testing.T, wallet *Wallet, prev *Transaction, idx int, lockTime int64, sequence uint32) *Transaction {
+	tx := newSpendTx(prev, idx, prev.Vout[idx].Value, NewWallet().GetAddress())
+	tx.LockTime = lockTime
+	tx.Vin[0].Sequence = sequence
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(prev)))
+	return tx
+}
+
+func mineEmptyBlocks(t *testing.T, bcs *BlockChains, n int) {
+	for idx := 0; idx < n; idx++ {
+		block := MineBlock([]*Transaction{NewCoinbaseTX(NewWallet().GetAddress(), "")}, bcs.GetLatestBlock().Hash)
+		assert.Nil(t, bcs.AddBlock(block))
+	}
+}
+
+// nolint: funlen
+func TestBlockChains_LockTime(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	// enough blocks for the median time past to leave the genesis block behind
+	mineEmptyBlocks(t, bcs, medianTimeBlocks/2)
+	wallet := NewWallet()
+	prev := NewCoinbaseTX(wallet.GetAddress(), "prev")
+	assert.Nil(t, bcs.AddBlock(MineBlock([]*Transaction{prev}, bcs.GetLatestBlock().Hash)))
+	prevHeight := bcs.GetBestHeight()
+
+	// the transaction can be mined once the chain is past its lock time
+	locked := newLockedSpendTx(t, wallet, prev, 0, prevHeight+1, 0)
+	assert.True(t, IsErrorCode(bcs.CheckTransactionLocks(locked), ErrUnfinalizedTx))
+	block := MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), ""), locked}, bcs.GetLatestBlock().Hash)
+	assert.True(t, IsErrorCode(bcs.AddBlock(block), ErrUnfinalizedTx))
+	assert.Equal(t, prevHeight, bcs.GetBestHeight())
+
+	// final inputs turn the lock time off
+	final := newLockedSpendTx(t, wallet, prev, 0, prevHeight+1, MaxTxInSequenceNum)
+	assert.Nil(t, bcs.CheckTransactionLocks(final))
+
+	timeLocked := newLockedSpendTx(t, wallet, prev, 0, time.Now().Unix()+3600, 0)
+	assert.True(t, IsErrorCode(bcs.CheckTransactionLocks(timeLocked), ErrUnfinalizedTx))
+	timePassed := newLockedSpendTx(t, wallet, prev, 0, LockTimeThreshold, 0)
+	assert.Nil(t, bcs.CheckTransactionLocks(timePassed))
+
+	// the relative lock of 3 blocks counts the block of the output
+	relative := newLockedSpendTx(t, wallet, prev, 0, 0, 3)
+	assert.True(t, IsErrorCode(bcs.CheckTransactionLocks(relative), ErrUnfinalizedTx))
+	relativeTime := newLockedSpendTx(t, wallet, prev, 0, 0, SequenceLockTimeIsSeconds|1)
+	assert.True(t, IsErrorCode(bcs.CheckTransactionLocks(relativeTime), ErrUnfinalizedTx))
+	disabled := newLockedSpendTx(t, wallet, prev, 0, 0, SequenceLockTimeDisabled|3)
+	assert.Nil(t, bcs.CheckTransactionLocks(disabled))
+
+	mineEmptyBlocks(t, bcs, 1)
+	assert.Nil(t, bcs.CheckTransactionLocks(locked))
+	assert.True(t, IsErrorCode(bcs.CheckTransactionLocks(relative), ErrUnfinalizedTx))
+	mineEmptyBlocks(t, bcs, 1)
+	assert.Nil(t, bcs.CheckTransactionLocks(relative))
+	block = MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), ""), relative}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(block))
+}
+
+func TestBlockChains_LockTimeOnSideChain(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	wallet := NewWallet()
+	prev := NewCoinbaseTX(wallet.GetAddress(), "prev")
+	b1 := MineBlock([]*Transaction{prev}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	mineEmptyBlocks(t, bcs, 2)
+
+	// the side block is at height b1+1, whatever the height of the main chain
+	s2 := MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), ""),
+		newLockedSpendTx(t, wallet, prev, 0, b1.Height+1, 0)}, b1.Hash)
+	assert.True(t, IsErrorCode(bcs.AddBlock(s2), ErrUnfinalizedTx))
+	s2 = MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), ""),
+		newLockedSpendTx(t, wallet, prev, 0, 0, 2)}, b1.Hash)
+	assert.True(t, IsErrorCode(bcs.AddBlock(s2), ErrUnfinalizedTx))
+
+	s2 = MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), "")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(s2))
+	s3 := MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), ""),
+		newLockedSpendTx(t, wallet, prev, 0, b1.Height+1, 2)}, s2.Hash)
+	assert.Nil(t, bcs.AddBlock(s3))
+	assert.Equal(t, b1.Height+2, s3.Height)
+}
+
+func TestTransaction_CheckLockTimeVerify(t *testing.T) {
+	wallet := NewWallet()
+	lockScript, err := script.NewBuilder().AddInt64(100).AddOp(script.OpCheckLockTimeVerify).AddOp(script.OpDrop).
+		AddData(wallet.PublicKey).AddOp(script.OpCheckSig).Script()
+	assert.Nil(t, err)
+	prev := NewCoinbaseTX(wallet.GetAddress(), "prev")
+	prev.Vout[0] = *NewScriptTXOutput(0, Subsidy, lockScript)
+	prev.TxID = hex.EncodeToString(prev.Hash()[:])
+
+	spend := func(lockTime int64, sequence uint32) error {
+		tx := newSpendTx(prev, 0, Subsidy, wallet.GetAddress())
+		tx.LockTime = lockTime
+		tx.Vin[0].Sequence = sequence
+		tx.Vin[0].Amount = Subsidy
+		sig, errSign := rawTxInSignature(tx, 0, lockScript, Subsidy, NewTxSigHashes(tx), SigHashAll,
+			utils.SchemeP256, &wallet.PrivateKey)
+		assert.Nil(t, errSign)
+		tx.Vin[0].SigScript, errSign = script.NewBuilder().AddData(sig).Script()
+		assert.Nil(t, errSign)
+		return tx.Verify(condOf(prev))
+	}
+	assert.Nil(t, spend(100, 0))
+	assert.Nil(t, spend(150, 0))
+	assert.True(t, IsErrorCode(spend(99, 0), ErrScriptFailed))
+	assert.True(t, IsErrorCode(spend(100, MaxTxInSequenceNum), ErrScriptFailed))
+	assert.True(t, IsErrorCode(spend(time.Now().Unix(), 0), ErrScriptFailed))
+}
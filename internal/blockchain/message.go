@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// messageMagic prefixes the signed messages, so a message signature can not pass for a transaction one.
+const messageMagic = "Blockchain Signed Message:\n"
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// MessageHash returns the hash a message signature signs: the double SHA256 of the length prefixed magic and
+// message.
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	for _, s := range []string{messageMagic, message} {
+		var size [binary.MaxVarintLen64]byte
+		buf.Write(size[:binary.PutUvarint(size[:], uint64(len(s)))])
+		buf.WriteString(s)
+	}
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// SignMessage signs message with the P256 key of address and returns the base64 signature, the wallet has to
+// be unlocked when it is encrypted.
+func (ws *Wallets) SignMessage(address, message string) (string, error) {
+	wallet, err := ws.GetSigningWallet(address)
+	if err != nil {
+		return "", err
+	}
+	if wallet.Scheme() != utils.SchemeP256 {
+		return "", utils.ErrUnsupportedScheme
+	}
+	sig, err := utils.SignCompact(&wallet.PrivateKey, MessageHash(message))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifyMessage reports whether the base64 signature of message was made with the key of address. Malformed
+// addresses and signatures are errors.
+func VerifyMessage(address, signature, message string) (bool, error) {
+	pubKeyHash, err := utils.Address2PubkeyHash(address)
+	if err != nil {
+		return false, ErrInvalidAddress
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != utils.CompactSignatureLen {
+		return false, utils.ErrMalformedSignature
+	}
+
+	pubKey, err := utils.RecoverCompact(sig, MessageHash(message))
+	if errors.Is(err, utils.ErrMalformedSignature) {
+		return false, err
+	}
+	if err != nil {
+		return false, nil
+	}
+	// the legacy wallets hash the raw coordinates of their key
+	return bytes.Equal(utils.HashPubKey(utils.MarshalPubKey(pubKey)), pubKeyHash) ||
+		bytes.Equal(utils.HashPubKey(utils.MarshalLegacyPubKey(pubKey)), pubKeyHash), nil
+}
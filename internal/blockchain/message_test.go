@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWallets_SignMessage(t *testing.T) {
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	address, err := ws.CreateWallet()
+	assert.Nil(t, err)
+	other, err := ws.CreateWallet()
+	assert.Nil(t, err)
+
+	_, err = ws.SignMessage(NewWallet().GetAddress(), "hello")
+	assert.ErrorIs(t, err, ErrAddressNotInWallet)
+	signature, err := ws.SignMessage(address, "hello")
+	assert.Nil(t, err)
+
+	ok, err := VerifyMessage(address, signature, "hello")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = VerifyMessage(address, signature, "hello!")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = VerifyMessage(other, signature, "hello")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = VerifyMessage("bad", signature, "hello")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	_, err = VerifyMessage(address, "!"+signature, "hello")
+	assert.ErrorIs(t, err, utils.ErrMalformedSignature)
+	_, err = VerifyMessage(address, base64.StdEncoding.EncodeToString([]byte("short")), "hello")
+	assert.ErrorIs(t, err, utils.ErrMalformedSignature)
+
+	// the message hash is not a plain hash of the message
+	wallet, err := ws.GetSigningWallet(address)
+	assert.Nil(t, err)
+	sig, err := utils.SignCompact(&wallet.PrivateKey, []byte("hello"))
+	assert.Nil(t, err)
+	ok, err = VerifyMessage(address, base64.StdEncoding.EncodeToString(sig), "hello")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, ws.Encrypt("pass"))
+	_, err = ws.SignMessage(address, "hello")
+	assert.ErrorIs(t, err, ErrWalletLocked)
+}
+
+func TestWallets_SignMessage_Legacy(t *testing.T) {
+	priKey, _ := utils.NewKeyPair()
+	file := newTestWalletsFile(t)
+	address := writeLegacyWalletFile(t, file, &priKey, utils.MarshalLegacyPubKey(&priKey.PublicKey))
+	ws, err := NewWalletsFromFile(file)
+	assert.Nil(t, err)
+
+	signature, err := ws.SignMessage(address, "hello")
+	assert.Nil(t, err)
+	ok, err := VerifyMessage(address, signature, "hello")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = VerifyMessage(address, signature, "hello!")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+)
+
+// NotificationType identifies the kind of a Notification.
+type NotificationType int
+
+const (
+	// NTBlockConnected is sent for a block joining the main chain, Data is the *Block.
+	NTBlockConnected NotificationType = iota
+	// NTBlockDisconnected is sent for a block leaving the main chain on a reorg, Data is the *Block.
+	NTBlockDisconnected
+	// NTOrphanAdded is sent for a block waiting for its ancestors, Data is the *Block.
+	NTOrphanAdded
+	// NTUTXOChanged follows each connected and disconnected block, Data is the *UTXOChange.
+	NTUTXOChanged
+)
+
+var notificationTypeStrings = map[NotificationType]string{
+	NTBlockConnected:    "blockconnected",
+	NTBlockDisconnected: "blockdisconnected",
+	NTOrphanAdded:       "orphanadded",
+	NTUTXOChanged:       "utxochanged",
+}
+
+func (t NotificationType) String() string {
+	if s, ok := notificationTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Notification Type (%d)", int(t))
+}
+
+// Notification is an event of the chains, Data depends on Type.
+type Notification struct {
+	Type NotificationType
+	Data interface{}
+}
+
+// NotificationCallback receives the notifications synchronously, with the chains locked by the caller of
+// AddBlock. It must not block nor call back into the chains.
+type NotificationCallback func(*Notification)
+
+// UTXOEntry is an output entering or leaving the UTXO set.
+type UTXOEntry struct {
+	TxID   string
+	Output TXOutput
+}
+
+// UTXOChange lists how a block changed the UTXO set: the outputs it created and the ones it spent.
+// When the block is disconnected the change is undone, Created leave the set and Spent come back.
+type UTXOChange struct {
+	BlockHash chainhash.Hash
+	Height    int64
+	Connected bool
+	Created   []UTXOEntry
+	// Spent are in the order of the inputs of the block.
+	Spent []UTXOEntry
+	// Block is the connected or disconnected block.
+	Block *Block
+}
+
+func newUTXOChange(block *Block, spent []UTXOEntry, connected bool) *UTXOChange {
+	change := &UTXOChange{
+		BlockHash: block.Hash,
+		Height:    block.Height,
+		Connected: connected,
+		Created:   make([]UTXOEntry, 0),
+		Spent:     spent,
+		Block:     block,
+	}
+	for _, transaction := range block.Transactions {
+		for _, output := range transaction.Vout {
+			change.Created = append(change.Created, UTXOEntry{TxID: transaction.TxID, Output: output})
+		}
+	}
+	return change
+}
+
+// Subscribe registers a callback for all later notifications.
+func (bcs *BlockChains) Subscribe(callback NotificationCallback) {
+	bcs.notificationsLock.Lock()
+	defer bcs.notificationsLock.Unlock()
+	bcs.notifications = append(bcs.notifications, callback)
+}
+
+// queueNotification holds a notification until the storage update it reports is committed.
+func (bcs *BlockChains) queueNotification(typ NotificationType, data interface{}) {
+	bcs.pendingNotifications = append(bcs.pendingNotifications, &Notification{Type: typ, Data: data})
+}
+
+func (bcs *BlockChains) queueBlockConnected(block *Block, spent []UTXOEntry) {
+	bcs.queueNotification(NTBlockConnected, block)
+	bcs.queueNotification(NTUTXOChanged, newUTXOChange(block, spent, true))
+}
+
+func (bcs *BlockChains) queueBlockDisconnected(block *Block, spent []UTXOEntry) {
+	bcs.queueNotification(NTBlockDisconnected, block)
+	bcs.queueNotification(NTUTXOChanged, newUTXOChange(block, spent, false))
+}
+
+// flushNotifications sends the queued notifications when committed is set, and drops them otherwise.
+func (bcs *BlockChains) flushNotifications(committed bool) {
+	pending := bcs.pendingNotifications
+	bcs.pendingNotifications = nil
+	if !committed {
+		return
+	}
+
+	bcs.notificationsLock.RLock()
+	callbacks := bcs.notifications
+	bcs.notificationsLock.RUnlock()
+	for _, n := range pending {
+		for _, callback := range callbacks {
+			callback(n)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockChains_Notifications(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	notifications := make([]*Notification, 0)
+	bcs.Subscribe(func(n *Notification) {
+		notifications = append(notifications, n)
+	})
+
+	wallet := newTestWallet()
+	genesis := bcs.GetLatestBlock()
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "b1")}, genesis.Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	assert.Len(t, notifications, 2)
+	assert.Equal(t, NTBlockConnected, notifications[0].Type)
+	assert.Equal(t, b1.Hash, notifications[0].Data.(*Block).Hash)
+	change := notifications[1].Data.(*UTXOChange)
+	assert.True(t, change.Connected)
+	assert.Len(t, change.Created, 1)
+	assert.Equal(t, b1.Transactions[0].TxID, change.Created[0].TxID)
+
+	// a rejected block is not notified
+	notifications = notifications[:0]
+	assert.NotNil(t, bcs.AddBlock(b1))
+	assert.Len(t, notifications, 0)
+
+	s1 := MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "s1")}, genesis.Hash)
+	s2 := MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "s2")}, s1.Hash)
+	assert.Nil(t, bcs.AddBlock(s2))
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, NTOrphanAdded, notifications[0].Type)
+
+	// s1 links the orphan, the side chain outgrows the main chain
+	notifications = notifications[:0]
+	assert.Nil(t, bcs.AddBlock(s1))
+	types := make([]NotificationType, 0)
+	for _, n := range notifications {
+		types = append(types, n.Type)
+	}
+	assert.Equal(t, []NotificationType{
+		NTBlockDisconnected, NTUTXOChanged,
+		NTBlockConnected, NTUTXOChanged,
+		NTBlockConnected, NTUTXOChanged,
+	}, types)
+	assert.Equal(t, b1.Hash, notifications[0].Data.(*Block).Hash)
+	assert.False(t, notifications[1].Data.(*UTXOChange).Connected)
+	assert.Equal(t, s1.Hash, notifications[2].Data.(*Block).Hash)
+	assert.Equal(t, s2.Hash, notifications[4].Data.(*Block).Hash)
+	assert.EqualValues(t, 3, notifications[4].Data.(*Block).Height)
+}
+
+// nolint: funlen
+func TestBlockChains_ReorgRestoresSpentOutputs(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	address, err := ws.CreateWallet()
+	assert.Nil(t, err)
+	wallet, err := ws.GetWallet(address)
+	assert.Nil(t, err)
+	bcs.Subscribe(ws.HandleNotification)
+	notifications := make([]*Notification, 0)
+	bcs.Subscribe(func(n *Notification) {
+		notifications = append(notifications, n)
+	})
+
+	// the unlocking script of a pay to public key output tells nothing of it
+	lockScript, err := script.PayToPubKeyScript(wallet.PublicKey)
+	assert.Nil(t, err)
+	cb1 := NewCoinbaseTX(address, "b1")
+	cb1.Vout[0] = *NewScriptTXOutput(0, Subsidy, lockScript)
+	cb1.TxID = hex.EncodeToString(cb1.Hash()[:])
+	b1 := MineBlock([]*Transaction{cb1}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+
+	other := NewWallet()
+	tx := newSpendTx(cb1, 0, Subsidy, other.GetAddress())
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(cb1)))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b2"), tx}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	assert.Nil(t, bcs.GetUTXO(cb1.TxID, 0))
+	change := notifications[len(notifications)-1].Data.(*UTXOChange)
+	assert.Equal(t, []UTXOEntry{{TxID: cb1.TxID, Output: cb1.Vout[0]}}, change.Spent)
+	chained := newSpendTx(tx, 0, Subsidy, address)
+	assert.Nil(t, chained.Sign(other.PrivateKey, condOf(tx)))
+	b3 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b3"), chained}, b2.Hash)
+	assert.Nil(t, bcs.AddBlock(b3))
+	spent, err := bcs.SpentOutputs(b3)
+	assert.Nil(t, err)
+	assert.Equal(t, []UTXOEntry{{TxID: tx.TxID, Output: tx.Vout[0]}}, spent)
+
+	txs := ws.ListTransactions()
+	assert.Len(t, txs, 5)
+	assert.Equal(t, tx.TxID, txs[2].TxID)
+	assert.Equal(t, Subsidy, txs[2].Sent)
+	assert.Equal(t, 0, txs[2].Fee)
+	assert.Equal(t, []string{other.GetAddress()}, txs[2].Counterparties)
+	assert.Equal(t, []string{other.GetAddress()}, txs[4].Counterparties)
+
+	// the side chain takes over, the output comes back whole and the one of the switched tx does not
+	notifications = notifications[:0]
+	s2 := MineBlock([]*Transaction{NewCoinbaseTX(address, "s2")}, b1.Hash)
+	s3 := MineBlock([]*Transaction{NewCoinbaseTX(address, "s3")}, s2.Hash)
+	s4 := MineBlock([]*Transaction{NewCoinbaseTX(address, "s4")}, s3.Hash)
+	assert.Nil(t, bcs.AddBlock(s2))
+	assert.Nil(t, bcs.AddBlock(s3))
+	assert.Nil(t, bcs.AddBlock(s4))
+	assert.Equal(t, s4.Hash, bcs.GetLatestBlock().Hash)
+	assert.Equal(t, &cb1.Vout[0], bcs.GetUTXO(cb1.TxID, 0))
+	assert.Nil(t, bcs.GetUTXO(tx.TxID, 0))
+	change = notifications[1].Data.(*UTXOChange)
+	assert.False(t, change.Connected)
+	assert.Equal(t, b3.Hash, change.BlockHash)
+	assert.Equal(t, spent, change.Spent)
+	assert.Len(t, ws.ListTransactions(), 4)
+
+	// and is spent again on the new chain
+	s5 := MineBlock([]*Transaction{NewCoinbaseTX(address, "s5"), tx}, s4.Hash)
+	assert.Nil(t, bcs.AddBlock(s5))
+	assert.Equal(t, s5.Hash, bcs.GetLatestBlock().Hash)
+}
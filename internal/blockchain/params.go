@@ -0,0 +1,25 @@
+package blockchain
+
+// Params are the consensus limits of a chain.
+type Params struct {
+	// MaxBlockSize is the most bytes a serialized block takes.
+	MaxBlockSize int
+	// MaxTxSize is the most bytes a serialized transaction takes.
+	MaxTxSize int
+	// MaxTxInputs and MaxTxOutputs bound the inputs and outputs of a transaction.
+	MaxTxInputs  int
+	MaxTxOutputs int
+	// MaxBlockSigOps and MaxTxSigOps bound the signature operations of a block and of a transaction.
+	MaxBlockSigOps int
+	MaxTxSigOps    int
+}
+
+// MainNetParams are the limits of the chains built by NewBlockChainsWithDB.
+var MainNetParams = Params{
+	MaxBlockSize:   1000000,
+	MaxTxSize:      100000,
+	MaxTxInputs:    1000,
+	MaxTxOutputs:   2000,
+	MaxBlockSigOps: 20000,
+	MaxTxSigOps:    4000,
+}
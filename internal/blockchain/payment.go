@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// Recipient is an address paid by a transaction.
+type Recipient struct {
+	Address string
+	Amount  int
+}
+
+// paymentOutputs checks the addresses of a payment, it returns the pubkey hashes of the sources, once each,
+// and the outputs paying the recipients in their order followed by extra.
+func paymentOutputs(from []string, recipients []Recipient, extra []TXOutput) ([][]byte, []TXOutput, error) {
+	if len(from) == 0 || len(recipients)+len(extra) == 0 {
+		return nil, nil, errors.New("invalid input")
+	}
+	pubKeyHashes := make([][]byte, 0, len(from))
+	seen := make(map[string]bool, len(from))
+	for _, address := range from {
+		if !utils.IsValidAddress(address) {
+			return nil, nil, fmt.Errorf("invalid address %s", address)
+		}
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		pubKeyHash, _ := utils.Address2PubkeyHash(address)
+		pubKeyHashes = append(pubKeyHashes, pubKeyHash)
+	}
+	outputs := make([]TXOutput, 0, len(recipients)+len(extra))
+	for idx, recipient := range recipients {
+		if !utils.IsValidAddress(recipient.Address) {
+			return nil, nil, fmt.Errorf("invalid address %s", recipient.Address)
+		}
+		if recipient.Amount <= 0 {
+			return nil, nil, fmt.Errorf("invalid amount %d to %s", recipient.Amount, recipient.Address)
+		}
+		outputs = append(outputs, *NewTXOutput(idx, recipient.Amount, recipient.Address))
+	}
+	for _, output := range extra {
+		output.Index = len(outputs)
+		outputs = append(outputs, output)
+	}
+	return pubKeyHashes, outputs, nil
+}
+
+// CreatePayment builds the unsigned transaction paying recipients from the outputs of the addresses from, the
+// change goes to changeAddress. No key of the sources is needed, they may be watch-only.
+func CreatePayment(bcs *BlockChains, from []string, recipients []Recipient, changeAddress string,
+	opts *SpendOptions) (*PartialTx, error) {
+	if !utils.IsValidAddress(changeAddress) {
+		return nil, errors.New("invalid change address")
+	}
+	pubKeyHashes, outputs, err := paymentOutputs(from, recipients, nil)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := selectCoins(bcs, pubKeyHashes, outputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewPartialTx(newSelectionTransaction(nil, changeAddress, sel, outputs, opts.lockTime()), bcs)
+}
+
+// Send builds and signs the transaction paying recipients from the outputs of the addresses from, all the
+// spendable addresses when from is empty. The change goes to a new key of the internal HD branch, or back to
+// the first source when the wallet has no HD seed. The wallet has to be unlocked when it is encrypted.
+func (ws *Wallets) Send(bcs *BlockChains, from []string, recipients []Recipient, opts *SpendOptions) (*Transaction, error) {
+	return ws.send(bcs, from, recipients, nil, opts)
+}
+
+// AnchorData builds and signs the transaction carrying data in an output nobody can spend, at most
+// script.MaxDataCarrierSize bytes. The sources, the fee and the change are those of Send.
+func (ws *Wallets) AnchorData(bcs *BlockChains, from []string, data []byte, opts *SpendOptions) (*Transaction, error) {
+	output, err := NewDataTXOutput(0, data)
+	if err != nil {
+		return nil, err
+	}
+	return ws.send(bcs, from, nil, []TXOutput{*output}, opts)
+}
+
+func (ws *Wallets) send(bcs *BlockChains, from []string, recipients []Recipient, extra []TXOutput,
+	opts *SpendOptions) (*Transaction, error) {
+	if len(from) == 0 {
+		from = ws.GetSpendableAddresses()
+		if len(from) == 0 {
+			return nil, errors.New("no spendable address in wallet")
+		}
+		sort.Strings(from)
+	}
+	for _, address := range from {
+		_, err := ws.GetSigningWallet(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pubKeyHashes, outputs, err := paymentOutputs(from, recipients, extra)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := selectCoins(bcs, pubKeyHashes, outputs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// a change key is only used up when there is change
+	changeAddress := from[0]
+	if sel.Change > 0 {
+		address, errChange := ws.NewChangeAddress()
+		if errChange == nil {
+			changeAddress = address
+		} else if !errors.Is(errChange, ErrNoHDSeed) {
+			return nil, errChange
+		}
+	}
+	p, err := NewPartialTx(newSelectionTransaction(nil, changeAddress, sel, outputs, opts.lockTime()), bcs)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ws.SignPartialTx(p)
+	if err != nil {
+		return nil, err
+	}
+	return p.Finalize()
+}
@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUTXOTransactionEx_OutputIndexes(t *testing.T) {
+	address := NewWallet().GetAddress()
+	inputs := map[string][]TXOutput{"a": {{Value: 10, Index: 1}}}
+	outputs := []TXOutput{*NewTXOutput(7, 3, address), *NewTXOutput(7, 4, address)}
+
+	tx, err := NewUTXOTransactionEx(nil, address, inputs, outputs)
+	assert.Nil(t, err)
+	assert.Len(t, tx.Vout, 3)
+	for idx, output := range tx.Vout {
+		assert.Equal(t, idx, output.Index)
+	}
+	assert.Equal(t, 3, tx.Vout[2].Value)
+
+	_, err = NewUTXOTransactionEx(nil, address, inputs, []TXOutput{*NewTXOutput(0, 11, address)})
+	assert.NotNil(t, err)
+}
+
+// nolint: funlen
+func TestWallets_Send(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	first, err := ws.CreateWallet()
+	assert.Nil(t, err)
+	second, err := ws.CreateWallet()
+	assert.Nil(t, err)
+	to1 := NewWallet().GetAddress()
+	to2 := NewWallet().GetAddress()
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(first, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(second, "b2")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+
+	_, err = ws.Send(bcs, []string{first}, []Recipient{{to1, Subsidy + 1}}, nil)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	_, err = ws.Send(bcs, []string{to1}, []Recipient{{to2, 1}}, nil)
+	assert.ErrorIs(t, err, ErrAddressNotInWallet)
+	_, err = ws.Send(bcs, []string{first}, []Recipient{{to2, 0}}, nil)
+	assert.NotNil(t, err)
+
+	// both sources pay two recipients, the change goes to a new internal key
+	recipients := []Recipient{{to1, Subsidy - 1}, {to2, Subsidy - 2}}
+	tx, err := ws.Send(bcs, []string{first, second, first}, recipients, &SpendOptions{Selector: LargestFirst})
+	assert.Nil(t, err)
+	assert.Len(t, tx.Vin, 2)
+	assert.Len(t, tx.Vout, 3)
+	for idx, output := range tx.Vout {
+		assert.Equal(t, idx, output.Index)
+	}
+	assert.Equal(t, to1, tx.Vout[0].Address())
+	assert.Equal(t, to2, tx.Vout[1].Address())
+	assert.Equal(t, 3, tx.Vout[2].Value)
+	change := tx.Vout[2].Address()
+	assert.NotEqual(t, first, change)
+	assert.NotEqual(t, second, change)
+	wallet, err := ws.GetWallet(change)
+	assert.Nil(t, err)
+	assert.Equal(t, InternalBranch, wallet.Path[3])
+
+	cond, err := bcs.GetCond4TransactionVerify(tx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify(cond))
+	b3 := MineBlock([]*Transaction{NewCoinbaseTX(first, "b3"), tx}, b2.Hash)
+	assert.Nil(t, bcs.AddBlock(b3))
+	assert.Equal(t, 3, bcs.GetBalance(change))
+
+	// without change no key is used up, the sources default to the spendable addresses
+	addresses := len(ws.GetAddresses())
+	tx, err = ws.Send(bcs, nil, []Recipient{{to1, Subsidy + 3}}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, tx.Vin, 2)
+	assert.Len(t, tx.Vout, 1)
+	assert.Equal(t, addresses, len(ws.GetAddresses()))
+
+	// the wallet is locked
+	assert.Nil(t, ws.Encrypt("pass"))
+	_, err = ws.Send(bcs, nil, []Recipient{{to1, 1}}, nil)
+	assert.ErrorIs(t, err, ErrWalletLocked)
+}
+
+func TestCreatePayment(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	from := NewWallet().GetAddress()
+	change := NewWallet().GetAddress()
+	to := NewWallet().GetAddress()
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(from, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+
+	p, err := CreatePayment(bcs, []string{from}, []Recipient{{to, 1}, {to, 2}}, change, nil)
+	assert.Nil(t, err)
+	assert.Len(t, p.Tx.Vout, 3)
+	assert.Equal(t, change, p.Tx.Vout[2].Address())
+	assert.Equal(t, Subsidy-3, p.Tx.Vout[2].Value)
+	assert.Equal(t, 0, p.Fee())
+
+	_, err = CreatePayment(bcs, []string{from}, []Recipient{{to, 1}}, "bad", nil)
+	assert.NotNil(t, err)
+	_, err = CreatePayment(bcs, nil, []Recipient{{to, 1}}, change, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,343 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+var (
+	ErrPartialTxIncomplete = errors.New("partial transaction is not fully signed")
+	ErrPartialTxMismatch   = errors.New("partial transactions spend different transactions")
+)
+
+// PartialTx is a transaction with the outputs its inputs spend, in the spirit of BIP174. It carries
+// everything signing needs, so it can be signed away from the chain, by several signers, whose signatures
+// are combined before the transaction is finalized.
+type PartialTx struct {
+	Tx Transaction
+	// Prevouts are the outputs spent by the inputs, in their order.
+	Prevouts []TXOutput
+	// RedeemScripts are the scripts the pay to script hash prevouts hash to, added by the signers knowing them.
+	RedeemScripts [][]byte
+	// PartialSigs are the signatures collected for the multisig redeem scripts, by hex public key. The
+	// unlocking script is set once there are enough of them.
+	PartialSigs []map[string][]byte
+	// SigHashType is the hash type the signers sign with, SigHashAll when it is not set.
+	SigHashType SigHashType
+}
+
+// NewPartialTx wraps the unsigned tx, looking up the outputs it spends in bcs.
+func NewPartialTx(tx *Transaction, bcs *BlockChains) (*PartialTx, error) {
+	if tx.IsCoinbase() {
+		return nil, errors.New("coinbase transactions are not signed")
+	}
+	p := &PartialTx{
+		Tx:       *tx,
+		Prevouts: make([]TXOutput, 0, len(tx.Vin)),
+	}
+	p.Tx.Vin = append([]TXInput(nil), tx.Vin...)
+	for idx, input := range tx.Vin {
+		output := bcs.GetUTXO(input.Txid, input.Vout)
+		if output == nil {
+			return nil, ruleError(ErrMissingTxOut, fmt.Sprintf("no input: %s,%d", input.Txid, input.Vout))
+		}
+		p.Prevouts = append(p.Prevouts, *output)
+		p.Tx.Vin[idx].Amount = output.Value
+	}
+	return p, nil
+}
+
+// CreatePartialTx builds the unsigned transaction paying amount to the address to from the outputs of the
+// address from, the change goes back to from. No key of from is needed, it may be watch-only.
+func CreatePartialTx(bcs *BlockChains, from, to string, amount int, opts *SpendOptions) (*PartialTx, error) {
+	return CreatePayment(bcs, []string{from}, []Recipient{{to, amount}}, from, opts)
+}
+
+// DeserializePartialTx decodes a PartialTx encoded by Serialize.
+func DeserializePartialTx(data []byte) (*PartialTx, error) {
+	var p PartialTx
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Prevouts) != len(p.Tx.Vin) {
+		return nil, errors.New("partial transaction misses previous outputs")
+	}
+	if (p.RedeemScripts != nil && len(p.RedeemScripts) != len(p.Tx.Vin)) ||
+		(p.PartialSigs != nil && len(p.PartialSigs) != len(p.Tx.Vin)) {
+		return nil, errors.New("partial transaction has inconsistent inputs")
+	}
+	return &p, nil
+}
+
+// Serialize encodes the PartialTx.
+func (p *PartialTx) Serialize() []byte {
+	var content bytes.Buffer
+	err := gob.NewEncoder(&content).Encode(p)
+	if err != nil {
+		panic(err)
+	}
+	return content.Bytes()
+}
+
+// Fee is what the inputs pay beyond the outputs.
+func (p *PartialTx) Fee() int {
+	fee := 0
+	for _, output := range p.Prevouts {
+		fee += output.Value
+	}
+	for _, output := range p.Tx.Vout {
+		fee -= output.Value
+	}
+	return fee
+}
+
+// IsComplete tells if every input is signed.
+func (p *PartialTx) IsComplete() bool {
+	for _, input := range p.Tx.Vin {
+		if !input.IsSigned() {
+			return false
+		}
+	}
+	return true
+}
+
+// SignWith signs the inputs spending outputs locked to the key of wallet, and the multisig inputs the key is
+// one of, and returns how many it signed.
+func (p *PartialTx) SignWith(wallet *Wallet) (int, error) {
+	hashType := p.hashType()
+	if !hashType.IsValid() {
+		return 0, ErrInvalidSigHashType
+	}
+	sigHashes := NewTxSigHashes(&p.Tx)
+	signed := 0
+	for idx := range p.Prevouts {
+		if !p.canSign(idx, wallet.PublicKey) {
+			continue
+		}
+		if wallet.PrivateKey.D == nil {
+			return signed, ErrWalletLocked
+		}
+		p.Tx.Vin[idx].Amount = p.Prevouts[idx].Value
+		var err error
+		if redeemScript := p.redeemScript(idx); redeemScript != nil {
+			err = p.signMultiSig(idx, redeemScript, &wallet.PrivateKey, wallet.PublicKey, sigHashes, hashType)
+		} else {
+			err = p.Tx.signInput(idx, &wallet.PrivateKey, &p.Prevouts[idx], sigHashes, hashType)
+		}
+		if err != nil {
+			return signed, err
+		}
+		signed++
+	}
+	return signed, nil
+}
+
+func (p *PartialTx) hashType() SigHashType {
+	if p.SigHashType == 0 {
+		return SigHashAll
+	}
+	return p.SigHashType
+}
+
+// canSign tells if the key pubKey signs the input idx, which is not fully signed yet.
+func (p *PartialTx) canSign(idx int, pubKey []byte) bool {
+	if p.Tx.Vin[idx].IsSigned() {
+		return false
+	}
+	redeemScript := p.redeemScript(idx)
+	if redeemScript == nil {
+		return p.Prevouts[idx].IsLockedWithKey(utils.HashPubKey(pubKey))
+	}
+	_, pubKeys, err := script.ExtractMultiSig(redeemScript)
+	if err != nil {
+		return false
+	}
+	for _, key := range pubKeys {
+		if bytes.Equal(key, pubKey) {
+			return !p.hasPartialSig(idx, pubKey)
+		}
+	}
+	return false
+}
+
+// AddRedeemScript gives redeemScript to the inputs spending its pay to script hash outputs, and returns to
+// how many.
+func (p *PartialTx) AddRedeemScript(redeemScript []byte) int {
+	scriptHash := utils.HashPubKey(redeemScript)
+	added := 0
+	for idx, prevout := range p.Prevouts {
+		if script.GetClass(prevout.LockingScript()) != script.ScriptHashTy || !prevout.IsLockedWithKey(scriptHash) {
+			continue
+		}
+		if p.RedeemScripts == nil {
+			p.RedeemScripts = make([][]byte, len(p.Prevouts))
+		}
+		if p.RedeemScripts[idx] == nil {
+			p.RedeemScripts[idx] = redeemScript
+			added++
+		}
+	}
+	return added
+}
+
+func (p *PartialTx) redeemScript(idx int) []byte {
+	if p.RedeemScripts == nil {
+		return nil
+	}
+	return p.RedeemScripts[idx]
+}
+
+func (p *PartialTx) hasPartialSig(idx int, pubKey []byte) bool {
+	if p.PartialSigs == nil {
+		return false
+	}
+	_, ok := p.PartialSigs[idx][hex.EncodeToString(pubKey)]
+	return ok
+}
+
+func (p *PartialTx) addPartialSig(idx int, pubKey, sig []byte) {
+	if p.PartialSigs == nil {
+		p.PartialSigs = make([]map[string][]byte, len(p.Prevouts))
+	}
+	if p.PartialSigs[idx] == nil {
+		p.PartialSigs[idx] = make(map[string][]byte)
+	}
+	p.PartialSigs[idx][hex.EncodeToString(pubKey)] = sig
+}
+
+// signMultiSig adds the signature of privKey to the input idx spending the hash of the multisig redeemScript.
+func (p *PartialTx) signMultiSig(idx int, redeemScript []byte, privKey *ecdsa.PrivateKey, pubKey []byte,
+	sigHashes *TxSigHashes, hashType SigHashType) error {
+	sig, err := rawTxInSignature(&p.Tx, idx, redeemScript, p.Prevouts[idx].Value, sigHashes, hashType,
+		schemeOfKey(pubKey), privKey)
+	if err != nil {
+		return err
+	}
+	p.addPartialSig(idx, pubKey, sig)
+	return p.finalizeMultiSig(idx)
+}
+
+// finalizeMultiSig sets the unlocking script of the multisig input idx once it has enough signatures:
+// OP_0 <sig>... <redeemScript>, the signatures in the order of their keys.
+func (p *PartialTx) finalizeMultiSig(idx int) error {
+	redeemScript := p.redeemScript(idx)
+	if redeemScript == nil || p.PartialSigs == nil || p.Tx.Vin[idx].IsSigned() {
+		return nil
+	}
+	m, pubKeys, err := script.ExtractMultiSig(redeemScript)
+	if err != nil {
+		return err
+	}
+	b := script.NewBuilder().AddOp(script.Op0)
+	sigs := 0
+	for _, pubKey := range pubKeys {
+		sig, ok := p.PartialSigs[idx][hex.EncodeToString(pubKey)]
+		if !ok || sigs == m {
+			continue
+		}
+		b.AddData(sig)
+		sigs++
+	}
+	if sigs < m {
+		return nil
+	}
+	sigScript, err := b.AddData(redeemScript).Script()
+	if err != nil {
+		return err
+	}
+	p.Tx.Vin[idx].SigScript = sigScript
+	return nil
+}
+
+// Combine adds the signatures and the redeem scripts of other, which has to be a copy of the same unsigned
+// transaction.
+func (p *PartialTx) Combine(other *PartialTx) error {
+	if !bytes.Equal(p.unsigned().Serialize(), other.unsigned().Serialize()) {
+		return ErrPartialTxMismatch
+	}
+	for idx, input := range other.Tx.Vin {
+		if redeemScript := other.redeemScript(idx); redeemScript != nil {
+			p.AddRedeemScript(redeemScript)
+		}
+		if other.PartialSigs != nil {
+			for key, sig := range other.PartialSigs[idx] {
+				pubKey, _ := hex.DecodeString(key)
+				p.addPartialSig(idx, pubKey, sig)
+			}
+		}
+		if input.IsSigned() && !p.Tx.Vin[idx].IsSigned() {
+			p.Tx.Vin[idx].PubKey = input.PubKey
+			p.Tx.Vin[idx].Signature = input.Signature
+			p.Tx.Vin[idx].SigScript = input.SigScript
+		}
+		err := p.finalizeMultiSig(idx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsigned is the PartialTx without the signatures, the public keys and the redeem scripts the signers add.
+func (p *PartialTx) unsigned() *PartialTx {
+	u := &PartialTx{Tx: p.Tx, Prevouts: p.Prevouts}
+	u.Tx.Vin = make([]TXInput, 0, len(p.Tx.Vin))
+	for _, input := range p.Tx.Vin {
+		u.Tx.Vin = append(u.Tx.Vin, TXInput{Txid: input.Txid, Vout: input.Vout, Amount: input.Amount,
+			Sequence: input.Sequence})
+	}
+	return u
+}
+
+// Finalize verifies the signatures against the previous outputs and returns the transaction to broadcast.
+func (p *PartialTx) Finalize() (*Transaction, error) {
+	if !p.IsComplete() {
+		return nil, ErrPartialTxIncomplete
+	}
+	cond := &TransactionVerifyCond{Outputs: make(map[string][]TXOutput)}
+	for idx, input := range p.Tx.Vin {
+		cond.Outputs[input.Txid] = append(cond.Outputs[input.Txid], p.Prevouts[idx])
+	}
+	tx := p.Tx
+	tx.Vin = append([]TXInput(nil), p.Tx.Vin...)
+	err := tx.Verify(cond)
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// SignPartialTx signs the inputs of p spending outputs of the wallet keys, and adds the signatures of the
+// wallet keys to the inputs spending its multisig addresses. It returns how many inputs it signed, the wallet
+// has to be unlocked when it is encrypted.
+func (ws *Wallets) SignPartialTx(p *PartialTx) (int, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	for _, redeemScript := range ws.scripts {
+		p.AddRedeemScript(redeemScript)
+	}
+	signed := 0
+	for _, wallet := range ws.Wallets {
+		for idx := range p.Prevouts {
+			if !p.canSign(idx, wallet.PublicKey) {
+				continue
+			}
+			n, err := p.SignWith(wallet)
+			if err != nil {
+				return signed, err
+			}
+			signed += n
+			break
+		}
+	}
+	return signed, nil
+}
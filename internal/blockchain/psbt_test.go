@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint: funlen
+func TestPartialTx_Workflow(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	alice, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	bob, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	aliceAddress, err := alice.CreateWallet()
+	assert.Nil(t, err)
+	bobAddress, err := bob.CreateWallet()
+	assert.Nil(t, err)
+	to := NewWallet().GetAddress()
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(aliceAddress, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(bobAddress, "b2")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+
+	// both pay into one transaction, built by a node holding no key
+	inputs := map[string][]TXOutput{
+		b1.Transactions[0].TxID: {b1.Transactions[0].Vout[0]},
+		b2.Transactions[0].TxID: {b2.Transactions[0].Vout[0]},
+	}
+	tx, err := NewUTXOTransactionEx(nil, aliceAddress, inputs, []TXOutput{*NewTXOutput(0, 2*Subsidy-1, to)})
+	assert.Nil(t, err)
+	unsigned, err := NewPartialTx(tx, bcs)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, unsigned.Fee())
+	assert.Len(t, unsigned.Tx.Vout, 2)
+
+	aliceCopy, err := DeserializePartialTx(unsigned.Serialize())
+	assert.Nil(t, err)
+	bobCopy, err := DeserializePartialTx(unsigned.Serialize())
+	assert.Nil(t, err)
+	signed, err := alice.SignPartialTx(aliceCopy)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	assert.False(t, aliceCopy.IsComplete())
+	_, err = aliceCopy.Finalize()
+	assert.ErrorIs(t, err, ErrPartialTxIncomplete)
+
+	assert.Nil(t, bob.Encrypt("pass"))
+	_, err = bob.SignPartialTx(bobCopy)
+	assert.ErrorIs(t, err, ErrWalletLocked)
+	assert.Nil(t, bob.Unlock("pass", 0))
+	signed, err = bob.SignPartialTx(bobCopy)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+
+	other, err := CreatePartialTx(bcs, aliceAddress, to, 1, nil)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, aliceCopy.Combine(other), ErrPartialTxMismatch)
+	assert.Nil(t, aliceCopy.Combine(bobCopy))
+	assert.True(t, aliceCopy.IsComplete())
+	final, err := aliceCopy.Finalize()
+	assert.Nil(t, err)
+	assert.Equal(t, tx.TxID, final.TxID)
+
+	b3 := MineBlock([]*Transaction{NewCoinbaseTX(to, "b3"), final}, b2.Hash)
+	assert.Nil(t, bcs.AddBlock(b3))
+	assert.Equal(t, 2*Subsidy-1+Subsidy, bcs.GetBalance(to))
+}
+
+func TestPartialTx_WatchOnly(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	// the online wallet watches the address of the offline one
+	offline, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	cold, err := offline.CreateWallet()
+	assert.Nil(t, err)
+	online, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	_, err = online.AddWatchOnly(cold)
+	assert.Nil(t, err)
+	to := NewWallet().GetAddress()
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(cold, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+
+	_, err = CreatePartialTx(bcs, cold, to, Subsidy+1, nil)
+	assert.NotNil(t, err)
+	p, err := CreatePartialTx(bcs, cold, to, 4, nil)
+	assert.Nil(t, err)
+	signed, err := online.SignPartialTx(p)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, signed)
+
+	p, err = DeserializePartialTx(p.Serialize())
+	assert.Nil(t, err)
+	signed, err = offline.SignPartialTx(p)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	final, err := p.Finalize()
+	assert.Nil(t, err)
+
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(to, "b2"), final}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	assert.Equal(t, Subsidy-4, bcs.GetBalance(cold))
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWallets_SendWithScheme(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	to, err := ws.CreateWallet()
+	assert.Nil(t, err)
+	prev := bcs.GetLatestBlock().Hash
+	others := map[utils.Scheme]utils.Scheme{
+		utils.SchemeSecp256k1: utils.SchemeSchnorr,
+		utils.SchemeSchnorr:   utils.SchemeSecp256k1,
+	}
+	for _, scheme := range []utils.Scheme{utils.SchemeSecp256k1, utils.SchemeSchnorr} {
+		from, errCreate := ws.CreateWalletWithScheme(scheme)
+		assert.Nil(t, errCreate)
+		wallet, errGet := ws.GetWallet(from)
+		assert.Nil(t, errGet)
+		assert.Equal(t, scheme, wallet.Scheme())
+		assert.Equal(t, scheme.Curve(), wallet.PrivateKey.Curve)
+
+		block := MineBlock([]*Transaction{NewCoinbaseTX(from, scheme.Name())}, prev)
+		assert.Nil(t, bcs.AddBlock(block))
+		prev = block.Hash
+
+		tx, errSend := ws.Send(bcs, []string{from}, []Recipient{{Address: to, Amount: Subsidy / 2}}, nil)
+		assert.Nil(t, errSend, scheme.Name())
+		assert.Equal(t, wallet.PublicKey, tx.Vin[0].PubKey)
+		cond, errCond := bcs.GetCond4TransactionVerify(tx)
+		assert.Nil(t, errCond)
+		assert.Nil(t, tx.Verify(cond), scheme.Name())
+
+		// the same key tagged with the other scheme is another address
+		other := *tx
+		other.Vin = append([]TXInput(nil), tx.Vin...)
+		other.Vin[0].PubKey = others[scheme].MarshalPubKey(&wallet.PrivateKey.PublicKey)
+		assert.True(t, IsErrorCode(other.Verify(cond), ErrScriptFailed))
+
+		block = MineBlock([]*Transaction{NewCoinbaseTX(to, scheme.Name()+" spend"), tx}, prev)
+		assert.Nil(t, bcs.AddBlock(block))
+		prev = block.Hash
+	}
+
+	// the keys of the other schemes have no WIF nor message signatures
+	from, err := ws.CreateWalletWithScheme(utils.SchemeSchnorr)
+	assert.Nil(t, err)
+	_, err = ws.DumpPrivKey(from)
+	assert.ErrorIs(t, err, utils.ErrUnsupportedScheme)
+	_, err = ws.SignMessage(from, "message")
+	assert.ErrorIs(t, err, utils.ErrUnsupportedScheme)
+}
+
+func TestWallets_SchemeKeysReload(t *testing.T) {
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	addresses := make(map[string]utils.Scheme)
+	for _, scheme := range utils.Schemes() {
+		address, err := ws.CreateWalletWithScheme(scheme)
+		assert.Nil(t, err)
+		addresses[address] = scheme
+	}
+	check := func(ws *Wallets) {
+		for address, scheme := range addresses {
+			wallet, err := ws.GetSigningWallet(address)
+			assert.Nil(t, err)
+			assert.Equal(t, scheme, wallet.Scheme())
+			assert.Equal(t, wallet.PublicKey, scheme.MarshalPubKey(&wallet.PrivateKey.PublicKey))
+		}
+	}
+
+	assert.Nil(t, ws.SaveToFile())
+	reloaded, err := NewWalletsFromFile(ws.file)
+	assert.Nil(t, err)
+	check(reloaded)
+
+	assert.Nil(t, reloaded.Encrypt("pass"))
+	reloaded, err = NewWalletsFromFile(ws.file)
+	assert.Nil(t, err)
+	assert.Nil(t, reloaded.Unlock("pass", 0))
+	check(reloaded)
+}
+
+func TestTransaction_Verify_SchnorrPubKeyScript(t *testing.T) {
+	ws, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	address, err := ws.CreateWalletWithScheme(utils.SchemeSchnorr)
+	assert.Nil(t, err)
+	wallet, err := ws.GetWallet(address)
+	assert.Nil(t, err)
+
+	lockScript, err := script.PayToPubKeyScript(wallet.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, script.PubKeyTy, script.GetClass(lockScript))
+	prev := NewCoinbaseTX(address, "prev")
+	prev.Vout[0] = *NewScriptTXOutput(0, Subsidy, lockScript)
+	prev.TxID = hex.EncodeToString(prev.Hash()[:])
+
+	tx := newSpendTx(prev, 0, Subsidy, address)
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(prev)))
+	// a 64 bytes signature and the hash type
+	assert.Len(t, tx.Vin[0].SigScript, 1+65)
+	assert.Nil(t, tx.Verify(condOf(prev)))
+}
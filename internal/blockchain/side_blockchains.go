@@ -11,6 +11,9 @@ import (
 type ChainLooker interface {
 	GetTXOChangeUtil(height int64) (deletedTx map[string]interface{}, uTx map[string][]TXOutput)
 	GetUTXO(txID string, outIndex int) *TXOutput
+	GetBlockByHeight(height int64) *Block
+	GetTxHeight(txID string) (int64, bool)
+	Params() *Params
 }
 
 type UTXO struct {
@@ -141,9 +144,9 @@ func (sbs *SideBlockChains) NewSortedBlocks(blocks []*Block, preBlockOnMain *Blo
 	if len(blocks) == 0 {
 		return 0, nil
 	}
-	h := sbs.NewBlock(blocks[0], preBlockOnMain)
-	if h <= 0 {
-		return h, nil
+	h, err := sbs.NewBlock(blocks[0], preBlockOnMain)
+	if err != nil || h <= 0 {
+		return h, err
 	}
 	lastHash := blocks[0].Hash
 	for idx := 1; idx < len(blocks); idx++ {
@@ -151,7 +154,10 @@ func (sbs *SideBlockChains) NewSortedBlocks(blocks []*Block, preBlockOnMain *Blo
 			return 0, errors.New("unsorted blocks")
 		}
 		lastHash = blocks[idx].Hash
-		hCur := sbs.NewBlock(blocks[idx], nil)
+		hCur, err := sbs.NewBlock(blocks[idx], nil)
+		if err != nil {
+			return h, err
+		}
 		if hCur != h+1 {
 			loge.Errorf(nil, "height check failed: %v, %v", h, hCur)
 			break
@@ -166,12 +172,12 @@ func (sbs *SideBlockChains) verifyTxInput(input TXInput, deletedTxOnM map[string
 	utxo := sbs.cl.GetUTXO(input.Txid, input.Vout)
 	if utxo != nil {
 		if v, ok := deletedTxOnM[input.Txid]; ok {
-			return nil, fmt.Errorf("utxo txid int the future of main chain: %v", v)
+			return nil, ruleError(ErrMissingTxOut, fmt.Sprintf("utxo txid int the future of main chain: %v", v))
 		}
 		if outputs, ok := sTXOOnS[input.Txid]; ok {
 			for _, output := range outputs {
 				if output == input.Vout {
-					return nil, fmt.Errorf("utxo %s,%d has been consumed", input.Txid, input.Vout)
+					return nil, ruleError(ErrSpentTxOut, fmt.Sprintf("utxo %s,%d has been consumed", input.Txid, input.Vout))
 				}
 			}
 		}
@@ -184,7 +190,7 @@ func (sbs *SideBlockChains) verifyTxInput(input TXInput, deletedTxOnM map[string
 				return &output, nil
 			}
 		}
-		return nil, fmt.Errorf("no utxo output %d for %s", input.Vout, input.Txid)
+		return nil, ruleError(ErrMissingTxOut, fmt.Sprintf("no utxo output %d for %s", input.Vout, input.Txid))
 	}
 	if outputs, ok := uTXOOnS[input.Txid]; ok {
 		for _, output := range outputs {
@@ -192,54 +198,68 @@ func (sbs *SideBlockChains) verifyTxInput(input TXInput, deletedTxOnM map[string
 				return &output, nil
 			}
 		}
-		return nil, fmt.Errorf("no utxo output %d for %s", input.Vout, input.Txid)
+		return nil, ruleError(ErrMissingTxOut, fmt.Sprintf("no utxo output %d for %s", input.Vout, input.Txid))
 	}
-	return nil, fmt.Errorf("no utxo: %s, %d", input.Txid, input.Vout)
+	return nil, ruleError(ErrMissingTxOut, fmt.Sprintf("no utxo: %s, %d", input.Txid, input.Vout))
 }
 
 func (sbs *SideBlockChains) verifyBlock(block *Block, heightOnMC int64, sTXOOnS map[string][]int,
 	uTXOOnS map[string][]TXOutput) error {
 	deletedTxOnM, uTxOnM := sbs.cl.GetTXOChangeUtil(heightOnMC)
+	view := sbs.newSideChainView(block.PrevBlockHash, heightOnMC)
+	params := sbs.cl.Params()
 
+	sigOps := 0
 	for _, transaction := range block.Transactions {
 		err := transaction.simpleVerify()
 		if err != nil {
 			return err
 		}
+		err = checkTransactionLocks(view, transaction, block.Height)
+		if err != nil {
+			return err
+		}
 		if transaction.IsCoinbase() {
+			sigOps += transaction.SigOpCount()
 			continue
 		}
-		inputAmount := 0
-		outAmount := 0
-		if !transaction.IsCoinbase() {
-			for _, input := range transaction.Vin {
-				utxo, err := sbs.verifyTxInput(input, deletedTxOnM, uTxOnM, sTXOOnS, uTXOOnS)
-				if err != nil {
-					return err
-				}
-				inputAmount += utxo.Value
+		cond := &TransactionVerifyCond{Outputs: make(map[string][]TXOutput)}
+		for _, input := range transaction.Vin {
+			utxo, err := sbs.verifyTxInput(input, deletedTxOnM, uTxOnM, sTXOOnS, uTXOOnS)
+			if err != nil {
+				return err
 			}
+			cond.Outputs[input.Txid] = append(cond.Outputs[input.Txid], *utxo)
+		}
+		err = transaction.Verify(cond)
+		if err != nil {
+			return err
 		}
-		for _, output := range transaction.Vout {
-			outAmount += output.Value
+		prevouts, err := transaction.spentOutputs(cond)
+		if err != nil {
+			return err
 		}
-		if inputAmount < outAmount {
-			return fmt.Errorf("invalid amount: %v, %v", inputAmount, outAmount)
+		txSigOps := transaction.sigOpCountSpending(prevouts)
+		err = transaction.checkSigOps(txSigOps, params)
+		if err != nil {
+			return err
 		}
+		sigOps += txSigOps
 	}
-	return nil
+	return block.checkSigOps(sigOps, params)
 }
 
-func (sbs *SideBlockChains) NewBlock(block *Block, preBlockOnMain *Block) int64 {
+// NewBlock adds the block to the side chain it extends, it returns the new height or 0 when no chain fits.
+func (sbs *SideBlockChains) NewBlock(block *Block, preBlockOnMain *Block) (int64, error) {
 	if preBlockOnMain != nil {
 		block.Height = preBlockOnMain.Height + 1
 		sbs.blockHashes[block.Hash] = true
 		err := sbs.verifyBlock(block, preBlockOnMain.Height, nil, nil)
 		if err != nil {
 			loge.Errorf(nil, "verify block #%v failed: %v", block.Height, err)
-			return 0
+			return 0, err
 		}
-		return sbs.newChain(0, preBlockOnMain.Height, block, nil, nil)
+		return sbs.newChain(0, preBlockOnMain.Height, block, nil, nil), nil
 	}
 
 	for id, chain := range sbs.blockChains {
@@ -263,16 +283,16 @@ func (sbs *SideBlockChains) NewBlock(block *Block, preBlockOnMain *Block) int64
 		err := sbs.verifyBlock(block, chain.mainHeight, sTXO, uTXO)
 		if err != nil {
 			loge.Errorf(nil, "verify block #%v failed: %v", block.Height, err)
-			return 0
+			return 0, err
 		}
 		sbs.blockHashes[block.Hash] = true
 		if chainTop {
-			return chain.AddBlock(block)
+			return chain.AddBlock(block), nil
 		}
-		return sbs.newChain(id, chain.mainHeight, block, sTXO, uTXO)
+		return sbs.newChain(id, chain.mainHeight, block, sTXO, uTXO), nil
 	}
 
-	return 0
+	return 0, nil
 }
 
 func (sbs *SideBlockChains) newChain(baseID, mainHeight int64, block *Block, sTXO map[string][]int,
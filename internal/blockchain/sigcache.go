@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+)
+
+// DefaultSigCacheSize is how many verified inputs the cache of the chains remembers.
+const DefaultSigCacheSize = 50000
+
+// sigCacheKey is an input, by the id of its transaction and its index.
+type sigCacheKey struct {
+	txID string
+	inID int
+}
+
+// SigCache remembers the inputs whose scripts passed, so a transaction checked when it entered the pool is
+// not checked again when its block arrives. An entry holds the digest of what the input was checked against:
+// the transaction with its signatures and the output the input spends. The id of a transaction is not bound
+// to its content, the same id with other signatures, or another output at the outpoint, misses.
+type SigCache struct {
+	lock       sync.RWMutex
+	entries    map[sigCacheKey]chainhash.Hash
+	maxEntries int
+}
+
+// NewSigCache returns a cache of at most maxEntries inputs, a cache of 0 entries remembers nothing.
+func NewSigCache(maxEntries int) *SigCache {
+	return &SigCache{
+		entries:    make(map[sigCacheKey]chainhash.Hash),
+		maxEntries: maxEntries,
+	}
+}
+
+// Exists tells if the input inID of txID passed against digest.
+func (c *SigCache) Exists(txID string, inID int, digest chainhash.Hash) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	entry, ok := c.entries[sigCacheKey{txID, inID}]
+	return ok && entry.IsEqual(&digest)
+}
+
+// Add remembers the input inID of txID passed against digest, a random entry is evicted when the cache is full.
+func (c *SigCache) Add(txID string, inID int, digest chainhash.Hash) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := sigCacheKey{txID, inID}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+			break
+		}
+	}
+	c.entries[key] = digest
+}
+
+// Len returns how many inputs the cache remembers.
+func (c *SigCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.entries)
+}
+
+// sigCacheDigest is what the input inID of tx is checked against, txHash is the hash of the serialized tx.
+func sigCacheDigest(txHash chainhash.Hash, inID int, prevout *TXOutput) chainhash.Hash {
+	var buff bytes.Buffer
+	buff.Write(txHash[:])
+	writeInt64(&buff, int64(inID))
+	writeInt64(&buff, int64(prevout.Value))
+	writeVarBytes(&buff, prevout.LockingScript())
+	return chainhash.HashH(buff.Bytes())
+}
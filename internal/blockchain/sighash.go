@@ -0,0 +1,206 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// SigHashType tells which parts of the transaction a signature commits to, it is the last byte of the
+// signature.
+type SigHashType uint32
+
+const (
+	// SigHashAll commits to every input and every output.
+	SigHashAll SigHashType = 0x1
+	// SigHashNone commits to the inputs but to no output, anybody may pay the value anywhere.
+	SigHashNone SigHashType = 0x2
+	// SigHashSingle commits to the inputs and to the output of the same index as the signed input.
+	SigHashSingle SigHashType = 0x3
+	// SigHashAnyOneCanPay combined with the others commits to the signed input only, so others may add inputs.
+	SigHashAnyOneCanPay SigHashType = 0x80
+)
+
+var (
+	ErrInvalidSigHashType = errors.New("invalid signature hash type")
+	ErrSigHashSingleIndex = errors.New("no output of the index of the input signed with SIGHASH_SINGLE")
+)
+
+var sigHashNames = map[SigHashType]string{
+	SigHashAll:    "ALL",
+	SigHashNone:   "NONE",
+	SigHashSingle: "SINGLE",
+}
+
+// IsValid tells if hashType is one of the base types, optionally with SigHashAnyOneCanPay.
+func (hashType SigHashType) IsValid() bool {
+	_, ok := sigHashNames[hashType&^SigHashAnyOneCanPay]
+	return ok
+}
+
+func (hashType SigHashType) String() string {
+	name, ok := sigHashNames[hashType&^SigHashAnyOneCanPay]
+	if !ok {
+		return fmt.Sprintf("%#x", uint32(hashType))
+	}
+	if hashType&SigHashAnyOneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// ParseSigHashType parses the names String returns: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY.
+func ParseSigHashType(s string) (SigHashType, error) {
+	parts := strings.Split(strings.ToUpper(s), "|")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "ANYONECANPAY") {
+		return 0, ErrInvalidSigHashType
+	}
+	for hashType, name := range sigHashNames {
+		if name == parts[0] {
+			if len(parts) == 2 {
+				hashType |= SigHashAnyOneCanPay
+			}
+			return hashType, nil
+		}
+	}
+	return 0, ErrInvalidSigHashType
+}
+
+// TxSigHashes are the midstates of the signature hashes of a transaction, the hashes of all its outpoints,
+// sequences and outputs. They are shared by the signatures of every input, so hashing a transaction for all
+// its inputs takes a time linear in its size.
+type TxSigHashes struct {
+	HashPrevOuts chainhash.Hash
+	HashSequence chainhash.Hash
+	HashOutputs  chainhash.Hash
+}
+
+// NewTxSigHashes computes the midstates of tx.
+func NewTxSigHashes(tx *Transaction) *TxSigHashes {
+	var prevOuts, sequences, outputs bytes.Buffer
+	for _, input := range tx.Vin {
+		writeOutPoint(&prevOuts, input.Txid, input.Vout)
+		writeUint32(&sequences, input.Sequence)
+	}
+	for idx := range tx.Vout {
+		writeOutput(&outputs, &tx.Vout[idx])
+	}
+	return &TxSigHashes{
+		HashPrevOuts: chainhash.DoubleHashH(prevOuts.Bytes()),
+		HashSequence: chainhash.DoubleHashH(sequences.Bytes()),
+		HashOutputs:  chainhash.DoubleHashH(outputs.Bytes()),
+	}
+}
+
+// CalcSignatureHash returns the digest the signature of the input inID of tx signs with hashType, prevScript
+// is the script the signature is checked by and amount the value of the output the input spends. Integers
+// are little endian, byte strings are prefixed by their uvarint length. The digest is the double SHA-256 of:
+//
+//  1. the R of tx
+//  2. HashPrevOuts, the outpoints (txid, int64 vout), zero with SigHashAnyOneCanPay
+//  3. HashSequence, the uint32 sequences, zero with SigHashAnyOneCanPay, SigHashNone or SigHashSingle
+//  4. the outpoint of the input
+//  5. prevScript
+//  6. amount, int64
+//  7. the sequence of the input, uint32
+//  8. HashOutputs, the outputs (int64 index, int64 value, locking script), with SigHashSingle the output of
+//     index inID only, zero with SigHashNone
+//  9. the lock time of tx, int64
+//  10. hashType, uint32
+func CalcSignatureHash(prevScript []byte, sigHashes *TxSigHashes, hashType SigHashType, tx *Transaction, inID int,
+	amount int) (chainhash.Hash, error) {
+	if !hashType.IsValid() {
+		return chainhash.Hash{}, ErrInvalidSigHashType
+	}
+	if inID < 0 || inID >= len(tx.Vin) {
+		return chainhash.Hash{}, fmt.Errorf("no input %d", inID)
+	}
+	baseType := hashType &^ SigHashAnyOneCanPay
+	anyOneCanPay := hashType&SigHashAnyOneCanPay != 0
+	if baseType == SigHashSingle && inID >= len(tx.Vout) {
+		return chainhash.Hash{}, ErrSigHashSingleIndex
+	}
+
+	var zeroHash chainhash.Hash
+	var buff bytes.Buffer
+	writeVarBytes(&buff, []byte(tx.R))
+	if anyOneCanPay {
+		buff.Write(zeroHash[:])
+	} else {
+		buff.Write(sigHashes.HashPrevOuts[:])
+	}
+	if anyOneCanPay || baseType != SigHashAll {
+		buff.Write(zeroHash[:])
+	} else {
+		buff.Write(sigHashes.HashSequence[:])
+	}
+	input := &tx.Vin[inID]
+	writeOutPoint(&buff, input.Txid, input.Vout)
+	writeVarBytes(&buff, prevScript)
+	writeInt64(&buff, int64(amount))
+	writeUint32(&buff, input.Sequence)
+	switch baseType {
+	case SigHashAll:
+		buff.Write(sigHashes.HashOutputs[:])
+	case SigHashSingle:
+		var output bytes.Buffer
+		writeOutput(&output, &tx.Vout[inID])
+		h := chainhash.DoubleHashH(output.Bytes())
+		buff.Write(h[:])
+	default:
+		buff.Write(zeroHash[:])
+	}
+	writeInt64(&buff, tx.LockTime)
+	writeUint32(&buff, uint32(hashType))
+	return chainhash.DoubleHashH(buff.Bytes()), nil
+}
+
+// rawTxInSignature signs the input inID of tx with hashType and scheme, the signature ends with the hash type
+// byte.
+func rawTxInSignature(tx *Transaction, inID int, prevScript []byte, amount int, sigHashes *TxSigHashes,
+	hashType SigHashType, scheme utils.Scheme, privKey *ecdsa.PrivateKey) ([]byte, error) {
+	h, err := CalcSignatureHash(prevScript, sigHashes, hashType, tx, inID, amount)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := scheme.Sign(privKey, h[:])
+	if err != nil {
+		return nil, err
+	}
+	return append(sig, byte(hashType)), nil
+}
+
+func writeVarBytes(buff *bytes.Buffer, b []byte) {
+	var size [binary.MaxVarintLen64]byte
+	buff.Write(size[:binary.PutUvarint(size[:], uint64(len(b)))])
+	buff.Write(b)
+}
+
+func writeInt64(buff *bytes.Buffer, v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	buff.Write(b[:])
+}
+
+func writeUint32(buff *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	buff.Write(b[:])
+}
+
+func writeOutPoint(buff *bytes.Buffer, txID string, vout int) {
+	writeVarBytes(buff, []byte(txID))
+	writeInt64(buff, int64(vout))
+}
+
+func writeOutput(buff *bytes.Buffer, output *TXOutput) {
+	writeInt64(buff, int64(output.Index))
+	writeInt64(buff, int64(output.Value))
+	writeVarBytes(buff, output.LockingScript())
+}
@@ -0,0 +1,171 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// sigHashVectorTx is a transaction of testdata/sighash.json, the byte strings are hex encoded.
+type sigHashVectorTx struct {
+	R        string `json:"r"`
+	LockTime int64  `json:"locktime"`
+	Vin      []struct {
+		Txid     string `json:"txid"`
+		Vout     int    `json:"vout"`
+		Sequence uint32 `json:"sequence"`
+	} `json:"vin"`
+	Vout []struct {
+		Index  int    `json:"index"`
+		Value  int    `json:"value"`
+		Script string `json:"script"`
+	} `json:"vout"`
+}
+
+// sigHashVector is a test of testdata/sighash.json, SigHash is the hex digest, empty when there is none.
+type sigHashVector struct {
+	Comment  string          `json:"comment"`
+	Tx       sigHashVectorTx `json:"tx"`
+	Input    int             `json:"input"`
+	Script   string          `json:"script"`
+	Amount   int             `json:"amount"`
+	HashType uint32          `json:"hashtype"`
+	SigHash  string          `json:"sighash"`
+}
+
+func (v *sigHashVectorTx) transaction(t *testing.T) *Transaction {
+	tx := &Transaction{R: v.R, LockTime: v.LockTime}
+	for _, input := range v.Vin {
+		tx.Vin = append(tx.Vin, TXInput{Txid: input.Txid, Vout: input.Vout, Sequence: input.Sequence})
+	}
+	for _, output := range v.Vout {
+		pkScript, err := hex.DecodeString(output.Script)
+		assert.Nil(t, err)
+		tx.Vout = append(tx.Vout, *NewScriptTXOutput(output.Index, output.Value, pkScript))
+	}
+	return tx
+}
+
+func TestCalcSignatureHash_Vectors(t *testing.T) {
+	content, err := ioutil.ReadFile("testdata/sighash.json")
+	assert.Nil(t, err)
+	var vectors []sigHashVector
+	assert.Nil(t, json.Unmarshal(content, &vectors))
+	assert.NotEmpty(t, vectors)
+
+	for _, v := range vectors {
+		tx := v.Tx.transaction(t)
+		prevScript, err := hex.DecodeString(v.Script)
+		assert.Nil(t, err)
+		h, err := CalcSignatureHash(prevScript, NewTxSigHashes(tx), SigHashType(v.HashType), tx, v.Input, v.Amount)
+		if v.SigHash == "" {
+			assert.NotNil(t, err, v.Comment)
+			continue
+		}
+		assert.Nil(t, err, v.Comment)
+		assert.Equal(t, v.SigHash, hex.EncodeToString(h[:]), v.Comment)
+	}
+}
+
+func TestSigHashType_String(t *testing.T) {
+	for _, hashType := range []SigHashType{SigHashAll, SigHashNone, SigHashSingle, SigHashAll | SigHashAnyOneCanPay,
+		SigHashNone | SigHashAnyOneCanPay, SigHashSingle | SigHashAnyOneCanPay} {
+		assert.True(t, hashType.IsValid())
+		parsed, err := ParseSigHashType(hashType.String())
+		assert.Nil(t, err)
+		assert.Equal(t, hashType, parsed)
+	}
+	parsed, err := ParseSigHashType("single|anyonecanpay")
+	assert.Nil(t, err)
+	assert.Equal(t, SigHashSingle|SigHashAnyOneCanPay, parsed)
+	for _, s := range []string{"", "ANYONECANPAY", "ALL|NONE", "ALL|ANYONECANPAY|ANYONECANPAY"} {
+		_, err = ParseSigHashType(s)
+		assert.ErrorIs(t, err, ErrInvalidSigHashType, s)
+	}
+	assert.False(t, SigHashType(0).IsValid())
+	assert.False(t, SigHashType(0x4).IsValid())
+	assert.False(t, (SigHashAll | 0x40).IsValid())
+}
+
+// nolint: funlen
+func TestTransaction_SignWithHashType(t *testing.T) {
+	wallet := NewWallet()
+	prev1 := NewCoinbaseTX(wallet.GetAddress(), "prev1")
+	prev2 := NewCoinbaseTX(wallet.GetAddress(), "prev2")
+	to := NewWallet().GetAddress()
+	newTx := func() *Transaction {
+		tx := newSpendTx(prev1, 0, Subsidy/2, to)
+		tx.Vout = append(tx.Vout, *NewTXOutput(1, Subsidy/2, wallet.GetAddress()))
+		return tx
+	}
+	addInput := func(tx *Transaction) {
+		tx.Vin = append(tx.Vin, TXInput{Txid: prev2.TxID, Vout: 0, Amount: Subsidy})
+		sig, err := rawTxInSignature(tx, 1, prev2.Vout[0].LockingScript(), Subsidy, NewTxSigHashes(tx), SigHashAll,
+			utils.SchemeP256, &wallet.PrivateKey)
+		assert.Nil(t, err)
+		tx.Vin[1].Signature = sig
+		tx.Vin[1].PubKey = wallet.PublicKey
+	}
+	cond := condOf(prev1, prev2)
+
+	tests := []struct {
+		hashType      SigHashType
+		changeOutput0 bool
+		changeOutput1 bool
+		addInput      bool
+	}{
+		{SigHashAll, false, false, false},
+		{SigHashNone, true, true, false},
+		{SigHashSingle, false, true, false},
+		{SigHashAll | SigHashAnyOneCanPay, false, false, true},
+		{SigHashSingle | SigHashAnyOneCanPay, false, true, true},
+	}
+	for _, test := range tests {
+		tx := newTx()
+		assert.Nil(t, tx.SignWithHashType(wallet.PrivateKey, cond, test.hashType))
+		assert.Equal(t, byte(test.hashType), tx.Vin[0].Signature[len(tx.Vin[0].Signature)-1])
+		assert.Nil(t, tx.Verify(cond), test.hashType)
+
+		// what the signature commits to can not change, the rest can
+		changed := *tx
+		changed.Vout = append([]TXOutput(nil), tx.Vout...)
+		changed.Vout[0].Value--
+		assert.Equal(t, test.changeOutput0, changed.Verify(cond) == nil, test.hashType)
+		changed.Vout = append([]TXOutput(nil), tx.Vout...)
+		changed.Vout[1].Value--
+		assert.Equal(t, test.changeOutput1, changed.Verify(cond) == nil, test.hashType)
+		changed.Vout = tx.Vout
+		changed.Vin = append([]TXInput(nil), tx.Vin...)
+		addInput(&changed)
+		assert.Equal(t, test.addInput, changed.Verify(cond) == nil, test.hashType)
+		changed.Vin = append([]TXInput(nil), tx.Vin...)
+		changed.LockTime++
+		assert.True(t, IsErrorCode(changed.Verify(cond), ErrScriptFailed), test.hashType)
+	}
+
+	// SIGHASH_SINGLE needs the output of the index of the input
+	tx := newSpendTx(prev1, 0, Subsidy, to)
+	addInput(tx)
+	_, err := rawTxInSignature(tx, 1, prev2.Vout[0].LockingScript(), Subsidy, NewTxSigHashes(tx), SigHashSingle,
+		utils.SchemeP256, &wallet.PrivateKey)
+	assert.ErrorIs(t, err, ErrSigHashSingleIndex)
+	assert.NotNil(t, tx.SignWithHashType(wallet.PrivateKey, cond, SigHashSingle))
+	assert.ErrorIs(t, tx.SignWithHashType(wallet.PrivateKey, cond, SigHashType(0x4)), ErrInvalidSigHashType)
+
+	// a signature whose hash type byte is changed, or undefined, fails
+	tx = newTx()
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, cond))
+	for _, hashType := range []SigHashType{SigHashNone, 0x4, 0} {
+		tx.Vin[0].Signature[len(tx.Vin[0].Signature)-1] = byte(hashType)
+		assert.True(t, IsErrorCode(tx.Verify(cond), ErrScriptFailed), hashType)
+	}
+	tx.Vin[0].Signature = nil
+	tx.Vin[0].SigScript, err = script.NewBuilder().AddData(nil).AddData(wallet.PublicKey).Script()
+	assert.Nil(t, err)
+	assert.True(t, IsErrorCode(tx.Verify(cond), ErrScriptFailed))
+}
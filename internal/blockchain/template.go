@@ -0,0 +1,40 @@
+package blockchain
+
+const (
+	// blockTemplateReserveSize is what a block template leaves for the header and the coinbase of the block.
+	blockTemplateReserveSize = 2000
+	// blockTemplateReserveSigOps is what a block template leaves for the coinbase of the block.
+	blockTemplateReserveSigOps = 100
+)
+
+// SelectBlockTxs returns the txs, in their order, a block on top of the best block holds within the limits of
+// the chains, room is left for the coinbase. A transaction over what is left, or whose inputs are not in the UTXO
+// set, is skipped.
+func (bcs *BlockChains) SelectBlockTxs(txs []*Transaction) []*Transaction {
+	size := blockTemplateReserveSize
+	sigOps := blockTemplateReserveSigOps
+	selected := make([]*Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if tx.IsCoinbase() || tx.CheckLimits(bcs.params) != nil {
+			continue
+		}
+		conds, err := bcs.GetConds4TransactionsVerify([]*Transaction{tx})
+		if err != nil {
+			continue
+		}
+		prevouts, err := tx.spentOutputs(conds[0])
+		if err != nil {
+			continue
+		}
+		txSize := len(tx.Serialize())
+		txSigOps := tx.sigOpCountSpending(prevouts)
+		if size+txSize > bcs.params.MaxBlockSize || sigOps+txSigOps > bcs.params.MaxBlockSigOps ||
+			tx.checkSigOps(txSigOps, bcs.params) != nil {
+			continue
+		}
+		size += txSize
+		sigOps += txSigOps
+		selected = append(selected, tx)
+	}
+	return selected
+}
@@ -3,23 +3,22 @@ package blockchain
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 	"time"
 
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 	uuid "github.com/satori/go.uuid"
 )
 
-const subsidy = 10
+// Subsidy is the value paid by a coinbase transaction.
+const Subsidy = 10
 
 // Transaction represents a Bitcoin transaction.
 type Transaction struct {
@@ -28,6 +27,8 @@ type Transaction struct {
 	Vout []TXOutput
 
 	R string
+	// LockTime is the height, or from LockTimeThreshold the time, the transaction can not be mined before.
+	LockTime int64
 }
 
 // IsCoinbase checks whether the transaction is coinbase.
@@ -50,29 +51,39 @@ func (tx Transaction) Serialize() []byte {
 
 func (tx *Transaction) simpleVerify() error {
 	if tx.R == "" {
-		return errors.New("no R")
+		return ruleError(ErrBadTxHeader, "no R")
 	}
 	if tx.TxID == "" {
-		return errors.New("no tx id")
+		return ruleError(ErrBadTxHeader, "no tx id")
 	}
 	if len(tx.Vin) <= 0 {
-		return errors.New("no inputs")
+		return ruleError(ErrNoTxInputs, "no inputs")
 	}
 
 	if !tx.IsCoinbase() {
 		for _, input := range tx.Vin {
-			if len(input.PubKey) == 0 || len(input.Signature) == 0 {
-				return errors.New("no pubkey or signature")
+			if !input.IsSigned() {
+				return ruleError(ErrNoTxSignature, "no unlocking script")
 			}
 		}
 	}
 
 	for _, output := range tx.Vout {
-		if output.Value <= 0 {
-			return fmt.Errorf("utxo %d no value", output.Index)
+		lockingScript := output.LockingScript()
+		if len(lockingScript) == 0 {
+			return ruleError(ErrNoTxOutPubKeyHash, fmt.Sprintf("no locking script on output %d", output.Index))
 		}
-		if len(output.PubKeyHash) == 0 {
-			return fmt.Errorf("no pubkey hash on output %d", output.Index)
+		// the data carriers, which nobody can spend, may hold no value
+		isData := script.GetClass(lockingScript) == script.NullDataTy
+		if lockingScript[0] == script.OpReturn && !isData {
+			return ruleError(ErrBadTxOutScript, fmt.Sprintf("data carrier output %d holds more than %d bytes",
+				output.Index, script.MaxDataCarrierSize))
+		}
+		if output.Value < 0 || (output.Value == 0 && !isData) {
+			return ruleError(ErrBadTxOutValue, fmt.Sprintf("utxo %d no value", output.Index))
+		}
+		if len(output.PkScript) > 0 && !bytes.Equal(output.PubKeyHash, script.ExtractPubKeyHash(output.PkScript)) {
+			return ruleError(ErrBadTxOutScript, fmt.Sprintf("pubkey hash of output %d does not match its script", output.Index))
 		}
 	}
 
@@ -105,8 +116,13 @@ func (tx *Transaction) DefSign(bcs *BlockChains, priKey ecdsa.PrivateKey) error
 	return tx.Sign(priKey, cond)
 }
 
-// Sign signs each input of a Transaction.
+// Sign signs each input of a Transaction with SigHashAll.
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, vc *TransactionVerifyCond) error {
+	return tx.SignWithHashType(privKey, vc, SigHashAll)
+}
+
+// SignWithHashType signs each input of a Transaction, the signatures commit to the parts hashType tells.
+func (tx *Transaction) SignWithHashType(privKey ecdsa.PrivateKey, vc *TransactionVerifyCond, hashType SigHashType) error {
 	if tx.IsCoinbase() {
 		return nil
 	}
@@ -123,31 +139,128 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, vc *TransactionVerifyCond)
 		tx.Vin[idx].Amount = utxo.Value
 	}
 
-	txCopy := tx.TrimmedCopy()
+	sigHashes := NewTxSigHashes(tx)
+	for inID, vin := range tx.Vin {
+		err := tx.signInput(inID, &privKey, vc.Get(vin.Txid, vin.Vout), sigHashes, hashType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	for inID, vin := range txCopy.Vin {
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = vc.Get(vin.Txid, vin.Vout).PubKeyHash
+// signInput signs the input inID spending prevout with hashType, with the unlocking data the locking script of
+// prevout asks.
+func (tx *Transaction) signInput(inID int, privKey *ecdsa.PrivateKey, prevout *TXOutput, sigHashes *TxSigHashes,
+	hashType SigHashType) error {
+	lockingScript := prevout.LockingScript()
+	scheme, pubKey := lockingKey(privKey, lockingScript)
+	sig, err := rawTxInSignature(tx, inID, lockingScript, prevout.Value, sigHashes, hashType, scheme, privKey)
+	if err != nil {
+		return err
+	}
 
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
+	switch class := script.GetClass(lockingScript); class {
+	case script.PubKeyHashTy:
+		tx.Vin[inID].Signature = sig
+		tx.Vin[inID].PubKey = pubKey
+	case script.PubKeyTy:
+		tx.Vin[inID].SigScript, err = script.NewBuilder().AddData(sig).Script()
+	default:
+		err = fmt.Errorf("can not sign the %s output %s,%d", class, tx.Vin[inID].Txid, tx.Vin[inID].Vout)
+	}
+	return err
+}
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign))
-		if err != nil {
-			return fmt.Errorf("%w", err)
+// lockingKey returns the scheme and the encoding of the key of privKey lockingScript locks to. A key of
+// secp256k1 is an ECDSA key or a Schnorr one depending on the tag of the key the address hashes, the first
+// scheme of the curve is used when lockingScript locks to another key. The P256 keys of the legacy wallets
+// hash their raw coordinates.
+func lockingKey(privKey *ecdsa.PrivateKey, lockingScript []byte) (utils.Scheme, []byte) {
+	pubKeyHash := script.ExtractPubKeyHash(lockingScript)
+	var first utils.Scheme
+	for _, scheme := range utils.Schemes() {
+		if scheme.Curve().Params().Name != privKey.Curve.Params().Name {
+			continue
+		}
+		pubKey := scheme.MarshalPubKey(&privKey.PublicKey)
+		if bytes.Equal(utils.HashPubKey(pubKey), pubKeyHash) {
+			return scheme, pubKey
+		}
+		if scheme == utils.SchemeP256 {
+			pubKey = utils.MarshalLegacyPubKey(&privKey.PublicKey)
+			if bytes.Equal(utils.HashPubKey(pubKey), pubKeyHash) {
+				return scheme, pubKey
+			}
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
+		if first == nil {
+			first = scheme
+		}
+	}
+	if first == nil {
+		first = utils.SchemeP256
+	}
+	return first, first.MarshalPubKey(&privKey.PublicKey)
+}
+
+// sigChecker checks the signatures of the input inID spending amount, they commit to the script checking them.
+type sigChecker struct {
+	tx        *Transaction
+	inID      int
+	amount    int
+	sigHashes *TxSigHashes
+}
 
-		tx.Vin[inID].Signature = signature
-		txCopy.Vin[inID].PubKey = nil
+// CheckSig checks sig, which ends with its hash type.
+func (c *sigChecker) CheckSig(sig, pubKey, prevScript []byte) bool {
+	if len(sig) == 0 {
+		return false
 	}
+	if _, err := utils.ParsePubKey(pubKey); err != nil {
+		return false
+	}
+	hashType := SigHashType(sig[len(sig)-1])
+	h, err := CalcSignatureHash(prevScript, c.sigHashes, hashType, c.tx, c.inID, c.amount)
+	if err != nil {
+		return false
+	}
+	return utils.VerifySign(sig[:len(sig)-1], pubKey, h[:])
+}
 
-	return nil
+// CheckLockTime tells if the transaction lock time, enforced by the chain, is past lockTime of the same kind.
+func (c *sigChecker) CheckLockTime(lockTime int64) bool {
+	if (c.tx.LockTime < LockTimeThreshold) != (lockTime < LockTimeThreshold) {
+		return false
+	}
+	return lockTime <= c.tx.LockTime && c.tx.Vin[c.inID].Sequence != MaxTxInSequenceNum
+}
+
+// CheckSequence tells if the relative lock of the input, enforced by the chain, is past sequence of the
+// same kind. A disabled sequence passes.
+func (c *sigChecker) CheckSequence(sequence int64) bool {
+	if uint32(sequence)&SequenceLockTimeDisabled != 0 {
+		return true
+	}
+	txSequence := c.tx.Vin[c.inID].Sequence
+	if txSequence&SequenceLockTimeDisabled != 0 {
+		return false
+	}
+	mask := SequenceLockTimeIsSeconds | SequenceLockTimeMask
+	lock, txLock := uint32(sequence)&mask, txSequence&mask
+	if (lock < SequenceLockTimeIsSeconds) != (txLock < SequenceLockTimeIsSeconds) {
+		return false
+	}
+	return lock <= txLock
 }
 
 // String returns a human-readable representation of a transaction.
 func (tx Transaction) String() string {
 	lines := make([]string, 0, len(tx.Vin)+len(tx.Vout)+1)
 	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.TxID))
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("     LockTime: %d", tx.LockTime))
+	}
 
 	for i, input := range tx.Vin {
 		lines = append(lines, fmt.Sprintf("     Input %d:", i))
@@ -155,32 +268,20 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
 		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		if input.Sequence != 0 {
+			lines = append(lines, fmt.Sprintf("       Sequence:  %#x", input.Sequence))
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
-		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+		lines = append(lines, fmt.Sprintf("       Script: %s", script.Disasm(output.LockingScript())))
 	}
 
 	return strings.Join(lines, "\n")
 }
 
-// TrimmedCopy creates a trimmed copy of Transaction to be used in signing.
-func (tx *Transaction) TrimmedCopy() Transaction {
-	inputs := make([]TXInput, 0, len(tx.Vin))
-	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, 0, nil, nil})
-	}
-
-	outputs := make([]TXOutput, 0, len(tx.Vout))
-	outputs = append(outputs, tx.Vout...)
-
-	txCopy := Transaction{tx.TxID, inputs, outputs, tx.R}
-
-	return txCopy
-}
-
 type TransactionVerifyCond struct {
 	Outputs map[string][]TXOutput
 }
@@ -196,7 +297,7 @@ func (tv *TransactionVerifyCond) Get(txID string, idx int) *TXOutput {
 	return nil
 }
 
-// Verify verifies signatures of Transaction inputs.
+// Verify runs the unlocking scripts of the inputs against the locking scripts of the outputs they spend.
 func (tx *Transaction) Verify(vc *TransactionVerifyCond) error {
 	if tx.IsCoinbase() {
 		return nil
@@ -205,41 +306,111 @@ func (tx *Transaction) Verify(vc *TransactionVerifyCond) error {
 		return errors.New("no condition transactions")
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
+	prevouts, err := tx.spentOutputs(vc)
+	if err != nil {
+		return err
+	}
+	sigHashes := NewTxSigHashes(tx)
+	for inID := range tx.Vin {
+		err = tx.verifyInput(inID, prevouts[inID], sigHashes)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.checkSpend(prevouts)
+}
 
+// spentOutputs returns the outputs of vc the inputs spend, in the order of the inputs. The amounts the inputs
+// sign have to be theirs.
+func (tx *Transaction) spentOutputs(vc *TransactionVerifyCond) ([]*TXOutput, error) {
+	prevouts := make([]*TXOutput, len(tx.Vin))
 	for inID, vin := range tx.Vin {
 		utxo := vc.Get(vin.Txid, vin.Vout)
 		if utxo == nil {
-			return fmt.Errorf("utxo %s,%d not exists", vin.Txid, vin.Vout)
+			return nil, ruleError(ErrMissingTxOut, fmt.Sprintf("utxo %s,%d not exists", vin.Txid, vin.Vout))
 		}
 		if utxo.Value != vin.Amount {
-			return fmt.Errorf("amount mismatch: %v - %v", utxo.Value, vin.Amount)
+			return nil, ruleError(ErrAmountMismatch, fmt.Sprintf("amount mismatch: %v - %v", utxo.Value, vin.Amount))
 		}
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = utxo.PubKeyHash
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
-
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if !ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) {
-			return errors.New("hash verify failed")
-		}
-		txCopy.Vin[inID].PubKey = nil
+		prevouts[inID] = utxo
 	}
+	return prevouts, nil
+}
 
+// verifyInput runs the unlocking script of the input inID against the locking script of prevout.
+func (tx *Transaction) verifyInput(inID int, prevout *TXOutput, sigHashes *TxSigHashes) error {
+	checker := &sigChecker{tx: tx, inID: inID, amount: prevout.Value, sigHashes: sigHashes}
+	err := script.Verify(tx.Vin[inID].UnlockingScript(), prevout.LockingScript(), checker)
+	if err != nil {
+		return ruleError(ErrScriptFailed, fmt.Sprintf("input %d: %v", inID, err))
+	}
+	return nil
+}
+
+// checkSpend checks the outputs the inputs spend cover the outputs of tx.
+func (tx *Transaction) checkSpend(prevouts []*TXOutput) error {
+	inputAmount := 0
+	for _, prevout := range prevouts {
+		inputAmount += prevout.Value
+	}
+	outAmount := 0
+	for _, output := range tx.Vout {
+		outAmount += output.Value
+	}
+	if inputAmount < outAmount {
+		return ruleError(ErrSpendTooHigh, fmt.Sprintf("invalid amount: %v, %v", inputAmount, outAmount))
+	}
+	return nil
+}
+
+// Check verifies the transaction without looking up its inputs.
+func (tx *Transaction) Check() error {
+	return tx.simpleVerify()
+}
+
+// SigOpCount returns the signature operations of the unlocking scripts and the locking scripts of tx, a multisig
+// counts script.MaxPubKeysPerMultiSig. Those of the redeem scripts are told by the outputs spent.
+func (tx *Transaction) SigOpCount() int {
+	count := 0
+	for idx := range tx.Vin {
+		count += script.GetSigOpCount(tx.Vin[idx].UnlockingScript())
+	}
+	for idx := range tx.Vout {
+		count += script.GetSigOpCount(tx.Vout[idx].LockingScript())
+	}
+	return count
+}
+
+// CheckLimits checks the size, the inputs, the outputs and the signature operations of tx against params.
+func (tx *Transaction) CheckLimits(params *Params) error {
+	if size := len(tx.Serialize()); size > params.MaxTxSize {
+		return ruleError(ErrTxTooBig, fmt.Sprintf("transaction of %d bytes, the most is %d", size, params.MaxTxSize))
+	}
+	if len(tx.Vin) > params.MaxTxInputs {
+		return ruleError(ErrTooManyTxInputs, fmt.Sprintf("%d inputs, the most is %d", len(tx.Vin), params.MaxTxInputs))
+	}
+	if len(tx.Vout) > params.MaxTxOutputs {
+		return ruleError(ErrTooManyTxOutputs, fmt.Sprintf("%d outputs, the most is %d", len(tx.Vout), params.MaxTxOutputs))
+	}
+	return tx.checkSigOps(tx.SigOpCount(), params)
+}
+
+// sigOpCountSpending returns the signature operations of tx with those of the redeem scripts it runs, prevouts
+// are the outputs the inputs spend.
+func (tx *Transaction) sigOpCountSpending(prevouts []*TXOutput) int {
+	count := tx.SigOpCount()
+	for idx, prevout := range prevouts {
+		count += script.GetPreciseSigOpCount(tx.Vin[idx].UnlockingScript(), prevout.LockingScript())
+	}
+	return count
+}
+
+// checkSigOps checks sigOps, the signature operations of tx with its redeem scripts, against params.
+func (tx *Transaction) checkSigOps(sigOps int, params *Params) error {
+	if sigOps > params.MaxTxSigOps {
+		return ruleError(ErrTooManySigOps, fmt.Sprintf("transaction %s of %d signature operations, the most is %d",
+			tx.TxID, sigOps, params.MaxTxSigOps))
+	}
 	return nil
 }
 
@@ -249,8 +420,8 @@ func NewCoinbaseTX(to, data string) *Transaction {
 		data = uuid.NewV4().String() + "-" + time.Now().String()
 	}
 
-	txin := TXInput{"", -1, 0, nil, []byte(data)}
-	txout := NewTXOutput(0, subsidy, to)
+	txin := TXInput{Txid: "", Vout: -1, PubKey: []byte(data)}
+	txout := NewTXOutput(0, Subsidy, to)
 	tx := Transaction{
 		TxID: "",
 		Vin:  []TXInput{txin},
@@ -261,36 +432,86 @@ func NewCoinbaseTX(to, data string) *Transaction {
 	return &tx
 }
 
+// SpendOptions tune the coin selection of a payment. The zero value selects with DefaultCoinSelector,
+// pays no fee and skips no outputs.
+type SpendOptions struct {
+	Selector CoinSelector
+	FeeRate  FeeRate
+	// Locks are the outputs not to spend, those of pending transactions.
+	Locks *CoinLocks
+	// LockTime is the height, or from LockTimeThreshold the time, the transaction can not be mined before.
+	LockTime int64
+}
+
+func (opts *SpendOptions) lockTime() int64 {
+	if opts == nil {
+		return 0
+	}
+	return opts.LockTime
+}
+
 // NewUTXOTransaction creates a new transaction.
-func NewUTXOTransaction(wallet *Wallet, to string, amount int, blockUTXO UTXOFilter,
+func NewUTXOTransaction(wallet *Wallet, to string, amount int, opts *SpendOptions,
 	blockChains *BlockChains) (*Transaction, error) {
-	if wallet == nil || to == "" || amount <= 0 || blockChains == nil {
+	if wallet == nil {
 		return nil, errors.New("invalid input")
 	}
-	pubKeyHash := utils.HashPubKey(wallet.PublicKey)
-	acc, uTXOs := blockChains.FindSpendableOutputs(pubKeyHash, amount, blockUTXO)
+	return NewTransaction(wallet.PublicKey, wallet.GetAddress(), amount, to, opts, blockChains)
+}
 
-	if acc < amount {
-		return nil, errors.New("no enough amount")
+// NewTransaction pays amount to the address to from the outputs of pubKey, the change goes back to address.
+func NewTransaction(pubKey []byte, address string, amount int, to string, opts *SpendOptions,
+	blockChains *BlockChains) (*Transaction, error) {
+	if len(pubKey) == 0 || address == "" || to == "" || amount <= 0 || blockChains == nil {
+		return nil, errors.New("invalid input")
 	}
+	outputs := []TXOutput{*NewTXOutput(0, amount, to)}
+	sel, err := selectCoins(blockChains, [][]byte{utils.HashPubKey(pubKey)}, outputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newSelectionTransaction(pubKey, address, sel, outputs, opts.lockTime()), nil
+}
 
-	return NewUTXOTransactionEx(wallet.PublicKey, wallet.GetAddress(),
-		uTXOs, []TXOutput{*NewTXOutput(0, amount, to)})
+func selectCoins(blockChains *BlockChains, pubKeyHashes [][]byte, outputs []TXOutput,
+	opts *SpendOptions) (*CoinSelection, error) {
+	if opts == nil {
+		opts = &SpendOptions{}
+	}
+	params := SelectionParams{Outputs: len(outputs), FeeRate: opts.FeeRate}
+	for _, output := range outputs {
+		params.Target += output.Value
+	}
+	return blockChains.FindSpendableOutputs(pubKeyHashes, params, opts.Selector, opts.Locks)
 }
 
-func NewTransaction(pubKey []byte, address string, amount int, to string, blockUTXO UTXOFilter,
-	blockChains *BlockChains) (*Transaction, error) {
-	if len(pubKey) == 0 || address == "" || amount <= 0 || blockChains == nil {
-		return nil, errors.New("invalid input")
+// newSelectionTransaction spends the coins of sel paying outputs and the change of sel to changeAddress,
+// what is left is the fee. The transaction can not be mined before lockTime.
+func newSelectionTransaction(pubKey []byte, changeAddress string, sel *CoinSelection, outputs []TXOutput,
+	lockTime int64) *Transaction {
+	txInputs := make([]TXInput, 0, len(sel.Coins))
+	for _, c := range sel.Coins {
+		txInputs = append(txInputs, TXInput{
+			Txid:   c.TxID,
+			Vout:   c.Output.Index,
+			PubKey: pubKey,
+		})
+	}
+	txOutputs := make([]TXOutput, 0, len(outputs)+1)
+	for idx, output := range outputs {
+		output.Index = idx
+		txOutputs = append(txOutputs, output)
 	}
-	acc, uTXOs := blockChains.FindSpendableOutputs(utils.HashPubKey(pubKey), amount, blockUTXO)
-	if acc < amount {
-		return nil, errors.New("no enough amount")
+	if sel.Change > 0 {
+		txOutputs = append(txOutputs, *NewTXOutput(len(txOutputs), sel.Change, changeAddress))
 	}
-	return NewUTXOTransactionEx(pubKey, address, uTXOs, []TXOutput{*NewTXOutput(0, amount, to)})
+
+	tx := Transaction{Vin: txInputs, Vout: txOutputs, LockTime: lockTime}
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	return &tx
 }
 
-// NewUTXOTransaction creates a new transaction.
+// NewUTXOTransactionEx spends inputs paying outputs, what is left goes to address.
 func NewUTXOTransactionEx(pubKey []byte, address string, inputs map[string][]TXOutput,
 	outputs []TXOutput) (*Transaction, error) {
 	amount := 0
@@ -307,20 +528,20 @@ func NewUTXOTransactionEx(pubKey []byte, address string, inputs map[string][]TXO
 		}
 	}
 
-	idx := 0
-	var output TXOutput
-	for idx, output = range outputs {
+	txOutputs := make([]TXOutput, 0, len(outputs)+1)
+	for idx, output := range outputs {
 		output.Index = idx
+		txOutputs = append(txOutputs, output)
 		amount -= output.Value
 	}
 	if amount < 0 {
 		return nil, errors.New("no enough amount")
 	}
 	if amount > 0 {
-		outputs = append(outputs, *NewTXOutput(idx+1, amount, address))
+		txOutputs = append(txOutputs, *NewTXOutput(len(txOutputs), amount, address))
 	}
 
-	tx := Transaction{"", txInputs, outputs, ""}
+	tx := Transaction{Vin: txInputs, Vout: txOutputs}
 	tx.TxID = hex.EncodeToString(tx.Hash()[:])
 	return &tx, nil
 }
@@ -3,6 +3,7 @@ package blockchain
 import (
 	"bytes"
 
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 )
 
@@ -13,6 +14,11 @@ type TXInput struct {
 	Amount    int
 	Signature []byte
 	PubKey    []byte
+	// SigScript is the unlocking script, inputs spending pay to public key hash outputs carry Signature and
+	// PubKey instead.
+	SigScript []byte
+	// Sequence is the relative lock of the input, MaxTxInSequenceNum makes it final.
+	Sequence uint32
 }
 
 // UsesKey checks whether the address initiated the transaction.
@@ -21,3 +27,20 @@ func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
 
 	return bytes.Equal(lockingHash, pubKeyHash)
 }
+
+// UnlockingScript returns SigScript, or the script pushing Signature and PubKey.
+func (in *TXInput) UnlockingScript() []byte {
+	if len(in.SigScript) > 0 {
+		return in.SigScript
+	}
+	unlockingScript, err := script.NewBuilder().AddData(in.Signature).AddData(in.PubKey).Script()
+	if err != nil {
+		return nil
+	}
+	return unlockingScript
+}
+
+// IsSigned tells if the input carries its unlocking data.
+func (in *TXInput) IsSigned() bool {
+	return len(in.SigScript) > 0 || len(in.Signature) > 0
+}
@@ -5,23 +5,49 @@ import (
 	"encoding/gob"
 	"log"
 
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 )
 
 // TXOutput represents a transaction output.
 type TXOutput struct {
-	Index      int
-	Value      int
+	Index int
+	Value int
+	// PubKeyHash is the hash of the key PkScript pays to, the wallets and the UTXO scans look outputs up by it.
 	PubKeyHash []byte
+	// PkScript is the locking script, outputs made before scripts have none and are locked to PubKeyHash.
+	PkScript []byte
 }
 
-// Lock signs the output.
+// Lock signs the output, to the key or the redeem script the version of address tells.
 func (out *TXOutput) Lock(address string) {
-	pubKeyHash, err := utils.Address2PubkeyHash(address)
+	addressVersion, pubKeyHash, err := utils.DecodeAddress(address)
+	if err != nil {
+		log.Fatal("ERROR: invalid address")
+	}
+	var pkScript []byte
+	if addressVersion == scriptHashVersion {
+		pkScript, err = script.PayToScriptHashScript(pubKeyHash)
+	} else {
+		pkScript, err = script.PayToPubKeyHashScript(pubKeyHash)
+	}
 	if err != nil {
 		log.Fatal("ERROR: invalid address")
 	}
 	out.PubKeyHash = pubKeyHash
+	out.PkScript = pkScript
+}
+
+// LockingScript returns PkScript, or the pay to public key hash script of PubKeyHash.
+func (out *TXOutput) LockingScript() []byte {
+	if len(out.PkScript) > 0 {
+		return out.PkScript
+	}
+	pkScript, err := script.PayToPubKeyHashScript(out.PubKeyHash)
+	if err != nil {
+		return nil
+	}
+	return pkScript
 }
 
 // IsLockedWithKey checks if the output can be used by the owner of the pubkey.
@@ -29,14 +55,50 @@ func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
 	return bytes.Equal(out.PubKeyHash, pubKeyHash)
 }
 
+// Address returns the address the output is locked to, none for the outputs paying neither to a key nor to a
+// script hash.
+func (out *TXOutput) Address() string {
+	if len(out.PubKeyHash) == 0 {
+		return ""
+	}
+	if script.GetClass(out.PkScript) == script.ScriptHashTy {
+		return utils.PubkeyHash2Address(out.PubKeyHash, scriptHashVersion)
+	}
+	return utils.PubkeyHash2Address(out.PubKeyHash, version)
+}
+
+// ScriptHashAddress returns the address paying to the hash of redeemScript.
+func ScriptHashAddress(redeemScript []byte) string {
+	return utils.PubkeyHash2Address(utils.HashPubKey(redeemScript), scriptHashVersion)
+}
+
 // NewTXOutput create a new TXOutput.
 func NewTXOutput(index, value int, address string) *TXOutput {
-	txo := &TXOutput{index, value, nil}
+	txo := &TXOutput{Index: index, Value: value}
 	txo.Lock(address)
 
 	return txo
 }
 
+// NewScriptTXOutput creates the TXOutput locked by pkScript.
+func NewScriptTXOutput(index, value int, pkScript []byte) *TXOutput {
+	return &TXOutput{
+		Index:      index,
+		Value:      value,
+		PubKeyHash: script.ExtractPubKeyHash(pkScript),
+		PkScript:   pkScript,
+	}
+}
+
+// NewDataTXOutput creates the TXOutput carrying data, nobody can spend it and it holds no value.
+func NewDataTXOutput(index int, data []byte) (*TXOutput, error) {
+	pkScript, err := script.NullDataScript(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewScriptTXOutput(index, 0, pkScript), nil
+}
+
 // TXOutputs collects TXOutput.
 type TXOutputs struct {
 	Outputs []TXOutput
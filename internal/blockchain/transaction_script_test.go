@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSpendTx spends the output idx of prev paying value to the address to.
+func newSpendTx(prev *Transaction, idx, value int, to string) *Transaction {
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: prev.TxID, Vout: idx}},
+		Vout: []TXOutput{*NewTXOutput(0, value, to)},
+	}
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	return tx
+}
+
+func condOf(txs ...*Transaction) *TransactionVerifyCond {
+	cond := &TransactionVerifyCond{Outputs: make(map[string][]TXOutput)}
+	for _, tx := range txs {
+		cond.Outputs[tx.TxID] = tx.Vout
+	}
+	return cond
+}
+
+// nolint: funlen
+func TestTransaction_VerifyScripts(t *testing.T) {
+	wallet := NewWallet()
+	to := NewWallet().GetAddress()
+	pkScript, err := script.PayToPubKeyScript(wallet.PublicKey)
+	assert.Nil(t, err)
+
+	prev := &Transaction{
+		Vin: []TXInput{{Txid: "", Vout: -1, PubKey: []byte("prev")}},
+		Vout: []TXOutput{
+			*NewTXOutput(0, 5, wallet.GetAddress()),
+			*NewScriptTXOutput(1, 5, pkScript),
+			// made before scripts
+			{Index: 2, Value: 5, PubKeyHash: NewTXOutput(0, 5, wallet.GetAddress()).PubKeyHash},
+		},
+	}
+	prev.TxID = hex.EncodeToString(prev.Hash()[:])
+	assert.Equal(t, script.PubKeyHashTy, script.GetClass(prev.Vout[0].LockingScript()))
+	assert.Equal(t, prev.Vout[0].LockingScript(), prev.Vout[2].LockingScript())
+	assert.Equal(t, prev.Vout[0].PubKeyHash, prev.Vout[1].PubKeyHash)
+	assert.Equal(t, wallet.GetAddress(), prev.Vout[1].Address())
+
+	for idx := range prev.Vout {
+		tx := newSpendTx(prev, idx, 4, to)
+		assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(prev)))
+		assert.Nil(t, tx.Check())
+		assert.Nil(t, tx.Verify(condOf(prev)))
+	}
+	spend := newSpendTx(prev, 1, 4, to)
+	assert.Nil(t, spend.Sign(wallet.PrivateKey, condOf(prev)))
+	assert.NotEmpty(t, spend.Vin[0].SigScript)
+	assert.Empty(t, spend.Vin[0].Signature)
+
+	// another key does not unlock
+	other := NewWallet()
+	for idx := range prev.Vout {
+		tx := newSpendTx(prev, idx, 4, to)
+		assert.Nil(t, tx.Sign(other.PrivateKey, condOf(prev)))
+		assert.True(t, IsErrorCode(tx.Verify(condOf(prev)), ErrScriptFailed))
+	}
+
+	// the signature commits to the outputs
+	spend.Vout[0].Value = 5
+	assert.True(t, IsErrorCode(spend.Verify(condOf(prev)), ErrScriptFailed))
+	spend.Vout[0].Value = 4
+	spend.Vin[0].SigScript = []byte{script.OpTrue}
+	assert.True(t, IsErrorCode(spend.Verify(condOf(prev)), ErrScriptFailed))
+	spend.Vin[0].SigScript = []byte{script.OpTrue, script.OpDup}
+	assert.True(t, IsErrorCode(spend.Verify(condOf(prev)), ErrScriptFailed))
+	spend.Vin[0].SigScript = nil
+	assert.True(t, IsErrorCode(spend.Check(), ErrNoTxSignature))
+}
+
+func TestTransaction_CheckOutputs(t *testing.T) {
+	address := NewWallet().GetAddress()
+	dataScript, err := script.NullDataScript([]byte("hello"))
+	assert.Nil(t, err)
+
+	tx := NewCoinbaseTX(address, "")
+	tx.Vout = append(tx.Vout, *NewScriptTXOutput(1, 0, dataScript))
+	assert.Nil(t, tx.Check())
+	assert.Empty(t, tx.Vout[1].Address())
+
+	tx.Vout[0].Value = 0
+	assert.True(t, IsErrorCode(tx.Check(), ErrBadTxOutValue))
+	tx.Vout[0].Value = Subsidy
+	tx.Vout[0].PubKeyHash = NewTXOutput(0, 1, NewWallet().GetAddress()).PubKeyHash
+	assert.True(t, IsErrorCode(tx.Check(), ErrBadTxOutScript))
+	tx.Vout[0] = TXOutput{Index: 0, Value: Subsidy}
+	assert.True(t, IsErrorCode(tx.Check(), ErrNoTxOutPubKeyHash))
+}
+
+func TestBlockChains_UnspendableOutputs(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	dataScript, err := script.NullDataScript([]byte("hello"))
+	assert.Nil(t, err)
+	coinbase := NewCoinbaseTX(NewWallet().GetAddress(), "")
+	coinbase.Vout = append(coinbase.Vout, *NewScriptTXOutput(1, 0, dataScript))
+	coinbase.TxID = hex.EncodeToString(coinbase.Hash()[:])
+	assert.Nil(t, bcs.AddBlock(MineBlock([]*Transaction{coinbase}, bcs.GetLatestBlock().Hash)))
+
+	assert.NotNil(t, bcs.GetUTXO(coinbase.TxID, 0))
+	assert.Nil(t, bcs.GetUTXO(coinbase.TxID, 1))
+	assert.Nil(t, bcs.ReindexUTXO())
+	assert.NotNil(t, bcs.GetUTXO(coinbase.TxID, 0))
+	assert.Nil(t, bcs.GetUTXO(coinbase.TxID, 1))
+}
+
+func TestTransaction_Verify_MalleatedSignature(t *testing.T) {
+	wallet := NewWallet()
+	prev := NewCoinbaseTX(wallet.GetAddress(), "prev")
+	tx := newSpendTx(prev, 0, Subsidy, NewWallet().GetAddress())
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(prev)))
+	assert.Nil(t, tx.Verify(condOf(prev)))
+	assert.Len(t, tx.Vin[0].PubKey, utils.PubKeyBytesLenCompressed)
+
+	sig := tx.Vin[0].Signature
+	r, s, err := utils.ParseSignature(sig[:len(sig)-1])
+	assert.Nil(t, err)
+	highS := new(big.Int).Sub(elliptic.P256().Params().N, s)
+	for _, malleated := range [][]byte{
+		append(utils.SerializeSignature(r, highS), sig[len(sig)-1]),
+		append(append(append([]byte{}, sig[:len(sig)-1]...), 0), sig[len(sig)-1]),
+	} {
+		tx.Vin[0].Signature = malleated
+		assert.True(t, IsErrorCode(tx.Verify(condOf(prev)), ErrScriptFailed))
+	}
+}
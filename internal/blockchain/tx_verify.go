@@ -0,0 +1,207 @@
+package blockchain
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+// sigVerifyWorkers bounds the goroutines checking the scripts of a batch of transactions.
+var sigVerifyWorkers = runtime.NumCPU()
+
+// scriptJob is the check of the script of an input, digest is its entry in the signature cache.
+type scriptJob struct {
+	tx        *Transaction
+	inID      int
+	prevout   *TXOutput
+	sigHashes *TxSigHashes
+	digest    chainhash.Hash
+}
+
+// GetConds4TransactionsVerify looks up the outputs the transactions spend in the UTXO set, all of them in one
+// read, and returns the conditions of the transactions in their order. The transactions are taken as applied
+// one after the other: a transaction may spend the outputs of one before it, and an output spent twice is an
+// ErrSpentTxOut.
+func (bcs *BlockChains) GetConds4TransactionsVerify(txs []*Transaction) ([]*TransactionVerifyCond, error) {
+	conds := make([]*TransactionVerifyCond, len(txs))
+	err := bcs.db.View(func(dbTx db.Tx) error {
+		bucket := dbTx.Bucket(utxoBucketName)
+		// the outputs of a transaction are decoded once however many inputs spend them, the outputs of the
+		// transactions looked up already overlay the UTXO set
+		fetched := make(map[string]*TXOutputs)
+		spent := make(map[OutPoint]bool)
+		for idx, transaction := range txs {
+			outputs := make(map[string][]TXOutput)
+			if !transaction.IsCoinbase() {
+				for _, input := range transaction.Vin {
+					op := OutPoint{input.Txid, input.Vout}
+					if spent[op] {
+						return ruleError(ErrSpentTxOut, fmt.Sprintf("input spent twice: %s,%d", input.Txid, input.Vout))
+					}
+					output := lookupUTXO(bucket, fetched, input.Txid, input.Vout)
+					if output == nil {
+						return ruleError(ErrMissingTxOut, fmt.Sprintf("no input: %s,%d", input.Txid, input.Vout))
+					}
+					spent[op] = true
+					outputs[input.Txid] = append(outputs[input.Txid], *output)
+				}
+			}
+			conds[idx] = &TransactionVerifyCond{Outputs: outputs}
+			fetched[transaction.TxID] = spendableOutputs(transaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conds, nil
+}
+
+// spendableOutputs returns the outputs of tx the UTXO set keeps, nil when there are none.
+func spendableOutputs(tx *Transaction) *TXOutputs {
+	outputs := TXOutputs{}
+	for _, out := range tx.Vout {
+		if !script.IsUnspendable(out.LockingScript()) {
+			outputs.Outputs = append(outputs.Outputs, out)
+		}
+	}
+	if len(outputs.Outputs) == 0 {
+		return nil
+	}
+	return &outputs
+}
+
+func lookupUTXO(bucket db.Bucket, fetched map[string]*TXOutputs, txID string, index int) *TXOutput {
+	outputs, ok := fetched[txID]
+	if !ok {
+		var err error
+		outputs, err = DeserializeOutputs(bucket.Get([]byte(txID)))
+		if err != nil {
+			outputs = nil
+		}
+		fetched[txID] = outputs
+	}
+	if outputs == nil {
+		return nil
+	}
+	for idx := range outputs.Outputs {
+		if outputs.Outputs[idx].Index == index {
+			return &outputs.Outputs[idx]
+		}
+	}
+	return nil
+}
+
+// VerifyTransaction verifies tx against the UTXO set, the inputs which pass are added to the signature cache.
+func (bcs *BlockChains) VerifyTransaction(tx *Transaction) error {
+	conds, err := bcs.GetConds4TransactionsVerify([]*Transaction{tx})
+	if err != nil {
+		return err
+	}
+	_, err = bcs.verifyTransactions([]*Transaction{tx}, conds)
+	return err
+}
+
+// SigCache returns the cache of the inputs verified already.
+func (bcs *BlockChains) SigCache() *SigCache {
+	return bcs.sigCache
+}
+
+// verifyTransactions verifies txs against conds, the outputs they spend, as Transaction.Verify does, and checks
+// the signature operations of each of them with its redeem scripts, which it returns. The scripts of the inputs
+// missing from the signature cache run on a pool of sigVerifyWorkers goroutines, the error is the one of the first
+// failing input.
+func (bcs *BlockChains) verifyTransactions(txs []*Transaction, conds []*TransactionVerifyCond) ([]int, error) {
+	jobs := make([]scriptJob, 0)
+	spent := make([][]*TXOutput, len(txs))
+	sigOps := make([]int, len(txs))
+	for idx, tx := range txs {
+		if tx.IsCoinbase() {
+			sigOps[idx] = tx.SigOpCount()
+			continue
+		}
+		if conds[idx] == nil {
+			return nil, fmt.Errorf("no condition transactions of %s", tx.TxID)
+		}
+		prevouts, err := tx.spentOutputs(conds[idx])
+		if err != nil {
+			return nil, err
+		}
+		spent[idx] = prevouts
+		sigOps[idx] = tx.sigOpCountSpending(prevouts)
+		err = tx.checkSigOps(sigOps[idx], bcs.params)
+		if err != nil {
+			return nil, err
+		}
+
+		sigHashes := NewTxSigHashes(tx)
+		txHash := chainhash.HashH(tx.Serialize())
+		for inID, prevout := range prevouts {
+			digest := sigCacheDigest(txHash, inID, prevout)
+			if bcs.sigCache.Exists(tx.TxID, inID, digest) {
+				continue
+			}
+			jobs = append(jobs, scriptJob{tx: tx, inID: inID, prevout: prevout, sigHashes: sigHashes, digest: digest})
+		}
+	}
+
+	errs := runScriptJobs(jobs)
+	for idx, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		bcs.sigCache.Add(jobs[idx].tx.TxID, jobs[idx].inID, jobs[idx].digest)
+	}
+
+	for idx, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
+		err := tx.checkSpend(spent[idx])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sigOps, nil
+}
+
+// runScriptJobs checks the scripts of jobs on the workers and returns their errors. Once one fails the jobs
+// left are not started, the jobs before it all ran so the first error is the one of the first failing job.
+func runScriptJobs(jobs []scriptJob) []error {
+	errs := make([]error, len(jobs))
+	workers := sigVerifyWorkers
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	var failed int32
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range next {
+				job := &jobs[idx]
+				errs[idx] = job.tx.verifyInput(job.inID, job.prevout, job.sigHashes)
+				if errs[idx] != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	for idx := range jobs {
+		if atomic.LoadInt32(&failed) != 0 {
+			break
+		}
+		next <- idx
+	}
+	close(next)
+	wg.Wait()
+	return errs
+}
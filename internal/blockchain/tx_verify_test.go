@@ -0,0 +1,168 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigCache(t *testing.T) {
+	cache := NewSigCache(2)
+	digest := chainhash.HashH([]byte("digest"))
+	other := chainhash.HashH([]byte("other"))
+	assert.False(t, cache.Exists("tx", 0, digest))
+	cache.Add("tx", 0, digest)
+	assert.True(t, cache.Exists("tx", 0, digest))
+	assert.False(t, cache.Exists("tx", 0, other))
+	assert.False(t, cache.Exists("tx", 1, digest))
+
+	// replacing an entry does not evict, a new one beyond the size does
+	cache.Add("tx", 0, other)
+	cache.Add("tx", 1, digest)
+	assert.Equal(t, 2, cache.Len())
+	assert.True(t, cache.Exists("tx", 0, other))
+	cache.Add("tx", 2, digest)
+	assert.Equal(t, 2, cache.Len())
+	assert.True(t, cache.Exists("tx", 2, digest))
+
+	empty := NewSigCache(0)
+	empty.Add("tx", 0, digest)
+	assert.False(t, empty.Exists("tx", 0, digest))
+}
+
+// fundedTxs mines n blocks paying a new wallet and returns a signed transaction spending each coinbase.
+func fundedTxs(t *testing.T, bcs *BlockChains, n int) []*Transaction {
+	wallet := NewWallet()
+	to := NewWallet().GetAddress()
+	txs := make([]*Transaction, 0, n)
+	for i := 0; i < n; i++ {
+		coinbase := NewCoinbaseTX(wallet.GetAddress(), fmt.Sprintf("funded %d", i))
+		assert.Nil(t, bcs.AddBlock(MineBlock([]*Transaction{coinbase}, bcs.GetLatestBlock().Hash)))
+		tx := newSpendTx(coinbase, 0, Subsidy, to)
+		assert.Nil(t, tx.Sign(wallet.PrivateKey, condOf(coinbase)))
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func TestBlockChains_VerifyTransaction_SigCache(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+	tx := fundedTxs(t, bcs, 1)[0]
+
+	assert.Nil(t, bcs.VerifyTransaction(tx))
+	assert.Equal(t, 1, bcs.SigCache().Len())
+
+	// the same id with another signature misses the cache and fails
+	malleated := *tx
+	malleated.Vin = append([]TXInput(nil), tx.Vin...)
+	malleated.Vin[0].Signature = append([]byte(nil), tx.Vin[0].Signature...)
+	malleated.Vin[0].Signature[10] ^= 1
+	assert.True(t, IsErrorCode(bcs.VerifyTransaction(&malleated), ErrScriptFailed))
+	assert.Equal(t, 1, bcs.SigCache().Len())
+
+	// the block of the transaction verified by the pool passes from the cache
+	block := MineBlock([]*Transaction{NewCoinbaseTX(tx.Vout[0].Address(), "block"), tx}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(block))
+	assert.Equal(t, block.Hash, bcs.GetLatestBlock().Hash)
+
+	// the outputs are spent now
+	assert.True(t, IsErrorCode(bcs.VerifyTransaction(tx), ErrMissingTxOut))
+}
+
+func TestBlockChains_VerifyTransactions_Parallel(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+	workers := sigVerifyWorkers
+	sigVerifyWorkers = 4
+	defer func() { sigVerifyWorkers = workers }()
+
+	txs := fundedTxs(t, bcs, 12)
+	conds, err := bcs.GetConds4TransactionsVerify(txs)
+	assert.Nil(t, err)
+	assert.Len(t, conds, len(txs))
+
+	// the error is the one of the first failing input, the inputs before it are cached
+	for _, idx := range []int{5, 9} {
+		txs[idx].Vin[0].Signature[10] ^= 1
+	}
+	for i := 0; i < 8; i++ {
+		_, err = bcs.verifyTransactions(txs, conds)
+		assert.True(t, IsErrorCode(err, ErrScriptFailed))
+		assert.Equal(t, 5, bcs.SigCache().Len())
+	}
+	for _, idx := range []int{5, 9} {
+		txs[idx].Vin[0].Signature[10] ^= 1
+	}
+	sigOps, err := bcs.verifyTransactions(txs, conds)
+	assert.Nil(t, err)
+	assert.Len(t, sigOps, len(txs))
+	assert.Equal(t, len(txs), bcs.SigCache().Len())
+
+	// the amounts are checked as Transaction.Verify does
+	txs[3].Vout[0].Value++
+	_, err = bcs.verifyTransactions(txs, conds)
+	assert.True(t, IsErrorCode(err, ErrScriptFailed))
+	txs[3].Vin[0].Amount++
+	_, err = bcs.verifyTransactions(txs, conds)
+	assert.True(t, IsErrorCode(err, ErrAmountMismatch))
+
+	// the outputs of the transactions before are spendable, once
+	chained := newSpendTx(txs[0], 0, 1, txs[0].Vout[0].Address())
+	conds, err = bcs.GetConds4TransactionsVerify(append(txs, chained))
+	assert.Nil(t, err)
+	assert.Equal(t, txs[0].Vout, conds[len(txs)].Outputs[txs[0].TxID])
+	_, err = bcs.GetConds4TransactionsVerify(append(txs, chained, newSpendTx(txs[0], 0, 1, chained.Vout[0].Address())))
+	assert.True(t, IsErrorCode(err, ErrSpentTxOut))
+	_, err = bcs.GetConds4TransactionsVerify(append([]*Transaction{chained}, txs...))
+	assert.True(t, IsErrorCode(err, ErrMissingTxOut))
+	_, err = bcs.GetConds4TransactionsVerify(append(txs, newSpendTx(txs[0], 1, 1, chained.Vout[0].Address())))
+	assert.True(t, IsErrorCode(err, ErrMissingTxOut))
+}
+
+// a block spends the outputs of a transaction before it, in the block or in a block connected with it
+func TestBlockChains_AddBlock_ChainedSpend(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+	w1, w2, w3 := NewWallet(), NewWallet(), NewWallet()
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(w1.GetAddress(), "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	tx1 := newSpendTx(b1.Transactions[0], 0, Subsidy, w2.GetAddress())
+	assert.Nil(t, tx1.Sign(w1.PrivateKey, condOf(b1.Transactions[0])))
+	tx2 := newSpendTx(tx1, 0, Subsidy, w3.GetAddress())
+	assert.Nil(t, tx2.Sign(w2.PrivateKey, condOf(tx1)))
+
+	// the same output spent twice in a block
+	double := newSpendTx(b1.Transactions[0], 0, Subsidy, w3.GetAddress())
+	assert.Nil(t, double.Sign(w1.PrivateKey, condOf(b1.Transactions[0])))
+	bad := MineBlock([]*Transaction{NewCoinbaseTX(w1.GetAddress(), "bad"), tx1, double}, b1.Hash)
+	assert.True(t, IsErrorCode(bcs.AddBlock(bad), ErrSpentTxOut))
+	assert.Equal(t, b1.Hash, bcs.GetLatestBlock().Hash)
+
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(w1.GetAddress(), "b2"), tx1, tx2}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	assert.Equal(t, b2.Hash, bcs.GetLatestBlock().Hash)
+	assert.Equal(t, 0, bcs.GetBalance(w2.GetAddress()))
+	assert.Equal(t, Subsidy, bcs.GetBalance(w3.GetAddress()))
+
+	// b4 spends the output of b3, it waits for it as an orphan and they are connected together
+	tx3 := newSpendTx(tx2, 0, Subsidy, w1.GetAddress())
+	assert.Nil(t, tx3.Sign(w3.PrivateKey, condOf(tx2)))
+	b3 := MineBlock([]*Transaction{NewCoinbaseTX(w1.GetAddress(), "b3"), tx3}, b2.Hash)
+	tx4 := newSpendTx(tx3, 0, Subsidy, w2.GetAddress())
+	assert.Nil(t, tx4.Sign(w1.PrivateKey, condOf(tx3)))
+	b4 := MineBlock([]*Transaction{NewCoinbaseTX(w1.GetAddress(), "b4"), tx4}, b3.Hash)
+	assert.Nil(t, bcs.AddBlock(b4))
+	assert.True(t, bcs.IsOrphan(b4.Hash))
+	assert.Nil(t, bcs.AddBlock(b3))
+	assert.Equal(t, b4.Hash, bcs.GetLatestBlock().Hash)
+	assert.Equal(t, Subsidy, bcs.GetBalance(w2.GetAddress()))
+	assert.Equal(t, 0, bcs.GetBalance(w3.GetAddress()))
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/boltdb/bolt"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
 	"github.com/jiuzhou-zhao/go-fundamental/loge"
@@ -55,24 +56,41 @@ func (bcs *BlockChains) ScanUTXO(pubkeyHash []byte, cb func(txID string, output
 	}
 }
 
-type UTXOFilter func(txID string, output TXOutput) bool
-
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs.
-func (bcs *BlockChains) FindSpendableOutputs(pubkeyHash []byte, amount int,
-	blockUTXO UTXOFilter) (int, map[string][]TXOutput) {
-	unspentOutputs := make(map[string][]TXOutput)
-	accumulated := 0
+// ListCoins returns the unlocked unspent outputs of pubKeyHash with the heights of their blocks.
+func (bcs *BlockChains) ListCoins(pubKeyHash []byte, locks *CoinLocks) []Coin {
+	coins := make([]Coin, 0)
+	bcs.ScanUTXO(pubKeyHash, func(txID string, output TXOutput) bool {
+		if !locks.IsLocked(OutPoint{txID, output.Index}) {
+			coins = append(coins, Coin{TxID: txID, Output: output})
+		}
+		return true
+	})
 
-	bcs.ScanUTXO(pubkeyHash, func(txID string, output TXOutput) bool {
-		if blockUTXO != nil && blockUTXO(txID, output) {
-			return true
+	err := bcs.db.View(func(tx db.Tx) error {
+		b := tx.Bucket(txBucketName)
+		for idx := range coins {
+			coins[idx].Height, _ = strconv.ParseInt(string(b.Get([]byte(coins[idx].TxID))), 10, 64)
 		}
-		accumulated += output.Value
-		unspentOutputs[txID] = append(unspentOutputs[txID], output)
-		return accumulated < amount
+		return nil
 	})
+	if err != nil {
+		loge.Errorf(nil, "db failed: %v", err)
+	}
+	return coins
+}
 
-	return accumulated, unspentOutputs
+// FindSpendableOutputs selects the unlocked outputs of the pubkey hashes paying params with selector, the
+// DefaultCoinSelector when it is nil.
+func (bcs *BlockChains) FindSpendableOutputs(pubKeyHashes [][]byte, params SelectionParams, selector CoinSelector,
+	locks *CoinLocks) (*CoinSelection, error) {
+	if selector == nil {
+		selector = DefaultCoinSelector
+	}
+	coins := make([]Coin, 0)
+	for _, pubKeyHash := range pubKeyHashes {
+		coins = append(coins, bcs.ListCoins(pubKeyHash, locks)...)
+	}
+	return selector.Select(coins, params)
 }
 
 // FindUTXOByPubKeyHash finds UTXO for a public key hash.
@@ -94,11 +112,17 @@ func (bcs *BlockChains) UpdateUTXOInTx(block *Block, tx db.Tx) error {
 
 	for _, tx := range block.Transactions {
 		newOutputs := TXOutputs{}
-		newOutputs.Outputs = append(newOutputs.Outputs, tx.Vout...)
+		for _, out := range tx.Vout {
+			if !script.IsUnspendable(out.LockingScript()) {
+				newOutputs.Outputs = append(newOutputs.Outputs, out)
+			}
+		}
 
-		err := b.Put([]byte(tx.TxID), newOutputs.Serialize())
-		if err != nil {
-			return err
+		if len(newOutputs.Outputs) > 0 {
+			err := b.Put([]byte(tx.TxID), newOutputs.Serialize())
+			if err != nil {
+				return err
+			}
 		}
 
 		if tx.IsCoinbase() {
@@ -134,10 +158,14 @@ func (bcs *BlockChains) UpdateUTXOInTx(block *Block, tx db.Tx) error {
 	return nil
 }
 
-//  ReindexUTXOOnTx rebuilds the UTXO set
+//  ReindexUTXO rebuilds the UTXO set and the data index
 func (bcs *BlockChains) ReindexUTXO() error {
 	return bcs.db.Update(func(tx db.Tx) error {
-		return bcs.ReindexUTXOOnTx(tx)
+		err := bcs.ReindexUTXOOnTx(tx)
+		if err != nil {
+			return err
+		}
+		return bcs.reindexDataOnTx(tx)
 	})
 }
 
@@ -191,6 +219,7 @@ func (bcs *BlockChains) GetTXOChangeUtil(height int64) (deletedTx map[string]int
 		if err != nil {
 			return err
 		}
+		blocks := make(map[int64]*Block)
 		for idx := height + 1; idx <= maxHeight; idx++ {
 			block := bcs.getBlockByHeightOnTX(tx, idx)
 			for _, transaction := range block.Transactions {
@@ -200,11 +229,11 @@ func (bcs *BlockChains) GetTXOChangeUtil(height int64) (deletedTx map[string]int
 						if _, ok := deletedTx[input.Txid]; ok {
 							continue
 						}
-						uTx[input.Txid] = append(uTx[input.Txid], TXOutput{
-							Index:      input.Vout,
-							Value:      input.Amount,
-							PubKeyHash: input.PubKey,
-						})
+						output, errFind := bcs.findOutputOnTx(tx, input.Txid, input.Vout, blocks)
+						if errFind != nil {
+							return errFind
+						}
+						uTx[input.Txid] = append(uTx[input.Txid], *output)
 					}
 				}
 			}
@@ -214,18 +243,66 @@ func (bcs *BlockChains) GetTXOChangeUtil(height int64) (deletedTx map[string]int
 	return
 }
 
-func (bcs *BlockChains) GetUTXO(txID string, outIndex int) (output *TXOutput) {
-	_ = bcs.db.View(func(tx db.Tx) error {
-		b := tx.Bucket(utxoBucketName)
-		d := b.Get([]byte(txID))
-		if d == nil {
-			c := b.Cursor()
+// spentOutputsOnTx returns the outputs the transactions of block spend, in the order of their inputs, read from
+// the main chain blocks holding them. blocks caches the blocks read by height.
+func (bcs *BlockChains) spentOutputsOnTx(tx db.Tx, block *Block, blocks map[int64]*Block) ([]UTXOEntry, error) {
+	spent := make([]UTXOEntry, 0)
+	for _, transaction := range block.Transactions {
+		if transaction.IsCoinbase() {
+			continue
+		}
+		for _, input := range transaction.Vin {
+			output, err := bcs.findOutputOnTx(tx, input.Txid, input.Vout, blocks)
+			if err != nil {
+				return nil, err
+			}
+			spent = append(spent, UTXOEntry{TxID: input.Txid, Output: *output})
+		}
+	}
+	return spent, nil
+}
 
-			for k, _ := c.First(); k != nil; k, _ = c.Next() {
-				fmt.Println(string(k))
+// findOutputOnTx returns the output index of the main chain transaction txID, spent or not.
+func (bcs *BlockChains) findOutputOnTx(tx db.Tx, txID string, index int, blocks map[int64]*Block) (*TXOutput, error) {
+	height, err := strconv.ParseInt(string(tx.Bucket(txBucketName).Get([]byte(txID))), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("no main chain transaction %s", txID)
+	}
+	block, ok := blocks[height]
+	if !ok {
+		block = bcs.getBlockByHeightOnTX(tx, height)
+		if block == nil {
+			return nil, fmt.Errorf("no main chain block at height %d", height)
+		}
+		blocks[height] = block
+	}
+	for _, transaction := range block.Transactions {
+		if transaction.TxID != txID {
+			continue
+		}
+		for idx := range transaction.Vout {
+			if transaction.Vout[idx].Index == index {
+				return &transaction.Vout[idx], nil
 			}
 		}
-		ots, err := DeserializeOutputs(d)
+	}
+	return nil, fmt.Errorf("no output %d of transaction %s", index, txID)
+}
+
+// SpentOutputs returns the outputs the transactions of the main chain block spend, in the order of their inputs.
+func (bcs *BlockChains) SpentOutputs(block *Block) (spent []UTXOEntry, err error) {
+	err = bcs.db.View(func(tx db.Tx) error {
+		var errFind error
+		spent, errFind = bcs.spentOutputsOnTx(tx, block, make(map[int64]*Block))
+		return errFind
+	})
+	return
+}
+
+func (bcs *BlockChains) GetUTXO(txID string, outIndex int) (output *TXOutput) {
+	_ = bcs.db.View(func(tx db.Tx) error {
+		b := tx.Bucket(utxoBucketName)
+		ots, err := DeserializeOutputs(b.Get([]byte(txID)))
 		if err != nil {
 			return err
 		}
@@ -3,105 +3,396 @@ package blockchain
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 )
 
 const (
 	version    = byte(0x00)
 	walletFile = "wallet.dat"
+	// scriptHashVersion is the version byte of the addresses paying to the hash of a redeem script.
+	scriptHashVersion = byte(0x05)
+
+	walletFileVersion = 1
+)
+
+var (
+	ErrWalletLocked       = errors.New("wallet is locked")
+	ErrWalletNotEncrypted = errors.New("wallet is not encrypted")
+	ErrWalletEncrypted    = errors.New("wallet is already encrypted")
+	ErrWrongPassphrase    = errors.New("wrong passphrase")
 )
 
-// Wallet stores private and public keys.
+// Wallet stores private and public keys, Path is the derivation path of HD keys.
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	Path       []uint32
 }
 
-//
+// Wallets holds the keys of the wallet file, the watch-only addresses and the redeem scripts of the multisig
+// addresses. Once encrypted the private keys are only kept in memory between Unlock and Lock.
 type Wallets struct {
 	Wallets map[string]*Wallet
+
+	lock       sync.Mutex
+	file       string
+	crypter    *walletCrypter
+	cryptedKey map[string]*cryptedKey
+	lockTimer  *time.Timer
+	hd         *hdChain
+	watchOnly  map[string]*WatchOnly
+	scripts    map[string][]byte
+	txs        map[string]*WalletTx
+	syncHash   chainhash.Hash
+	syncHeight int64
+	labels     map[string]string
+}
+
+// walletData is the content of the wallet file.
+type walletData struct {
+	Version   int
+	KDF       *kdfParams
+	Keys      []walletKeyData
+	HD        *hdData
+	History   *walletHistory
+	WatchOnly []*WatchOnly
+	Scripts   [][]byte
+}
+
+// walletKeyData holds the private key in clear, or encrypted when the wallet is.
+type walletKeyData struct {
+	PublicKey  []byte
+	PrivateKey []byte
+	Crypted    *cryptedKey
+	Path       []uint32
+}
+
+// legacyWalletData is the plaintext format of the wallet file before encryption was supported, the
+// gob encoded Wallets. Only the private scalar is decoded, the rest of the key is derived from it.
+type legacyWalletData struct {
+	Wallets map[string]*legacyWallet
 }
 
-// creates Wallets and fills it form a file f it exists.
-func NewWallets() (*Wallets, error) {
-	wallets := Wallets{}
-	wallets.Wallets = make(map[string]*Wallet)
+type legacyWallet struct {
+	PrivateKey struct {
+		D *big.Int
+	}
+	PublicKey []byte
+}
+
+// NewWalletsFromFile creates Wallets kept in file, the error wraps os.ErrNotExist for a new file.
+func NewWalletsFromFile(file string) (*Wallets, error) {
+	wallets := Wallets{
+		file:      file,
+		Wallets:   make(map[string]*Wallet),
+		watchOnly: make(map[string]*WatchOnly),
+		scripts:   make(map[string][]byte),
+		txs:       make(map[string]*WalletTx),
+		labels:    make(map[string]string),
+	}
 	err := wallets.LoadFromFile()
 	return &wallets, err
 }
 
-// adds a Wallet to Wallets.
-func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+// adds the next HD key to Wallets, the wallet has to be unlocked when it is encrypted. A wallet without
+// HD seed gets a new one, see Mnemonic for its backup.
+func (ws *Wallets) CreateWallet() (string, error) {
+	return ws.CreateWalletWithScheme(utils.SchemeP256)
+}
+
+// CreateWalletWithScheme adds the next HD key as a key of scheme, its address tells the scheme from the key
+// it hashes.
+func (ws *Wallets) CreateWalletWithScheme(scheme utils.Scheme) (string, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.hd == nil {
+		secret, err := newHDSecret()
+		if err != nil {
+			return "", err
+		}
+		err = ws.setHDSeedLocked(secret)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	wallet, err := ws.nextHDWalletLocked(ExternalBranch, scheme)
+	if err != nil {
+		return "", err
+	}
+	return wallet.GetAddress(), nil
+}
+
+// addWalletLocked adds wallet, encrypting its private key when the wallet is encrypted. A watch-only entry
+// of the address is replaced.
+func (ws *Wallets) addWalletLocked(wallet *Wallet) error {
 	address := wallet.GetAddress()
+	if ws.crypter != nil {
+		if ws.crypter.key == nil {
+			return ErrWalletLocked
+		}
+		ck, err := ws.crypter.encrypt(wallet.PublicKey, wallet.PrivateKey.D.Bytes())
+		if err != nil {
+			return err
+		}
+		ws.cryptedKey[address] = ck
+	}
 	ws.Wallets[address] = wallet
-	return address
+	delete(ws.watchOnly, address)
+	return nil
 }
 
-// return s an array of addresses stored in the wallet file.
+// return s an array of addresses stored in the wallet file, the watch-only and multisig ones included.
 func (ws *Wallets) GetAddresses() []string {
-	addresses := make([]string, 0, len(ws.Wallets))
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	addresses := make([]string, 0, len(ws.Wallets)+len(ws.watchOnly)+len(ws.scripts))
 	for address := range ws.Wallets {
 		addresses = append(addresses, address)
 	}
+	for address := range ws.watchOnly {
+		addresses = append(addresses, address)
+	}
+	for address := range ws.scripts {
+		addresses = append(addresses, address)
+	}
 
 	return addresses
 }
 
-// returns a Wallet by its address.
-func (ws Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+// returns a Wallet by its address, the private key is missing while the wallet is locked.
+func (ws *Wallets) GetWallet(address string) (*Wallet, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	return ws.getWalletLocked(address)
+}
+
+func (ws *Wallets) getWalletLocked(address string) (*Wallet, error) {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		if _, watched := ws.watchOnly[address]; watched {
+			return nil, fmt.Errorf("%w: %s", ErrWatchOnly, address)
+		}
+		if _, ok := ws.scripts[address]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrMultiSig, address)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrAddressNotInWallet, address)
+	}
+	w := *wallet
+	return &w, nil
+}
+
+// GetSigningWallet returns the Wallet of the address with its private key, which requires an unlocked wallet.
+func (ws *Wallets) GetSigningWallet(address string) (*Wallet, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	wallet, err := ws.getWalletLocked(address)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.PrivateKey.D == nil {
+		return nil, ErrWalletLocked
+	}
+	return wallet, nil
 }
 
 // loads wallets from the file.
 func (ws *Wallets) LoadFromFile() error {
-	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
+	if ws.file == "" {
+		ws.file = walletFile
+	}
+	if _, err := os.Stat(ws.file); os.IsNotExist(err) {
 		return fmt.Errorf("%w", err)
 	}
-	fileContent, err := ioutil.ReadFile(walletFile)
+	fileContent, err := ioutil.ReadFile(ws.file)
 	if err != nil {
 		log.Panic(err)
 	}
-	var wallets Wallets
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
-	if err != nil {
-		log.Panic(err)
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	ws.txs = make(map[string]*WalletTx)
+	ws.labels = make(map[string]string)
+	ws.watchOnly = make(map[string]*WatchOnly)
+	ws.scripts = make(map[string][]byte)
+	var data walletData
+	err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&data)
+	if err != nil || data.Version == 0 {
+		// migrate the plaintext files written before encryption
+		var legacy legacyWalletData
+		err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&legacy)
+		if err != nil {
+			log.Panic(err)
+		}
+		ws.Wallets = make(map[string]*Wallet, len(legacy.Wallets))
+		for address, wallet := range legacy.Wallets {
+			ws.Wallets[address] = &Wallet{
+				PrivateKey: privateKeyFromBytes(wallet.PrivateKey.D.Bytes(), wallet.PublicKey),
+				PublicKey:  wallet.PublicKey,
+			}
+		}
+		return nil
+	}
+	if data.Version != walletFileVersion {
+		return fmt.Errorf("unknown wallet file version %d", data.Version)
+	}
+
+	ws.Wallets = make(map[string]*Wallet)
+	if data.KDF != nil {
+		ws.crypter = &walletCrypter{params: *data.KDF}
+		ws.cryptedKey = make(map[string]*cryptedKey)
+	}
+	for _, key := range data.Keys {
+		address := utils.Pubkey2Address(key.PublicKey, version)
+		wallet := &Wallet{PublicKey: key.PublicKey, Path: key.Path}
+		if key.Crypted != nil {
+			ws.cryptedKey[address] = key.Crypted
+			wallet.PrivateKey.Curve = wallet.Scheme().Curve()
+		} else {
+			wallet.PrivateKey = privateKeyFromBytes(key.PrivateKey, key.PublicKey)
+		}
+		ws.Wallets[address] = wallet
+	}
+	for _, w := range data.WatchOnly {
+		ws.watchOnly[w.GetAddress()] = w
+	}
+	for _, redeemScript := range data.Scripts {
+		ws.scripts[ScriptHashAddress(redeemScript)] = redeemScript
+	}
+	if data.History != nil {
+		for _, wtx := range data.History.Txs {
+			ws.txs[wtx.TxID] = wtx
+		}
+		ws.syncHash = data.History.SyncHash
+		ws.syncHeight = data.History.SyncHeight
+		if data.History.Labels != nil {
+			ws.labels = data.History.Labels
+		}
+	}
+	if data.HD != nil {
+		ws.hd = &hdChain{crypted: data.HD.Crypted, next: data.HD.Next}
+		if data.HD.Crypted == nil {
+			secret, errSecret := decodeHDSecret(data.HD.Secret)
+			if errSecret != nil {
+				return errSecret
+			}
+			err = ws.hd.setSecret(secret)
+			if err != nil {
+				return err
+			}
+		}
 	}
-	ws.Wallets = wallets.Wallets
 	return nil
 }
 
-// saves wallets to a file.
-func (ws Wallets) SaveToFile() {
+// saves wallets to a file, replacing it atomically.
+func (ws *Wallets) SaveToFile() error {
+	ws.lock.Lock()
+	data := walletData{
+		Version: walletFileVersion,
+		Keys:    make([]walletKeyData, 0, len(ws.Wallets)),
+	}
+	if ws.crypter != nil {
+		params := ws.crypter.params
+		data.KDF = &params
+	}
+	for address, wallet := range ws.Wallets {
+		key := walletKeyData{PublicKey: wallet.PublicKey, Path: wallet.Path}
+		if ws.crypter != nil {
+			key.Crypted = ws.cryptedKey[address]
+		} else {
+			key.PrivateKey = wallet.PrivateKey.D.Bytes()
+		}
+		data.Keys = append(data.Keys, key)
+	}
+	for _, w := range ws.watchOnly {
+		data.WatchOnly = append(data.WatchOnly, w)
+	}
+	for _, redeemScript := range ws.scripts {
+		data.Scripts = append(data.Scripts, redeemScript)
+	}
+	data.History = &walletHistory{
+		Txs:        make([]*WalletTx, 0, len(ws.txs)),
+		SyncHash:   ws.syncHash,
+		SyncHeight: ws.syncHeight,
+		Labels:     ws.labels,
+	}
+	for _, wtx := range ws.txs {
+		data.History.Txs = append(data.History.Txs, wtx)
+	}
+	if ws.hd != nil {
+		data.HD = &hdData{Crypted: ws.hd.crypted, Next: ws.hd.next}
+		if ws.crypter == nil {
+			data.HD.Secret = ws.hd.secret.encode()
+		}
+	}
+	file := ws.file
+	ws.lock.Unlock()
+	if file == "" {
+		file = walletFile
+	}
+
 	var content bytes.Buffer
-	gob.Register(elliptic.P256())
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
+	err := gob.NewEncoder(&content).Encode(&data)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
-
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0600)
+	tmpFile := file + ".tmp"
+	err = ioutil.WriteFile(tmpFile, content.Bytes(), 0600)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	return os.Rename(tmpFile, file)
 }
 
 func NewWallet() *Wallet {
 	private, public := utils.NewKeyPair()
-	wallet := Wallet{private, public}
+	wallet := Wallet{PrivateKey: private, PublicKey: public}
 	return &wallet
 }
 
 func (wallet Wallet) GetAddress() string {
 	return utils.Pubkey2Address(wallet.PublicKey, version)
 }
+
+// Scheme returns the signature scheme of the key.
+func (wallet Wallet) Scheme() utils.Scheme {
+	return schemeOfKey(wallet.PublicKey)
+}
+
+// schemeOfKey returns the scheme the public key is tagged with, the keys of the legacy wallets are P256.
+func schemeOfKey(pubKey []byte) utils.Scheme {
+	scheme, err := utils.SchemeOf(pubKey)
+	if err != nil {
+		return utils.SchemeP256
+	}
+	return scheme
+}
+
+// privateKeyFromBytes rebuilds the private key of the scalar d on the curve of the scheme of pubKey.
+func privateKeyFromBytes(d, pubKey []byte) ecdsa.PrivateKey {
+	curve := schemeOfKey(pubKey).Curve()
+	key, err := utils.PrivateKeyFromScalar(curve, d)
+	if err != nil {
+		// out of the range of the curve, the key signs nothing valid
+		key.Curve, key.D = curve, new(big.Int).SetBytes(d)
+		key.X, key.Y = curve.ScalarBaseMult(d)
+	}
+	return key
+}
@@ -0,0 +1,314 @@
+package blockchain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	walletKeyLen = 32
+	saltLen      = 16
+)
+
+// checkPlaintext is encrypted along the keys, decrypting it proves the passphrase also for an empty wallet.
+var checkPlaintext = []byte("blockchain.go wallet")
+
+// kdfParams are the scrypt parameters deriving the wallet key from the passphrase.
+type kdfParams struct {
+	N     int
+	R     int
+	P     int
+	Salt  []byte
+	Check *cryptedKey
+}
+
+// cryptedKey is a private key sealed with AES-256-GCM, the public key is the additional data.
+type cryptedKey struct {
+	Nonce      []byte
+	CipherText []byte
+}
+
+// walletCrypter holds the parameters of an encrypted wallet and its key while unlocked.
+type walletCrypter struct {
+	params kdfParams
+	key    []byte
+}
+
+func newWalletCrypter(passphrase string) (*walletCrypter, error) {
+	salt := make([]byte, saltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	c := &walletCrypter{
+		params: kdfParams{N: scryptN, R: scryptR, P: scryptP, Salt: salt},
+	}
+	c.key, err = c.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	c.params.Check, err = c.encrypt(nil, checkPlaintext)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *walletCrypter) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), c.params.Salt, c.params.N, c.params.R, c.params.P, walletKeyLen)
+}
+
+// unlockKey derives the key of passphrase and checks it against the wallet.
+func (c *walletCrypter) unlockKey(passphrase string) ([]byte, error) {
+	key, err := c.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	check, err := decryptWith(key, nil, c.params.Check)
+	if err != nil || subtle.ConstantTimeCompare(check, checkPlaintext) != 1 {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+func (c *walletCrypter) encrypt(pubKey, plaintext []byte) (*cryptedKey, error) {
+	aead, err := newAEAD(c.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &cryptedKey{
+		Nonce:      nonce,
+		CipherText: aead.Seal(nil, nonce, plaintext, pubKey),
+	}, nil
+}
+
+func decryptWith(key, pubKey []byte, ck *cryptedKey) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, ck.Nonce, ck.CipherText, pubKey)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncrypted tells if the private keys are encrypted in the wallet file.
+func (ws *Wallets) IsEncrypted() bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.crypter != nil
+}
+
+// IsLocked tells if the private keys are unavailable, a plaintext wallet is never locked.
+func (ws *Wallets) IsLocked() bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.crypter != nil && ws.crypter.key == nil
+}
+
+// Encrypt encrypts the private keys with passphrase, saves the wallet file and locks the wallet.
+// This also migrates a plaintext wallet file.
+func (ws *Wallets) Encrypt(passphrase string) error {
+	ws.lock.Lock()
+	if ws.crypter != nil {
+		ws.lock.Unlock()
+		return ErrWalletEncrypted
+	}
+	crypter, err := newWalletCrypter(passphrase)
+	if err != nil {
+		ws.lock.Unlock()
+		return err
+	}
+	cryptedKeys := make(map[string]*cryptedKey, len(ws.Wallets))
+	for address, wallet := range ws.Wallets {
+		cryptedKeys[address], err = crypter.encrypt(wallet.PublicKey, wallet.PrivateKey.D.Bytes())
+		if err != nil {
+			ws.lock.Unlock()
+			return err
+		}
+	}
+	if ws.hd != nil {
+		ws.hd.crypted, err = crypter.encrypt(hdSecretAD, ws.hd.secret.encode())
+		if err != nil {
+			ws.lock.Unlock()
+			return err
+		}
+	}
+	ws.crypter = crypter
+	ws.cryptedKey = cryptedKeys
+	ws.lockLocked()
+	ws.lock.Unlock()
+
+	return ws.SaveToFile()
+}
+
+// Unlock decrypts the private keys, they are dropped again after timeout unless it is zero.
+func (ws *Wallets) Unlock(passphrase string, timeout time.Duration) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.crypter == nil {
+		return ErrWalletNotEncrypted
+	}
+	key, err := ws.crypter.unlockKey(passphrase)
+	if err != nil {
+		return err
+	}
+	err = ws.decryptKeys(key)
+	if err != nil {
+		return err
+	}
+	ws.crypter.key = key
+
+	ws.stopLockTimer()
+	if timeout > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(timeout, func() {
+			ws.lock.Lock()
+			defer ws.lock.Unlock()
+			// a later Unlock or Lock replaced the timer
+			if ws.lockTimer == timer {
+				ws.lockLocked()
+			}
+		})
+		ws.lockTimer = timer
+	}
+	return nil
+}
+
+// Lock drops the decrypted private keys.
+func (ws *Wallets) Lock() error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.crypter == nil {
+		return ErrWalletNotEncrypted
+	}
+	ws.lockLocked()
+	return nil
+}
+
+// ChangePassphrase re-encrypts the private keys and the HD secret with newPassphrase and saves the wallet file.
+// The wallet stays locked or unlocked as it was.
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	ws.lock.Lock()
+	if ws.crypter == nil {
+		ws.lock.Unlock()
+		return ErrWalletNotEncrypted
+	}
+	oldKey, err := ws.crypter.unlockKey(oldPassphrase)
+	if err != nil {
+		ws.lock.Unlock()
+		return err
+	}
+	crypter, err := newWalletCrypter(newPassphrase)
+	if err != nil {
+		ws.lock.Unlock()
+		return err
+	}
+	cryptedKeys := make(map[string]*cryptedKey, len(ws.cryptedKey))
+	for address, ck := range ws.cryptedKey {
+		pubKey := ws.Wallets[address].PublicKey
+		plaintext, errDecrypt := decryptWith(oldKey, pubKey, ck)
+		if errDecrypt != nil {
+			ws.lock.Unlock()
+			return errDecrypt
+		}
+		cryptedKeys[address], err = crypter.encrypt(pubKey, plaintext)
+		if err != nil {
+			ws.lock.Unlock()
+			return err
+		}
+	}
+	var hdCrypted *cryptedKey
+	if ws.hd != nil {
+		plaintext, errDecrypt := decryptWith(oldKey, hdSecretAD, ws.hd.crypted)
+		if errDecrypt != nil {
+			ws.lock.Unlock()
+			return errDecrypt
+		}
+		hdCrypted, err = crypter.encrypt(hdSecretAD, plaintext)
+		if err != nil {
+			ws.lock.Unlock()
+			return err
+		}
+	}
+	if ws.crypter.key == nil {
+		crypter.key = nil
+	}
+	ws.crypter = crypter
+	ws.cryptedKey = cryptedKeys
+	if ws.hd != nil {
+		ws.hd.crypted = hdCrypted
+	}
+	ws.lock.Unlock()
+
+	return ws.SaveToFile()
+}
+
+func (ws *Wallets) decryptKeys(key []byte) error {
+	keys := make(map[string][]byte, len(ws.cryptedKey))
+	for address, ck := range ws.cryptedKey {
+		plaintext, err := decryptWith(key, ws.Wallets[address].PublicKey, ck)
+		if err != nil {
+			return err
+		}
+		keys[address] = plaintext
+	}
+	if ws.hd != nil {
+		plaintext, err := decryptWith(key, hdSecretAD, ws.hd.crypted)
+		if err != nil {
+			return err
+		}
+		secret, err := decodeHDSecret(plaintext)
+		if err != nil {
+			return err
+		}
+		err = ws.hd.setSecret(secret)
+		if err != nil {
+			return err
+		}
+	}
+	for address, d := range keys {
+		ws.Wallets[address].PrivateKey = privateKeyFromBytes(d, ws.Wallets[address].PublicKey)
+	}
+	return nil
+}
+
+// lockLocked drops the wallet key, the HD secret and the private keys, ws.lock must be held.
+func (ws *Wallets) lockLocked() {
+	ws.stopLockTimer()
+	ws.crypter.key = nil
+	if ws.hd != nil {
+		ws.hd.lock()
+	}
+	for _, wallet := range ws.Wallets {
+		wallet.PrivateKey.D = nil
+	}
+}
+
+func (ws *Wallets) stopLockTimer() {
+	if ws.lockTimer != nil {
+		ws.lockTimer.Stop()
+		ws.lockTimer = nil
+	}
+}
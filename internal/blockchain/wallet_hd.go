@@ -0,0 +1,320 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/bip39"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/hdkeychain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// The HD keys are derived along m/44'/0'/0'/branch/index.
+const (
+	ExternalBranch = uint32(0)
+	InternalBranch = uint32(1)
+
+	DefaultGapLimit = 20
+
+	hdPurpose           = 44
+	hdCoinType          = 0
+	hdAccount           = 0
+	mnemonicEntropyBits = 128
+)
+
+var (
+	ErrNoHDSeed     = errors.New("wallet has no HD seed")
+	ErrHDSeedExists = errors.New("wallet already has an HD seed")
+)
+
+// hdSecretAD is the additional data sealing the HD secret, as the public key does for private keys.
+var hdSecretAD = []byte("hd seed")
+
+// hdSecret is what restores an HD wallet, it is encrypted like the private keys.
+type hdSecret struct {
+	Mnemonic string
+	Seed     []byte
+}
+
+// hdData is the HD part of the wallet file, Secret is the gob encoded hdSecret of a plaintext wallet.
+type hdData struct {
+	Secret  []byte
+	Crypted *cryptedKey
+	Next    [2]uint32
+}
+
+// hdChain is the HD state of Wallets, secret and account are nil while the wallet is locked.
+type hdChain struct {
+	secret  *hdSecret
+	crypted *cryptedKey
+	next    [2]uint32
+	account *hdkeychain.ExtendedKey
+}
+
+func (s *hdSecret) encode() []byte {
+	var content bytes.Buffer
+	err := gob.NewEncoder(&content).Encode(s)
+	if err != nil {
+		panic(err)
+	}
+	return content.Bytes()
+}
+
+func decodeHDSecret(data []byte) (*hdSecret, error) {
+	var secret hdSecret
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&secret)
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// setSecret derives the account key of the secret.
+func (hd *hdChain) setSecret(secret *hdSecret) error {
+	master, err := hdkeychain.NewMaster(secret.Seed)
+	if err != nil {
+		return err
+	}
+	account, err := master.DerivePath([]uint32{
+		hdPurpose + hdkeychain.HardenedKeyStart,
+		hdCoinType + hdkeychain.HardenedKeyStart,
+		hdAccount + hdkeychain.HardenedKeyStart,
+	})
+	if err != nil {
+		return err
+	}
+	hd.secret = secret
+	hd.account = account
+	return nil
+}
+
+func (hd *hdChain) lock() {
+	hd.secret = nil
+	hd.account = nil
+}
+
+// deriveWallet derives the key of branch and index, the P256 scalar of the chain makes the key of scheme: it
+// is below the order of secp256k1 too.
+func (hd *hdChain) deriveWallet(branch, index uint32, scheme utils.Scheme) (*Wallet, error) {
+	key, err := hd.account.DerivePath([]uint32{branch, index})
+	if err != nil {
+		return nil, err
+	}
+	privKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	if scheme != utils.SchemeP256 {
+		*privKey, err = utils.PrivateKeyFromScalar(scheme.Curve(), privKey.D.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Wallet{
+		PrivateKey: *privKey,
+		PublicKey:  scheme.MarshalPubKey(&privKey.PublicKey),
+		Path: []uint32{
+			hdPurpose + hdkeychain.HardenedKeyStart,
+			hdCoinType + hdkeychain.HardenedKeyStart,
+			hdAccount + hdkeychain.HardenedKeyStart,
+			branch,
+			index,
+		},
+	}, nil
+}
+
+// HasHDSeed tells if the wallet derives its keys from a seed.
+func (ws *Wallets) HasHDSeed() bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.hd != nil
+}
+
+func newHDSecret() (*hdSecret, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	return &hdSecret{Mnemonic: mnemonic, Seed: bip39.NewSeed(mnemonic, "")}, nil
+}
+
+// NewHDSeed generates the seed of the wallet and returns its mnemonic for the backup.
+func (ws *Wallets) NewHDSeed() (string, error) {
+	secret, err := newHDSecret()
+	if err != nil {
+		return "", err
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	err = ws.setHDSeedLocked(secret)
+	if err != nil {
+		return "", err
+	}
+	return secret.Mnemonic, nil
+}
+
+// SetHDSeed sets the seed of the wallet to the one of mnemonic and the optional mnemonic passphrase, the
+// keys made before keep working. Restoring a wallet is followed by a Rescan.
+func (ws *Wallets) SetHDSeed(mnemonic, passphrase string) error {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return errors.New("invalid mnemonic")
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.setHDSeedLocked(&hdSecret{
+		Mnemonic: mnemonic,
+		Seed:     bip39.NewSeed(mnemonic, passphrase),
+	})
+}
+
+func (ws *Wallets) setHDSeedLocked(secret *hdSecret) error {
+	if ws.hd != nil {
+		return ErrHDSeedExists
+	}
+	if ws.crypter != nil && ws.crypter.key == nil {
+		return ErrWalletLocked
+	}
+
+	hd := &hdChain{}
+	err := hd.setSecret(secret)
+	if err != nil {
+		return err
+	}
+	if ws.crypter != nil {
+		hd.crypted, err = ws.crypter.encrypt(hdSecretAD, secret.encode())
+		if err != nil {
+			return err
+		}
+	}
+	ws.hd = hd
+	return nil
+}
+
+// Mnemonic returns the mnemonic backing up the HD keys.
+func (ws *Wallets) Mnemonic() (string, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.hd == nil {
+		return "", ErrNoHDSeed
+	}
+	if ws.hd.secret == nil {
+		return "", ErrWalletLocked
+	}
+	return ws.hd.secret.Mnemonic, nil
+}
+
+// NewChangeAddress adds the next key of the internal branch, which receives the change of transactions.
+func (ws *Wallets) NewChangeAddress() (string, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	wallet, err := ws.nextHDWalletLocked(InternalBranch, utils.SchemeP256)
+	if err != nil {
+		return "", err
+	}
+	return wallet.GetAddress(), nil
+}
+
+func (ws *Wallets) nextHDWalletLocked(branch uint32, scheme utils.Scheme) (*Wallet, error) {
+	if ws.hd == nil {
+		return nil, ErrNoHDSeed
+	}
+	if ws.hd.account == nil {
+		return nil, ErrWalletLocked
+	}
+	wallet, err := ws.hd.deriveWallet(branch, ws.hd.next[branch], scheme)
+	if err != nil {
+		return nil, err
+	}
+	err = ws.addWalletLocked(wallet)
+	if err != nil {
+		return nil, err
+	}
+	ws.hd.next[branch]++
+	return wallet, nil
+}
+
+// Rescan derives the keys of both branches, in every scheme, until gapLimit indexes in a row have no outputs in
+// the UTXO set and adds the keys with outputs. It returns the addresses found with funds, a SyncChain picks up their history.
+func (ws *Wallets) Rescan(bcs *BlockChains, gapLimit int) ([]string, error) {
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if ws.hd == nil {
+		return nil, ErrNoHDSeed
+	}
+	if ws.hd.account == nil {
+		return nil, ErrWalletLocked
+	}
+
+	used := usedPubKeyHashes(bcs)
+	found := make([]string, 0)
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		gap := 0
+		for index := uint32(0); gap < gapLimit || index < ws.hd.next[branch]; index++ {
+			wallets, err := ws.usedHDWalletsLocked(branch, index, used)
+			if err != nil {
+				return nil, err
+			}
+			if len(wallets) == 0 {
+				gap++
+				continue
+			}
+
+			gap = 0
+			for _, wallet := range wallets {
+				address := wallet.GetAddress()
+				found = append(found, address)
+				if _, ok := ws.Wallets[address]; !ok {
+					err = ws.addWalletLocked(wallet)
+					if err != nil {
+						return nil, err
+					}
+					// the history of the new key is in blocks synced already
+					ws.resetHistoryLocked()
+				}
+			}
+			if index >= ws.hd.next[branch] {
+				ws.hd.next[branch] = index + 1
+			}
+		}
+	}
+	return found, nil
+}
+
+// usedHDWalletsLocked returns the keys of branch and index, in the schemes with outputs in used.
+func (ws *Wallets) usedHDWalletsLocked(branch, index uint32, used map[string]bool) ([]*Wallet, error) {
+	wallets := make([]*Wallet, 0)
+	for _, scheme := range utils.Schemes() {
+		wallet, err := ws.hd.deriveWallet(branch, index, scheme)
+		if err != nil {
+			return nil, err
+		}
+		if used[string(utils.HashPubKey(wallet.PublicKey))] {
+			wallets = append(wallets, wallet)
+		}
+	}
+	return wallets, nil
+}
+
+// usedPubKeyHashes returns the pubkey hashes locking outputs of the UTXO set.
+func usedPubKeyHashes(bcs *BlockChains) map[string]bool {
+	used := make(map[string]bool)
+	bcs.ScanUTXO(nil, func(txID string, output TXOutput) bool {
+		used[string(output.PubKeyHash)] = true
+		return true
+	})
+	return used
+}
@@ -0,0 +1,58 @@
+package blockchain
+
+import (
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/wif"
+)
+
+// wifVersion is the version byte of the exported private keys.
+const wifVersion = wif.MainNetVersion
+
+// DumpPrivKey exports the private key of address in the Wallet Import Format, the wallet has to be unlocked
+// when it is encrypted. The format holds P256 keys only, the others are backed up by the HD seed. The keys of
+// the legacy wallets are flagged uncompressed, so they import under the address of their raw coordinates.
+func (ws *Wallets) DumpPrivKey(address string) (string, error) {
+	wallet, err := ws.GetSigningWallet(address)
+	if err != nil {
+		return "", err
+	}
+	if wallet.Scheme() != utils.SchemeP256 {
+		return "", utils.ErrUnsupportedScheme
+	}
+	w, err := wif.NewWIF(&wallet.PrivateKey, wifVersion, !utils.IsLegacyPubKey(wallet.PublicKey))
+	if err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// ImportPrivKey adds the key of the WIF string s and returns its address, the wallet has to be unlocked when
+// it is encrypted. An uncompressed key is a legacy one. A new key resets the history so the next SyncChain
+// picks up the transactions of its coins.
+func (ws *Wallets) ImportPrivKey(s string) (string, error) {
+	w, err := wif.DecodeForVersion(s, wifVersion)
+	if err != nil {
+		return "", err
+	}
+	wallet := &Wallet{
+		PrivateKey: *w.PrivKey,
+		PublicKey:  utils.MarshalPubKey(&w.PrivKey.PublicKey),
+	}
+	if !w.CompressPubKey {
+		wallet.PublicKey = utils.MarshalLegacyPubKey(&w.PrivKey.PublicKey)
+	}
+	address := wallet.GetAddress()
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.Wallets[address]; ok {
+		return address, nil
+	}
+	err = ws.addWalletLocked(wallet)
+	if err != nil {
+		return "", err
+	}
+	ws.resetHistoryLocked()
+	return address, nil
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/wif"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint: funlen
+func TestWallets_ImportPrivKey(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	source, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	address, err := source.CreateWallet()
+	assert.Nil(t, err)
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+
+	_, err = source.DumpPrivKey(NewWallet().GetAddress())
+	assert.ErrorIs(t, err, ErrAddressNotInWallet)
+	key, err := source.DumpPrivKey(address)
+	assert.Nil(t, err)
+
+	// the watched address becomes spendable
+	file := newTestWalletsFile(t)
+	target, _ := NewWalletsFromFile(file)
+	_, err = target.AddWatchOnly(address)
+	assert.Nil(t, err)
+	target.SyncChain(bcs)
+	assert.Len(t, target.ListTransactions(), 1)
+	assert.True(t, target.ListTransactions()[0].WatchOnly)
+
+	_, err = target.ImportPrivKey("bad")
+	assert.ErrorIs(t, err, wif.ErrMalformedPrivateKey)
+	imported, err := target.ImportPrivKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, address, imported)
+	assert.False(t, target.IsWatchOnly(address))
+	assert.Equal(t, []string{address}, target.GetSpendableAddresses())
+	assert.Empty(t, target.ListTransactions())
+	target.SyncChain(bcs)
+	assert.Len(t, target.ListTransactions(), 1)
+	assert.False(t, target.ListTransactions()[0].WatchOnly)
+
+	// the imported key signs
+	to := NewWallet().GetAddress()
+	tx, err := target.Send(bcs, nil, []Recipient{{to, 1}}, nil)
+	assert.Nil(t, err)
+	cond, err := bcs.GetCond4TransactionVerify(tx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify(cond))
+
+	imported, err = target.ImportPrivKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, address, imported)
+	dumped, err := target.DumpPrivKey(address)
+	assert.Nil(t, err)
+	assert.Equal(t, key, dumped)
+
+	// the key is kept encrypted
+	assert.Nil(t, target.Encrypt("pass"))
+	_, err = target.DumpPrivKey(address)
+	assert.ErrorIs(t, err, ErrWalletLocked)
+	other := NewWallet()
+	otherKey, err := wif.NewWIF(&other.PrivateKey, wif.MainNetVersion, true)
+	assert.Nil(t, err)
+	_, err = target.ImportPrivKey(otherKey.String())
+	assert.ErrorIs(t, err, ErrWalletLocked)
+	assert.Nil(t, target.Unlock("pass", 0))
+	_, err = target.ImportPrivKey(otherKey.String())
+	assert.Nil(t, err)
+	assert.Nil(t, target.SaveToFile())
+
+	reloaded, err := NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{address, other.GetAddress()}, reloaded.GetSpendableAddresses())
+	assert.Nil(t, reloaded.Unlock("pass", 0))
+	dumped, err = reloaded.DumpPrivKey(other.GetAddress())
+	assert.Nil(t, err)
+	assert.Equal(t, otherKey.String(), dumped)
+
+	testnet, err := wif.NewWIF(&other.PrivateKey, 0xef, true)
+	assert.Nil(t, err)
+	_, err = reloaded.ImportPrivKey(testnet.String())
+	assert.ErrorIs(t, err, wif.ErrWrongVersion)
+}
+
+// the key of a legacy wallet dumps uncompressed and imports under the address of its raw coordinates
+func TestWallets_ImportPrivKey_Legacy(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	priKey, _ := utils.NewKeyPair()
+	file := newTestWalletsFile(t)
+	address := writeLegacyWalletFile(t, file, &priKey, utils.MarshalLegacyPubKey(&priKey.PublicKey))
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+
+	source, err := NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	key, err := source.DumpPrivKey(address)
+	assert.Nil(t, err)
+	w, err := wif.DecodeForVersion(key, wif.MainNetVersion)
+	assert.Nil(t, err)
+	assert.False(t, w.CompressPubKey)
+
+	target, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	imported, err := target.ImportPrivKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, address, imported)
+	target.SyncChain(bcs)
+	assert.Len(t, target.ListTransactions(), 1)
+	dumped, err := target.DumpPrivKey(address)
+	assert.Nil(t, err)
+	assert.Equal(t, key, dumped)
+
+	tx, err := target.Send(bcs, nil, []Recipient{{NewWallet().GetAddress(), 3}}, nil)
+	assert.Nil(t, err)
+	cond, err := bcs.GetCond4TransactionVerify(tx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Verify(cond))
+}
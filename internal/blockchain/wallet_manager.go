@@ -0,0 +1,212 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultWalletName names the wallet kept at the top of the data directory, where wallet.dat has always been.
+// The named wallets live in their own directory under wallets.
+const (
+	DefaultWalletName = ""
+
+	walletsDirName = "wallets"
+)
+
+var (
+	ErrWalletNotFound    = errors.New("wallet not found")
+	ErrWalletExists      = errors.New("wallet already exists")
+	ErrWalletLoaded      = errors.New("wallet already loaded")
+	ErrWalletNotLoaded   = errors.New("wallet not loaded")
+	ErrInvalidWalletName = errors.New("invalid wallet name")
+)
+
+var walletNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// WalletManager creates, loads and unloads the wallets of a data directory. The loaded wallets follow the
+// chain once HandleNotification is subscribed to it.
+type WalletManager struct {
+	lock    sync.Mutex
+	dataDir string
+	loaded  map[string]*Wallets
+}
+
+func NewWalletManager(dataDir string) *WalletManager {
+	return &WalletManager{
+		dataDir: dataDir,
+		loaded:  make(map[string]*Wallets),
+	}
+}
+
+// WalletFile returns the file of the wallet name.
+func (m *WalletManager) WalletFile(name string) (string, error) {
+	if name == DefaultWalletName {
+		return filepath.Join(m.dataDir, walletFile), nil
+	}
+	if !walletNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidWalletName, name)
+	}
+	return filepath.Join(m.dataDir, walletsDirName, name, walletFile), nil
+}
+
+// CreateWallet creates the empty wallet name and loads it.
+func (m *WalletManager) CreateWallet(name string) (*Wallets, error) {
+	file, err := m.WalletFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, err = os.Stat(file); err == nil {
+		return nil, fmt.Errorf("%w: %q", ErrWalletExists, name)
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := NewWalletsFromFile(file)
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q", ErrWalletExists, name)
+	}
+	err = ws.SaveToFile()
+	if err != nil {
+		return nil, err
+	}
+	m.loaded[name] = ws
+	return ws, nil
+}
+
+// LoadWallet loads the wallet name from its file.
+func (m *WalletManager) LoadWallet(name string) (*Wallets, error) {
+	file, err := m.WalletFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.loaded[name]; ok {
+		return nil, fmt.Errorf("%w: %q", ErrWalletLoaded, name)
+	}
+	ws, err := NewWalletsFromFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q", ErrWalletNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.loaded[name] = ws
+	return ws, nil
+}
+
+// OpenWallet loads the wallet name, creating it when it does not exist.
+func (m *WalletManager) OpenWallet(name string) (*Wallets, error) {
+	ws, err := m.LoadWallet(name)
+	if errors.Is(err, ErrWalletNotFound) {
+		return m.CreateWallet(name)
+	}
+	return ws, err
+}
+
+// UnloadWallet saves the wallet name and drops it.
+func (m *WalletManager) UnloadWallet(name string) error {
+	m.lock.Lock()
+	ws, ok := m.loaded[name]
+	delete(m.loaded, name)
+	m.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrWalletNotLoaded, name)
+	}
+	err := ws.SaveToFile()
+	if ws.IsEncrypted() {
+		_ = ws.Lock()
+	}
+	return err
+}
+
+// Wallet returns the loaded wallet name.
+func (m *WalletManager) Wallet(name string) (*Wallets, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ws, ok := m.loaded[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrWalletNotLoaded, name)
+	}
+	return ws, nil
+}
+
+// LoadedWallets returns the names of the loaded wallets, sorted.
+func (m *WalletManager) LoadedWallets() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.loaded))
+	for name := range m.loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListWalletDir returns the names of the wallets in the data directory, sorted.
+func (m *WalletManager) ListWalletDir() ([]string, error) {
+	names := make([]string, 0)
+	if _, err := os.Stat(filepath.Join(m.dataDir, walletFile)); err == nil {
+		names = append(names, DefaultWalletName)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(m.dataDir, walletsDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !walletNameRegexp.MatchString(entry.Name()) {
+			continue
+		}
+		if _, err = os.Stat(filepath.Join(m.dataDir, walletsDirName, entry.Name(), walletFile)); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SaveAll saves the loaded wallets, it returns the first error but tries them all.
+func (m *WalletManager) SaveAll() error {
+	var firstErr error
+	for _, ws := range m.loadedWallets() {
+		err := ws.SaveToFile()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// HandleNotification passes n on to the loaded wallets, see Wallets.HandleNotification.
+func (m *WalletManager) HandleNotification(n *Notification) {
+	for _, ws := range m.loadedWallets() {
+		ws.HandleNotification(n)
+	}
+}
+
+func (m *WalletManager) loadedWallets() []*Wallets {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	loaded := make([]*Wallets, 0, len(m.loaded))
+	for _, ws := range m.loaded {
+		loaded = append(loaded, ws)
+	}
+	return loaded
+}
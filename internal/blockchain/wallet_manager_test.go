@@ -0,0 +1,62 @@
+package blockchain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint: funlen
+func TestWalletManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewWalletManager(dir)
+	names, err := m.ListWalletDir()
+	assert.Nil(t, err)
+	assert.Empty(t, names)
+
+	_, err = m.LoadWallet("alice")
+	assert.ErrorIs(t, err, ErrWalletNotFound)
+	for _, name := range []string{"../alice", "a/b", "a b", "."} {
+		_, err = m.CreateWallet(name)
+		assert.ErrorIs(t, err, ErrInvalidWalletName)
+	}
+
+	alice, err := m.CreateWallet("alice")
+	assert.Nil(t, err)
+	address, err := alice.CreateWallet()
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(dir, walletsDirName, "alice", walletFile))
+	_, err = m.CreateWallet("alice")
+	assert.ErrorIs(t, err, ErrWalletExists)
+	_, err = m.LoadWallet("alice")
+	assert.ErrorIs(t, err, ErrWalletLoaded)
+
+	def, err := m.OpenWallet(DefaultWalletName)
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(dir, walletFile))
+	assert.Equal(t, []string{DefaultWalletName, "alice"}, m.LoadedWallets())
+	loaded, err := m.Wallet(DefaultWalletName)
+	assert.Nil(t, err)
+	assert.Same(t, def, loaded)
+
+	// the unloaded wallet is saved and loads from its own file
+	assert.Nil(t, m.UnloadWallet("alice"))
+	assert.ErrorIs(t, m.UnloadWallet("alice"), ErrWalletNotLoaded)
+	_, err = m.Wallet("alice")
+	assert.ErrorIs(t, err, ErrWalletNotLoaded)
+	assert.Equal(t, []string{DefaultWalletName}, m.LoadedWallets())
+
+	names, err = NewWalletManager(dir).ListWalletDir()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{DefaultWalletName, "alice"}, names)
+	alice, err = m.OpenWallet("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{address}, alice.GetAddresses())
+	assert.Empty(t, def.GetAddresses())
+}
@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// ErrMultiSig tells the address needs the signatures of several keys, it is spent with a PartialTx.
+var ErrMultiSig = errors.New("address is multisig")
+
+// CreateMultiSig returns the pay to script hash address of the m of pubKeys multisig, and its redeem script.
+func CreateMultiSig(m int, pubKeys [][]byte) (string, []byte, error) {
+	redeemScript, err := script.MultiSigScript(m, pubKeys)
+	if err != nil {
+		return "", nil, err
+	}
+	return ScriptHashAddress(redeemScript), redeemScript, nil
+}
+
+// AddMultiSigAddress adds the m of keys multisig to the wallet and returns its address. The keys are hex
+// public keys, or addresses of the wallet whose public key it knows. The history is rebuilt by the next
+// SyncChain.
+func (ws *Wallets) AddMultiSigAddress(m int, keys []string) (string, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	pubKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		pubKey, err := ws.multiSigKeyLocked(key)
+		if err != nil {
+			return "", err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	address, redeemScript, err := CreateMultiSig(m, pubKeys)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := ws.scripts[address]; !ok {
+		ws.scripts[address] = redeemScript
+		ws.resetHistoryLocked()
+	}
+	return address, nil
+}
+
+// multiSigKeyLocked returns the public key of key, a hex public key or an address of the wallet.
+func (ws *Wallets) multiSigKeyLocked(key string) ([]byte, error) {
+	if utils.IsValidAddress(key) {
+		if wallet, ok := ws.Wallets[key]; ok {
+			return wallet.PublicKey, nil
+		}
+		if w, ok := ws.watchOnly[key]; ok && len(w.PublicKey) > 0 {
+			return w.PublicKey, nil
+		}
+		return nil, fmt.Errorf("%w: no public key of %s", ErrAddressNotInWallet, key)
+	}
+	pubKey, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, errors.New("multisig key must be an address or a hex public key")
+	}
+	_, err = utils.ParsePubKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return pubKey, nil
+}
+
+// IsMultiSig tells if address is a multisig address of the wallet.
+func (ws *Wallets) IsMultiSig(address string) bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	_, ok := ws.scripts[address]
+	return ok
+}
+
+// GetRedeemScript returns the redeem script of the multisig address.
+func (ws *Wallets) GetRedeemScript(address string) ([]byte, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	redeemScript, ok := ws.scripts[address]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAddressNotInWallet, address)
+	}
+	return redeemScript, nil
+}
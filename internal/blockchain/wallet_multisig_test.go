@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint: funlen
+func TestWallets_MultiSig(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	signers := make([]*Wallets, 3)
+	addresses := make([]string, 3)
+	pubKeys := make([]string, 3)
+	for idx := range signers {
+		signers[idx], _ = NewWalletsFromFile(newTestWalletsFile(t))
+		addresses[idx], err = signers[idx].CreateWallet()
+		assert.Nil(t, err)
+		wallet, errWallet := signers[idx].GetWallet(addresses[idx])
+		assert.Nil(t, errWallet)
+		pubKeys[idx] = hex.EncodeToString(wallet.PublicKey)
+	}
+	alice, bob, carol := signers[0], signers[1], signers[2]
+
+	treasury, err := alice.AddMultiSigAddress(2, []string{addresses[0], pubKeys[1], pubKeys[2]})
+	assert.Nil(t, err)
+	version, _, err := utils.DecodeAddress(treasury)
+	assert.Nil(t, err)
+	assert.Equal(t, scriptHashVersion, version)
+	bobTreasury, err := bob.AddMultiSigAddress(2, []string{pubKeys[0], addresses[1], pubKeys[2]})
+	assert.Nil(t, err)
+	assert.Equal(t, treasury, bobTreasury)
+	_, err = alice.AddMultiSigAddress(2, []string{addresses[1], pubKeys[2]})
+	assert.ErrorIs(t, err, ErrAddressNotInWallet)
+	_, err = alice.AddMultiSigAddress(3, []string{pubKeys[1], pubKeys[2]})
+	assert.ErrorIs(t, err, script.ErrInvalidSigCount)
+
+	assert.True(t, alice.IsMultiSig(treasury))
+	assert.Contains(t, alice.GetAddresses(), treasury)
+	assert.NotContains(t, alice.GetSpendableAddresses(), treasury)
+	_, err = alice.GetSigningWallet(treasury)
+	assert.ErrorIs(t, err, ErrMultiSig)
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(treasury, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	assert.Equal(t, script.ScriptHashTy, script.GetClass(b1.Transactions[0].Vout[0].PkScript))
+	assert.Equal(t, treasury, b1.Transactions[0].Vout[0].Address())
+	assert.Equal(t, Subsidy, bcs.GetBalance(treasury))
+
+	_, err = alice.Send(bcs, []string{treasury}, []Recipient{{addresses[0], 1}}, nil)
+	assert.ErrorIs(t, err, ErrMultiSig)
+
+	to := NewWallet().GetAddress()
+	unsigned, err := CreatePartialTx(bcs, treasury, to, 7, nil)
+	assert.Nil(t, err)
+	aliceCopy, err := DeserializePartialTx(unsigned.Serialize())
+	assert.Nil(t, err)
+	bobCopy, err := DeserializePartialTx(unsigned.Serialize())
+	assert.Nil(t, err)
+
+	// carol does not know the redeem script until a cosigner adds it
+	signed, err := carol.SignPartialTx(unsigned)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, signed)
+
+	signed, err = alice.SignPartialTx(aliceCopy)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	assert.False(t, aliceCopy.IsComplete())
+	signed, err = alice.SignPartialTx(aliceCopy)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, signed)
+	_, err = aliceCopy.Finalize()
+	assert.ErrorIs(t, err, ErrPartialTxIncomplete)
+
+	signed, err = bob.SignPartialTx(bobCopy)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	assert.Nil(t, bobCopy.Combine(aliceCopy))
+	assert.True(t, bobCopy.IsComplete())
+	final, err := bobCopy.Finalize()
+	assert.Nil(t, err)
+
+	// carol signs the copy alice passed on
+	carolCopy, err := DeserializePartialTx(aliceCopy.Serialize())
+	assert.Nil(t, err)
+	signed, err = carol.SignPartialTx(carolCopy)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	assert.True(t, carolCopy.IsComplete())
+	_, err = carolCopy.Finalize()
+	assert.Nil(t, err)
+
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(to, "b2"), final}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	assert.Equal(t, Subsidy+7, bcs.GetBalance(to))
+	assert.Equal(t, Subsidy-7, bcs.GetBalance(treasury))
+
+	alice.SyncChain(bcs)
+	txs := alice.ListTransactions()
+	assert.Len(t, txs, 2)
+	assert.Equal(t, Subsidy, txs[0].Received)
+	assert.Equal(t, Subsidy, txs[1].Sent)
+	assert.Equal(t, Subsidy-7, txs[1].Received)
+
+	assert.Nil(t, alice.SaveToFile())
+	reloaded, err := NewWalletsFromFile(alice.file)
+	assert.Nil(t, err)
+	redeemScript, err := reloaded.GetRedeemScript(treasury)
+	assert.Nil(t, err)
+	assert.Equal(t, treasury, ScriptHashAddress(redeemScript))
+}
+
+func TestTransaction_MultiSigSpend(t *testing.T) {
+	keys := []*Wallet{NewWallet(), NewWallet(), NewWallet()}
+	address, redeemScript, err := CreateMultiSig(2, [][]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey})
+	assert.Nil(t, err)
+	prev := NewCoinbaseTX(address, "prev")
+	prevout := &prev.Vout[0]
+	assert.Equal(t, utils.HashPubKey(redeemScript), prevout.PubKeyHash)
+
+	newPartial := func() *PartialTx {
+		tx := newSpendTx(prev, 0, Subsidy, NewWallet().GetAddress())
+		return &PartialTx{Tx: *tx, Prevouts: []TXOutput{*prevout}}
+	}
+
+	p := newPartial()
+	assert.Equal(t, 1, p.AddRedeemScript(redeemScript))
+	assert.Equal(t, 0, p.AddRedeemScript([]byte{script.OpTrue}))
+	for _, key := range []*Wallet{keys[2], keys[0]} {
+		signed, errSign := p.SignWith(key)
+		assert.Nil(t, errSign)
+		assert.Equal(t, 1, signed)
+	}
+	_, err = p.Finalize()
+	assert.Nil(t, err)
+
+	// one signature is not enough, even pushed with the redeem script
+	p = newPartial()
+	p.AddRedeemScript(redeemScript)
+	_, err = p.SignWith(keys[1])
+	assert.Nil(t, err)
+	assert.False(t, p.IsComplete())
+	sig := p.PartialSigs[0][hex.EncodeToString(keys[1].PublicKey)]
+	p.Tx.Vin[0].SigScript, err = script.NewBuilder().AddOp(script.Op0).AddData(sig).AddData(sig).AddData(redeemScript).Script()
+	assert.Nil(t, err)
+	_, err = p.Finalize()
+	assert.True(t, IsErrorCode(err, ErrScriptFailed))
+}
@@ -0,0 +1,301 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/hdkeychain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWalletsFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wallet")
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, walletFile)
+}
+
+func walletPath(t *testing.T, wallets *Wallets, address string) []uint32 {
+	wallet, err := wallets.GetWallet(address)
+	assert.Nil(t, err)
+	return wallet.Path
+}
+
+func TestWallets_Encrypt(t *testing.T) {
+	file := newTestWalletsFile(t)
+	wallets, err := NewWalletsFromFile(file)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	address, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	wallet, err := wallets.GetWallet(address)
+	assert.Nil(t, err)
+	d := wallet.PrivateKey.D.Bytes()
+	assert.False(t, wallets.IsEncrypted())
+	assert.ErrorIs(t, wallets.Unlock("pass", 0), ErrWalletNotEncrypted)
+
+	assert.Nil(t, wallets.Encrypt("pass"))
+	assert.ErrorIs(t, wallets.Encrypt("pass"), ErrWalletEncrypted)
+	assert.True(t, wallets.IsLocked())
+	_, err = wallets.GetSigningWallet(address)
+	assert.ErrorIs(t, err, ErrWalletLocked)
+	_, err = wallets.CreateWallet()
+	assert.ErrorIs(t, err, ErrWalletLocked)
+
+	content, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(content, d))
+
+	wallets, err = NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.True(t, wallets.IsLocked())
+	assert.Equal(t, []string{address}, wallets.GetAddresses())
+	assert.ErrorIs(t, wallets.Unlock("wrong", 0), ErrWrongPassphrase)
+	assert.Nil(t, wallets.Unlock("pass", 0))
+	wallet, err = wallets.GetSigningWallet(address)
+	assert.Nil(t, err)
+	assert.Equal(t, d, wallet.PrivateKey.D.Bytes())
+	assert.True(t, wallet.PrivateKey.Curve.IsOnCurve(wallet.PrivateKey.X, wallet.PrivateKey.Y))
+
+	other, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	assert.Nil(t, wallets.SaveToFile())
+	assert.Nil(t, wallets.Lock())
+	_, err = wallets.GetSigningWallet(other)
+	assert.ErrorIs(t, err, ErrWalletLocked)
+
+	assert.ErrorIs(t, wallets.ChangePassphrase("wrong", "new"), ErrWrongPassphrase)
+	assert.Nil(t, wallets.ChangePassphrase("pass", "new"))
+	assert.True(t, wallets.IsLocked())
+	wallets, err = NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, wallets.Unlock("pass", 0), ErrWrongPassphrase)
+	assert.Nil(t, wallets.Unlock("new", 0))
+	_, err = wallets.GetSigningWallet(other)
+	assert.Nil(t, err)
+}
+
+func TestWallets_SaveToFile(t *testing.T) {
+	file := newTestWalletsFile(t)
+	wallets, _ := NewWalletsFromFile(file)
+	address, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	assert.Nil(t, wallets.SaveToFile())
+	_, err = os.Stat(file + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	loaded, err := NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{address}, loaded.GetAddresses())
+
+	// a failed write leaves the previous file in place
+	assert.Nil(t, os.Mkdir(file+".tmp", 0700))
+	_, err = wallets.CreateWallet()
+	assert.Nil(t, err)
+	assert.NotNil(t, wallets.SaveToFile())
+	loaded, err = NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{address}, loaded.GetAddresses())
+}
+
+func TestWallets_UnlockTimeout(t *testing.T) {
+	wallets, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	assert.Nil(t, wallets.Encrypt("pass"))
+
+	assert.Nil(t, wallets.Unlock("pass", 50*time.Millisecond))
+	assert.False(t, wallets.IsLocked())
+	assert.Eventually(t, wallets.IsLocked, time.Second, 10*time.Millisecond)
+
+	// unlocking again replaces the pending timeout
+	assert.Nil(t, wallets.Unlock("pass", 50*time.Millisecond))
+	assert.Nil(t, wallets.Unlock("pass", time.Hour))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, wallets.IsLocked())
+}
+
+// writeLegacyWalletFile writes the plaintext wallet file of the key before encryption was supported.
+func writeLegacyWalletFile(t *testing.T, file string, priKey *ecdsa.PrivateKey, pubKey []byte) string {
+	// the curve used to be gob encoded as the registered *elliptic.CurveParams of P256
+	type legacyKey struct {
+		PublicKey struct {
+			Curve elliptic.Curve
+			X, Y  *big.Int
+		}
+		D *big.Int
+	}
+	var key legacyKey
+	key.PublicKey.Curve = elliptic.P256().Params()
+	key.PublicKey.X, key.PublicKey.Y = priKey.X, priKey.Y
+	key.D = priKey.D
+	type legacyWallet struct {
+		PrivateKey legacyKey
+		PublicKey  []byte
+	}
+	address := utils.Pubkey2Address(pubKey, version)
+	legacy := struct {
+		Wallets map[string]*legacyWallet
+	}{Wallets: map[string]*legacyWallet{address: {PrivateKey: key, PublicKey: pubKey}}}
+	gob.Register(elliptic.P256().Params())
+	var content bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&content).Encode(&legacy))
+	assert.Nil(t, ioutil.WriteFile(file, content.Bytes(), 0600))
+	return address
+}
+
+func TestWallets_MigratePlaintext(t *testing.T) {
+	file := newTestWalletsFile(t)
+	wallet := NewWallet()
+	writeLegacyWalletFile(t, file, &wallet.PrivateKey, wallet.PublicKey)
+
+	wallets, err := NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.False(t, wallets.IsEncrypted())
+	migrated, err := wallets.GetSigningWallet(wallet.GetAddress())
+	assert.Nil(t, err)
+	assert.Equal(t, wallet.PrivateKey.D, migrated.PrivateKey.D)
+
+	assert.Nil(t, wallets.Encrypt("pass"))
+	wallets, err = NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.True(t, wallets.IsEncrypted())
+	assert.Nil(t, wallets.Unlock("pass", 0))
+	migrated, err = wallets.GetSigningWallet(wallet.GetAddress())
+	assert.Nil(t, err)
+	assert.Equal(t, wallet.PrivateKey.D, migrated.PrivateKey.D)
+}
+
+const testMnemonic = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+
+func TestWallets_SpendMigratedLegacy(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	// the first wallets hashed the raw coordinates of the key
+	priKey, _ := utils.NewKeyPair()
+	file := newTestWalletsFile(t)
+	address := writeLegacyWalletFile(t, file, &priKey, utils.MarshalLegacyPubKey(&priKey.PublicKey))
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+
+	wallets, err := NewWalletsFromFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{address}, wallets.GetAddresses())
+	wallet, err := wallets.GetSigningWallet(address)
+	assert.Nil(t, err)
+	assert.Equal(t, address, wallet.GetAddress())
+	tx, err := NewUTXOTransaction(wallet, NewWallet().GetAddress(), 3, nil, bcs)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.DefSign(bcs, wallet.PrivateKey))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(address, "b2"), tx}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	assert.Equal(t, b2.Hash, bcs.GetLatestBlock().Hash)
+	assert.Equal(t, 2*Subsidy-3, bcs.GetBalance(address))
+}
+
+func TestWallets_HD(t *testing.T) {
+	wallets, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	assert.False(t, wallets.HasHDSeed())
+	assert.Nil(t, wallets.SetHDSeed(testMnemonic, ""))
+	assert.ErrorIs(t, wallets.SetHDSeed(testMnemonic, ""), ErrHDSeedExists)
+	first, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	change, err := wallets.NewChangeAddress()
+	assert.Nil(t, err)
+	second, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{44 + hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart, 0, 1},
+		walletPath(t, wallets, second))
+
+	// the same mnemonic derives the same keys, the mnemonic passphrase others
+	other, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	assert.Nil(t, other.SetHDSeed(testMnemonic, ""))
+	address, err := other.CreateWallet()
+	assert.Nil(t, err)
+	assert.Equal(t, first, address)
+	address, err = other.NewChangeAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, change, address)
+	other, _ = NewWalletsFromFile(newTestWalletsFile(t))
+	assert.Nil(t, other.SetHDSeed(testMnemonic, "TREZOR"))
+	address, err = other.CreateWallet()
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, address)
+
+	// the seed and the next indices survive encryption and reloading
+	assert.Nil(t, wallets.Encrypt("pass"))
+	wallets, err = NewWalletsFromFile(wallets.file)
+	assert.Nil(t, err)
+	_, err = wallets.Mnemonic()
+	assert.ErrorIs(t, err, ErrWalletLocked)
+	_, err = wallets.CreateWallet()
+	assert.ErrorIs(t, err, ErrWalletLocked)
+	assert.Nil(t, wallets.Unlock("pass", 0))
+	mnemonic, err := wallets.Mnemonic()
+	assert.Nil(t, err)
+	assert.Equal(t, testMnemonic, mnemonic)
+	third, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, walletPath(t, wallets, third)[4])
+	assert.Nil(t, wallets.ChangePassphrase("pass", "new"))
+	wallets, _ = NewWalletsFromFile(wallets.file)
+	assert.Nil(t, wallets.Unlock("new", 0))
+	mnemonic, err = wallets.Mnemonic()
+	assert.Nil(t, err)
+	assert.Equal(t, testMnemonic, mnemonic)
+}
+
+func TestWallets_Rescan(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	funded, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	assert.Nil(t, funded.SetHDSeed(testMnemonic, ""))
+	prev := bcs.GetLatestBlock().Hash
+	pay := func(branch, index uint32, scheme utils.Scheme) string {
+		wallet, errDerive := funded.hd.deriveWallet(branch, index, scheme)
+		assert.Nil(t, errDerive)
+		block := MineBlock([]*Transaction{NewCoinbaseTX(wallet.GetAddress(), fmt.Sprintf("%d/%d", branch, index))}, prev)
+		assert.Nil(t, bcs.AddBlock(block))
+		prev = block.Hash
+		return wallet.GetAddress()
+	}
+	// the keys of every scheme are found
+	want := []string{pay(ExternalBranch, 0, utils.SchemeP256), pay(ExternalBranch, 5, utils.SchemeSchnorr),
+		pay(InternalBranch, 2, utils.SchemeSecp256k1)}
+
+	restored, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	_, err = restored.Rescan(bcs, 0)
+	assert.ErrorIs(t, err, ErrNoHDSeed)
+	assert.Nil(t, restored.SetHDSeed(testMnemonic, ""))
+	// the external key 5 lies beyond a gap of 3 unused keys
+	found, err := restored.Rescan(bcs, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{want[0], want[2]}, found)
+
+	found, err = restored.Rescan(bcs, DefaultGapLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, want, found)
+	assert.ElementsMatch(t, want, restored.GetAddresses())
+
+	// new addresses continue after the last used ones
+	address, err := restored.CreateWallet()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 6, walletPath(t, restored, address)[4])
+	address, err = restored.NewChangeAddress()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, walletPath(t, restored, address)[4])
+}
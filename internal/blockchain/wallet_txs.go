@@ -0,0 +1,250 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+)
+
+// WalletTx is a main chain transaction paying to or from the wallet.
+type WalletTx struct {
+	TxID      string
+	BlockHash chainhash.Hash
+	Height    int64
+	// Index is the position of the transaction in its block.
+	Index    int
+	Time     int64
+	Coinbase bool
+	// WatchOnly is set when watch-only addresses take part in the transaction.
+	WatchOnly bool
+	// Received sums the outputs paying the wallet, Sent the inputs spending its outputs.
+	Received int
+	Sent     int
+	// Fee is known when the wallet paid all the inputs.
+	Fee int
+	// Counterparties are the recipients of a payment of the wallet, the senders otherwise.
+	Counterparties []string
+	Label          string
+}
+
+// Net is the amount the transaction changed the wallet balance by.
+func (wtx *WalletTx) Net() int {
+	return wtx.Received - wtx.Sent
+}
+
+// Confirmations counts the blocks from the one of the transaction to the best block.
+func (wtx *WalletTx) Confirmations(bestHeight int64) int64 {
+	return bestHeight - wtx.Height + 1
+}
+
+// walletHistory is the transaction part of the wallet file, it was synced up to the block SyncHash.
+type walletHistory struct {
+	Txs        []*WalletTx
+	SyncHash   chainhash.Hash
+	SyncHeight int64
+	Labels     map[string]string
+}
+
+// SetLabel labels an address or a transaction id, an empty label removes it.
+func (ws *Wallets) SetLabel(target, label string) error {
+	if !utils.IsValidAddress(target) {
+		id, err := hex.DecodeString(target)
+		if err != nil || len(id) != chainhash.HashSize {
+			return errors.New("label target must be an address or a transaction id")
+		}
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if label == "" {
+		delete(ws.labels, target)
+	} else {
+		ws.labels[target] = label
+	}
+	return nil
+}
+
+// Label returns the label of an address or a transaction id.
+func (ws *Wallets) Label(target string) string {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.labels[target]
+}
+
+// ListTransactions returns the wallet transactions in chain order, with their labels.
+func (ws *Wallets) ListTransactions() []WalletTx {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	txs := make([]WalletTx, 0, len(ws.txs))
+	for _, wtx := range ws.txs {
+		tx := *wtx
+		tx.Label = ws.labels[tx.TxID]
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Height != txs[j].Height {
+			return txs[i].Height < txs[j].Height
+		}
+		return txs[i].Index < txs[j].Index
+	})
+	return txs
+}
+
+// HandleNotification keeps the history in step with the main chain, register it with BlockChains.Subscribe
+// after a SyncChain.
+func (ws *Wallets) HandleNotification(n *Notification) {
+	change, ok := n.Data.(*UTXOChange)
+	if !ok {
+		return
+	}
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if change.Connected {
+		ws.connectBlockLocked(change.Block, change.Spent)
+	} else {
+		ws.disconnectBlockLocked(change.Block)
+	}
+}
+
+// SyncChain brings the history up to the best block of bcs, rebuilding it when the block it was synced to
+// left the main chain.
+func (ws *Wallets) SyncChain(bcs *BlockChains) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	start := int64(0)
+	if ws.syncHeight > 0 {
+		block := bcs.GetBlockByHeight(ws.syncHeight)
+		if block != nil && block.Hash.IsEqual(&ws.syncHash) {
+			start = ws.syncHeight + 1
+		} else {
+			ws.resetHistoryLocked()
+		}
+	}
+
+	best := bcs.GetBestHeight()
+	for height := start; height <= best; height++ {
+		block := bcs.GetBlockByHeight(height)
+		if block == nil {
+			continue
+		}
+		spent, err := bcs.SpentOutputs(block)
+		if err != nil {
+			loge.Errorf(nil, "sync block #%d failed: %v", height, err)
+			return
+		}
+		ws.connectBlockLocked(block, spent)
+	}
+}
+
+// resetHistoryLocked drops the transactions so the next SyncChain rebuilds them, the labels stay.
+func (ws *Wallets) resetHistoryLocked() {
+	ws.txs = make(map[string]*WalletTx)
+	ws.syncHash = chainhash.Hash{}
+	ws.syncHeight = 0
+}
+
+// connectBlockLocked adds the wallet transactions of block, spent are the outputs its inputs spend.
+func (ws *Wallets) connectBlockLocked(block *Block, spent []UTXOEntry) {
+	pubKeyHashes := ws.pubKeyHashesLocked()
+	for idx, tx := range block.Transactions {
+		var txSpent []UTXOEntry
+		if !tx.IsCoinbase() {
+			txSpent, spent = spent[:len(tx.Vin)], spent[len(tx.Vin):]
+		}
+		wtx := newWalletTx(tx, txSpent, pubKeyHashes)
+		if wtx == nil {
+			continue
+		}
+		wtx.BlockHash = block.Hash
+		wtx.Height = block.Height
+		wtx.Index = idx
+		wtx.Time = block.Timestamp
+		ws.txs[wtx.TxID] = wtx
+	}
+	ws.syncHash = block.Hash
+	ws.syncHeight = block.Height
+}
+
+func (ws *Wallets) disconnectBlockLocked(block *Block) {
+	for _, tx := range block.Transactions {
+		wtx, ok := ws.txs[tx.TxID]
+		if ok && wtx.BlockHash.IsEqual(&block.Hash) {
+			delete(ws.txs, tx.TxID)
+		}
+	}
+	ws.syncHash = block.PrevBlockHash
+	ws.syncHeight = block.Height - 1
+}
+
+// newWalletTx returns the wallet view of tx spending the outputs spent, nil when it does not touch the keys of
+// pubKeyHashes, which maps them to whether they are watch-only.
+func newWalletTx(tx *Transaction, spent []UTXOEntry, pubKeyHashes map[string]bool) *WalletTx {
+	wtx := &WalletTx{
+		TxID:     tx.TxID,
+		Coinbase: tx.IsCoinbase(),
+	}
+
+	ours := 0
+	inputs := 0
+	var senders []string
+	if !wtx.Coinbase {
+		for idx := range spent {
+			output := &spent[idx].Output
+			inputs += output.Value
+			if watchOnly, ok := pubKeyHashes[string(output.PubKeyHash)]; ok {
+				wtx.WatchOnly = wtx.WatchOnly || watchOnly
+				wtx.Sent += output.Value
+				ours++
+				continue
+			}
+			if address := output.Address(); address != "" {
+				senders = appendUnique(senders, address)
+			}
+		}
+	}
+
+	outputs := 0
+	var recipients []string
+	for _, output := range tx.Vout {
+		outputs += output.Value
+		if watchOnly, ok := pubKeyHashes[string(output.PubKeyHash)]; ok {
+			wtx.WatchOnly = wtx.WatchOnly || watchOnly
+			wtx.Received += output.Value
+			continue
+		}
+		if address := output.Address(); address != "" {
+			recipients = appendUnique(recipients, address)
+		}
+	}
+
+	if wtx.Sent == 0 && wtx.Received == 0 {
+		return nil
+	}
+	if wtx.Sent > 0 {
+		wtx.Counterparties = recipients
+		if ours == len(tx.Vin) {
+			wtx.Fee = inputs - outputs
+		}
+	} else {
+		wtx.Counterparties = senders
+	}
+	return wtx
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint: funlen
+func TestWallets_History(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	alice, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	bob, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	aliceAddress, err := alice.CreateWallet()
+	assert.Nil(t, err)
+	bobAddress, err := bob.CreateWallet()
+	assert.Nil(t, err)
+	bcs.Subscribe(alice.HandleNotification)
+	bcs.Subscribe(bob.HandleNotification)
+	other := NewWallet().GetAddress()
+
+	genesis := bcs.GetLatestBlock()
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(aliceAddress, "b1")}, genesis.Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	txs := alice.ListTransactions()
+	assert.Len(t, txs, 1)
+	assert.True(t, txs[0].Coinbase)
+	assert.Equal(t, Subsidy, txs[0].Net())
+	assert.EqualValues(t, 1, txs[0].Confirmations(bcs.GetBestHeight()))
+
+	wallet, err := alice.GetSigningWallet(aliceAddress)
+	assert.Nil(t, err)
+	tx, err := NewUTXOTransaction(wallet, bobAddress, 3, nil, bcs)
+	assert.Nil(t, err)
+	// the data output pays to no address and is no counterparty
+	dataOutput, err := NewDataTXOutput(len(tx.Vout), []byte("memo"))
+	assert.Nil(t, err)
+	tx.Vout = append(tx.Vout, *dataOutput)
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	assert.Nil(t, tx.DefSign(bcs, wallet.PrivateKey))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(other, "b2"), tx}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+
+	txs = alice.ListTransactions()
+	assert.Len(t, txs, 2)
+	assert.EqualValues(t, 2, txs[0].Confirmations(bcs.GetBestHeight()))
+	assert.Equal(t, tx.TxID, txs[1].TxID)
+	assert.Equal(t, b2.Hash, txs[1].BlockHash)
+	assert.Equal(t, Subsidy, txs[1].Sent)
+	assert.Equal(t, -3, txs[1].Net())
+	assert.Equal(t, 0, txs[1].Fee)
+	assert.Equal(t, []string{bobAddress}, txs[1].Counterparties)
+	txs = bob.ListTransactions()
+	assert.Len(t, txs, 1)
+	assert.Equal(t, 3, txs[0].Net())
+	assert.Equal(t, []string{aliceAddress}, txs[0].Counterparties)
+
+	assert.NotNil(t, alice.SetLabel("nothing", "x"))
+	assert.Nil(t, alice.SetLabel(bobAddress, "bob"))
+	assert.Nil(t, alice.SetLabel(tx.TxID, "rent"))
+	assert.Equal(t, "rent", alice.ListTransactions()[1].Label)
+	assert.Nil(t, alice.SaveToFile())
+	reloaded, err := NewWalletsFromFile(alice.file)
+	assert.Nil(t, err)
+	assert.Equal(t, alice.ListTransactions(), reloaded.ListTransactions())
+	assert.Equal(t, "bob", reloaded.Label(bobAddress))
+
+	// a side chain takes over, the payment leaves the history
+	s2 := MineBlock([]*Transaction{NewCoinbaseTX(other, "s2")}, b1.Hash)
+	s3 := MineBlock([]*Transaction{NewCoinbaseTX(other, "s3")}, s2.Hash)
+	assert.Nil(t, bcs.AddBlock(s2))
+	assert.Nil(t, bcs.AddBlock(s3))
+	assert.Len(t, alice.ListTransactions(), 1)
+	assert.Len(t, bob.ListTransactions(), 0)
+	assert.Nil(t, alice.SetLabel(tx.TxID, ""))
+
+	// the wallet synced to b2 notices the reorg and rebuilds its history
+	reloaded.SyncChain(bcs)
+	assert.Equal(t, alice.ListTransactions(), reloaded.ListTransactions())
+	assert.Equal(t, s3.Hash, reloaded.syncHash)
+}
@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+var (
+	ErrWatchOnly          = errors.New("address is watch-only")
+	ErrAddressNotInWallet = errors.New("address not in wallet")
+)
+
+// WatchOnly is an address the wallet follows without holding its private key, PublicKey is empty when
+// only the address is known. Version is the address version, a key or a script hash.
+type WatchOnly struct {
+	PubKeyHash []byte
+	PublicKey  []byte
+	Version    byte
+}
+
+// GetAddress returns the watched address.
+func (w *WatchOnly) GetAddress() string {
+	return utils.PubkeyHash2Address(w.PubKeyHash, w.Version)
+}
+
+// AddWatchOnly watches an address or a hex encoded public key and returns the address. The history is
+// rebuilt by the next SyncChain.
+func (ws *Wallets) AddWatchOnly(target string) (string, error) {
+	w := &WatchOnly{Version: version}
+	if utils.IsValidAddress(target) {
+		w.Version, w.PubKeyHash, _ = utils.DecodeAddress(target)
+	} else {
+		pubKey, err := hex.DecodeString(target)
+		if err != nil {
+			return "", errors.New("watch-only target must be an address or a hex public key")
+		}
+		_, err = utils.ParsePubKey(pubKey)
+		if err != nil {
+			return "", err
+		}
+		w.PublicKey = pubKey
+		w.PubKeyHash = utils.HashPubKey(pubKey)
+	}
+	address := w.GetAddress()
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	if _, ok := ws.Wallets[address]; ok {
+		return "", fmt.Errorf("the key of %s is in the wallet already", address)
+	}
+	if old, ok := ws.watchOnly[address]; ok && len(old.PublicKey) > 0 {
+		return address, nil
+	}
+	ws.watchOnly[address] = w
+	ws.resetHistoryLocked()
+	return address, nil
+}
+
+// IsWatchOnly tells if address is watched without its private key.
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	_, ok := ws.watchOnly[address]
+	return ok
+}
+
+// GetWatchOnly returns the watch-only entry of address.
+func (ws *Wallets) GetWatchOnly(address string) (*WatchOnly, error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	w, ok := ws.watchOnly[address]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAddressNotInWallet, address)
+	}
+	watched := *w
+	return &watched, nil
+}
+
+// GetSpendableAddresses returns the addresses the wallet holds the private keys of.
+func (ws *Wallets) GetSpendableAddresses() []string {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	addresses := make([]string, 0, len(ws.Wallets))
+	for address := range ws.Wallets {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// pubKeyHashesLocked maps the pubkey hashes of the wallet, and the script hashes of its multisig addresses,
+// to whether they are watch-only.
+func (ws *Wallets) pubKeyHashesLocked() map[string]bool {
+	pubKeyHashes := make(map[string]bool, len(ws.Wallets)+len(ws.watchOnly)+len(ws.scripts))
+	for _, wallet := range ws.Wallets {
+		pubKeyHashes[string(utils.HashPubKey(wallet.PublicKey))] = false
+	}
+	for _, w := range ws.watchOnly {
+		pubKeyHashes[string(w.PubKeyHash)] = true
+	}
+	for _, redeemScript := range ws.scripts {
+		pubKeyHashes[string(utils.HashPubKey(redeemScript))] = false
+	}
+	return pubKeyHashes
+}
@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWallets_WatchOnly(t *testing.T) {
+	bcs, err := NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer bcs.Close()
+
+	wallets, _ := NewWalletsFromFile(newTestWalletsFile(t))
+	own, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	watchedKey := NewWallet()
+	watched := NewWallet().GetAddress()
+
+	_, err = wallets.GetWallet(watched)
+	assert.ErrorIs(t, err, ErrAddressNotInWallet)
+	_, err = wallets.AddWatchOnly("nothing")
+	assert.NotNil(t, err)
+	_, err = wallets.AddWatchOnly(hex.EncodeToString(watchedKey.PublicKey[1:]))
+	assert.NotNil(t, err)
+	_, err = wallets.AddWatchOnly(own)
+	assert.NotNil(t, err)
+	address, err := wallets.AddWatchOnly(watched)
+	assert.Nil(t, err)
+	assert.Equal(t, watched, address)
+	address, err = wallets.AddWatchOnly(hex.EncodeToString(watchedKey.PublicKey))
+	assert.Nil(t, err)
+	assert.Equal(t, watchedKey.GetAddress(), address)
+
+	assert.ElementsMatch(t, []string{own, watched, address}, wallets.GetAddresses())
+	assert.Equal(t, []string{own}, wallets.GetSpendableAddresses())
+	assert.True(t, wallets.IsWatchOnly(watched))
+	assert.False(t, wallets.IsWatchOnly(own))
+	_, err = wallets.GetSigningWallet(watched)
+	assert.ErrorIs(t, err, ErrWatchOnly)
+	w, err := wallets.GetWatchOnly(address)
+	assert.Nil(t, err)
+	assert.Equal(t, watchedKey.PublicKey, w.PublicKey)
+	redeemScript, err := script.MultiSigScript(1, [][]byte{watchedKey.PublicKey})
+	assert.Nil(t, err)
+	scriptAddress, err := wallets.AddWatchOnly(ScriptHashAddress(redeemScript))
+	assert.Nil(t, err)
+	assert.Equal(t, ScriptHashAddress(redeemScript), scriptAddress)
+	assert.True(t, wallets.IsWatchOnly(scriptAddress))
+
+	b1 := MineBlock([]*Transaction{NewCoinbaseTX(watched, "b1")}, bcs.GetLatestBlock().Hash)
+	assert.Nil(t, bcs.AddBlock(b1))
+	b2 := MineBlock([]*Transaction{NewCoinbaseTX(own, "b2")}, b1.Hash)
+	assert.Nil(t, bcs.AddBlock(b2))
+	wallets.SyncChain(bcs)
+	txs := wallets.ListTransactions()
+	assert.Len(t, txs, 2)
+	assert.True(t, txs[0].WatchOnly)
+	assert.Equal(t, Subsidy, txs[0].Received)
+	assert.False(t, txs[1].WatchOnly)
+
+	assert.Nil(t, wallets.SaveToFile())
+	reloaded, err := NewWalletsFromFile(wallets.file)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, wallets.GetAddresses(), reloaded.GetAddresses())
+	assert.True(t, reloaded.IsWatchOnly(watched))
+	assert.True(t, reloaded.IsWatchOnly(scriptAddress))
+	assert.Equal(t, txs, reloaded.ListTransactions())
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// anchorPayload returns the hex data, or the SHA-256 of the file when data is empty.
+func anchorPayload(hexData, file string) []byte {
+	if hexData != "" {
+		data, err := hex.DecodeString(hexData)
+		if err != nil {
+			log.Panicf("ERROR: %s is not hex data", hexData)
+		}
+		return data
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	h := sha256.Sum256(content)
+	return h[:]
+}
+
+// anchorData puts data into an output nobody can spend, paid from the addresses from, all the spendable ones
+// when it is empty.
+func anchorData(wo *walletOptions, from []string, data []byte, mineNow bool, passphrase string, feeRate int) {
+	fmt.Printf("Data: %x\n", data)
+	opts := &blockchain.SpendOptions{FeeRate: blockchain.FeeRate(feeRate)}
+	sendWalletTx(wo, from, mineNow, passphrase, func(wallets *blockchain.Wallets, bcs *blockchain.BlockChains) (*blockchain.Transaction, error) {
+		return wallets.AnchorData(bcs, from, data, opts)
+	})
+}
+
+// findAnchors prints the main chain transactions carrying data.
+func findAnchors(data []byte) {
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+
+	anchors := bcs.FindDataAnchors(data)
+	if len(anchors) == 0 {
+		fmt.Printf("No anchor of %x\n", data)
+		return
+	}
+	for _, anchor := range anchors {
+		fmt.Printf("%s in block %d %s at %s\n", anchor.TxID, anchor.Height, anchor.BlockHash,
+			time.Unix(anchor.Timestamp, 0).UTC().Format(time.RFC3339))
+	}
+}
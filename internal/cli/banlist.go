@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/p2p"
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+func openBanList() (db.DB, *p2p.BanList) {
+	stg, err := blockchain.NewDB()
+	if err != nil {
+		log.Panic(err)
+	}
+	banList, err := p2p.NewBanList(stg)
+	if err != nil {
+		log.Panic(err)
+	}
+	return stg, banList
+}
+
+func listBanned() {
+	stg, banList := openBanList()
+	defer stg.Close()
+
+	for _, banned := range banList.List() {
+		fmt.Printf("%s\tuntil %s\t%s\n", banned.Host, banned.Until.Format(time.RFC3339), banned.Reason)
+	}
+}
+
+func setBan(address string, duration time.Duration, remove bool) {
+	stg, banList := openBanList()
+	defer stg.Close()
+
+	host := p2p.HostOf(address)
+	if remove {
+		err := banList.Unban(host)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Printf("Unbanned %s\n", host)
+		return
+	}
+
+	err := banList.Ban(host, time.Now().Add(duration), "manually added")
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Banned %s for %s\n", host, duration)
+}
+
+func clearBanned() {
+	stg, banList := openBanList()
+	defer stg.Close()
+
+	err := banList.Clear()
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Println("Done!")
+}
@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/mempool"
 )
 
 // CLI responsible for processing command line arguments.
@@ -12,13 +17,55 @@ type CLI struct{}
 
 func (cli *CLI) printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  addmultisigaddress -nrequired M -keys KEYS -label LABEL - Adds the address needing M signatures of the comma separated " +
+		"KEYS to the wallet, KEYS are hex public keys or addresses of the wallet")
+	fmt.Println("  anchordata -from FROMS -data HEX -file FILE -mine -passphrase PASS -feerate RATE - Anchors the HEX data, or the " +
+		"SHA-256 of FILE, into the chain with an unspendable output paid from the comma separated FROMS")
+	fmt.Println("  clearbanned - Removes all banned peers")
+	fmt.Println("  combinepsbt -in FILES -out FILE - Combines the signatures of the comma separated partial transaction FILES")
+	fmt.Println("  createmultisig -nrequired M -keys PUBKEYS - Prints the address and the redeem script needing M signatures of the comma " +
+		"separated hex PUBKEYS")
+	fmt.Println("  createpsbt -from FROM -to TO -amount AMOUNT -locktime LOCKTIME -out FILE - Writes the unsigned transaction sending " +
+		"AMOUNT from FROM to TO to FILE, FROM may be watch-only. It can not be mined before LOCKTIME, a height or a unix time")
+	fmt.Println("  createwallet -scheme SCHEME -passphrase PASS - Derives the next key-pair of the HD wallet and saves it into the " +
+		"wallet file, SCHEME is p256, secp256k1 or schnorr. PASS unlocks an encrypted wallet")
+	fmt.Println("  dumpmnemonic -passphrase PASS - Prints the recovery phrase of the HD wallet")
+	fmt.Println("  dumpprivkey -address ADDRESS -passphrase PASS - Prints the private key of ADDRESS in the Wallet Import Format")
+	fmt.Println("  encryptwallet -passphrase PASS - Encrypts the private keys of the wallet file with PASS")
+	fmt.Println("  finalizepsbt -in FILE -mine -miner ADDRESS - Checks the signed partial transaction and prints it for " +
+		"sendrawtransaction, or mines it paying the reward to ADDRESS when -mine is set")
+	fmt.Println("  findanchors -data HEX -file FILE - Lists the main chain transactions anchoring the HEX data, or the SHA-256 of FILE")
+	fmt.Println("  getaddressinfo -address ADDRESS - Prints the public key, or the redeem script, of the wallet address ADDRESS")
 	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
-	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
+	fmt.Println("  importaddress -target ADDRESS|PUBKEY -label LABEL - Watches ADDRESS or the hex public key PUBKEY without its private key")
+	fmt.Println("  importprivkey -key WIF -label LABEL -passphrase PASS - Adds the private key WIF to the wallet and finds its coins")
+	fmt.Println("  listaddresses - Lists all addresses from the wallet file, watch-only and multisig ones are marked")
+	fmt.Println("  listbanned - Lists all banned peers")
+	fmt.Println("  listtransactions - Lists the transactions of the wallet")
+	fmt.Println("  listwallets -datadir DIR - Lists the wallets of the data directory DIR")
 	fmt.Println("  printchain - Print all the blocks of the blockchain")
-	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT -mine - Send AMOUNT of coins from FROM address to TO. Mine on the same node, when -mine is set.")
-	fmt.Println("  startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining")
+	fmt.Println("  reindexutxo - Rebuilds the UTXO set and the data anchor index")
+	fmt.Println("  restorewallet -mnemonic WORDS -mnemonicpassphrase MPASS -gaplimit N -passphrase PASS - Restores the HD wallet " +
+		"of the recovery phrase WORDS and finds its addresses with funds, stopping after N unused addresses in a row")
+	fmt.Println("  setban -address ADDRESS -duration DURATION -remove - Bans the peer ADDRESS for DURATION, lifts the ban when -remove is set")
+	fmt.Println("  setlabel -target ADDRESS|TXID -label LABEL - Labels an address or a transaction, an empty LABEL removes the label")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT -mine -passphrase PASS -strategy STRATEGY -feerate RATE - Send AMOUNT of coins " +
+		"from FROM address to TO. Mine on the same node, when -mine is set. PASS unlocks an encrypted wallet. STRATEGY selects the " +
+		"coins: branchandbound, largestfirst, oldestfirst or randomimprove, the default tries branchandbound first. RATE is the fee per 1000 bytes. " +
+		"The change goes to a new address")
+	fmt.Println("  sendmany -from FROMS -to TO:AMOUNT,... -mine -passphrase PASS -strategy STRATEGY -feerate RATE - Pays each AMOUNT to " +
+		"its TO from the comma separated FROMS addresses, all spendable ones when empty, like send")
+	fmt.Println("  signmessage -address ADDRESS -message MESSAGE -passphrase PASS - Prints the signature of MESSAGE by the key of ADDRESS")
+	fmt.Println("  signpsbt -in FILE -out FILE -passphrase PASS -sighashtype TYPE - Signs the inputs of the partial transaction " +
+		"the wallet has the keys of, without access to the chain. TYPE is ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	fmt.Println("  startnode -miner ADDRESS -seeds SEEDS -rpclisten ADDR -rpcuser USER -rpcpassword PASS -datadir DIR -wallets NAMES " +
+		"-minrelayfee RATE - Start a node with ID specified in NODE_ID env. var. -miner enables mining, -seeds are comma separated " +
+		"host:port peers to bootstrap from, -rpclisten serves the JSON-RPC API to USER authenticated by PASS with the comma separated " +
+		"wallets NAMES of DIR loaded, created when missing, transactions paying less than RATE per 1000 bytes are not relayed")
+	fmt.Println("  verifymessage -address ADDRESS -signature SIGNATURE -message MESSAGE - Checks that SIGNATURE of MESSAGE was made by the key of ADDRESS")
+	fmt.Println("  walletpassphrasechange -old OLD -new NEW - Changes the passphrase of the encrypted wallet file")
+	fmt.Println("The wallet commands take -datadir DIR, the directory of the wallet files, and -wallet NAME, the wallet to act on, " +
+		"the default wallet DIR/wallet.dat when empty, otherwise DIR/wallets/NAME/wallet.dat")
 }
 
 func (cli *CLI) validateArgs() {
@@ -40,7 +87,51 @@ func (cli *CLI) Run() {
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	listBannedCmd := flag.NewFlagSet("listbanned", flag.ExitOnError)
+	setBanCmd := flag.NewFlagSet("setban", flag.ExitOnError)
+	clearBannedCmd := flag.NewFlagSet("clearbanned", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	passphraseChangeCmd := flag.NewFlagSet("walletpassphrasechange", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	dumpMnemonicCmd := flag.NewFlagSet("dumpmnemonic", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	finalizePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
+	listWalletsCmd := flag.NewFlagSet("listwallets", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	addMultiSigCmd := flag.NewFlagSet("addmultisigaddress", flag.ExitOnError)
+	getAddressInfoCmd := flag.NewFlagSet("getaddressinfo", flag.ExitOnError)
+	anchorDataCmd := flag.NewFlagSet("anchordata", flag.ExitOnError)
+	findAnchorsCmd := flag.NewFlagSet("findanchors", flag.ExitOnError)
+
+	createWalletOpts := addWalletFlags(createWalletCmd)
+	listAddressesOpts := addWalletFlags(listAddressesCmd)
+	sendOpts := addWalletFlags(sendCmd)
+	sendManyOpts := addWalletFlags(sendManyCmd)
+	encryptWalletOpts := addWalletFlags(encryptWalletCmd)
+	passphraseChangeOpts := addWalletFlags(passphraseChangeCmd)
+	restoreWalletOpts := addWalletFlags(restoreWalletCmd)
+	dumpMnemonicOpts := addWalletFlags(dumpMnemonicCmd)
+	listTransactionsOpts := addWalletFlags(listTransactionsCmd)
+	setLabelOpts := addWalletFlags(setLabelCmd)
+	importAddressOpts := addWalletFlags(importAddressCmd)
+	dumpPrivKeyOpts := addWalletFlags(dumpPrivKeyCmd)
+	importPrivKeyOpts := addWalletFlags(importPrivKeyCmd)
+	signPSBTOpts := addWalletFlags(signPSBTCmd)
+	signMessageOpts := addWalletFlags(signMessageCmd)
+	addMultiSigOpts := addWalletFlags(addMultiSigCmd)
+	getAddressInfoOpts := addWalletFlags(getAddressInfoCmd)
+	anchorDataOpts := addWalletFlags(anchorDataCmd)
 
 	minAddress := mineCmd.String("address", "", "mining wallet address")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
@@ -48,7 +139,80 @@ func (cli *CLI) Run() {
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendPassphrase := sendCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	sendStrategy := sendCmd.String("strategy", "", "Coin selection strategy")
+	sendFeeRate := sendCmd.Int("feerate", 0, "Fee per 1000 bytes")
+	sendManyFrom := sendManyCmd.String("from", "", "Comma separated source wallet addresses")
+	sendManyTo := sendManyCmd.String("to", "", "Comma separated ADDRESS:AMOUNT recipients")
+	sendManyMine := sendManyCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyPassphrase := sendManyCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	sendManyStrategy := sendManyCmd.String("strategy", "", "Coin selection strategy")
+	sendManyFeeRate := sendManyCmd.Int("feerate", 0, "Fee per 1000 bytes")
+	createWalletPassphrase := createWalletCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	createWalletScheme := createWalletCmd.String("scheme", "p256", "Signature scheme of the key: p256, secp256k1 or schnorr")
+	encryptWalletPassphrase := encryptWalletCmd.String("passphrase", "", "The new wallet passphrase")
+	passphraseChangeOld := passphraseChangeCmd.String("old", "", "The current wallet passphrase")
+	passphraseChangeNew := passphraseChangeCmd.String("new", "", "The new wallet passphrase")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "The recovery phrase")
+	restoreWalletMnemonicPassphrase := restoreWalletCmd.String("mnemonicpassphrase", "", "The optional passphrase of the recovery phrase")
+	restoreWalletGapLimit := restoreWalletCmd.Int("gaplimit", blockchain.DefaultGapLimit, "Unused addresses in a row ending the scan")
+	restoreWalletPassphrase := restoreWalletCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	dumpMnemonicPassphrase := dumpMnemonicCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	setLabelTarget := setLabelCmd.String("target", "", "The address or transaction id to label")
+	setLabelLabel := setLabelCmd.String("label", "", "The label")
+	importAddressTarget := importAddressCmd.String("target", "", "The address or hex public key to watch")
+	importAddressLabel := importAddressCmd.String("label", "", "The optional label of the address")
+	dumpPrivKeyAddress := dumpPrivKeyCmd.String("address", "", "The address to export the private key of")
+	dumpPrivKeyPassphrase := dumpPrivKeyCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	importPrivKeyKey := importPrivKeyCmd.String("key", "", "The private key in the Wallet Import Format")
+	importPrivKeyLabel := importPrivKeyCmd.String("label", "", "The optional label of the address")
+	importPrivKeyPassphrase := importPrivKeyCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	createPSBTFrom := createPSBTCmd.String("from", "", "Source wallet address")
+	createPSBTTo := createPSBTCmd.String("to", "", "Destination wallet address")
+	createPSBTAmount := createPSBTCmd.Int("amount", 0, "Amount to send")
+	createPSBTLockTime := createPSBTCmd.Int64("locktime", 0, "Height or unix time the transaction can not be mined before")
+	createPSBTOut := createPSBTCmd.String("out", "", "The partial transaction file to write")
+	signPSBTIn := signPSBTCmd.String("in", "", "The partial transaction file to sign")
+	signPSBTOut := signPSBTCmd.String("out", "", "The signed partial transaction file to write")
+	signPSBTPassphrase := signPSBTCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	signPSBTSigHashType := signPSBTCmd.String("sighashtype", "", "ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	combinePSBTIn := combinePSBTCmd.String("in", "", "Comma separated partial transaction files")
+	combinePSBTOut := combinePSBTCmd.String("out", "", "The combined partial transaction file to write")
+	finalizePSBTIn := finalizePSBTCmd.String("in", "", "The signed partial transaction file")
+	finalizePSBTMine := finalizePSBTCmd.Bool("mine", false, "Mine immediately on the same node")
+	finalizePSBTMiner := finalizePSBTCmd.String("miner", "", "The address of the mining reward")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	startNodeSeeds := startNodeCmd.String("seeds", "", "Comma separated host:port seed nodes")
+	startNodeRPCListen := startNodeCmd.String("rpclisten", "", "Serve JSON-RPC on the host:port")
+	startNodeRPCUser := startNodeCmd.String("rpcuser", "", "JSON-RPC user")
+	startNodeRPCPassword := startNodeCmd.String("rpcpassword", "", "JSON-RPC password")
+	startNodeDataDir := startNodeCmd.String("datadir", ".", "Directory of the wallet files")
+	startNodeWallets := startNodeCmd.String("wallets", "", "Comma separated names of the wallets the JSON-RPC server loads, the default wallet when empty")
+	startNodeMinRelayFee := startNodeCmd.Int("minrelayfee", int(mempool.DefaultMinRelayFee), "Lowest fee per 1000 bytes of the relayed transactions, 0 relays free ones")
+	listWalletsDataDir := listWalletsCmd.String("datadir", ".", "Directory of the wallet files")
+	signMessageAddress := signMessageCmd.String("address", "", "The address to sign with")
+	signMessageMessage := signMessageCmd.String("message", "", "The message to sign")
+	signMessagePassphrase := signMessageCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	verifyMessageAddress := verifyMessageCmd.String("address", "", "The address which signed")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "The base64 signature")
+	verifyMessageMessage := verifyMessageCmd.String("message", "", "The signed message")
+	createMultiSigRequired := createMultiSigCmd.Int("nrequired", 0, "How many signatures spending needs")
+	createMultiSigKeys := createMultiSigCmd.String("keys", "", "Comma separated hex public keys")
+	addMultiSigRequired := addMultiSigCmd.Int("nrequired", 0, "How many signatures spending needs")
+	addMultiSigKeys := addMultiSigCmd.String("keys", "", "Comma separated hex public keys or wallet addresses")
+	addMultiSigLabel := addMultiSigCmd.String("label", "", "The optional label of the address")
+	getAddressInfoAddress := getAddressInfoCmd.String("address", "", "The wallet address")
+	anchorDataFrom := anchorDataCmd.String("from", "", "Comma separated source wallet addresses")
+	anchorDataData := anchorDataCmd.String("data", "", "The hex data to anchor")
+	anchorDataFile := anchorDataCmd.String("file", "", "The file whose SHA-256 to anchor")
+	anchorDataMine := anchorDataCmd.Bool("mine", false, "Mine immediately on the same node")
+	anchorDataPassphrase := anchorDataCmd.String("passphrase", "", "Passphrase of the encrypted wallet")
+	anchorDataFeeRate := anchorDataCmd.Int("feerate", 0, "Fee per 1000 bytes")
+	findAnchorsData := findAnchorsCmd.String("data", "", "The anchored hex data")
+	findAnchorsFile := findAnchorsCmd.String("file", "", "The file whose SHA-256 was anchored")
+	setBanAddress := setBanCmd.String("address", "", "The peer address or host to ban")
+	setBanDuration := setBanCmd.Duration("duration", 24*time.Hour, "How long the ban lasts")
+	setBanRemove := setBanCmd.Bool("remove", false, "Lift the ban instead")
 
 	switch os.Args[1] {
 	case "mine":
@@ -86,11 +250,136 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "sendmany":
+		err := sendManyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "startnode":
 		err := startNodeCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "listbanned":
+		err := listBannedCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setban":
+		err := setBanCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "clearbanned":
+		err := clearBannedCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrasechange":
+		err := passphraseChangeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpmnemonic":
+		err := dumpMnemonicCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpprivkey":
+		err := dumpPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importprivkey":
+		err := importPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createpsbt":
+		err := createPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signpsbt":
+		err := signPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "combinepsbt":
+		err := combinePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepsbt":
+		err := finalizePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listwallets":
+		err := listWalletsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmessage":
+		err := signMessageCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifymessage":
+		err := verifyMessageCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createmultisig":
+		err := createMultiSigCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "addmultisigaddress":
+		err := addMultiSigCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getaddressinfo":
+		err := getAddressInfoCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "anchordata":
+		err := anchorDataCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "findanchors":
+		err := findAnchorsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	default:
 		cli.printUsage()
 		os.Exit(1)
@@ -113,11 +402,11 @@ func (cli *CLI) Run() {
 	}
 
 	if createWalletCmd.Parsed() {
-		createWallet()
+		createWallet(createWalletOpts, *createWalletPassphrase, *createWalletScheme)
 	}
 
 	if listAddressesCmd.Parsed() {
-		listAddresses()
+		listAddresses(listAddressesOpts)
 	}
 
 	if printChainCmd.Parsed() {
@@ -134,7 +423,23 @@ func (cli *CLI) Run() {
 			os.Exit(1)
 		}
 
-		send(*sendFrom, *sendTo, *sendAmount, *sendMine)
+		send(sendOpts, *sendFrom, *sendTo, *sendAmount, *sendMine, *sendPassphrase, *sendStrategy, *sendFeeRate)
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyTo == "" {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		recipients, err := parseRecipients(*sendManyTo)
+		if err != nil {
+			log.Panic(err)
+		}
+		var from []string
+		if *sendManyFrom != "" {
+			from = strings.Split(*sendManyFrom, ",")
+		}
+		sendMany(sendManyOpts, from, recipients, *sendManyMine, *sendManyPassphrase, *sendManyStrategy, *sendManyFeeRate)
 	}
 
 	if startNodeCmd.Parsed() {
@@ -143,6 +448,189 @@ func (cli *CLI) Run() {
 			startNodeCmd.Usage()
 			os.Exit(1)
 		}
-		startNode(nodeID, *startNodeMiner)
+		startNode(nodeID, *startNodeMiner, *startNodeSeeds, blockchain.FeeRate(*startNodeMinRelayFee), rpcOptions{
+			Listen:   *startNodeRPCListen,
+			User:     *startNodeRPCUser,
+			Password: *startNodeRPCPassword,
+			DataDir:  *startNodeDataDir,
+			Wallets:  strings.Split(*startNodeWallets, ","),
+		})
+	}
+
+	if listBannedCmd.Parsed() {
+		listBanned()
+	}
+
+	if setBanCmd.Parsed() {
+		if *setBanAddress == "" || (*setBanDuration <= 0 && !*setBanRemove) {
+			setBanCmd.Usage()
+			os.Exit(1)
+		}
+		setBan(*setBanAddress, *setBanDuration, *setBanRemove)
+	}
+
+	if clearBannedCmd.Parsed() {
+		clearBanned()
+	}
+
+	if encryptWalletCmd.Parsed() {
+		if *encryptWalletPassphrase == "" {
+			encryptWalletCmd.Usage()
+			os.Exit(1)
+		}
+		encryptWallet(encryptWalletOpts, *encryptWalletPassphrase)
+	}
+
+	if passphraseChangeCmd.Parsed() {
+		if *passphraseChangeOld == "" || *passphraseChangeNew == "" {
+			passphraseChangeCmd.Usage()
+			os.Exit(1)
+		}
+		changeWalletPassphrase(passphraseChangeOpts, *passphraseChangeOld, *passphraseChangeNew)
+	}
+
+	if restoreWalletCmd.Parsed() {
+		if *restoreWalletMnemonic == "" {
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+		restoreWallet(restoreWalletOpts, *restoreWalletMnemonic, *restoreWalletMnemonicPassphrase, *restoreWalletGapLimit, *restoreWalletPassphrase)
+	}
+
+	if dumpMnemonicCmd.Parsed() {
+		dumpMnemonic(dumpMnemonicOpts, *dumpMnemonicPassphrase)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		listTransactions(listTransactionsOpts)
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelTarget == "" {
+			setLabelCmd.Usage()
+			os.Exit(1)
+		}
+		setLabel(setLabelOpts, *setLabelTarget, *setLabelLabel)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressTarget == "" {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		importAddress(importAddressOpts, *importAddressTarget, *importAddressLabel)
+	}
+
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddress == "" {
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		dumpPrivKey(dumpPrivKeyOpts, *dumpPrivKeyAddress, *dumpPrivKeyPassphrase)
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		if *importPrivKeyKey == "" {
+			importPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		importPrivKey(importPrivKeyOpts, *importPrivKeyKey, *importPrivKeyLabel, *importPrivKeyPassphrase)
+	}
+
+	if createPSBTCmd.Parsed() {
+		if *createPSBTFrom == "" || *createPSBTTo == "" || *createPSBTAmount <= 0 || *createPSBTLockTime < 0 || *createPSBTOut == "" {
+			createPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		createPartialTx(*createPSBTFrom, *createPSBTTo, *createPSBTAmount, *createPSBTLockTime, *createPSBTOut)
+	}
+
+	if signPSBTCmd.Parsed() {
+		if *signPSBTIn == "" || *signPSBTOut == "" {
+			signPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		signPartialTx(signPSBTOpts, *signPSBTIn, *signPSBTOut, *signPSBTPassphrase, *signPSBTSigHashType)
+	}
+
+	if combinePSBTCmd.Parsed() {
+		if *combinePSBTIn == "" || *combinePSBTOut == "" {
+			combinePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		combinePartialTxs(strings.Split(*combinePSBTIn, ","), *combinePSBTOut)
+	}
+
+	if finalizePSBTCmd.Parsed() {
+		if *finalizePSBTIn == "" || (*finalizePSBTMine && *finalizePSBTMiner == "") {
+			finalizePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		finalizePartialTx(*finalizePSBTIn, *finalizePSBTMine, *finalizePSBTMiner)
+	}
+
+	if listWalletsCmd.Parsed() {
+		listWallets(*listWalletsDataDir)
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddress == "" {
+			signMessageCmd.Usage()
+			os.Exit(1)
+		}
+		signMessage(signMessageOpts, *signMessageAddress, *signMessageMessage, *signMessagePassphrase)
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageSignature == "" {
+			verifyMessageCmd.Usage()
+			os.Exit(1)
+		}
+		verifyMessage(*verifyMessageAddress, *verifyMessageSignature, *verifyMessageMessage)
+	}
+
+	if createMultiSigCmd.Parsed() {
+		if *createMultiSigRequired <= 0 || *createMultiSigKeys == "" {
+			createMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		createMultiSig(*createMultiSigRequired, *createMultiSigKeys)
+	}
+
+	if addMultiSigCmd.Parsed() {
+		if *addMultiSigRequired <= 0 || *addMultiSigKeys == "" {
+			addMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		addMultiSigAddress(addMultiSigOpts, *addMultiSigRequired, *addMultiSigKeys, *addMultiSigLabel)
+	}
+
+	if getAddressInfoCmd.Parsed() {
+		if *getAddressInfoAddress == "" {
+			getAddressInfoCmd.Usage()
+			os.Exit(1)
+		}
+		getAddressInfo(getAddressInfoOpts, *getAddressInfoAddress)
+	}
+
+	if anchorDataCmd.Parsed() {
+		if (*anchorDataData == "") == (*anchorDataFile == "") {
+			anchorDataCmd.Usage()
+			os.Exit(1)
+		}
+		var from []string
+		if *anchorDataFrom != "" {
+			from = strings.Split(*anchorDataFrom, ",")
+		}
+		anchorData(anchorDataOpts, from, anchorPayload(*anchorDataData, *anchorDataFile), *anchorDataMine,
+			*anchorDataPassphrase, *anchorDataFeeRate)
+	}
+
+	if findAnchorsCmd.Parsed() {
+		if (*findAnchorsData == "") == (*findAnchorsFile == "") {
+			findAnchorsCmd.Usage()
+			os.Exit(1)
+		}
+		findAnchors(anchorPayload(*findAnchorsData, *findAnchorsFile))
 	}
 }
@@ -2,14 +2,46 @@ package cli
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 )
 
-func createWallet() {
-	wallets, _ := blockchain.NewWallets()
-	address := wallets.CreateWallet()
-	wallets.SaveToFile()
+func createWallet(wo *walletOptions, passphrase, schemeName string) {
+	scheme, err := utils.ParseScheme(schemeName)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets := wo.open()
+	unlockWallets(wallets, passphrase)
+	newSeed := !wallets.HasHDSeed()
+	address, err := wallets.CreateWalletWithScheme(scheme)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
 
+	if newSeed {
+		mnemonic, _ := wallets.Mnemonic()
+		fmt.Printf("Your wallet recovery phrase, write it down: %s\n", mnemonic)
+	}
 	fmt.Printf("Your new address: %s\n", address)
 }
+
+// unlockWallets unlocks an encrypted wallet for the command.
+func unlockWallets(wallets *blockchain.Wallets, passphrase string) {
+	if !wallets.IsEncrypted() {
+		return
+	}
+	if passphrase == "" {
+		log.Panic("ERROR: The wallet is encrypted, pass -passphrase")
+	}
+	err := wallets.Unlock(passphrase, 0)
+	if err != nil {
+		log.Panic(err)
+	}
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+)
+
+func encryptWallet(wo *walletOptions, passphrase string) {
+	wallets := wo.load()
+	err := wallets.Encrypt(passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Wallet encrypted")
+}
+
+func changeWalletPassphrase(wo *walletOptions, oldPassphrase, newPassphrase string) {
+	wallets := wo.load()
+	err := wallets.ChangePassphrase(oldPassphrase, newPassphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Wallet passphrase changed")
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// importAddress watches an address or a hex public key and picks up its history.
+func importAddress(wo *walletOptions, target, label string) {
+	wallets := wo.open()
+	address, err := wallets.AddWatchOnly(target)
+	if err != nil {
+		log.Panic(err)
+	}
+	if label != "" {
+		_ = wallets.SetLabel(address, label)
+	}
+
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+	wallets.SyncChain(bcs)
+	err = wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Watching address: %s\n", address)
+}
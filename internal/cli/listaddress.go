@@ -1,20 +1,20 @@
 package cli
 
-import (
-	"fmt"
-	"log"
+import "fmt"
 
-	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
-)
-
-func listAddresses() {
-	wallets, err := blockchain.NewWallets()
-	if err != nil {
-		log.Panic(err)
-	}
+func listAddresses(wo *walletOptions) {
+	wallets := wo.load()
 	addresses := wallets.GetAddresses()
 
 	for _, address := range addresses {
+		if wallets.IsWatchOnly(address) {
+			fmt.Printf("%s (watch-only)\n", address)
+			continue
+		}
+		if wallets.IsMultiSig(address) {
+			fmt.Printf("%s (multisig)\n", address)
+			continue
+		}
 		fmt.Println(address)
 	}
 }
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+func listTransactions(wo *walletOptions) {
+	wallets := wo.load()
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+
+	wallets.SyncChain(bcs)
+	err := wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	best := bcs.GetBestHeight()
+	for _, wtx := range wallets.ListTransactions() {
+		fmt.Printf("============ Transaction %s ============\n", wtx.TxID)
+		fmt.Printf("Block: %s (height %d, %d confirmations)\n", wtx.BlockHash, wtx.Height, wtx.Confirmations(best))
+		fmt.Printf("Amount: %d\n", wtx.Net())
+		if wtx.Fee > 0 {
+			fmt.Printf("Fee: %d\n", wtx.Fee)
+		}
+		if wtx.Coinbase {
+			fmt.Println("Coinbase: true")
+		}
+		counterparties := make([]string, 0, len(wtx.Counterparties))
+		for _, address := range wtx.Counterparties {
+			if label := wallets.Label(address); label != "" {
+				address += " (" + label + ")"
+			}
+			counterparties = append(counterparties, address)
+		}
+		if len(counterparties) > 0 {
+			fmt.Printf("Counterparties: %s\n", strings.Join(counterparties, ", "))
+		}
+		if wtx.Label != "" {
+			fmt.Printf("Label: %s\n", wtx.Label)
+		}
+		fmt.Println()
+	}
+}
+
+func setLabel(wo *walletOptions, target, label string) {
+	wallets := wo.load()
+	err := wallets.SetLabel(target, label)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Success!")
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// signMessage prints the signature of message by the key of address.
+func signMessage(wo *walletOptions, address, message, passphrase string) {
+	wallets := wo.load()
+	unlockWallets(wallets, passphrase)
+	signature, err := wallets.SignMessage(address, message)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(signature)
+}
+
+// verifyMessage checks that signature of message was made by the key of address.
+func verifyMessage(address, signature, message string) {
+	ok, err := blockchain.VerifyMessage(address, signature, message)
+	if err != nil {
+		log.Panic(err)
+	}
+	if !ok {
+		fmt.Println("Signature is not valid")
+		os.Exit(1)
+	}
+
+	fmt.Println("Signature is valid")
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+)
+
+// createMultiSig prints the address and the redeem script of the nRequired of the comma separated hex public
+// keys multisig, without a wallet.
+func createMultiSig(nRequired int, keys string) {
+	pubKeys := make([][]byte, 0)
+	for _, key := range strings.Split(keys, ",") {
+		pubKey, err := hex.DecodeString(strings.TrimSpace(key))
+		if err != nil {
+			log.Panicf("ERROR: %s is not a hex public key", key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	address, redeemScript, err := blockchain.CreateMultiSig(nRequired, pubKeys)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Address: %s\n", address)
+	fmt.Printf("Redeem script: %x\n", redeemScript)
+}
+
+// addMultiSigAddress adds the nRequired of the comma separated keys multisig to the wallet and picks up its
+// history, the keys are hex public keys or addresses of the wallet.
+func addMultiSigAddress(wo *walletOptions, nRequired int, keys, label string) {
+	wallets := wo.load()
+	address, err := wallets.AddMultiSigAddress(nRequired, strings.Split(keys, ","))
+	if err != nil {
+		log.Panic(err)
+	}
+	if label != "" {
+		_ = wallets.SetLabel(address, label)
+	}
+
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+	wallets.SyncChain(bcs)
+	err = wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Multisig address: %s\n", address)
+}
+
+// getAddressInfo prints what the wallet knows of address, the public key to give to the cosigners of a
+// multisig address.
+func getAddressInfo(wo *walletOptions, address string) {
+	wallets := wo.load()
+	fmt.Printf("Address: %s\n", address)
+	if wallet, err := wallets.GetWallet(address); err == nil {
+		fmt.Printf("Public key: %x\n", wallet.PublicKey)
+		return
+	}
+	if w, err := wallets.GetWatchOnly(address); err == nil {
+		fmt.Println("Watch-only: true")
+		if len(w.PublicKey) > 0 {
+			fmt.Printf("Public key: %x\n", w.PublicKey)
+		}
+		return
+	}
+	redeemScript, err := wallets.GetRedeemScript(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Redeem script: %x\n", redeemScript)
+	fmt.Printf("Script: %s\n", script.Disasm(redeemScript))
+}
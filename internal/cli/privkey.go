@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// dumpPrivKey prints the private key of address in the Wallet Import Format.
+func dumpPrivKey(wo *walletOptions, address, passphrase string) {
+	wallets := wo.load()
+	unlockWallets(wallets, passphrase)
+	key, err := wallets.DumpPrivKey(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(key)
+}
+
+// importPrivKey adds the WIF private key to the wallet and rescans the chain for its coins.
+func importPrivKey(wo *walletOptions, key, label, passphrase string) {
+	wallets := wo.open()
+	unlockWallets(wallets, passphrase)
+	address, err := wallets.ImportPrivKey(key)
+	if err != nil {
+		log.Panic(err)
+	}
+	if label != "" {
+		_ = wallets.SetLabel(address, label)
+	}
+
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+	wallets.SyncChain(bcs)
+	err = wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Imported '%s': %d\n", address, bcs.GetBalance(address))
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// The partial transaction files hold the hex encoded blockchain.PartialTx.
+
+func readPartialTx(file string) *blockchain.PartialTx {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	data, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		log.Panic(err)
+	}
+	p, err := blockchain.DeserializePartialTx(data)
+	if err != nil {
+		log.Panic(err)
+	}
+	return p
+}
+
+func writePartialTx(file string, p *blockchain.PartialTx) {
+	err := ioutil.WriteFile(file, []byte(hex.EncodeToString(p.Serialize())+"\n"), 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+func printPartialTx(p *blockchain.PartialTx) {
+	for idx, prevout := range p.Prevouts {
+		signed := p.Tx.Vin[idx].IsSigned()
+		fmt.Printf("Input %d: %d from %s, signed: %t\n", idx, prevout.Value, prevout.Address(), signed)
+	}
+	for idx, output := range p.Tx.Vout {
+		fmt.Printf("Output %d: %d to %s\n", idx, output.Value, output.Address())
+	}
+	fmt.Printf("Fee: %d\n", p.Fee())
+}
+
+// createPartialTx writes the unsigned transaction paying amount from the address from to out, on the
+// online node. The transaction can not be mined before lockTime, a height or a unix time.
+func createPartialTx(from, to string, amount int, lockTime int64, out string) {
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+
+	p, err := blockchain.CreatePartialTx(bcs, from, to, amount, &blockchain.SpendOptions{LockTime: lockTime})
+	if err != nil {
+		log.Panic(err)
+	}
+	writePartialTx(out, p)
+	printPartialTx(p)
+}
+
+// signPartialTx signs the inputs of in the wallet has the keys of, with sigHashType unless it is empty. It
+// needs no chain so it runs offline.
+func signPartialTx(wo *walletOptions, in, out, passphrase, sigHashType string) {
+	wallets := wo.load()
+	unlockWallets(wallets, passphrase)
+
+	p := readPartialTx(in)
+	if sigHashType != "" {
+		var err error
+		p.SigHashType, err = blockchain.ParseSigHashType(sigHashType)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	signed, err := wallets.SignPartialTx(p)
+	if err != nil {
+		log.Panic(err)
+	}
+	writePartialTx(out, p)
+	printPartialTx(p)
+	fmt.Printf("Signed %d inputs, complete: %t\n", signed, p.IsComplete())
+}
+
+func combinePartialTxs(ins []string, out string) {
+	p := readPartialTx(ins[0])
+	for _, in := range ins[1:] {
+		err := p.Combine(readPartialTx(in))
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	writePartialTx(out, p)
+	fmt.Printf("Complete: %t\n", p.IsComplete())
+}
+
+// finalizePartialTx checks the signed transaction against the chain and mines it on the same node when
+// mineNow is set, otherwise it prints the transaction for sendrawtransaction.
+func finalizePartialTx(in string, mineNow bool, miner string) {
+	tx, err := readPartialTx(in).Finalize()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+	cond, err := bcs.GetCond4TransactionVerify(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = tx.Verify(cond)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if mineNow {
+		txs := []*blockchain.Transaction{blockchain.NewCoinbaseTX(miner, ""), tx}
+		err = bcs.AddBlock(blockchain.MineBlock(txs, bcs.GetLatestBlock().Hash))
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Printf("Mined transaction %s\n", tx.TxID)
+		return
+	}
+	fmt.Println(hex.EncodeToString(tx.Serialize()))
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+func restoreWallet(wo *walletOptions, mnemonic, mnemonicPassphrase string, gapLimit int, passphrase string) {
+	wallets := wo.open()
+	unlockWallets(wallets, passphrase)
+	err := wallets.SetHDSeed(mnemonic, mnemonicPassphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bcs, _ := blockchain.NewBlockChains()
+	defer bcs.Close()
+
+	addresses, err := wallets.Rescan(bcs, gapLimit)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SyncChain(bcs)
+	err = wallets.SaveToFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, address := range addresses {
+		fmt.Printf("Found '%s': %d\n", address, bcs.GetBalance(address))
+	}
+	fmt.Printf("Restored %d addresses with funds\n", len(addresses))
+}
+
+func dumpMnemonic(wo *walletOptions, passphrase string) {
+	wallets := wo.load()
+	unlockWallets(wallets, passphrase)
+	mnemonic, err := wallets.Mnemonic()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(mnemonic)
+}
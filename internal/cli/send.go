@@ -3,35 +3,92 @@ package cli
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 )
 
-func send(from, to string, amount int, mineNow bool) {
+func send(wo *walletOptions, from, to string, amount int, mineNow bool, passphrase, strategy string, feeRate int) {
 	if !utils.IsValidAddress(from) {
 		log.Panic("ERROR: Sender address is not valid")
 	}
 	if !utils.IsValidAddress(to) {
 		log.Panic("ERROR: Recipient address is not valid")
 	}
+	sendMany(wo, []string{from}, []blockchain.Recipient{{Address: to, Amount: amount}}, mineNow, passphrase, strategy, feeRate)
+}
+
+// parseRecipients parses the ADDRESS:AMOUNT pairs of sendmany, separated by commas.
+func parseRecipients(s string) ([]blockchain.Recipient, error) {
+	recipients := make([]blockchain.Recipient, 0)
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("recipient %q is not ADDRESS:AMOUNT", pair)
+		}
+		if !utils.IsValidAddress(parts[0]) {
+			return nil, fmt.Errorf("recipient address %s is not valid", parts[0])
+		}
+		amount, err := strconv.Atoi(parts[1])
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("amount %q to %s is not valid", parts[1], parts[0])
+		}
+		recipients = append(recipients, blockchain.Recipient{Address: parts[0], Amount: amount})
+	}
+	return recipients, nil
+}
 
+// sendMany pays the recipients from the addresses from, all the spendable ones when it is empty. The change
+// goes to a new address of the wallet.
+func sendMany(wo *walletOptions, from []string, recipients []blockchain.Recipient, mineNow bool, passphrase, strategy string, feeRate int) {
+	opts := &blockchain.SpendOptions{FeeRate: blockchain.FeeRate(feeRate)}
+	if strategy != "" {
+		selector, err := blockchain.CoinSelectorByName(strategy)
+		if err != nil {
+			log.Panic(err)
+		}
+		opts.Selector = selector
+	}
+
+	sendWalletTx(wo, from, mineNow, passphrase, func(wallets *blockchain.Wallets, bcs *blockchain.BlockChains) (*blockchain.Transaction, error) {
+		return wallets.Send(bcs, from, recipients, opts)
+	})
+}
+
+// sendWalletTx unlocks the wallet for the transaction create builds from the addresses from, and mines it
+// at once when mineNow is set.
+func sendWalletTx(wo *walletOptions, from []string, mineNow bool, passphrase string,
+	create func(wallets *blockchain.Wallets, bcs *blockchain.BlockChains) (*blockchain.Transaction, error)) {
 	bcs, _ := blockchain.NewBlockChains()
 	defer bcs.Close()
 
-	wallets, err := blockchain.NewWallets()
+	wallets := wo.load()
+	for _, address := range from {
+		if wallets.IsWatchOnly(address) {
+			log.Panicf("ERROR: The sender address %s is watch-only, its private key is not in the wallet", address)
+		}
+	}
+	unlockWallets(wallets, passphrase)
+
+	tx, err := create(wallets, bcs)
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
-
-	tx, err := blockchain.NewUTXOTransaction(&wallet, to, amount, nil, bcs)
+	// the change key is new
+	err = wallets.SaveToFile()
 	if err != nil {
 		log.Panic(err)
 	}
 
 	if mineNow {
-		cbTx := blockchain.NewCoinbaseTX(from, "")
+		if len(from) == 0 {
+			from = wallets.GetSpendableAddresses()
+			sort.Strings(from)
+		}
+		cbTx := blockchain.NewCoinbaseTX(from[0], "")
 		txs := []*blockchain.Transaction{cbTx, tx}
 
 		err = bcs.AddBlock(blockchain.MineBlock(txs, bcs.GetLatestBlock().Hash))
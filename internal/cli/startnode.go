@@ -3,11 +3,32 @@ package cli
 import (
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/jiuzhou-zhao/blockchain.go/internal/addrmgr"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/p2p"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/rpcserver"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
 )
 
-func startNode(nodeID, minerAddress string) {
+const minePollInterval = 10 * time.Second
+
+// rpcOptions enables the JSON-RPC server of a node when Listen is set, the server loads the Wallets of DataDir.
+type rpcOptions struct {
+	Listen   string
+	User     string
+	Password string
+	DataDir  string
+	Wallets  []string
+}
+
+// nolint: funlen
+func startNode(nodeID, minerAddress, seeds string, minRelayFee blockchain.FeeRate, rpcOpts rpcOptions) {
 	fmt.Printf("Starting node %s\n", nodeID)
 	if len(minerAddress) > 0 {
 		if utils.IsValidAddress(minerAddress) {
@@ -16,5 +37,93 @@ func startNode(nodeID, minerAddress string) {
 			log.Panic("Wrong miner address!")
 		}
 	}
-	// StartServer(nodeID, minerAddress)
+
+	stg, err := blockchain.NewDB()
+	if err != nil {
+		log.Panic(err)
+	}
+	chains, err := blockchain.NewBlockChainsWithDB(stg)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer chains.Close()
+	banList, err := p2p.NewBanList(stg)
+	if err != nil {
+		log.Panic(err)
+	}
+	addrManager := addrmgr.New(fmt.Sprintf("peers_%s.dat", nodeID))
+	err = addrManager.Load()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	cfg := &p2p.Config{MinRelayFee: minRelayFee, FreeRelay: minRelayFee == 0}
+	for _, seed := range strings.Split(seeds, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			cfg.Seeds = append(cfg.Seeds, seed)
+		}
+	}
+
+	node := p2p.NewNode(chains, banList, addrManager, cfg)
+	err = node.Listen("localhost:" + nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	node.Start()
+	defer node.Close()
+
+	if rpcOpts.Listen != "" {
+		wallets := blockchain.NewWalletManager(rpcOpts.DataDir)
+		for _, name := range rpcOpts.Wallets {
+			_, errWallet := wallets.OpenWallet(strings.TrimSpace(name))
+			if errWallet != nil {
+				log.Panic(errWallet)
+			}
+		}
+		defer func() {
+			errSave := wallets.SaveAll()
+			if errSave != nil {
+				log.Println(errSave)
+			}
+		}()
+		server, errServer := rpcserver.New(rpcserver.Config{
+			Listen:   rpcOpts.Listen,
+			User:     rpcOpts.User,
+			Password: rpcOpts.Password,
+		}, node, wallets)
+		if errServer != nil {
+			log.Panic(errServer)
+		}
+		errServer = server.Start()
+		if errServer != nil {
+			log.Panic(errServer)
+		}
+		defer func() {
+			_ = server.Stop()
+		}()
+		fmt.Printf("JSON-RPC server listening on %s\n", server.Addr())
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(minePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			fmt.Println("Stopping node")
+			return
+		case <-ticker.C:
+			if minerAddress == "" || node.TxPool().Count() == 0 {
+				continue
+			}
+			block, errMine := node.Mine(minerAddress, node.TxPool().Transactions()...)
+			if errMine != nil {
+				fmt.Printf("Mine failed: %v\n", errMine)
+				continue
+			}
+			fmt.Printf("New block %s is mined!\n", block.Hash)
+		}
+	}
 }
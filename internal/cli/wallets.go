@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// walletOptions select the wallet a command acts on among the wallets of a data directory.
+type walletOptions struct {
+	dataDir string
+	name    string
+}
+
+func addWalletFlags(cmd *flag.FlagSet) *walletOptions {
+	opts := &walletOptions{}
+	cmd.StringVar(&opts.dataDir, "datadir", ".", "Directory of the wallet files")
+	cmd.StringVar(&opts.name, "wallet", blockchain.DefaultWalletName, "Name of the wallet, the default wallet when empty")
+	return opts
+}
+
+// load loads the wallet, which has to exist.
+func (opts *walletOptions) load() *blockchain.Wallets {
+	wallets, err := blockchain.NewWalletManager(opts.dataDir).LoadWallet(opts.name)
+	if err != nil {
+		log.Panic(err)
+	}
+	return wallets
+}
+
+// open loads the wallet, creating it when it does not exist.
+func (opts *walletOptions) open() *blockchain.Wallets {
+	wallets, err := blockchain.NewWalletManager(opts.dataDir).OpenWallet(opts.name)
+	if err != nil {
+		log.Panic(err)
+	}
+	return wallets
+}
+
+// listWallets prints the wallets of the data directory.
+func listWallets(dataDir string) {
+	names, err := blockchain.NewWalletManager(dataDir).ListWalletDir()
+	if err != nil {
+		log.Panic(err)
+	}
+	for _, name := range names {
+		if name == blockchain.DefaultWalletName {
+			name = "(default)"
+		}
+		fmt.Println(name)
+	}
+}
@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+var (
+	errDBClosed        = errors.New("database not open")
+	errTxNotWritable   = errors.New("tx not writable")
+	errBucketExists    = errors.New("bucket already exists")
+	errBucketNotFound  = errors.New("bucket not found")
+	errBucketNameEmpty = errors.New("bucket name required")
+	errKeyEmpty        = errors.New("key required")
+)
+
+type buckets map[string]map[string][]byte
+
+func (bs buckets) clone() buckets {
+	n := make(buckets, len(bs))
+	for name, kvs := range bs {
+		nkvs := make(map[string][]byte, len(kvs))
+		for k, v := range kvs {
+			nkvs[k] = v
+		}
+		n[name] = nkvs
+	}
+	return n
+}
+
+type dbImpl struct {
+	lock    sync.RWMutex
+	closed  bool
+	buckets buckets
+}
+
+// NewDB returns an empty in-memory db.DB, it follows the bolt semantics the chain relies on:
+// Update is atomic and rolled back on error, cursors walk keys in byte order.
+func NewDB() db.DB {
+	return &dbImpl{
+		buckets: make(buckets),
+	}
+}
+
+func (impl *dbImpl) Close() error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	impl.closed = true
+	return nil
+}
+
+func (impl *dbImpl) Update(fn func(db.Tx) error) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	if impl.closed {
+		return errDBClosed
+	}
+
+	tx := newTx(impl.buckets.clone(), true)
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	impl.buckets = tx.buckets
+	return nil
+}
+
+func (impl *dbImpl) View(fn func(db.Tx) error) error {
+	impl.lock.RLock()
+	defer impl.lock.RUnlock()
+	if impl.closed {
+		return errDBClosed
+	}
+
+	return fn(newTx(impl.buckets, false))
+}
+
+func (impl *dbImpl) Destroy() error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	impl.buckets = make(buckets)
+	return nil
+}
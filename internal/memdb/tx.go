@@ -0,0 +1,120 @@
+package memdb
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+type txImpl struct {
+	buckets  buckets
+	writable bool
+}
+
+func newTx(bs buckets, writable bool) *txImpl {
+	return &txImpl{
+		buckets:  bs,
+		writable: writable,
+	}
+}
+
+func (impl *txImpl) CreateBucket(name []byte) (db.Bucket, error) {
+	if !impl.writable {
+		return nil, errTxNotWritable
+	}
+	if len(name) == 0 {
+		return nil, errBucketNameEmpty
+	}
+	if _, ok := impl.buckets[string(name)]; ok {
+		return nil, errBucketExists
+	}
+	kvs := make(map[string][]byte)
+	impl.buckets[string(name)] = kvs
+	return &bucketImpl{tx: impl, kvs: kvs}, nil
+}
+
+func (impl *txImpl) Bucket(name []byte) db.Bucket {
+	kvs, ok := impl.buckets[string(name)]
+	if !ok {
+		return nil
+	}
+	return &bucketImpl{tx: impl, kvs: kvs}
+}
+
+func (impl *txImpl) DeleteBucket(name []byte) error {
+	if !impl.writable {
+		return errTxNotWritable
+	}
+	if _, ok := impl.buckets[string(name)]; !ok {
+		return errBucketNotFound
+	}
+	delete(impl.buckets, string(name))
+	return nil
+}
+
+type bucketImpl struct {
+	tx  *txImpl
+	kvs map[string][]byte
+}
+
+func (impl *bucketImpl) Put(key []byte, value []byte) error {
+	if !impl.tx.writable {
+		return errTxNotWritable
+	}
+	if len(key) == 0 {
+		return errKeyEmpty
+	}
+	impl.kvs[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (impl *bucketImpl) Get(key []byte) []byte {
+	return impl.kvs[string(key)]
+}
+
+func (impl *bucketImpl) Delete(key []byte) error {
+	if !impl.tx.writable {
+		return errTxNotWritable
+	}
+	delete(impl.kvs, string(key))
+	return nil
+}
+
+func (impl *bucketImpl) Cursor() db.Cursor {
+	keys := make([][]byte, 0, len(impl.kvs))
+	for k := range impl.kvs {
+		keys = append(keys, []byte(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	return &cursorImpl{bucket: impl, keys: keys}
+}
+
+type cursorImpl struct {
+	bucket *bucketImpl
+	keys   [][]byte
+	pos    int
+}
+
+func (impl *cursorImpl) First() (key []byte, value []byte) {
+	impl.pos = 0
+	return impl.current()
+}
+
+func (impl *cursorImpl) Next() (key []byte, value []byte) {
+	impl.pos++
+	return impl.current()
+}
+
+func (impl *cursorImpl) current() (key []byte, value []byte) {
+	for ; impl.pos < len(impl.keys); impl.pos++ {
+		var ok bool
+		key = impl.keys[impl.pos]
+		if value, ok = impl.bucket.kvs[string(key)]; ok {
+			return
+		}
+	}
+	return nil, nil
+}
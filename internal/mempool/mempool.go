@@ -0,0 +1,180 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+var (
+	ErrCoinbase    = errors.New("coinbase transaction")
+	ErrDuplicate   = errors.New("transaction already in pool")
+	ErrDoubleSpend = errors.New("output already spent in pool")
+)
+
+type outPoint struct {
+	txID  string
+	index int
+}
+
+// TxPool holds the verified transactions which are not on the main chain yet.
+// The chains are only read while accepting or pruning, callers serialize those calls with chain updates.
+type TxPool struct {
+	lock   sync.RWMutex
+	chains *blockchain.BlockChains
+	pool   map[string]*blockchain.Transaction
+	spends map[outPoint]string
+	locks  *blockchain.CoinLocks
+	policy Policy
+}
+
+func New(chains *blockchain.BlockChains) *TxPool {
+	return NewWithPolicy(chains, DefaultPolicy)
+}
+
+// NewWithPolicy returns a pool taking the transactions which follow policy.
+func NewWithPolicy(chains *blockchain.BlockChains, policy Policy) *TxPool {
+	return &TxPool{
+		chains: chains,
+		pool:   make(map[string]*blockchain.Transaction),
+		spends: make(map[outPoint]string),
+		locks:  blockchain.NewCoinLocks(),
+		policy: policy,
+	}
+}
+
+// MaybeAcceptTransaction verifies the transaction against the UTXO set, the pool and the policy of the pool, and
+// adds it on success.
+func (mp *TxPool) MaybeAcceptTransaction(tx *blockchain.Transaction) error {
+	if tx == nil {
+		return errors.New("nil transaction")
+	}
+	err := tx.Check()
+	if err != nil {
+		return err
+	}
+	if tx.IsCoinbase() {
+		return ErrCoinbase
+	}
+	err = tx.CheckLimits(mp.chains.Params())
+	if err != nil {
+		return err
+	}
+	err = mp.policy.CheckStandard(tx)
+	if err != nil {
+		return err
+	}
+
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	if _, ok := mp.pool[tx.TxID]; ok {
+		return ErrDuplicate
+	}
+	for _, input := range tx.Vin {
+		if spender, ok := mp.spends[outPoint{input.Txid, input.Vout}]; ok {
+			return fmt.Errorf("%w: %s,%d by %s", ErrDoubleSpend, input.Txid, input.Vout, spender)
+		}
+	}
+
+	err = mp.chains.CheckTransactionLocks(tx)
+	if err != nil {
+		return err
+	}
+	// the inputs checked here are in the signature cache when the block of tx arrives
+	err = mp.chains.VerifyTransaction(tx)
+	if err != nil {
+		return err
+	}
+	// the amounts of the inputs are those of the outputs they spend once verified
+	err = mp.policy.CheckFee(tx)
+	if err != nil {
+		return err
+	}
+
+	mp.addTransaction(tx)
+	return nil
+}
+
+func (mp *TxPool) addTransaction(tx *blockchain.Transaction) {
+	mp.pool[tx.TxID] = tx
+	for _, input := range tx.Vin {
+		mp.spends[outPoint{input.Txid, input.Vout}] = tx.TxID
+	}
+	mp.locks.LockTx(tx)
+}
+
+func (mp *TxPool) removeTransaction(tx *blockchain.Transaction) {
+	delete(mp.pool, tx.TxID)
+	for _, input := range tx.Vin {
+		delete(mp.spends, outPoint{input.Txid, input.Vout})
+	}
+	mp.locks.UnlockTx(tx)
+}
+
+func (mp *TxPool) HaveTransaction(txID string) bool {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	_, ok := mp.pool[txID]
+	return ok
+}
+
+func (mp *TxPool) FetchTransaction(txID string) *blockchain.Transaction {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	return mp.pool[txID]
+}
+
+// IsSpent tells whether a pooled transaction spends the output.
+func (mp *TxPool) IsSpent(txID string, index int) bool {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	_, ok := mp.spends[outPoint{txID, index}]
+	return ok
+}
+
+// CoinLocks returns the locks of the outputs the pooled transactions spend, wallets pass them to coin
+// selection and may lock further outputs by hand.
+func (mp *TxPool) CoinLocks() *blockchain.CoinLocks {
+	return mp.locks
+}
+
+// MinRelayFee returns the lowest fee rate the pool takes, wallets pay at least it.
+func (mp *TxPool) MinRelayFee() blockchain.FeeRate {
+	return mp.policy.MinRelayFee
+}
+
+// Transactions returns the pooled transactions in no particular order.
+func (mp *TxPool) Transactions() []*blockchain.Transaction {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+
+	txs := make([]*blockchain.Transaction, 0, len(mp.pool))
+	for _, tx := range mp.pool {
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func (mp *TxPool) Count() int {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+	return len(mp.pool)
+}
+
+// Prune drops the transactions whose inputs left the UTXO set, the ones mined and the ones double spent by a block.
+func (mp *TxPool) Prune() {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	for _, tx := range mp.pool {
+		for _, input := range tx.Vin {
+			if mp.chains.GetUTXO(input.Txid, input.Vout) == nil {
+				mp.removeTransaction(tx)
+				break
+			}
+		}
+	}
+}
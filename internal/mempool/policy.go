@@ -0,0 +1,119 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+)
+
+const (
+	// MaxStandardTxSize is the most bytes of a serialized transaction the pool relays.
+	MaxStandardTxSize = 40000
+	// MaxStandardSigScriptSize is the most bytes of an unlocking script the pool relays, room for a multisig
+	// redeem script of 15 keys with its signatures.
+	MaxStandardSigScriptSize = 1650
+	// MaxStandardMultiSigKeys is the most keys of a bare multisig output the pool relays.
+	MaxStandardMultiSigKeys = 3
+	// DefaultMinRelayFee is the fee rate, per 1000 bytes, the pools take by default.
+	DefaultMinRelayFee blockchain.FeeRate = 1
+)
+
+var (
+	ErrNonStandard     = errors.New("non standard transaction")
+	ErrDust            = errors.New("dust output")
+	ErrInsufficientFee = errors.New("fee below the minimum relay fee")
+)
+
+// Policy are the rules, besides consensus, a transaction follows to enter the pool and be relayed. Blocks are
+// not checked against them.
+type Policy struct {
+	// MinRelayFee is the lowest fee rate the pool takes, outputs worth less than
+	// blockchain.DustRelayFeeMultiplier times the fee of spending them at it are dust.
+	MinRelayFee blockchain.FeeRate
+	// AcceptNonStandard skips the checks of the sizes and the scripts of the transactions.
+	AcceptNonStandard bool
+}
+
+// DefaultPolicy is the policy of the pools made by New. The zero Policy takes transactions paying no fee.
+var DefaultPolicy = Policy{MinRelayFee: DefaultMinRelayFee}
+
+// IsDust tells if output is worth less than blockchain.DustThreshold at minRelayFee, the data carriers nobody
+// spends are never dust.
+func IsDust(output *blockchain.TXOutput, minRelayFee blockchain.FeeRate) bool {
+	if script.GetClass(output.LockingScript()) == script.NullDataTy {
+		return false
+	}
+	return output.Value < blockchain.DustThreshold(output, minRelayFee)
+}
+
+// CheckStandard checks the sizes of tx and of its unlocking scripts, the classes of its locking scripts and
+// its outputs against dust.
+func (p *Policy) CheckStandard(tx *blockchain.Transaction) error {
+	if !p.AcceptNonStandard {
+		err := checkStandardScripts(tx)
+		if err != nil {
+			return err
+		}
+	}
+	for idx := range tx.Vout {
+		if IsDust(&tx.Vout[idx], p.MinRelayFee) {
+			return fmt.Errorf("%w: output %d of %d is below %d", ErrDust, idx, tx.Vout[idx].Value,
+				blockchain.DustThreshold(&tx.Vout[idx], p.MinRelayFee))
+		}
+	}
+	return nil
+}
+
+func checkStandardScripts(tx *blockchain.Transaction) error {
+	if size := len(tx.Serialize()); size > MaxStandardTxSize {
+		return fmt.Errorf("%w: %d bytes, the most is %d", ErrNonStandard, size, MaxStandardTxSize)
+	}
+	for idx := range tx.Vin {
+		unlockingScript := tx.Vin[idx].UnlockingScript()
+		if len(unlockingScript) > MaxStandardSigScriptSize {
+			return fmt.Errorf("%w: unlocking script of input %d of %d bytes", ErrNonStandard, idx, len(unlockingScript))
+		}
+		if !script.IsPushOnly(unlockingScript) {
+			return fmt.Errorf("%w: unlocking script of input %d is not push only", ErrNonStandard, idx)
+		}
+	}
+
+	dataCarriers := 0
+	for idx := range tx.Vout {
+		lockingScript := tx.Vout[idx].LockingScript()
+		switch script.GetClass(lockingScript) {
+		case script.PubKeyHashTy, script.PubKeyTy, script.ScriptHashTy:
+		case script.MultiSigTy:
+			_, pubKeys, err := script.ExtractMultiSig(lockingScript)
+			if err != nil || len(pubKeys) > MaxStandardMultiSigKeys {
+				return fmt.Errorf("%w: multisig output %d of more than %d keys", ErrNonStandard, idx, MaxStandardMultiSigKeys)
+			}
+		case script.NullDataTy:
+			dataCarriers++
+			if dataCarriers > 1 {
+				return fmt.Errorf("%w: more than one data carrier output", ErrNonStandard)
+			}
+		default:
+			return fmt.Errorf("%w: output %d of a non standard script", ErrNonStandard, idx)
+		}
+	}
+	return nil
+}
+
+// CheckFee checks tx, which spends the amounts of its inputs, pays MinRelayFee.
+func (p *Policy) CheckFee(tx *blockchain.Transaction) error {
+	fee := 0
+	for idx := range tx.Vin {
+		fee += tx.Vin[idx].Amount
+	}
+	for idx := range tx.Vout {
+		fee -= tx.Vout[idx].Value
+	}
+	size := len(tx.Serialize())
+	if minFee := p.MinRelayFee.Fee(size); fee < minFee {
+		return fmt.Errorf("%w: %d paid for %d bytes, %d wanted", ErrInsufficientFee, fee, size, minFee)
+	}
+	return nil
+}
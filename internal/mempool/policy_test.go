@@ -0,0 +1,161 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSpendTx mines a block paying a new wallet and returns the transaction signed by it spending the coinbase
+// to outputs.
+func newSpendTx(t *testing.T, chains *blockchain.BlockChains, outputs ...blockchain.TXOutput) *blockchain.Transaction {
+	wallet := blockchain.NewWallet()
+	coinbase := blockchain.NewCoinbaseTX(wallet.GetAddress(), "")
+	assert.Nil(t, chains.AddBlock(blockchain.MineBlock([]*blockchain.Transaction{coinbase}, chains.GetLatestBlock().Hash)))
+
+	tx := &blockchain.Transaction{Vin: []blockchain.TXInput{{Txid: coinbase.TxID, Vout: 0}}, Vout: outputs}
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	cond := &blockchain.TransactionVerifyCond{Outputs: map[string][]blockchain.TXOutput{coinbase.TxID: coinbase.Vout}}
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, cond))
+	return tx
+}
+
+func TestIsDust(t *testing.T) {
+	output := blockchain.NewTXOutput(0, 1, blockchain.NewWallet().GetAddress())
+	assert.Equal(t, 0, blockchain.DustThreshold(output, 0))
+	assert.False(t, IsDust(output, 0))
+
+	spendSize := blockchain.EstimateTxSize(1, 1) - blockchain.EstimateTxSize(0, 0) + len(output.LockingScript())
+	threshold := blockchain.DustRelayFeeMultiplier * blockchain.FeeRate(1000).Fee(spendSize)
+	assert.Equal(t, threshold, blockchain.DustThreshold(output, 1000))
+	output.Value = threshold - 1
+	assert.True(t, IsDust(output, 1000))
+	output.Value = threshold
+	assert.False(t, IsDust(output, 1000))
+
+	data, err := blockchain.NewDataTXOutput(0, []byte("data"))
+	assert.Nil(t, err)
+	assert.False(t, IsDust(data, 1000))
+}
+
+func TestPolicy_CheckStandard(t *testing.T) {
+	policy := &Policy{}
+	to := blockchain.NewWallet().GetAddress()
+	tx := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: "prev", Vout: 0, Signature: []byte{1}, PubKey: []byte{2}}},
+		Vout: []blockchain.TXOutput{*blockchain.NewTXOutput(0, 1, to)},
+	}
+	assert.Nil(t, policy.CheckStandard(tx))
+
+	nonStandard := func(lockingScript []byte) *blockchain.Transaction {
+		other := *tx
+		other.Vout = append([]blockchain.TXOutput{*blockchain.NewScriptTXOutput(1, 1, lockingScript)}, tx.Vout...)
+		return &other
+	}
+	keys := make([][]byte, MaxStandardMultiSigKeys+1)
+	for idx := range keys {
+		_, keys[idx] = utils.NewKeyPair()
+	}
+	multiSig, err := script.MultiSigScript(1, keys[:MaxStandardMultiSigKeys])
+	assert.Nil(t, err)
+	assert.Nil(t, policy.CheckStandard(nonStandard(multiSig)))
+	multiSig, err = script.MultiSigScript(1, keys)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, policy.CheckStandard(nonStandard(multiSig)), ErrNonStandard)
+	assert.ErrorIs(t, policy.CheckStandard(nonStandard([]byte{script.OpTrue})), ErrNonStandard)
+
+	dataScript, err := script.NullDataScript([]byte("data"))
+	assert.Nil(t, err)
+	twoCarriers := nonStandard(dataScript)
+	assert.Nil(t, policy.CheckStandard(twoCarriers))
+	twoCarriers.Vout = append(twoCarriers.Vout, twoCarriers.Vout[0])
+	assert.ErrorIs(t, policy.CheckStandard(twoCarriers), ErrNonStandard)
+
+	// the unlocking scripts only push, within a size
+	tx.Vin[0].SigScript = []byte{script.OpTrue, script.OpDup}
+	assert.ErrorIs(t, policy.CheckStandard(tx), ErrNonStandard)
+	b := script.NewBuilder()
+	for size := 0; size <= MaxStandardSigScriptSize; size += 500 {
+		b.AddData(make([]byte, 500))
+	}
+	tx.Vin[0].SigScript, err = b.Script()
+	assert.Nil(t, err)
+	assert.ErrorIs(t, policy.CheckStandard(tx), ErrNonStandard)
+
+	// a policy taking non standard transactions still checks the dust
+	policy = &Policy{AcceptNonStandard: true}
+	assert.Nil(t, policy.CheckStandard(tx))
+	assert.Nil(t, policy.CheckStandard(nonStandard([]byte{script.OpTrue})))
+	policy.MinRelayFee = 1000
+	assert.ErrorIs(t, policy.CheckStandard(tx), ErrDust)
+}
+
+func TestTxPool_Policy(t *testing.T) {
+	chains, err := blockchain.NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer chains.Close()
+	policy := Policy{MinRelayFee: 4}
+	pool := NewWithPolicy(chains, policy)
+	to := blockchain.NewWallet().GetAddress()
+
+	noFee := newSpendTx(t, chains, *blockchain.NewTXOutput(0, blockchain.Subsidy, to))
+	fee := policy.MinRelayFee.Fee(len(noFee.Serialize()))
+	assert.ErrorIs(t, pool.MaybeAcceptTransaction(noFee), ErrInsufficientFee)
+	assert.ErrorIs(t, New(chains).MaybeAcceptTransaction(noFee), ErrInsufficientFee)
+	assert.Nil(t, NewWithPolicy(chains, Policy{}).MaybeAcceptTransaction(noFee))
+
+	dustValue := blockchain.DustThreshold(blockchain.NewTXOutput(0, 1, to), policy.MinRelayFee) - 1
+	dust := newSpendTx(t, chains, *blockchain.NewTXOutput(0, dustValue, to))
+	assert.ErrorIs(t, pool.MaybeAcceptTransaction(dust), ErrDust)
+
+	paid := newSpendTx(t, chains, *blockchain.NewTXOutput(0, blockchain.Subsidy-fee, to))
+	assert.Nil(t, pool.MaybeAcceptTransaction(paid))
+	assert.True(t, pool.HaveTransaction(paid.TxID))
+
+	// blocks are not checked against the policy
+	block := blockchain.MineBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(to, ""), noFee, dust},
+		chains.GetLatestBlock().Hash)
+	assert.Nil(t, chains.AddBlock(block))
+	assert.Equal(t, block.Hash, chains.GetLatestBlock().Hash)
+}
+
+// the wallet leaves the change the pool would take for dust to the fee
+func TestPolicy_WalletChange(t *testing.T) {
+	chains, err := blockchain.NewBlockChainsWithDB(memdb.NewDB())
+	assert.Nil(t, err)
+	defer chains.Close()
+	pool := New(chains)
+	dir, err := ioutil.TempDir("", "wallet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	wallets, _ := blockchain.NewWalletsFromFile(filepath.Join(dir, "wallet.dat"))
+	address, err := wallets.CreateWallet()
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		coinbase := blockchain.NewCoinbaseTX(address, string(rune('a'+i)))
+		assert.Nil(t, chains.AddBlock(blockchain.MineBlock([]*blockchain.Transaction{coinbase}, chains.GetLatestBlock().Hash)))
+	}
+	to := blockchain.NewWallet().GetAddress()
+	opts := &blockchain.SpendOptions{FeeRate: pool.MinRelayFee(), Selector: blockchain.LargestFirst}
+
+	tx, err := wallets.Send(chains, nil, []blockchain.Recipient{{Address: to, Amount: 7}}, opts)
+	assert.Nil(t, err)
+	assert.Len(t, tx.Vout, 1)
+	assert.Nil(t, pool.MaybeAcceptTransaction(tx))
+
+	opts.Locks = blockchain.NewCoinLocks()
+	opts.Locks.LockTx(tx)
+	tx, err = wallets.Send(chains, nil, []blockchain.Recipient{{Address: to, Amount: 5}}, opts)
+	assert.Nil(t, err)
+	assert.Len(t, tx.Vout, 2)
+	assert.Equal(t, 4, tx.Vout[1].Value)
+	assert.Nil(t, pool.MaybeAcceptTransaction(tx))
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// OverflowPolicy tells what happens to a notification when the subscriber's buffer is full.
+// Publishing never blocks, the chains are locked while notifications are sent.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the notification and counts it in Dropped, the subscriber should resync from the chain.
+	DropNewest OverflowPolicy = iota
+	// Disconnect ends the subscription, Err returns ErrSlowConsumer once C is closed.
+	Disconnect
+)
+
+var (
+	ErrSlowConsumer = errors.New("subscriber does not keep up")
+	ErrHubClosed    = errors.New("notification hub closed")
+)
+
+// Hub fans the chain notifications out to buffered subscriptions.
+type Hub struct {
+	lock   sync.Mutex
+	subs   map[*Subscription]interface{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[*Subscription]interface{}),
+	}
+}
+
+// Subscription receives the notifications of the given types, of every type when none are given.
+type Subscription struct {
+	hub     *Hub
+	c       chan *blockchain.Notification
+	types   map[blockchain.NotificationType]bool
+	policy  OverflowPolicy
+	dropped uint64
+	err     error
+}
+
+// Subscribe returns a subscription buffering up to bufferSize notifications.
+func (h *Hub) Subscribe(bufferSize int, policy OverflowPolicy, types ...blockchain.NotificationType) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		c:      make(chan *blockchain.Notification, bufferSize),
+		policy: policy,
+	}
+	if len(types) > 0 {
+		s.types = make(map[blockchain.NotificationType]bool)
+		for _, typ := range types {
+			s.types[typ] = true
+		}
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		s.err = ErrHubClosed
+		close(s.c)
+		return s
+	}
+	h.subs[s] = true
+	return s
+}
+
+// Publish hands the notification to every subscription, it is a blockchain.NotificationCallback.
+func (h *Hub) Publish(n *blockchain.Notification) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for s := range h.subs {
+		if s.types != nil && !s.types[n.Type] {
+			continue
+		}
+		select {
+		case s.c <- n:
+			continue
+		default:
+		}
+
+		if s.policy == Disconnect {
+			h.remove(s, ErrSlowConsumer)
+			continue
+		}
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// remove ends the subscription, the caller holds the lock.
+func (h *Hub) remove(s *Subscription, err error) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	s.err = err
+	close(s.c)
+}
+
+// Close ends all subscriptions.
+func (h *Hub) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s, ErrHubClosed)
+	}
+}
+
+// C delivers the notifications in order, it is closed when the subscription ends.
+func (s *Subscription) C() <-chan *blockchain.Notification {
+	return s.c
+}
+
+// Dropped returns how many notifications did not fit in the buffer.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Err tells why the subscription ended, nil while it runs or after Close.
+func (s *Subscription) Err() error {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+	return s.err
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+	s.hub.remove(s, nil)
+}
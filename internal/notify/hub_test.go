@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub_Filter(t *testing.T) {
+	h := NewHub()
+	all := h.Subscribe(4, DropNewest)
+	orphans := h.Subscribe(4, DropNewest, blockchain.NTOrphanAdded)
+
+	h.Publish(&blockchain.Notification{Type: blockchain.NTBlockConnected})
+	h.Publish(&blockchain.Notification{Type: blockchain.NTOrphanAdded})
+	assert.Len(t, all.C(), 2)
+	assert.Len(t, orphans.C(), 1)
+	assert.Equal(t, blockchain.NTOrphanAdded, (<-orphans.C()).Type)
+
+	orphans.Close()
+	h.Publish(&blockchain.Notification{Type: blockchain.NTOrphanAdded})
+	_, ok := <-orphans.C()
+	assert.False(t, ok)
+	assert.Nil(t, orphans.Err())
+
+	h.Close()
+	assert.Len(t, all.C(), 3)
+	for range all.C() {
+	}
+	assert.ErrorIs(t, all.Err(), ErrHubClosed)
+	assert.ErrorIs(t, h.Subscribe(1, DropNewest).Err(), ErrHubClosed)
+}
+
+func TestHub_Overflow(t *testing.T) {
+	h := NewHub()
+	defer h.Close()
+	dropping := h.Subscribe(1, DropNewest)
+	disconnecting := h.Subscribe(1, Disconnect)
+
+	for idx := 0; idx < 3; idx++ {
+		h.Publish(&blockchain.Notification{Type: blockchain.NTBlockConnected})
+	}
+	assert.EqualValues(t, 2, dropping.Dropped())
+	assert.Len(t, dropping.C(), 1)
+
+	// the buffered notification is still delivered before the channel closes
+	_, ok := <-disconnecting.C()
+	assert.True(t, ok)
+	_, ok = <-disconnecting.C()
+	assert.False(t, ok)
+	assert.ErrorIs(t, disconnecting.Err(), ErrSlowConsumer)
+}
@@ -0,0 +1,123 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/jiuzhou-zhao/bolt-client/pkg/db"
+)
+
+var bannedBucketName = []byte("banned")
+
+// BannedHost is an entry of the ban list.
+type BannedHost struct {
+	Host   string
+	Until  time.Time
+	Reason string
+}
+
+// BanList keeps banned hosts in the database, so bans survive restarts.
+type BanList struct {
+	db db.DB
+}
+
+func NewBanList(stg db.DB) (*BanList, error) {
+	err := stg.Update(func(tx db.Tx) error {
+		if tx.Bucket(bannedBucketName) != nil {
+			return nil
+		}
+		_, err := tx.CreateBucket(bannedBucketName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BanList{db: stg}, nil
+}
+
+// HostOf returns the host part of a peer address, the whole address when it has no port.
+func HostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func (bl *BanList) Ban(host string, until time.Time, reason string) error {
+	if host == "" {
+		return errors.New("no host")
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&BannedHost{Host: host, Until: until, Reason: reason})
+	if err != nil {
+		return err
+	}
+	return bl.db.Update(func(tx db.Tx) error {
+		return tx.Bucket(bannedBucketName).Put([]byte(host), buf.Bytes())
+	})
+}
+
+func (bl *BanList) Unban(host string) error {
+	return bl.db.Update(func(tx db.Tx) error {
+		return tx.Bucket(bannedBucketName).Delete([]byte(host))
+	})
+}
+
+// IsBanned reports whether the host has a ban which has not expired.
+func (bl *BanList) IsBanned(host string) bool {
+	var banned *BannedHost
+	_ = bl.db.View(func(tx db.Tx) error {
+		banned = decodeBannedHost(tx.Bucket(bannedBucketName).Get([]byte(host)))
+		return nil
+	})
+	return banned != nil && time.Now().Before(banned.Until)
+}
+
+// List returns the bans which have not expired, ordered by host.
+func (bl *BanList) List() []BannedHost {
+	now := time.Now()
+	hosts := make([]BannedHost, 0)
+	_ = bl.db.View(func(tx db.Tx) error {
+		c := tx.Bucket(bannedBucketName).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			banned := decodeBannedHost(v)
+			if banned != nil && now.Before(banned.Until) {
+				hosts = append(hosts, *banned)
+			}
+		}
+		return nil
+	})
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// Clear removes every ban.
+func (bl *BanList) Clear() error {
+	return bl.db.Update(func(tx db.Tx) error {
+		err := tx.DeleteBucket(bannedBucketName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket(bannedBucketName)
+		return err
+	})
+}
+
+func decodeBannedHost(d []byte) *BannedHost {
+	if d == nil {
+		return nil
+	}
+	var banned BannedHost
+	err := gob.NewDecoder(bytes.NewReader(d)).Decode(&banned)
+	if err != nil {
+		return nil
+	}
+	return &banned
+}
@@ -0,0 +1,87 @@
+package p2p
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+const (
+	// halfLife is how long it takes a transient score to decay to half its value.
+	halfLife = time.Minute
+
+	scoreInvalid        = 100
+	scoreMalformed      = 100
+	scoreUnknownCommand = 10
+	scoreOrphan         = 5
+)
+
+// banScore is a peer's misbehaviour score, a persistent part which never decays and a transient part which does.
+type banScore struct {
+	lock       sync.Mutex
+	persistent uint32
+	transient  float64
+	lastUnix   int64
+}
+
+func decayFactor(seconds int64) float64 {
+	return math.Exp2(-float64(seconds) / halfLife.Seconds())
+}
+
+// Increase adds to the score and returns the new total.
+func (s *banScore) Increase(persistent, transient uint32) uint32 {
+	return s.increase(persistent, transient, time.Now())
+}
+
+func (s *banScore) increase(persistent, transient uint32, t time.Time) uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := t.Unix()
+	s.transient *= decayFactor(now - s.lastUnix)
+	s.lastUnix = now
+	s.persistent += persistent
+	s.transient += float64(transient)
+	return s.persistent + uint32(s.transient)
+}
+
+func (s *banScore) Int() uint32 {
+	return s.int(time.Now())
+}
+
+func (s *banScore) int(t time.Time) uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.persistent + uint32(s.transient*decayFactor(t.Unix()-s.lastUnix))
+}
+
+// blockRejectScore rates a block the chain refused, duplicates are normal on a flooding network. The blocks
+// of unknown parents are kept as orphans, they are not refused.
+func blockRejectScore(err error) uint32 {
+	var ruleErr blockchain.RuleError
+	if !errors.As(err, &ruleErr) {
+		return 0
+	}
+	if ruleErr.ErrorCode == blockchain.ErrDuplicateBlock {
+		return 0
+	}
+	return scoreInvalid
+}
+
+// txRejectScore rates a transaction the pool refused, its inputs may be unknown to us or spent in the meantime,
+// and a timelock may be final on the sender's clock or tip but not yet on ours.
+func txRejectScore(err error) uint32 {
+	var ruleErr blockchain.RuleError
+	if !errors.As(err, &ruleErr) {
+		return 0
+	}
+	switch ruleErr.ErrorCode {
+	case blockchain.ErrMissingTxOut, blockchain.ErrSpentTxOut, blockchain.ErrUnfinalizedTx:
+		return 0
+	default:
+		return scoreInvalid
+	}
+}
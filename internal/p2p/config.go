@@ -0,0 +1,55 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/mempool"
+)
+
+const (
+	defaultBanThreshold = 100
+	defaultBanDuration  = 24 * time.Hour
+	defaultMaxOutbound  = 8
+)
+
+// Config tunes a Node, zero fields take the defaults.
+type Config struct {
+	// BanThreshold is the misbehaviour score at which a peer is disconnected and banned.
+	BanThreshold uint32
+	// BanDuration is how long a banned host is refused.
+	BanDuration time.Duration
+	// Seeds are host:port addresses put in the address book and dialed first when the node starts.
+	Seeds []string
+	// MaxOutbound is how many outbound peers the node keeps connected once started.
+	MaxOutbound int
+	// MinRelayFee is the lowest fee rate of the transactions the node pools and relays, it sets the dust
+	// threshold of their outputs too. Zero takes mempool.DefaultMinRelayFee.
+	MinRelayFee blockchain.FeeRate
+	// FreeRelay pools and relays transactions paying no fee, MinRelayFee is ignored.
+	FreeRelay bool
+	// AcceptNonStandard pools and relays transactions of non standard sizes and scripts.
+	AcceptNonStandard bool
+}
+
+func (cfg *Config) withDefaults() *Config {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.BanThreshold == 0 {
+		c.BanThreshold = defaultBanThreshold
+	}
+	if c.BanDuration == 0 {
+		c.BanDuration = defaultBanDuration
+	}
+	if c.MaxOutbound == 0 {
+		c.MaxOutbound = defaultMaxOutbound
+	}
+	if c.FreeRelay {
+		c.MinRelayFee = 0
+	} else if c.MinRelayFee == 0 {
+		c.MinRelayFee = mempool.DefaultMinRelayFee
+	}
+	return &c
+}
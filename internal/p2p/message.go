@@ -0,0 +1,118 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+)
+
+const (
+	protocolVersion = 1
+
+	cmdVersion   = "version"
+	cmdGetBlocks = "getblocks"
+	cmdInv       = "inv"
+	cmdGetData   = "getdata"
+	cmdBlock     = "block"
+	cmdTx        = "tx"
+	cmdGetAddr   = "getaddr"
+	cmdAddr      = "addr"
+
+	// maxAddrPerMsg caps the addresses of an addr message.
+	maxAddrPerMsg = 1000
+	// maxInvPerMsg caps the items of an inv or getdata message, a getblocks is answered with at most as many
+	// blocks and asked again once they are connected.
+	maxInvPerMsg = 500
+
+	invTypeBlock = "block"
+	invTypeTx    = "tx"
+)
+
+var errMalformedMessage = errors.New("malformed message")
+
+// message is the envelope written on the wire, the payload is the gob encoding of the command's msg struct.
+type message struct {
+	Command string
+	Payload []byte
+}
+
+type versionMsg struct {
+	Version    int
+	BestHeight int64
+	TopHash    chainhash.Hash
+	// AddrFrom is the address the sender listens on, empty when it does not.
+	AddrFrom string
+}
+
+type getBlocksMsg struct {
+	// Locator lists hashes of the sender's main chain from its tip back, see BlockChains.BlockLocator.
+	Locator []chainhash.Hash
+}
+
+type invMsg struct {
+	Type  string
+	Items []chainhash.Hash
+}
+
+type getDataMsg struct {
+	Type  string
+	Items []chainhash.Hash
+}
+
+type blockMsg struct {
+	Block []byte
+}
+
+type txMsg struct {
+	Transaction blockchain.Transaction
+}
+
+type getAddrMsg struct {
+	Max int
+}
+
+type addrMsg struct {
+	AddrList []string
+}
+
+func newMessage(command string, payload interface{}) (*message, error) {
+	var buf bytes.Buffer
+
+	err := gob.NewEncoder(&buf).Encode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s failed: %w", command, err)
+	}
+
+	return &message{
+		Command: command,
+		Payload: buf.Bytes(),
+	}, nil
+}
+
+func (m *message) decode(payload interface{}) error {
+	err := gob.NewDecoder(bytes.NewReader(m.Payload)).Decode(payload)
+	if err != nil {
+		return fmt.Errorf("%w: decode %s failed: %v", errMalformedMessage, m.Command, err)
+	}
+	return nil
+}
+
+// txID2Hash turns a transaction id into an inventory item, ids are the hex of the transaction hash.
+func txID2Hash(txID string) (chainhash.Hash, error) {
+	var h chainhash.Hash
+	d, err := hex.DecodeString(txID)
+	if err != nil {
+		return h, err
+	}
+	err = h.SetBytes(d)
+	return h, err
+}
+
+func hash2TxID(h chainhash.Hash) string {
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,695 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/addrmgr"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/mempool"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/notify"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+)
+
+const (
+	dialTimeout     = 10 * time.Second
+	connectInterval = 30 * time.Second
+	saveInterval    = 10 * time.Minute
+)
+
+var ErrBanned = errors.New("host is banned")
+
+// Node relays blocks and transactions between its peers and a local BlockChains.
+type Node struct {
+	cfg         *Config
+	banList     *BanList
+	addrManager *addrmgr.AddrManager
+
+	chainLock sync.Mutex
+	chains    *blockchain.BlockChains
+	txPool    *mempool.TxPool
+
+	notifications *notify.Hub
+
+	peerLock   sync.RWMutex
+	peers      map[*Peer]interface{}
+	listener   net.Listener
+	listenAddr string
+
+	quit      chan interface{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewNode(chains *blockchain.BlockChains, banList *BanList, addrManager *addrmgr.AddrManager, cfg *Config) *Node {
+	cfg = cfg.withDefaults()
+	policy := mempool.Policy{MinRelayFee: cfg.MinRelayFee, AcceptNonStandard: cfg.AcceptNonStandard}
+	n := &Node{
+		cfg:           cfg,
+		banList:       banList,
+		addrManager:   addrManager,
+		chains:        chains,
+		txPool:        mempool.NewWithPolicy(chains, policy),
+		notifications: notify.NewHub(),
+		peers:         make(map[*Peer]interface{}),
+		quit:          make(chan interface{}),
+	}
+	chains.Subscribe(n.notifications.Publish)
+	return n
+}
+
+// View runs fn with the chains locked against the peers' handlers.
+func (n *Node) View(fn func(chains *blockchain.BlockChains) error) error {
+	n.chainLock.Lock()
+	defer n.chainLock.Unlock()
+	return fn(n.chains)
+}
+
+func (n *Node) TxPool() *mempool.TxPool {
+	return n.txPool
+}
+
+func (n *Node) BanList() *BanList {
+	return n.banList
+}
+
+func (n *Node) AddrManager() *addrmgr.AddrManager {
+	return n.addrManager
+}
+
+// Notifications returns the hub of the chain notifications.
+func (n *Node) Notifications() *notify.Hub {
+	return n.notifications
+}
+
+// Listen accepts inbound peers on the tcp address.
+func (n *Node) Listen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("listen on %s failed: %w", address, err)
+	}
+	n.peerLock.Lock()
+	n.listener = listener
+	n.listenAddr = listener.Addr().String()
+	n.peerLock.Unlock()
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for {
+			conn, errAccept := listener.Accept()
+			if errAccept != nil {
+				return
+			}
+			_, errAccept = n.AddPeer(conn, "", true)
+			if errAccept != nil {
+				loge.Warnf(nil, "refuse peer %s: %v", conn.RemoteAddr(), errAccept)
+			}
+		}
+	}()
+	return nil
+}
+
+// Connect dials an outbound peer on the tcp address.
+func (n *Node) Connect(address string) (*Peer, error) {
+	if n.banList.IsBanned(HostOf(address)) {
+		return nil, fmt.Errorf("connect to %s failed: %w", address, ErrBanned)
+	}
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s failed: %w", address, err)
+	}
+	return n.AddPeer(conn, address, false)
+}
+
+// AddPeer starts serving the connection, addr names the peer and defaults to the remote address.
+// Connections from banned hosts are closed.
+func (n *Node) AddPeer(conn net.Conn, addr string, inbound bool) (*Peer, error) {
+	p := newPeer(conn, addr, inbound)
+	if n.banList.IsBanned(HostOf(p.addr)) {
+		_ = conn.Close()
+		return nil, ErrBanned
+	}
+
+	n.peerLock.Lock()
+	n.peers[p] = true
+	n.peerLock.Unlock()
+
+	n.wg.Add(3)
+	go func() {
+		defer n.wg.Done()
+		p.writeLoop()
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.dataLoop(p)
+	}()
+	go func() {
+		defer n.wg.Done()
+		err := p.readLoop(n.handleMessage)
+		if err != nil {
+			n.addBanScore(p, scoreMalformed, 0, err.Error())
+		}
+		n.removePeer(p)
+	}()
+
+	err := p.send(cmdVersion, n.newVersionMsg())
+	if err != nil {
+		loge.Errorf(nil, "send version to %s failed: %v", p.addr, err)
+	}
+	return p, nil
+}
+
+func (n *Node) removePeer(p *Peer) {
+	n.peerLock.Lock()
+	delete(n.peers, p)
+	n.peerLock.Unlock()
+}
+
+func (n *Node) Peers() []*Peer {
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+
+	peers := make([]*Peer, 0, len(n.peers))
+	for p := range n.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// Start puts the seeds in the address book and keeps up to MaxOutbound outbound peers connected until Close.
+func (n *Node) Start() {
+	for _, seed := range n.cfg.Seeds {
+		err := n.addrManager.AddAddress(seed, seed)
+		if err != nil {
+			loge.Errorf(nil, "add seed %s failed: %v", seed, err)
+		}
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.connectionLoop()
+	}()
+}
+
+func (n *Node) connectionLoop() {
+	connectTicker := time.NewTicker(connectInterval)
+	defer connectTicker.Stop()
+	saveTicker := time.NewTicker(saveInterval)
+	defer saveTicker.Stop()
+
+	n.connectOutbound()
+	for {
+		select {
+		case <-n.quit:
+			return
+		case <-connectTicker.C:
+			n.connectOutbound()
+		case <-saveTicker.C:
+			err := n.addrManager.Save()
+			if err != nil {
+				loge.Errorf(nil, "save address book failed: %v", err)
+			}
+		}
+	}
+}
+
+// connectOutbound dials addresses from the address book until MaxOutbound peers are connected
+// or the book runs out of candidates.
+func (n *Node) connectOutbound() {
+	connected := make(map[string]bool)
+	outbound := 0
+	for _, p := range n.Peers() {
+		connected[p.addr] = true
+		if !p.inbound {
+			outbound++
+		}
+	}
+	n.peerLock.RLock()
+	connected[n.listenAddr] = true
+	n.peerLock.RUnlock()
+
+	for tries := 0; outbound < n.cfg.MaxOutbound && tries < n.cfg.MaxOutbound*4; tries++ {
+		select {
+		case <-n.quit:
+			return
+		default:
+		}
+
+		ka := n.addrManager.GetAddress()
+		if ka == nil {
+			return
+		}
+		if connected[ka.Addr] || n.banList.IsBanned(HostOf(ka.Addr)) {
+			continue
+		}
+		connected[ka.Addr] = true
+
+		n.addrManager.Attempt(ka.Addr)
+		_, err := n.Connect(ka.Addr)
+		if err != nil {
+			loge.Warnf(nil, "connect to %s failed: %v", ka.Addr, err)
+			continue
+		}
+		outbound++
+	}
+}
+
+// Close disconnects every peer, waits for their loops to exit and saves the address book.
+func (n *Node) Close() {
+	n.closeOnce.Do(func() {
+		close(n.quit)
+	})
+
+	n.peerLock.Lock()
+	if n.listener != nil {
+		_ = n.listener.Close()
+	}
+	n.peerLock.Unlock()
+
+	for _, p := range n.Peers() {
+		p.Disconnect()
+	}
+	n.wg.Wait()
+	n.notifications.Close()
+
+	err := n.addrManager.Save()
+	if err != nil {
+		loge.Errorf(nil, "save address book failed: %v", err)
+	}
+}
+
+// addBanScore records misbehaviour, the peer is disconnected and its host banned once the threshold is crossed.
+func (n *Node) addBanScore(p *Peer, persistent, transient uint32, reason string) {
+	if persistent == 0 && transient == 0 {
+		return
+	}
+	score := p.banScore.Increase(persistent, transient)
+	if score < n.cfg.BanThreshold {
+		loge.Warnf(nil, "misbehaving peer %s: %s -- ban score increased to %d", p.addr, reason, score)
+		return
+	}
+
+	loge.Warnf(nil, "misbehaving peer %s: %s -- banning and disconnecting", p.addr, reason)
+	err := n.banList.Ban(HostOf(p.addr), time.Now().Add(n.cfg.BanDuration), reason)
+	if err != nil {
+		loge.Errorf(nil, "ban %s failed: %v", p.addr, err)
+	}
+	p.Disconnect()
+}
+
+// SubmitBlock adds a block built locally and announces it to all peers.
+func (n *Node) SubmitBlock(block *blockchain.Block) error {
+	n.chainLock.Lock()
+	err := n.chains.AddBlock(block)
+	if err == nil {
+		n.txPool.Prune()
+	}
+	n.chainLock.Unlock()
+	if err != nil {
+		return err
+	}
+	n.relayInv(invTypeBlock, block.Hash, nil)
+	return nil
+}
+
+// SubmitTransaction adds a transaction built locally to the pool and announces it to all peers.
+func (n *Node) SubmitTransaction(tx *blockchain.Transaction) error {
+	n.chainLock.Lock()
+	err := n.txPool.MaybeAcceptTransaction(tx)
+	n.chainLock.Unlock()
+	if err != nil {
+		return err
+	}
+	return n.relayTransaction(tx, nil)
+}
+
+// Mine mines a block with the coinbase paid to address and the given transactions on top of the best block.
+func (n *Node) Mine(address string, transactions ...*blockchain.Transaction) (*blockchain.Block, error) {
+	if address == "" {
+		return nil, errors.New("no miner address")
+	}
+	n.chainLock.Lock()
+	// the transactions over the limits of a block are left for the next ones
+	txs := append([]*blockchain.Transaction{blockchain.NewCoinbaseTX(address, "")},
+		n.chains.SelectBlockTxs(transactions)...)
+	block := blockchain.MineBlock(txs, n.chains.GetLatestBlock().Hash)
+	err := n.chains.AddBlock(block)
+	if err == nil {
+		n.txPool.Prune()
+	}
+	n.chainLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	n.relayInv(invTypeBlock, block.Hash, nil)
+	return block, nil
+}
+
+func (n *Node) newVersionMsg() *versionMsg {
+	n.chainLock.Lock()
+	defer n.chainLock.Unlock()
+
+	latestBlock := n.chains.GetLatestBlock()
+	n.peerLock.RLock()
+	defer n.peerLock.RUnlock()
+	return &versionMsg{
+		Version:    protocolVersion,
+		BestHeight: latestBlock.Height,
+		TopHash:    latestBlock.Hash,
+		AddrFrom:   n.listenAddr,
+	}
+}
+
+func (n *Node) relayInv(invType string, h chainhash.Hash, from *Peer) {
+	for _, p := range n.Peers() {
+		if p == from {
+			continue
+		}
+		err := p.send(cmdInv, &invMsg{Type: invType, Items: []chainhash.Hash{h}})
+		if err != nil {
+			loge.Errorf(nil, "relay %s to %s failed: %v", invType, p.addr, err)
+		}
+	}
+}
+
+func (n *Node) relayTransaction(tx *blockchain.Transaction, from *Peer) error {
+	h, err := txID2Hash(tx.TxID)
+	if err != nil {
+		return err
+	}
+	n.relayInv(invTypeTx, h, from)
+	return nil
+}
+
+func (n *Node) handleMessage(p *Peer, msg *message) error {
+	var err error
+	switch msg.Command {
+	case cmdVersion:
+		err = n.handleVersion(p, msg)
+	case cmdGetBlocks:
+		err = n.handleGetBlocks(p, msg)
+	case cmdInv:
+		err = n.handleInv(p, msg)
+	case cmdGetData:
+		err = n.handleGetData(p, msg)
+	case cmdBlock:
+		err = n.handleBlock(p, msg)
+	case cmdTx:
+		err = n.handleTx(p, msg)
+	case cmdGetAddr:
+		err = n.handleGetAddr(p, msg)
+	case cmdAddr:
+		err = n.handleAddr(p, msg)
+	default:
+		n.addBanScore(p, scoreUnknownCommand, 0, "unknown command "+msg.Command)
+		return fmt.Errorf("unknown command: %s", msg.Command)
+	}
+	if errors.Is(err, errMalformedMessage) {
+		n.addBanScore(p, scoreMalformed, 0, err.Error())
+	}
+	return err
+}
+
+func (n *Node) handleVersion(p *Peer, msg *message) error {
+	var payload versionMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+
+	if p.inbound {
+		if addrFrom := listenAddrOf(payload.AddrFrom, p.addr); addrFrom != "" {
+			_ = n.addrManager.AddAddress(addrFrom, p.addr)
+		}
+	} else {
+		n.addrManager.Good(p.addr)
+		if n.addrManager.NeedMoreAddresses() {
+			err = p.send(cmdGetAddr, &getAddrMsg{Max: maxAddrPerMsg})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	n.chainLock.Lock()
+	latestBlock := n.chains.GetLatestBlock()
+	n.chainLock.Unlock()
+
+	if payload.BestHeight > latestBlock.Height {
+		return p.send(cmdGetBlocks, n.newGetBlocksMsg())
+	}
+	return nil
+}
+
+func (n *Node) newGetBlocksMsg() *getBlocksMsg {
+	n.chainLock.Lock()
+	defer n.chainLock.Unlock()
+	return &getBlocksMsg{Locator: n.chains.BlockLocator()}
+}
+
+func (n *Node) handleGetAddr(p *Peer, msg *message) error {
+	var payload getAddrMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+
+	addrs := n.addrManager.AddressCache()
+	if payload.Max > 0 && len(addrs) > payload.Max {
+		addrs = addrs[:payload.Max]
+	}
+	return p.send(cmdAddr, &addrMsg{AddrList: addrs})
+}
+
+func (n *Node) handleAddr(p *Peer, msg *message) error {
+	var payload addrMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+	if len(payload.AddrList) > maxAddrPerMsg {
+		return fmt.Errorf("%w: %d addresses in one addr message", errMalformedMessage, len(payload.AddrList))
+	}
+
+	n.peerLock.RLock()
+	listenAddr := n.listenAddr
+	n.peerLock.RUnlock()
+	addrs := make([]string, 0, len(payload.AddrList))
+	for _, addr := range payload.AddrList {
+		if addr != listenAddr {
+			addrs = append(addrs, addr)
+		}
+	}
+	n.addrManager.AddAddresses(addrs, p.addr)
+	if !p.inbound {
+		n.addrManager.Connected(p.addr)
+	}
+	return nil
+}
+
+func (n *Node) handleGetBlocks(p *Peer, msg *message) error {
+	var payload getBlocksMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+
+	n.chainLock.Lock()
+	hashes := n.chains.LocateBlocks(payload.Locator, maxInvPerMsg)
+	n.chainLock.Unlock()
+
+	if len(hashes) == 0 {
+		return nil
+	}
+	return p.send(cmdInv, &invMsg{Type: invTypeBlock, Items: hashes})
+}
+
+func (n *Node) handleInv(p *Peer, msg *message) error {
+	var payload invMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+
+	if len(payload.Items) > maxInvPerMsg {
+		return fmt.Errorf("%w: %d items in one inv message", errMalformedMessage, len(payload.Items))
+	}
+
+	unknown := make([]chainhash.Hash, 0, len(payload.Items))
+	switch payload.Type {
+	case invTypeBlock:
+		// inventories list the tip first, ask for the ancestors first so that few of them become orphans
+		n.chainLock.Lock()
+		for idx := len(payload.Items) - 1; idx >= 0; idx-- {
+			if !n.chains.HasBlock(payload.Items[idx]) {
+				unknown = append(unknown, payload.Items[idx])
+			}
+		}
+		n.chainLock.Unlock()
+		// a full inventory answers a getblocks with more blocks to come, they are asked for after its tip
+		if len(payload.Items) == maxInvPerMsg {
+			if len(unknown) == 0 || unknown[len(unknown)-1] != payload.Items[0] {
+				err = p.send(cmdGetBlocks, n.newGetBlocksMsg())
+				if err != nil {
+					return err
+				}
+			} else {
+				p.continueHash = payload.Items[0]
+			}
+		}
+	case invTypeTx:
+		for _, h := range payload.Items {
+			if !n.txPool.HaveTransaction(hash2TxID(h)) {
+				unknown = append(unknown, h)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown inv type %s", errMalformedMessage, payload.Type)
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+	return p.send(cmdGetData, &getDataMsg{Type: payload.Type, Items: unknown})
+}
+
+func (n *Node) handleGetData(p *Peer, msg *message) error {
+	var payload getDataMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+	if payload.Type != invTypeBlock && payload.Type != invTypeTx {
+		return fmt.Errorf("%w: unknown getdata type %s", errMalformedMessage, payload.Type)
+	}
+	if len(payload.Items) > maxInvPerMsg {
+		return fmt.Errorf("%w: %d items in one getdata message", errMalformedMessage, len(payload.Items))
+	}
+
+	return p.queueGetData(&payload)
+}
+
+// dataLoop answers the getdata messages of the peer until it is disconnected.
+func (n *Node) dataLoop(p *Peer) {
+	for {
+		select {
+		case payload := <-p.getDataChan:
+			err := n.sendData(p, payload)
+			if err != nil {
+				loge.Errorf(nil, "answer getdata from peer %s failed: %v", p.addr, err)
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (n *Node) sendData(p *Peer, payload *getDataMsg) error {
+	switch payload.Type {
+	case invTypeBlock:
+		for idx := range payload.Items {
+			n.chainLock.Lock()
+			block := n.chains.GetBlock(&payload.Items[idx])
+			n.chainLock.Unlock()
+			if block == nil {
+				continue
+			}
+			err := p.send(cmdBlock, &blockMsg{Block: block.Serialize()})
+			if err != nil {
+				return err
+			}
+		}
+	case invTypeTx:
+		for _, h := range payload.Items {
+			tx := n.txPool.FetchTransaction(hash2TxID(h))
+			if tx == nil {
+				continue
+			}
+			err := p.send(cmdTx, &txMsg{Transaction: *tx})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *Node) handleBlock(p *Peer, msg *message) error {
+	var payload blockMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+	block := blockchain.DeserializeBlock(payload.Block)
+	if block == nil {
+		return fmt.Errorf("%w: invalid block data", errMalformedMessage)
+	}
+
+	n.chainLock.Lock()
+	if n.chains.HasBlock(block.Hash) {
+		n.chainLock.Unlock()
+		return nil
+	}
+	err = n.chains.AddBlock(block)
+	orphan := err == nil && n.chains.IsOrphan(block.Hash)
+	if err == nil && !orphan {
+		n.txPool.Prune()
+	}
+	n.chainLock.Unlock()
+	if err != nil {
+		n.addBanScore(p, blockRejectScore(err), 0, fmt.Sprintf("block %s rejected: %v", block.Hash, err))
+		return fmt.Errorf("add block %s failed: %w", block.Hash, err)
+	}
+
+	if orphan {
+		n.addBanScore(p, 0, scoreOrphan, fmt.Sprintf("orphan block %s", block.Hash))
+		return p.send(cmdGetBlocks, n.newGetBlocksMsg())
+	}
+	n.relayInv(invTypeBlock, block.Hash, p)
+	if block.Hash == p.continueHash {
+		p.continueHash = chainhash.Hash{}
+		return p.send(cmdGetBlocks, n.newGetBlocksMsg())
+	}
+	return nil
+}
+
+func (n *Node) handleTx(p *Peer, msg *message) error {
+	var payload txMsg
+	err := msg.decode(&payload)
+	if err != nil {
+		return err
+	}
+	tx := &payload.Transaction
+
+	n.chainLock.Lock()
+	err = n.txPool.MaybeAcceptTransaction(tx)
+	n.chainLock.Unlock()
+	if err != nil {
+		n.addBanScore(p, txRejectScore(err), 0, fmt.Sprintf("tx %s rejected: %v", tx.TxID, err))
+		if errors.Is(err, mempool.ErrDuplicate) {
+			return nil
+		}
+		return fmt.Errorf("accept tx %s failed: %w", tx.TxID, err)
+	}
+	return n.relayTransaction(tx, p)
+}
+
+// listenAddrOf returns where a peer advertising addrFrom can be dialed, a wildcard host is replaced by
+// the host the peer connected from.
+func listenAddrOf(addrFrom, remote string) string {
+	host, port, err := net.SplitHostPort(addrFrom)
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = HostOf(remote)
+	}
+	return net.JoinHostPort(host, port)
+}
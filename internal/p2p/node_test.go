@@ -0,0 +1,350 @@
+package p2p
+
+import (
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/addrmgr"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/mempool"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+	"github.com/sgostarter/liblog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger, err := liblog.NewZapLogger()
+	if err != nil {
+		panic(err)
+	}
+	loge.SetGlobalLogger(loge.NewLogger(logger))
+
+	m.Run()
+}
+
+type testRemote struct {
+	conn     net.Conn
+	encoder  *gob.Encoder
+	received chan *message
+	closed   chan struct{}
+}
+
+func newTestNode(t *testing.T, cfg *Config) *Node {
+	stg := memdb.NewDB()
+	chains, err := blockchain.NewBlockChainsWithDB(stg)
+	assert.Nil(t, err)
+	banList, err := NewBanList(stg)
+	assert.Nil(t, err)
+	return NewNode(chains, banList, addrmgr.New(""), cfg)
+}
+
+// connectTestRemote links a hand driven remote to the node, whatever the node sends is drained, the first
+// messages are kept for receive.
+func connectTestRemote(t *testing.T, n *Node, addr string) *testRemote {
+	local, remote := net.Pipe()
+	_, err := n.AddPeer(local, addr, true)
+	assert.Nil(t, err)
+
+	r := &testRemote{
+		conn:     remote,
+		encoder:  gob.NewEncoder(remote),
+		received: make(chan *message, 64),
+		closed:   make(chan struct{}),
+	}
+	go func() {
+		defer close(r.closed)
+		decoder := gob.NewDecoder(remote)
+		for {
+			var msg message
+			if decoder.Decode(&msg) != nil {
+				return
+			}
+			select {
+			case r.received <- &msg:
+			default:
+			}
+		}
+	}()
+	return r
+}
+
+// receive decodes the next message of the command into payload, skipping the others.
+func (r *testRemote) receive(t *testing.T, command string, payload interface{}) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-r.received:
+			if msg.Command == command {
+				assert.Nil(t, msg.decode(payload))
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s received", command)
+		}
+	}
+}
+
+func (r *testRemote) send(t *testing.T, command string, payload interface{}) {
+	msg, err := newMessage(command, payload)
+	assert.Nil(t, err)
+	_ = r.encoder.Encode(msg)
+}
+
+func (r *testRemote) waitClosed(t *testing.T) {
+	select {
+	case <-r.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer not disconnected")
+	}
+}
+
+func TestNode_BanInvalidBlock(t *testing.T) {
+	n := newTestNode(t, nil)
+	defer n.Close()
+	r := connectTestRemote(t, n, "10.0.0.1:8333")
+
+	var latestHash chainhash.Hash
+	_ = n.View(func(chains *blockchain.BlockChains) error {
+		latestHash = chains.GetLatestBlock().Hash
+		return nil
+	})
+	block := blockchain.MineBlock([]*blockchain.Transaction{
+		blockchain.NewCoinbaseTX(blockchain.NewWallet().GetAddress(), ""),
+	}, latestHash)
+	block.Nonce++
+	r.send(t, cmdBlock, &blockMsg{Block: block.Serialize()})
+
+	r.waitClosed(t)
+	assert.True(t, n.BanList().IsBanned("10.0.0.1"))
+
+	local, _ := net.Pipe()
+	_, err := n.AddPeer(local, "10.0.0.1:8334", true)
+	assert.ErrorIs(t, err, ErrBanned)
+}
+
+func TestNode_BanMalformed(t *testing.T) {
+	n := newTestNode(t, &Config{BanThreshold: 150})
+	defer n.Close()
+	r := connectTestRemote(t, n, "10.0.0.2:8333")
+
+	r.send(t, cmdBlock, &blockMsg{Block: []byte("garbage")})
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, n.BanList().IsBanned("10.0.0.2"))
+
+	_ = r.encoder.Encode(&message{Command: cmdInv, Payload: []byte("garbage")})
+	r.waitClosed(t)
+	assert.True(t, n.BanList().IsBanned("10.0.0.2"))
+}
+
+func TestNode_BanOrphans(t *testing.T) {
+	n := newTestNode(t, &Config{BanThreshold: 20})
+	defer n.Close()
+	r := connectTestRemote(t, n, "10.0.0.3:8333")
+
+	address := blockchain.NewWallet().GetAddress()
+	orphans := make([]*blockchain.Block, 0)
+	for idx := 0; idx < 5; idx++ {
+		orphans = append(orphans, blockchain.MineBlock([]*blockchain.Transaction{
+			blockchain.NewCoinbaseTX(address, ""),
+		}, chainhash.HashH([]byte{byte(idx)})))
+	}
+	for _, block := range orphans {
+		r.send(t, cmdBlock, &blockMsg{Block: block.Serialize()})
+	}
+
+	r.waitClosed(t)
+	assert.True(t, n.BanList().IsBanned("10.0.0.3"))
+}
+
+func TestNode_RelayPolicy(t *testing.T) {
+	n := newTestNode(t, &Config{MinRelayFee: 4})
+	defer n.Close()
+	r := connectTestRemote(t, n, "10.0.0.4:8333")
+
+	wallet := blockchain.NewWallet()
+	block, err := n.Mine(wallet.GetAddress())
+	assert.Nil(t, err)
+	coinbase := block.Transactions[0]
+	tx := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: coinbase.TxID, Vout: 0}},
+		Vout: []blockchain.TXOutput{*blockchain.NewTXOutput(0, blockchain.Subsidy, wallet.GetAddress())},
+	}
+	tx.TxID = hex.EncodeToString(tx.Hash()[:])
+	cond := &blockchain.TransactionVerifyCond{Outputs: map[string][]blockchain.TXOutput{coinbase.TxID: coinbase.Vout}}
+	assert.Nil(t, tx.Sign(wallet.PrivateKey, cond))
+
+	// a transaction paying no fee is neither pooled nor relayed, the peer sending it is not to blame
+	assert.ErrorIs(t, n.SubmitTransaction(tx), mempool.ErrInsufficientFee)
+	r.send(t, cmdTx, &txMsg{Transaction: *tx})
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, n.TxPool().HaveTransaction(tx.TxID))
+	assert.Len(t, n.Peers(), 1)
+	assert.False(t, n.BanList().IsBanned("10.0.0.4"))
+}
+
+func TestNode_GetBlocks(t *testing.T) {
+	n := newTestNode(t, nil)
+	defer n.Close()
+
+	address := blockchain.NewWallet().GetAddress()
+	hashes := make([]chainhash.Hash, 0)
+	for idx := 0; idx < 3; idx++ {
+		block, err := n.Mine(address)
+		assert.Nil(t, err)
+		hashes = append(hashes, block.Hash)
+	}
+	r := connectTestRemote(t, n, "10.0.0.5:8333")
+
+	// the blocks after the latest known hash of the locator, the tip first
+	r.send(t, cmdGetBlocks, &getBlocksMsg{Locator: []chainhash.Hash{chainhash.HashH([]byte("unknown")), hashes[0]}})
+	var inv invMsg
+	r.receive(t, cmdInv, &inv)
+	assert.Equal(t, invTypeBlock, inv.Type)
+	assert.Equal(t, []chainhash.Hash{hashes[2], hashes[1]}, inv.Items)
+
+	r.send(t, cmdInv, &invMsg{Type: invTypeBlock, Items: make([]chainhash.Hash, maxInvPerMsg+1)})
+	r.waitClosed(t)
+}
+
+// getdata is answered apart from the read loop, a peer not reading the answers still gets its other messages
+// handled, until it asks for more than the node keeps queued
+func TestNode_GetDataSlowPeer(t *testing.T) {
+	n := newTestNode(t, nil)
+	defer n.Close()
+	local, remote := net.Pipe()
+	_, err := n.AddPeer(local, "10.0.0.6:8333", true)
+	assert.Nil(t, err)
+	r := &testRemote{conn: remote, encoder: gob.NewEncoder(remote)}
+
+	var genesisHash chainhash.Hash
+	_ = n.View(func(chains *blockchain.BlockChains) error {
+		genesisHash = chains.GetLatestBlock().Hash
+		return nil
+	})
+	items := make([]chainhash.Hash, maxInvPerMsg)
+	for idx := range items {
+		items[idx] = genesisHash
+	}
+	r.send(t, cmdGetData, &getDataMsg{Type: invTypeBlock, Items: items})
+	r.send(t, cmdAddr, &addrMsg{AddrList: []string{"10.1.0.1:8333"}})
+	assert.Eventually(t, func() bool {
+		return n.AddrManager().NumAddresses() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for idx := 0; idx < getDataQueueSize+1; idx++ {
+		r.send(t, cmdGetData, &getDataMsg{Type: invTypeBlock, Items: items[:1]})
+	}
+	assert.Eventually(t, func() bool {
+		return len(n.Peers()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, n.BanList().IsBanned("10.0.0.6"))
+}
+
+func TestConfig_MinRelayFee(t *testing.T) {
+	assert.Equal(t, mempool.DefaultMinRelayFee, (*Config)(nil).withDefaults().MinRelayFee)
+	assert.EqualValues(t, 4, (&Config{MinRelayFee: 4}).withDefaults().MinRelayFee)
+	assert.EqualValues(t, 0, (&Config{MinRelayFee: 4, FreeRelay: true}).withDefaults().MinRelayFee)
+}
+
+func TestBlockRejectScore(t *testing.T) {
+	assert.EqualValues(t, 0, blockRejectScore(errors.New("storage failed")))
+	assert.EqualValues(t, 0, blockRejectScore(fmt.Errorf("wrapped: %w", blockchain.RuleError{ErrorCode: blockchain.ErrDuplicateBlock})))
+	for _, code := range []blockchain.ErrorCode{blockchain.ErrHighHash, blockchain.ErrMissingTxOut, blockchain.ErrSpentTxOut} {
+		assert.EqualValues(t, scoreInvalid, blockRejectScore(fmt.Errorf("wrapped: %w", blockchain.RuleError{ErrorCode: code})))
+	}
+}
+
+func TestTxRejectScore(t *testing.T) {
+	assert.EqualValues(t, 0, txRejectScore(errors.New("storage failed")))
+	for _, code := range []blockchain.ErrorCode{blockchain.ErrMissingTxOut, blockchain.ErrSpentTxOut, blockchain.ErrUnfinalizedTx} {
+		assert.EqualValues(t, 0, txRejectScore(fmt.Errorf("wrapped: %w", blockchain.RuleError{ErrorCode: code})))
+	}
+	assert.EqualValues(t, scoreInvalid, txRejectScore(blockchain.RuleError{ErrorCode: blockchain.ErrSpendTooHigh}))
+}
+
+func TestBanScore(t *testing.T) {
+	var s banScore
+	now := time.Now()
+
+	assert.EqualValues(t, 10, s.increase(10, 0, now))
+	assert.EqualValues(t, 50, s.increase(0, 40, now))
+	assert.EqualValues(t, 30, s.int(now.Add(halfLife)))
+	assert.EqualValues(t, 10, s.int(now.Add(halfLife*20)))
+}
+
+func TestBanList(t *testing.T) {
+	bl, err := NewBanList(memdb.NewDB())
+	assert.Nil(t, err)
+
+	assert.Nil(t, bl.Ban("10.0.0.1", time.Now().Add(time.Hour), "test"))
+	assert.Nil(t, bl.Ban("10.0.0.2", time.Now().Add(-time.Second), "expired"))
+	assert.True(t, bl.IsBanned("10.0.0.1"))
+	assert.False(t, bl.IsBanned("10.0.0.2"))
+	assert.Len(t, bl.List(), 1)
+
+	assert.Nil(t, bl.Unban("10.0.0.1"))
+	assert.False(t, bl.IsBanned("10.0.0.1"))
+
+	assert.Nil(t, bl.Ban("10.0.0.3", time.Now().Add(time.Hour), "test"))
+	assert.Nil(t, bl.Clear())
+	assert.Len(t, bl.List(), 0)
+
+	assert.Equal(t, "10.0.0.1", HostOf("10.0.0.1:8333"))
+	assert.Equal(t, "simnet-1", HostOf("simnet-1"))
+}
+
+func TestNode_AddrExchange(t *testing.T) {
+	a := newTestNode(t, nil)
+	defer a.Close()
+	b := newTestNode(t, nil)
+	defer b.Close()
+	assert.Nil(t, b.Listen("127.0.0.1:0"))
+
+	a.AddrManager().AddAddresses([]string{"10.0.0.1:8333", "10.1.0.1:8333"}, "10.2.0.1:8333")
+
+	connA, connB := net.Pipe()
+	_, err := a.AddPeer(connA, "10.3.0.1:8333", true)
+	assert.Nil(t, err)
+	_, err = b.AddPeer(connB, "10.4.0.1:8333", false)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return b.AddrManager().NumAddresses() == 2 && a.AddrManager().NumAddresses() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a learns where b listens from b's version, b does not record itself from a's reply
+	found := false
+	for _, ka := range a.AddrManager().Addresses() {
+		found = found || ka.Addr == b.listenAddr
+	}
+	assert.True(t, found)
+}
+
+func TestNode_ConnectSeeds(t *testing.T) {
+	seed := newTestNode(t, nil)
+	defer seed.Close()
+	assert.Nil(t, seed.Listen("127.0.0.1:0"))
+
+	n := newTestNode(t, &Config{Seeds: []string{seed.listenAddr}})
+	defer n.Close()
+	n.Start()
+
+	assert.Eventually(t, func() bool {
+		addrs := n.AddrManager().Addresses()
+		return len(n.Peers()) == 1 && len(seed.Peers()) == 1 && len(addrs) == 1 && addrs[0].Tried
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestListenAddrOf(t *testing.T) {
+	assert.Equal(t, "10.0.0.1:3000", listenAddrOf("[::]:3000", "10.0.0.1:50000"))
+	assert.Equal(t, "10.0.0.1:3000", listenAddrOf(":3000", "10.0.0.1:50000"))
+	assert.Equal(t, "10.0.0.2:3000", listenAddrOf("10.0.0.2:3000", "10.0.0.1:50000"))
+	assert.Equal(t, "", listenAddrOf("", "10.0.0.1:50000"))
+}
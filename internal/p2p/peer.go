@@ -0,0 +1,163 @@
+package p2p
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+)
+
+const (
+	sendQueueSize = 256
+	// getDataQueueSize caps the getdata messages of a peer waiting for an answer.
+	getDataQueueSize = 16
+)
+
+var (
+	errPeerClosed   = errors.New("peer closed")
+	errGetDataQueue = errors.New("getdata queue full")
+)
+
+// Peer is a connected remote node, messages are gob encoded on the connection.
+type Peer struct {
+	addr     string
+	inbound  bool
+	conn     net.Conn
+	sendChan chan *message
+	// getDataChan holds the getdata messages answered by the node's data loop, apart from the read loop so
+	// that a peer slow to read does not stall its other messages.
+	getDataChan chan *getDataMsg
+	quit        chan struct{}
+	banScore    banScore
+	// continueHash is the tip of the last full block inventory of the peer, once it is connected the blocks
+	// after it are asked for. Only the read loop uses it.
+	continueHash chainhash.Hash
+
+	closeOnce sync.Once
+}
+
+func newPeer(conn net.Conn, addr string, inbound bool) *Peer {
+	if addr == "" {
+		addr = conn.RemoteAddr().String()
+	}
+	return &Peer{
+		addr:        addr,
+		inbound:     inbound,
+		conn:        conn,
+		sendChan:    make(chan *message, sendQueueSize),
+		getDataChan: make(chan *getDataMsg, getDataQueueSize),
+		quit:        make(chan struct{}),
+	}
+}
+
+func (p *Peer) Addr() string {
+	return p.addr
+}
+
+func (p *Peer) Inbound() bool {
+	return p.inbound
+}
+
+// Connected tells if the peer was not disconnected yet.
+func (p *Peer) Connected() bool {
+	select {
+	case <-p.quit:
+		return false
+	default:
+		return true
+	}
+}
+
+// Disconnect closes the connection, the node drops the peer once its read loop exits.
+func (p *Peer) Disconnect() {
+	p.closeOnce.Do(func() {
+		close(p.quit)
+		_ = p.conn.Close()
+	})
+}
+
+func (p *Peer) send(command string, payload interface{}) error {
+	msg, err := newMessage(command, payload)
+	if err != nil {
+		return err
+	}
+	select {
+	case p.sendChan <- msg:
+		return nil
+	case <-p.quit:
+		return errPeerClosed
+	}
+}
+
+// queueGetData hands the getdata message to the data loop, a peer asking faster than it reads the answers is
+// disconnected.
+func (p *Peer) queueGetData(payload *getDataMsg) error {
+	select {
+	case p.getDataChan <- payload:
+		return nil
+	default:
+		p.Disconnect()
+		return errGetDataQueue
+	}
+}
+
+func (p *Peer) writeLoop() {
+	encoder := gob.NewEncoder(p.conn)
+	for {
+		select {
+		case msg := <-p.sendChan:
+			err := encoder.Encode(msg)
+			if err != nil {
+				loge.Errorf(nil, "write to peer %s failed: %v", p.addr, err)
+				p.Disconnect()
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// BanScore returns the current misbehaviour score.
+func (p *Peer) BanScore() uint32 {
+	return p.banScore.Int()
+}
+
+// readLoop dispatches messages until the connection fails, it returns the error of a garbled stream.
+func (p *Peer) readLoop(handle func(p *Peer, msg *message) error) error {
+	defer p.Disconnect()
+
+	decoder := gob.NewDecoder(p.conn)
+	for {
+		var msg message
+		err := decoder.Decode(&msg)
+		if err != nil {
+			select {
+			case <-p.quit:
+				return nil
+			default:
+			}
+			if isDisconnectError(err) {
+				return nil
+			}
+			loge.Errorf(nil, "read from peer %s failed: %v", p.addr, err)
+			return fmt.Errorf("%w: %v", errMalformedMessage, err)
+		}
+		err = handle(p, &msg)
+		if err != nil {
+			loge.Errorf(nil, "handle %s from peer %s failed: %v", msg.Command, p.addr, err)
+		}
+	}
+}
+
+// isDisconnectError tells a connection going away from a stream which can not be decoded.
+func isDisconnectError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.As(err, &netErr)
+}
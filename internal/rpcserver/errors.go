@@ -0,0 +1,74 @@
+package rpcserver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+)
+
+// Error codes of the JSON-RPC 2.0 spec, then the application codes which follow bitcoind's.
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+
+	ErrCodeMisc                = -1
+	ErrCodeWallet              = -4
+	ErrCodeInvalidAddressOrKey = -5
+	ErrCodeInsufficientFunds   = -6
+	ErrCodeInvalidParameter    = -8
+	ErrCodeWalletNotFound      = -18
+	ErrCodeWalletNotSpecified  = -19
+	ErrCodeDeserialization     = -22
+	ErrCodeVerify              = -25
+
+	ErrCodeWalletUnlockNeeded        = -13
+	ErrCodeWalletPassphraseIncorrect = -14
+	ErrCodeWalletWrongEncState       = -15
+)
+
+// Error is the error object of a JSON-RPC response.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+func newError(code int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func toError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	switch {
+	case errors.Is(err, blockchain.ErrWalletLocked):
+		return newError(ErrCodeWalletUnlockNeeded, "wallet is locked, unlock it with walletpassphrase first")
+	case errors.Is(err, blockchain.ErrWrongPassphrase):
+		return newError(ErrCodeWalletPassphraseIncorrect, err.Error())
+	case errors.Is(err, blockchain.ErrWalletEncrypted), errors.Is(err, blockchain.ErrWalletNotEncrypted):
+		return newError(ErrCodeWalletWrongEncState, err.Error())
+	case errors.Is(err, blockchain.ErrWatchOnly), errors.Is(err, blockchain.ErrAddressNotInWallet),
+		errors.Is(err, blockchain.ErrMultiSig):
+		return newError(ErrCodeWallet, err.Error())
+	case errors.Is(err, blockchain.ErrWalletNotFound), errors.Is(err, blockchain.ErrWalletNotLoaded):
+		return newError(ErrCodeWalletNotFound, err.Error())
+	case errors.Is(err, blockchain.ErrWalletExists), errors.Is(err, blockchain.ErrWalletLoaded),
+		errors.Is(err, blockchain.ErrInvalidWalletName):
+		return newError(ErrCodeWallet, err.Error())
+	case errors.Is(err, blockchain.ErrInsufficientFunds):
+		return newError(ErrCodeInsufficientFunds, err.Error())
+	}
+	return newError(ErrCodeMisc, err.Error())
+}
@@ -0,0 +1,1257 @@
+package rpcserver
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+type handler func(s *callContext, params []json.RawMessage) (interface{}, error)
+
+var rpcHandlers = map[string]handler{
+	"getbestblockhash":   handleGetBestBlockHash,
+	"getblock":           handleGetBlock,
+	"getblockhash":       handleGetBlockHash,
+	"getrawtransaction":  handleGetRawTransaction,
+	"gettxout":           handleGetTxOut,
+	"getbalance":         handleGetBalance,
+	"sendtoaddress":      handleSendToAddress,
+	"sendmany":           handleSendMany,
+	"getblocktemplate":   handleGetBlockTemplate,
+	"submitblock":        handleSubmitBlock,
+	"sendrawtransaction": handleSendRawTransaction,
+	"createpsbt":         handleCreatePSBT,
+	"combinepsbt":        handleCombinePSBT,
+	"finalizepsbt":       handleFinalizePSBT,
+	"verifymessage":      handleVerifyMessage,
+	"createmultisig":     handleCreateMultiSig,
+	"getdataanchors":     handleGetDataAnchors,
+
+	"encryptwallet":          handleEncryptWallet,
+	"walletpassphrase":       handleWalletPassphrase,
+	"walletpassphrasechange": handleWalletPassphraseChange,
+	"walletlock":             handleWalletLock,
+	"listtransactions":       handleListTransactions,
+	"setlabel":               handleSetLabel,
+	"importaddress":          handleImportAddress,
+	"addmultisigaddress":     handleAddMultiSigAddress,
+	"dumpprivkey":            handleDumpPrivKey,
+	"importprivkey":          handleImportPrivKey,
+	"listunspent":            handleListUnspent,
+	"walletprocesspsbt":      handleWalletProcessPSBT,
+	"signmessage":            handleSignMessage,
+	"anchordata":             handleAnchorData,
+	"createwallet":           handleCreateWallet,
+	"loadwallet":             handleLoadWallet,
+	"unloadwallet":           handleUnloadWallet,
+	"listwallets":            handleListWallets,
+	"listwalletdir":          handleListWalletDir,
+}
+
+// BlockResult is the verbose reply of getblock.
+type BlockResult struct {
+	Hash              string   `json:"hash"`
+	Confirmations     int64    `json:"confirmations"`
+	Height            int64    `json:"height"`
+	PreviousBlockHash string   `json:"previousblockhash"`
+	Time              int64    `json:"time"`
+	Nonce             uint32   `json:"nonce"`
+	Tx                []string `json:"tx"`
+}
+
+// TxInResult is an input of the verbose reply of getrawtransaction.
+type TxInResult struct {
+	TxID     string `json:"txid,omitempty"`
+	Vout     int    `json:"vout"`
+	Coinbase string `json:"coinbase,omitempty"`
+}
+
+// TxOutResult is an output of the verbose reply of getrawtransaction.
+type TxOutResult struct {
+	N          int    `json:"n"`
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubkeyhash"`
+	Address    string `json:"address"`
+	// Data is what a data carrier output holds, hex encoded.
+	Data string `json:"data,omitempty"`
+}
+
+// TxResult is the verbose reply of getrawtransaction.
+type TxResult struct {
+	Hex  string        `json:"hex"`
+	TxID string        `json:"txid"`
+	Vin  []TxInResult  `json:"vin"`
+	Vout []TxOutResult `json:"vout"`
+}
+
+// GetTxOutResult is the reply of gettxout.
+type GetTxOutResult struct {
+	BestBlock  string `json:"bestblock"`
+	Value      int    `json:"value"`
+	PubKeyHash string `json:"pubkeyhash"`
+	Address    string `json:"address"`
+}
+
+// ListTxResult is a transaction of the reply of listtransactions.
+type ListTxResult struct {
+	TxID           string   `json:"txid"`
+	BlockHash      string   `json:"blockhash"`
+	Height         int64    `json:"height"`
+	Confirmations  int64    `json:"confirmations"`
+	Time           int64    `json:"time"`
+	Amount         int      `json:"amount"`
+	Fee            int      `json:"fee"`
+	Coinbase       bool     `json:"coinbase"`
+	Counterparties []string `json:"counterparties"`
+	Label          string   `json:"label,omitempty"`
+	WatchOnly      bool     `json:"involveswatchonly,omitempty"`
+}
+
+// UnspentResult is an output of the reply of listunspent.
+type UnspentResult struct {
+	TxID      string `json:"txid"`
+	Vout      int    `json:"vout"`
+	Address   string `json:"address"`
+	Value     int    `json:"value"`
+	Spendable bool   `json:"spendable"`
+}
+
+// PSBTResult is the reply of walletprocesspsbt.
+type PSBTResult struct {
+	PSBT     string `json:"psbt"`
+	Complete bool   `json:"complete"`
+}
+
+// FinalizePSBTResult is the reply of finalizepsbt, Hex is the transaction once the partial one is complete.
+type FinalizePSBTResult struct {
+	PSBT     string `json:"psbt,omitempty"`
+	Hex      string `json:"hex,omitempty"`
+	Complete bool   `json:"complete"`
+}
+
+// MultiSigResult is the reply of createmultisig.
+type MultiSigResult struct {
+	Address      string `json:"address"`
+	RedeemScript string `json:"redeemScript"`
+}
+
+// TemplateTx is a transaction of the reply of getblocktemplate.
+type TemplateTx struct {
+	TxID string `json:"txid"`
+	Data string `json:"data"`
+}
+
+// BlockTemplateResult is the reply of getblocktemplate, the block to mine next without its coinbase.
+type BlockTemplateResult struct {
+	PreviousBlockHash string       `json:"previousblockhash"`
+	Height            int64        `json:"height"`
+	CurTime           int64        `json:"curtime"`
+	CoinbaseValue     int          `json:"coinbasevalue"`
+	SizeLimit         int          `json:"sizelimit"`
+	SigOpLimit        int          `json:"sigoplimit"`
+	Transactions      []TemplateTx `json:"transactions"`
+}
+
+func parseParams(params []json.RawMessage, required int, args ...interface{}) error {
+	if len(params) < required || len(params) > len(args) {
+		return newError(ErrCodeInvalidParams, fmt.Sprintf("want %d to %d params, got %d", required, len(args), len(params)))
+	}
+	for idx, param := range params {
+		err := json.Unmarshal(param, args[idx])
+		if err != nil {
+			return newError(ErrCodeInvalidParams, fmt.Sprintf("param %d: %v", idx+1, err))
+		}
+	}
+	return nil
+}
+
+func parseHash(s string) (*chainhash.Hash, error) {
+	h, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidParameter, fmt.Sprintf("invalid hash %s: %v", s, err))
+	}
+	return h, nil
+}
+
+func checkAddress(address string) error {
+	if !utils.IsValidAddress(address) {
+		return newError(ErrCodeInvalidAddressOrKey, "invalid address: "+address)
+	}
+	return nil
+}
+
+func handleGetBestBlockHash(s *callContext, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var h chainhash.Hash
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		h = chains.GetLatestBlock().Hash
+		return nil
+	})
+	return h.String(), nil
+}
+
+func handleGetBlockHash(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var height int64
+	err := parseParams(params, 1, &height)
+	if err != nil {
+		return nil, err
+	}
+
+	var block *blockchain.Block
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		block = chains.GetBlockByHeight(height)
+		return nil
+	})
+	if block == nil {
+		return nil, newError(ErrCodeInvalidParameter, "block height out of range")
+	}
+	return block.Hash.String(), nil
+}
+
+func handleGetBlock(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var hashStr string
+	verbose := true
+	err := parseParams(params, 1, &hashStr, &verbose)
+	if err != nil {
+		return nil, err
+	}
+	h, err := parseHash(hashStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var block *blockchain.Block
+	var bestHeight int64
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		block = chains.GetBlock(h)
+		bestHeight = chains.GetBestHeight()
+		return nil
+	})
+	if block == nil {
+		return nil, newError(ErrCodeInvalidAddressOrKey, "block not found")
+	}
+	if !verbose {
+		return hex.EncodeToString(block.Serialize()), nil
+	}
+
+	txIDs := make([]string, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txIDs = append(txIDs, tx.TxID)
+	}
+	return &BlockResult{
+		Hash:              block.Hash.String(),
+		Confirmations:     bestHeight - block.Height + 1,
+		Height:            block.Height,
+		PreviousBlockHash: block.PrevBlockHash.String(),
+		Time:              block.Timestamp,
+		Nonce:             block.Nonce,
+		Tx:                txIDs,
+	}, nil
+}
+
+func handleGetRawTransaction(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var txID string
+	var verbose bool
+	err := parseParams(params, 1, &txID, &verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.node.TxPool().FetchTransaction(txID)
+	if tx == nil {
+		_ = s.node.View(func(chains *blockchain.BlockChains) error {
+			tx, _ = chains.FindTransaction(txID)
+			return nil
+		})
+	}
+	if tx == nil {
+		return nil, newError(ErrCodeInvalidAddressOrKey, "no such mempool or blockchain transaction")
+	}
+
+	txHex := hex.EncodeToString(tx.Serialize())
+	if !verbose {
+		return txHex, nil
+	}
+
+	result := &TxResult{
+		Hex:  txHex,
+		TxID: tx.TxID,
+		Vin:  make([]TxInResult, 0, len(tx.Vin)),
+		Vout: make([]TxOutResult, 0, len(tx.Vout)),
+	}
+	for _, input := range tx.Vin {
+		if tx.IsCoinbase() {
+			result.Vin = append(result.Vin, TxInResult{Vout: input.Vout, Coinbase: hex.EncodeToString(input.PubKey)})
+			continue
+		}
+		result.Vin = append(result.Vin, TxInResult{TxID: input.Txid, Vout: input.Vout})
+	}
+	for idx := range tx.Vout {
+		data, _ := script.ExtractNullData(tx.Vout[idx].LockingScript())
+		result.Vout = append(result.Vout, TxOutResult{
+			N:          tx.Vout[idx].Index,
+			Value:      tx.Vout[idx].Value,
+			PubKeyHash: hex.EncodeToString(tx.Vout[idx].PubKeyHash),
+			Address:    tx.Vout[idx].Address(),
+			Data:       hex.EncodeToString(data),
+		})
+	}
+	return result, nil
+}
+
+// handleGetTxOut replies null for outputs which are spent, by the chain or by a pooled transaction.
+func handleGetTxOut(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var txID string
+	var index int
+	err := parseParams(params, 2, &txID, &index)
+	if err != nil {
+		return nil, err
+	}
+	if s.node.TxPool().IsSpent(txID, index) {
+		return nil, nil
+	}
+
+	var output *blockchain.TXOutput
+	var bestHash chainhash.Hash
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		output = chains.GetUTXO(txID, index)
+		bestHash = chains.GetLatestBlock().Hash
+		return nil
+	})
+	if output == nil {
+		return nil, nil
+	}
+	return &GetTxOutResult{
+		BestBlock:  bestHash.String(),
+		Value:      output.Value,
+		PubKeyHash: hex.EncodeToString(output.PubKeyHash),
+		Address:    output.Address(),
+	}, nil
+}
+
+// handleGetBalance replies the balance of the address, of all wallet addresses when none is given.
+func handleGetBalance(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var address string
+	err := parseParams(params, 0, &address)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{address}
+	if address == "" {
+		addresses = s.walletAddresses()
+	}
+	for _, addr := range addresses {
+		err = checkAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	balance := 0
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		for _, addr := range addresses {
+			balance += chains.GetBalance(addr)
+		}
+		return nil
+	})
+	return balance, nil
+}
+
+func (s *callContext) walletAddresses() []string {
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil
+	}
+	addresses := wallets.GetAddresses()
+	sort.Strings(addresses)
+	return addresses
+}
+
+func (s *callContext) spendableAddresses() []string {
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil
+	}
+	addresses := wallets.GetSpendableAddresses()
+	sort.Strings(addresses)
+	return addresses
+}
+
+// handleSendToAddress pays amount to the address from fromaddress, or from the spendable wallet addresses,
+// and relays the transaction. It replies the transaction id.
+func handleSendToAddress(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var to, from string
+	var amount int
+	err := parseParams(params, 2, &to, &amount, &from)
+	if err != nil {
+		return nil, err
+	}
+	err = checkAddress(to)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, newError(ErrCodeInvalidParameter, "amount must be positive")
+	}
+	var fromAddresses []string
+	if from != "" {
+		fromAddresses = []string{from}
+	}
+	return s.sendPayment(fromAddresses, []blockchain.Recipient{{Address: to, Amount: amount}})
+}
+
+// handleSendMany pays the amounts of the address map param from the fromaddresses, or from the spendable
+// wallet addresses, in one transaction and relays it. It replies the transaction id.
+func handleSendMany(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var amounts map[string]int
+	var from []string
+	err := parseParams(params, 1, &amounts, &from)
+	if err != nil {
+		return nil, err
+	}
+	if len(amounts) == 0 {
+		return nil, newError(ErrCodeInvalidParameter, "no recipient")
+	}
+	recipients := make([]blockchain.Recipient, 0, len(amounts))
+	for address, amount := range amounts {
+		err = checkAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		if amount <= 0 {
+			return nil, newError(ErrCodeInvalidParameter, "amount must be positive")
+		}
+		recipients = append(recipients, blockchain.Recipient{Address: address, Amount: amount})
+	}
+	sort.Slice(recipients, func(i, j int) bool {
+		return recipients[i].Address < recipients[j].Address
+	})
+	for _, address := range from {
+		err = checkAddress(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.sendPayment(from, recipients)
+}
+
+// sendPayment pays recipients from the wallet, skipping the outputs pooled transactions spend, and submits
+// the transaction.
+func (s *callContext) sendPayment(from []string, recipients []blockchain.Recipient) (interface{}, error) {
+	return s.sendWalletTx(from, func(wallets *blockchain.Wallets, chains *blockchain.BlockChains,
+		opts *blockchain.SpendOptions) (*blockchain.Transaction, error) {
+		return wallets.Send(chains, from, recipients, opts)
+	})
+}
+
+// sendWalletTx submits the transaction create builds with the wallet from the addresses from, or from the
+// spendable wallet addresses when there are none.
+func (s *callContext) sendWalletTx(from []string, create func(wallets *blockchain.Wallets,
+	chains *blockchain.BlockChains, opts *blockchain.SpendOptions) (*blockchain.Transaction, error)) (interface{}, error) {
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	if len(from) == 0 && len(s.spendableAddresses()) == 0 {
+		return nil, newError(ErrCodeWallet, "no wallet")
+	}
+
+	opts := &blockchain.SpendOptions{Locks: s.node.TxPool().CoinLocks(), FeeRate: s.node.TxPool().MinRelayFee()}
+	var tx *blockchain.Transaction
+	err = s.node.View(func(chains *blockchain.BlockChains) error {
+		var errSend error
+		tx, errSend = create(wallets, chains, opts)
+		return errSend
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.node.SubmitTransaction(tx)
+	if err != nil {
+		return nil, newError(ErrCodeVerify, err.Error())
+	}
+	return tx.TxID, nil
+}
+
+// parseData decodes the hex data a data carrier output is to hold.
+func parseData(hexData string) ([]byte, error) {
+	data, err := hex.DecodeString(hexData)
+	if err != nil || len(data) == 0 {
+		return nil, newError(ErrCodeInvalidParameter, "data must be non empty hex")
+	}
+	if len(data) > script.MaxDataCarrierSize {
+		return nil, newError(ErrCodeInvalidParameter, fmt.Sprintf("data is larger than %d bytes", script.MaxDataCarrierSize))
+	}
+	return data, nil
+}
+
+// handleAnchorData puts the hex data into an output nobody can spend, paid from the fromaddresses or from
+// the spendable wallet addresses, and relays the transaction. It replies the transaction id.
+func handleAnchorData(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var hexData string
+	var from []string
+	err := parseParams(params, 1, &hexData, &from)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseData(hexData)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range from {
+		err = checkAddress(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.sendWalletTx(from, func(wallets *blockchain.Wallets, chains *blockchain.BlockChains,
+		opts *blockchain.SpendOptions) (*blockchain.Transaction, error) {
+		return wallets.AnchorData(chains, from, data, opts)
+	})
+}
+
+// DataAnchorResult is a transaction of the reply of getdataanchors.
+type DataAnchorResult struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockhash"`
+	Height    int64  `json:"height"`
+	Time      int64  `json:"time"`
+}
+
+// handleGetDataAnchors replies the main chain transactions carrying the hex data, the oldest first.
+func handleGetDataAnchors(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var hexData string
+	err := parseParams(params, 1, &hexData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseData(hexData)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]DataAnchorResult, 0)
+	err = s.node.View(func(chains *blockchain.BlockChains) error {
+		for _, anchor := range chains.FindDataAnchors(data) {
+			results = append(results, DataAnchorResult{
+				TxID:      anchor.TxID,
+				BlockHash: anchor.BlockHash.String(),
+				Height:    anchor.Height,
+				Time:      anchor.Timestamp,
+			})
+		}
+		return nil
+	})
+	return results, err
+}
+
+func handleGetBlockTemplate(s *callContext, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BlockTemplateResult{
+		CurTime:       time.Now().Unix(),
+		CoinbaseValue: blockchain.Subsidy,
+		Transactions:  make([]TemplateTx, 0),
+	}
+	poolTxs := s.node.TxPool().Transactions()
+	var txs []*blockchain.Transaction
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		latestBlock := chains.GetLatestBlock()
+		result.PreviousBlockHash = latestBlock.Hash.String()
+		result.Height = latestBlock.Height + 1
+		result.SizeLimit = chains.Params().MaxBlockSize
+		result.SigOpLimit = chains.Params().MaxBlockSigOps
+		txs = chains.SelectBlockTxs(poolTxs)
+		return nil
+	})
+	for _, tx := range txs {
+		result.Transactions = append(result.Transactions, TemplateTx{
+			TxID: tx.TxID,
+			Data: hex.EncodeToString(tx.Serialize()),
+		})
+	}
+	return result, nil
+}
+
+// handleSubmitBlock follows BIP22: null when the block is accepted, the reason as a string otherwise.
+func handleSubmitBlock(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var blockHex string
+	err := parseParams(params, 1, &blockHex)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(blockHex)
+	if err != nil {
+		return nil, newError(ErrCodeDeserialization, "block decode failed: "+err.Error())
+	}
+	block := blockchain.DeserializeBlock(data)
+	if block == nil {
+		return nil, newError(ErrCodeDeserialization, "block decode failed")
+	}
+
+	duplicate := false
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		duplicate = chains.HasBlock(block.Hash)
+		return nil
+	})
+	if duplicate {
+		return "duplicate", nil
+	}
+
+	err = s.node.SubmitBlock(block)
+	if err != nil {
+		var ruleErr blockchain.RuleError
+		if errors.As(err, &ruleErr) {
+			return "rejected: " + ruleErr.Error(), nil
+		}
+		return nil, err
+	}
+
+	orphan := false
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		orphan = chains.IsOrphan(block.Hash)
+		return nil
+	})
+	if orphan {
+		return "inconclusive", nil
+	}
+	return nil, nil
+}
+
+// walletsOrError returns the wallet the request path names, or the only loaded one.
+func (s *callContext) walletsOrError() (*blockchain.Wallets, error) {
+	if s.wallets == nil {
+		return nil, newError(ErrCodeWalletNotFound, "no wallet loaded")
+	}
+	if s.named {
+		wallets, err := s.wallets.Wallet(s.walletName)
+		if err != nil {
+			return nil, newError(ErrCodeWalletNotFound, fmt.Sprintf("wallet %q is not loaded", s.walletName))
+		}
+		return wallets, nil
+	}
+
+	names := s.wallets.LoadedWallets()
+	switch len(names) {
+	case 0:
+		return nil, newError(ErrCodeWalletNotFound, "no wallet loaded")
+	case 1:
+		wallets, err := s.wallets.Wallet(names[0])
+		if err != nil {
+			return nil, newError(ErrCodeWalletNotFound, err.Error())
+		}
+		return wallets, nil
+	}
+	return nil, newError(ErrCodeWalletNotSpecified, "several wallets are loaded, select one with the "+walletPathPrefix+"<name> path")
+}
+
+func (s *callContext) walletManager() (*blockchain.WalletManager, error) {
+	if s.wallets == nil {
+		return nil, newError(ErrCodeWallet, "the node has no wallets")
+	}
+	return s.wallets, nil
+}
+
+// handleCreateWallet creates the empty wallet named by the param and loads it. It replies the name.
+func handleCreateWallet(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var name string
+	err := parseParams(params, 1, &name)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := s.walletManager()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = manager.CreateWallet(name)
+	if err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+// handleLoadWallet loads the wallet named by the param and brings its history up to the chain. It replies
+// the name.
+func handleLoadWallet(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var name string
+	err := parseParams(params, 1, &name)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := s.walletManager()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.node.View(func(chains *blockchain.BlockChains) error {
+		wallets, errLoad := manager.LoadWallet(name)
+		if errLoad != nil {
+			return errLoad
+		}
+		wallets.SyncChain(chains)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+// handleUnloadWallet saves and unloads the wallet named by the param, or by the request path.
+func handleUnloadWallet(s *callContext, params []json.RawMessage) (interface{}, error) {
+	name := s.walletName
+	err := parseParams(params, 0, &name)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 && !s.named {
+		return nil, newError(ErrCodeWalletNotSpecified, "no wallet name given")
+	}
+	manager, err := s.walletManager()
+	if err != nil {
+		return nil, err
+	}
+
+	err = manager.UnloadWallet(name)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleListWallets replies the names of the loaded wallets.
+func handleListWallets(s *callContext, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if s.wallets == nil {
+		return []string{}, nil
+	}
+	return s.wallets.LoadedWallets(), nil
+}
+
+// handleListWalletDir replies the names of the wallets in the data directory.
+func handleListWalletDir(s *callContext, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := s.walletManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.ListWalletDir()
+}
+
+// handleEncryptWallet encrypts the wallet with the passphrase param and leaves it locked.
+func handleEncryptWallet(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var passphrase string
+	err := parseParams(params, 1, &passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, newError(ErrCodeInvalidParameter, "passphrase must not be empty")
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	err = wallets.Encrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return "wallet encrypted, unlock it with walletpassphrase", nil
+}
+
+// handleWalletPassphrase unlocks the wallet for the params passphrase and timeout in seconds.
+func handleWalletPassphrase(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var passphrase string
+	var timeout int64
+	err := parseParams(params, 2, &passphrase, &timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		return nil, newError(ErrCodeInvalidParameter, "timeout must be positive")
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	return nil, wallets.Unlock(passphrase, time.Duration(timeout)*time.Second)
+}
+
+func handleWalletPassphraseChange(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var oldPassphrase, newPassphrase string
+	err := parseParams(params, 2, &oldPassphrase, &newPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	if newPassphrase == "" {
+		return nil, newError(ErrCodeInvalidParameter, "passphrase must not be empty")
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	return nil, wallets.ChangePassphrase(oldPassphrase, newPassphrase)
+}
+
+func handleWalletLock(s *callContext, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	return nil, wallets.Lock()
+}
+
+// handleListTransactions replies the latest count wallet transactions, all of them without count, oldest first.
+func handleListTransactions(s *callContext, params []json.RawMessage) (interface{}, error) {
+	count := -1
+	err := parseParams(params, 0, &count)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+
+	var bestHeight int64
+	var txs []blockchain.WalletTx
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		bestHeight = chains.GetBestHeight()
+		txs = wallets.ListTransactions()
+		return nil
+	})
+	if count >= 0 && count < len(txs) {
+		txs = txs[len(txs)-count:]
+	}
+
+	result := make([]ListTxResult, 0, len(txs))
+	for idx := range txs {
+		wtx := &txs[idx]
+		result = append(result, ListTxResult{
+			TxID:           wtx.TxID,
+			BlockHash:      wtx.BlockHash.String(),
+			Height:         wtx.Height,
+			Confirmations:  wtx.Confirmations(bestHeight),
+			Time:           wtx.Time,
+			Amount:         wtx.Net(),
+			Fee:            wtx.Fee,
+			Coinbase:       wtx.Coinbase,
+			Counterparties: wtx.Counterparties,
+			Label:          wtx.Label,
+			WatchOnly:      wtx.WatchOnly,
+		})
+	}
+	return result, nil
+}
+
+// handleSetLabel labels the address or transaction id of the first param with the second.
+func handleSetLabel(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var target, label string
+	err := parseParams(params, 2, &target, &label)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	err = wallets.SetLabel(target, label)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidParameter, err.Error())
+	}
+	return nil, nil
+}
+
+// handleImportAddress watches the address or hex public key of the first param, labelled with the optional
+// second, and rebuilds the wallet history.
+func handleImportAddress(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var target, label string
+	err := parseParams(params, 1, &target, &label)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	address, err := wallets.AddWatchOnly(target)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidAddressOrKey, err.Error())
+	}
+	if label != "" {
+		_ = wallets.SetLabel(address, label)
+	}
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		wallets.SyncChain(chains)
+		return nil
+	})
+	return nil, nil
+}
+
+// handleDumpPrivKey replies the private key of the address param in the Wallet Import Format.
+func handleDumpPrivKey(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var address string
+	err := parseParams(params, 1, &address)
+	if err != nil {
+		return nil, err
+	}
+	err = checkAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	return wallets.DumpPrivKey(address)
+}
+
+// handleImportPrivKey adds the WIF private key param with an optional label and, unless the rescan param is
+// false, picks up the transactions of its coins.
+func handleImportPrivKey(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var key, label string
+	rescan := true
+	err := parseParams(params, 1, &key, &label, &rescan)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	address, err := wallets.ImportPrivKey(key)
+	if errors.Is(err, blockchain.ErrWalletLocked) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, newError(ErrCodeInvalidAddressOrKey, err.Error())
+	}
+	if label != "" {
+		_ = wallets.SetLabel(address, label)
+	}
+	if rescan {
+		_ = s.node.View(func(chains *blockchain.BlockChains) error {
+			wallets.SyncChain(chains)
+			return nil
+		})
+	}
+	return nil, nil
+}
+
+// handleListUnspent replies the unspent outputs of the wallet addresses, watch-only ones are not spendable.
+func handleListUnspent(s *callContext, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := s.node.TxPool()
+	result := make([]UnspentResult, 0)
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		for _, address := range s.walletAddresses() {
+			pubKeyHash, _ := utils.Address2PubkeyHash(address)
+			spendable := !wallets.IsWatchOnly(address) && !wallets.IsMultiSig(address)
+			chains.ScanUTXO(pubKeyHash, func(txID string, output blockchain.TXOutput) bool {
+				if !pool.IsSpent(txID, output.Index) {
+					result = append(result, UnspentResult{
+						TxID:      txID,
+						Vout:      output.Index,
+						Address:   address,
+						Value:     output.Value,
+						Spendable: spendable,
+					})
+				}
+				return true
+			})
+		}
+		return nil
+	})
+	return result, nil
+}
+
+func parsePartialTx(data string) (*blockchain.PartialTx, error) {
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, newError(ErrCodeDeserialization, "partial transaction decode failed")
+	}
+	p, err := blockchain.DeserializePartialTx(raw)
+	if err != nil {
+		return nil, newError(ErrCodeDeserialization, "partial transaction decode failed: "+err.Error())
+	}
+	return p, nil
+}
+
+// handleCreatePSBT replies the unsigned partial transaction paying amount from the address from, which may be
+// watch-only, to the address to. It can not be mined before the optional lock time.
+func handleCreatePSBT(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var from, to string
+	var amount int
+	var lockTime int64
+	err := parseParams(params, 3, &from, &to, &amount, &lockTime)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range []string{from, to} {
+		err = checkAddress(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if amount <= 0 {
+		return nil, newError(ErrCodeInvalidParameter, "amount must be positive")
+	}
+	if lockTime < 0 {
+		return nil, newError(ErrCodeInvalidParameter, "lock time must not be negative")
+	}
+
+	opts := &blockchain.SpendOptions{Locks: s.node.TxPool().CoinLocks(), FeeRate: s.node.TxPool().MinRelayFee(), LockTime: lockTime}
+	var p *blockchain.PartialTx
+	err = s.node.View(func(chains *blockchain.BlockChains) error {
+		var errCreate error
+		p, errCreate = blockchain.CreatePartialTx(chains, from, to, amount, opts)
+		return errCreate
+	})
+	if err != nil {
+		return nil, newError(ErrCodeInsufficientFunds, err.Error())
+	}
+	return hex.EncodeToString(p.Serialize()), nil
+}
+
+// handleWalletProcessPSBT signs the inputs of the partial transaction the node wallet has the keys of, with
+// the optional sighashtype, such as ALL or SINGLE|ANYONECANPAY, instead of the one of the partial transaction.
+func handleWalletProcessPSBT(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var data, sigHashType string
+	err := parseParams(params, 1, &data, &sigHashType)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePartialTx(data)
+	if err != nil {
+		return nil, err
+	}
+	if sigHashType != "" {
+		p.SigHashType, err = blockchain.ParseSigHashType(sigHashType)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidParameter, err.Error())
+		}
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	_, err = wallets.SignPartialTx(p)
+	if err != nil {
+		return nil, err
+	}
+	return &PSBTResult{PSBT: hex.EncodeToString(p.Serialize()), Complete: p.IsComplete()}, nil
+}
+
+// handleCombinePSBT replies the partial transaction with the signatures of all the partial transactions
+// params, copies of the same unsigned transaction.
+func handleCombinePSBT(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var data []string
+	err := parseParams(params, 1, &data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, newError(ErrCodeInvalidParameter, "no partial transaction to combine")
+	}
+	p, err := parsePartialTx(data[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range data[1:] {
+		other, errParse := parsePartialTx(d)
+		if errParse != nil {
+			return nil, errParse
+		}
+		err = p.Combine(other)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidParameter, err.Error())
+		}
+	}
+	return hex.EncodeToString(p.Serialize()), nil
+}
+
+// handleFinalizePSBT replies the transaction of a fully signed partial transaction, the partial transaction
+// again while signatures are missing.
+func handleFinalizePSBT(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var data string
+	err := parseParams(params, 1, &data)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePartialTx(data)
+	if err != nil {
+		return nil, err
+	}
+	if !p.IsComplete() {
+		return &FinalizePSBTResult{PSBT: data}, nil
+	}
+	tx, err := p.Finalize()
+	if err != nil {
+		return nil, newError(ErrCodeVerify, err.Error())
+	}
+	return &FinalizePSBTResult{Hex: hex.EncodeToString(tx.Serialize()), Complete: true}, nil
+}
+
+// handleSendRawTransaction relays the hex serialized transaction and replies its id.
+func handleSendRawTransaction(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var data string
+	err := parseParams(params, 1, &data)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, newError(ErrCodeDeserialization, "transaction decode failed")
+	}
+	tx, err := decodeTransaction(raw)
+	if err != nil {
+		return nil, newError(ErrCodeDeserialization, "transaction decode failed")
+	}
+	err = s.node.SubmitTransaction(tx)
+	if err != nil {
+		return nil, newError(ErrCodeVerify, err.Error())
+	}
+	return tx.TxID, nil
+}
+
+// decodeTransaction decodes a transaction serialized by Transaction.Serialize, which
+// blockchain.DeserializeTransaction would panic on when it is malformed.
+func decodeTransaction(data []byte) (*blockchain.Transaction, error) {
+	var tx blockchain.Transaction
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tx)
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// handleSignMessage signs the message param with the key of the address param. It replies the base64 signature.
+func handleSignMessage(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var address, message string
+	err := parseParams(params, 2, &address, &message)
+	if err != nil {
+		return nil, err
+	}
+	err = checkAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+
+	return wallets.SignMessage(address, message)
+}
+
+// handleVerifyMessage replies whether the signature param of the message param was made by the key of the
+// address param.
+func handleVerifyMessage(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var address, signature, message string
+	err := parseParams(params, 3, &address, &signature, &message)
+	if err != nil {
+		return nil, err
+	}
+	err = checkAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := blockchain.VerifyMessage(address, signature, message)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidParameter, err.Error())
+	}
+	return ok, nil
+}
+
+// handleCreateMultiSig replies the address and the redeem script of the multisig needing nrequired
+// signatures of the hex public keys, without a wallet.
+func handleCreateMultiSig(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var nRequired int
+	var keys []string
+	err := parseParams(params, 2, &nRequired, &keys)
+	if err != nil {
+		return nil, err
+	}
+	pubKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		pubKey, errDecode := hex.DecodeString(key)
+		if errDecode != nil {
+			return nil, newError(ErrCodeInvalidAddressOrKey, "invalid public key "+key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	address, redeemScript, err := blockchain.CreateMultiSig(nRequired, pubKeys)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidParameter, err.Error())
+	}
+	return &MultiSigResult{Address: address, RedeemScript: hex.EncodeToString(redeemScript)}, nil
+}
+
+// handleAddMultiSigAddress adds the multisig needing nrequired signatures of the keys, hex public keys or
+// wallet addresses, to the wallet and replies its address.
+func handleAddMultiSigAddress(s *callContext, params []json.RawMessage) (interface{}, error) {
+	var nRequired int
+	var keys []string
+	var label string
+	err := parseParams(params, 2, &nRequired, &keys, &label)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.walletsOrError()
+	if err != nil {
+		return nil, err
+	}
+	address, err := wallets.AddMultiSigAddress(nRequired, keys)
+	if err != nil {
+		if errors.Is(err, blockchain.ErrAddressNotInWallet) {
+			return nil, toError(err)
+		}
+		return nil, newError(ErrCodeInvalidParameter, err.Error())
+	}
+	if label != "" {
+		_ = wallets.SetLabel(address, label)
+	}
+	_ = s.node.View(func(chains *blockchain.BlockChains) error {
+		wallets.SyncChain(chains)
+		return nil
+	})
+	return address, nil
+}
@@ -0,0 +1,335 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/p2p"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+)
+
+const (
+	jsonRPCVersion = "2.0"
+
+	maxRequestSize  = 4 << 20
+	shutdownTimeout = 5 * time.Second
+
+	// walletPathPrefix starts the request paths which select the wallet the calls act on.
+	walletPathPrefix = "/wallet/"
+)
+
+// Config tells where the server listens and the credentials clients authenticate with over HTTP basic auth.
+type Config struct {
+	Listen   string
+	User     string
+	Password string
+}
+
+// Server serves the JSON-RPC 2.0 API of a node and its wallets.
+type Server struct {
+	cfg      Config
+	authSHA  [sha256.Size]byte
+	node     *p2p.Node
+	httpSrv  *http.Server
+	listener net.Listener
+
+	wallets *blockchain.WalletManager
+
+	wsLock    sync.Mutex
+	wsClients map[*wsClient]interface{}
+}
+
+type request struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// callContext is the server as one request sees it, the request path /wallet/<name> selects the wallet the
+// wallet calls act on.
+type callContext struct {
+	*Server
+	walletName string
+	named      bool
+}
+
+func (s *Server) newCallContext(path string) *callContext {
+	cc := &callContext{Server: s}
+	if strings.HasPrefix(path, walletPathPrefix) {
+		cc.walletName = strings.TrimPrefix(path, walletPathPrefix)
+		cc.named = true
+	}
+	return cc
+}
+
+// New returns a server over the node, wallets may be nil when the node has no wallet. The transaction
+// history of the loaded wallets follows the chain of the node from then on.
+func New(cfg Config, node *p2p.Node, wallets *blockchain.WalletManager) (*Server, error) {
+	if cfg.User == "" || cfg.Password == "" {
+		return nil, errors.New("rpc user and password are required")
+	}
+	if node == nil {
+		return nil, errors.New("no node")
+	}
+	if wallets != nil {
+		_ = node.View(func(chains *blockchain.BlockChains) error {
+			for _, name := range wallets.LoadedWallets() {
+				if ws, err := wallets.Wallet(name); err == nil {
+					ws.SyncChain(chains)
+				}
+			}
+			chains.Subscribe(wallets.HandleNotification)
+			return nil
+		})
+	}
+	return &Server{
+		cfg:       cfg,
+		authSHA:   sha256.Sum256([]byte(cfg.User + ":" + cfg.Password)),
+		node:      node,
+		wallets:   wallets,
+		wsClients: make(map[*wsClient]interface{}),
+	}, nil
+}
+
+// Start listens on the configured address and serves in the background until Stop.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("listen on %s failed: %w", s.cfg.Listen, err)
+	}
+	s.listener = listener
+	s.httpSrv = &http.Server{Handler: s}
+	go func() {
+		errServe := s.httpSrv.Serve(listener)
+		if errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+			loge.Errorf(nil, "rpc server on %s failed: %v", s.cfg.Listen, errServe)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on once started.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop closes the listener and the websocket connections, and waits for the HTTP requests in flight.
+func (s *Server) Stop() error {
+	if s.httpSrv == nil {
+		return nil
+	}
+
+	s.wsLock.Lock()
+	for c := range s.wsClients {
+		_ = c.conn.Close()
+	}
+	s.wsLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.httpSrv.Shutdown(ctx)
+}
+
+func (s *Server) checkAuth(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	authSHA := sha256.Sum256([]byte(user + ":" + password))
+	return subtle.ConstantTimeCompare(authSHA[:], s.authSHA[:]) == 1
+}
+
+// ServeHTTP answers JSON-RPC requests POSTed over HTTP, and upgrades websocket requests to push notifications.
+// The wallet calls act on the wallet the path /wallet/<name> names, on the only loaded one otherwise.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+	cc := s.newCallContext(r.URL.Path)
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, cc)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC only accepts POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "read request failed", http.StatusBadRequest)
+		return
+	}
+
+	var reply interface{}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if replies := cc.processBatch(body); replies != nil {
+			reply = replies
+		}
+	} else if resp := cc.processSingle(body); resp != nil {
+		reply = resp
+	}
+	if reply == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(reply)
+	if err != nil {
+		loge.Errorf(nil, "write rpc reply failed: %v", err)
+	}
+}
+
+// processBatch runs the requests of a batch, a batch which can not be read is answered with one error.
+func (s *callContext) processBatch(body []byte) []*response {
+	var batch []json.RawMessage
+	err := json.Unmarshal(body, &batch)
+	if err != nil {
+		return []*response{newResponse(nil, nil, newError(ErrCodeParse, err.Error()))}
+	}
+	if len(batch) == 0 {
+		return []*response{newResponse(nil, nil, newError(ErrCodeInvalidRequest, "empty batch"))}
+	}
+
+	replies := make([]*response, 0, len(batch))
+	for _, item := range batch {
+		if reply := s.processSingle(item); reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	return replies
+}
+
+// processSingle runs one request, notifications, which carry no id, get no reply.
+func (s *callContext) processSingle(body []byte) *response {
+	var req request
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		return newResponse(nil, nil, newError(ErrCodeParse, err.Error()))
+	}
+	if req.JSONRPC != jsonRPCVersion || req.Method == "" {
+		return newResponse(req.ID, nil, newError(ErrCodeInvalidRequest, "invalid request"))
+	}
+
+	result, err := s.call(req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return newResponse(req.ID, nil, toError(err))
+	}
+	return newResponse(req.ID, result, nil)
+}
+
+func (s *callContext) call(method string, rawParams json.RawMessage) (interface{}, error) {
+	handler, ok := rpcHandlers[method]
+	if !ok {
+		return nil, newError(ErrCodeMethodNotFound, "method not found: "+method)
+	}
+
+	params := make([]json.RawMessage, 0)
+	if len(rawParams) > 0 && string(rawParams) != "null" {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidParams, "params must be an array")
+		}
+	}
+	return handler(s, params)
+}
+
+func newResponse(id *json.RawMessage, result interface{}, rpcErr *Error) *response {
+	resp := &response{
+		JSONRPC: jsonRPCVersion,
+		Error:   rpcErr,
+		ID:      json.RawMessage("null"),
+	}
+	if id != nil {
+		resp.ID = *id
+	}
+	if rpcErr != nil {
+		return resp
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = newError(ErrCodeInternal, err.Error())
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
+
+// Reply is a decoded JSON-RPC response.
+type Reply struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Call posts one request to the server at url, it is the client side of the API for tools and tests.
+func Call(client *http.Client, url, user, password, method string, params ...interface{}) (*Reply, error) {
+	if params == nil {
+		params = make([]interface{}, 0)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": jsonRPCVersion,
+		"method":  method,
+		"params":  params,
+		"id":      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(user, password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rpc call %s failed: %s", method, resp.Status)
+	}
+
+	var reply Reply
+	err = json.NewDecoder(resp.Body).Decode(&reply)
+	if err != nil {
+		return nil, fmt.Errorf("decode reply of %s failed: %w", method, err)
+	}
+	return &reply, nil
+}
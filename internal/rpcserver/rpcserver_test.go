@@ -0,0 +1,643 @@
+package rpcserver
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/addrmgr"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/mempool"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/p2p"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/script"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/wif"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+	"github.com/sgostarter/liblog"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testUser     = "user"
+	testPassword = "pass"
+)
+
+func TestMain(m *testing.M) {
+	logger, err := liblog.NewZapLogger()
+	if err != nil {
+		panic(err)
+	}
+	loge.SetGlobalLogger(loge.NewLogger(logger))
+
+	m.Run()
+}
+
+type testEnv struct {
+	dir     string
+	node    *p2p.Node
+	manager *blockchain.WalletManager
+	wallets *blockchain.Wallets
+	httpSrv *httptest.Server
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	stg := memdb.NewDB()
+	chains, err := blockchain.NewBlockChainsWithDB(stg)
+	assert.Nil(t, err)
+	banList, err := p2p.NewBanList(stg)
+	assert.Nil(t, err)
+	node := p2p.NewNode(chains, banList, addrmgr.New(""), nil)
+
+	dir, err := ioutil.TempDir("", "rpcserver")
+	assert.Nil(t, err)
+	manager := blockchain.NewWalletManager(dir)
+	wallets, err := manager.CreateWallet(blockchain.DefaultWalletName)
+	assert.Nil(t, err)
+	server, err := New(Config{User: testUser, Password: testPassword}, node, manager)
+	assert.Nil(t, err)
+
+	return &testEnv{
+		dir:     dir,
+		node:    node,
+		manager: manager,
+		wallets: wallets,
+		httpSrv: httptest.NewServer(server),
+	}
+}
+
+func (env *testEnv) close() {
+	env.httpSrv.Close()
+	env.node.Close()
+	_ = os.RemoveAll(env.dir)
+}
+
+func (env *testEnv) call(t *testing.T, result interface{}, method string, params ...interface{}) *Error {
+	return env.callURL(t, env.httpSrv.URL, result, method, params...)
+}
+
+// callWallet calls method on the wallet name.
+func (env *testEnv) callWallet(t *testing.T, name string, result interface{}, method string, params ...interface{}) *Error {
+	return env.callURL(t, env.httpSrv.URL+walletPathPrefix+name, result, method, params...)
+}
+
+func (env *testEnv) callURL(t *testing.T, url string, result interface{}, method string, params ...interface{}) *Error {
+	reply, err := Call(env.httpSrv.Client(), url, testUser, testPassword, method, params...)
+	assert.Nil(t, err)
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if result != nil {
+		assert.Nil(t, json.Unmarshal(reply.Result, result))
+	}
+	return nil
+}
+
+func TestServer_Auth(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	_, err := Call(env.httpSrv.Client(), env.httpSrv.URL, testUser, "wrong", "getbestblockhash")
+	assert.NotNil(t, err)
+
+	_, err = New(Config{User: testUser}, env.node, nil)
+	assert.NotNil(t, err)
+}
+
+func TestServer_Protocol(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	assert.Equal(t, ErrCodeMethodNotFound, env.call(t, nil, "nosuchmethod").Code)
+	assert.Equal(t, ErrCodeInvalidParams, env.call(t, nil, "getblockhash").Code)
+	assert.Equal(t, ErrCodeInvalidParams, env.call(t, nil, "getblockhash", "one").Code)
+
+	post := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, env.httpSrv.URL, strings.NewReader(body))
+		assert.Nil(t, err)
+		req.SetBasicAuth(testUser, testPassword)
+		resp, err := env.httpSrv.Client().Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+
+	resp := post(`[{"jsonrpc":"2.0","method":"getbestblockhash","id":1},` +
+		`{"jsonrpc":"2.0","method":"getbestblockhash"},{"jsonrpc":"1.0","method":"getbestblockhash","id":3}]`)
+	var replies []response
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&replies))
+	_ = resp.Body.Close()
+	assert.Len(t, replies, 2)
+	assert.Nil(t, replies[0].Error)
+	assert.Equal(t, ErrCodeInvalidRequest, replies[1].Error.Code)
+
+	resp = post(`{"jsonrpc":"2.0","method":"getbestblockhash"}`)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = post(`{"jsonrpc":`)
+	var reply response
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&reply))
+	_ = resp.Body.Close()
+	assert.Equal(t, ErrCodeParse, reply.Error.Code)
+}
+
+// nolint: funlen
+func TestServer_ChainAndWallet(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	from, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	to := blockchain.NewWallet().GetAddress()
+	block, err := env.node.Mine(from)
+	assert.Nil(t, err)
+
+	var bestHash string
+	assert.Nil(t, env.call(t, &bestHash, "getbestblockhash"))
+	assert.Equal(t, block.Hash.String(), bestHash)
+	var heightHash string
+	assert.Nil(t, env.call(t, &heightHash, "getblockhash", block.Height))
+	assert.Equal(t, bestHash, heightHash)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "getblockhash", block.Height+1).Code)
+
+	var blockResult BlockResult
+	assert.Nil(t, env.call(t, &blockResult, "getblock", bestHash))
+	assert.Equal(t, block.Height, blockResult.Height)
+	assert.EqualValues(t, 1, blockResult.Confirmations)
+	assert.Equal(t, []string{block.Transactions[0].TxID}, blockResult.Tx)
+	var blockHex string
+	assert.Nil(t, env.call(t, &blockHex, "getblock", bestHash, false))
+	assert.Equal(t, hex.EncodeToString(block.Serialize()), blockHex)
+
+	var balance int
+	assert.Nil(t, env.call(t, &balance, "getbalance"))
+	assert.Equal(t, blockchain.Subsidy, balance)
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "getbalance", "bad").Code)
+	assert.Equal(t, ErrCodeInsufficientFunds, env.call(t, nil, "sendtoaddress", to, blockchain.Subsidy+1).Code)
+
+	var txID string
+	assert.Nil(t, env.call(t, &txID, "sendtoaddress", to, 3))
+	assert.True(t, env.node.TxPool().HaveTransaction(txID))
+
+	var txResult TxResult
+	assert.Nil(t, env.call(t, &txResult, "getrawtransaction", txID, true))
+	assert.Equal(t, txID, txResult.TxID)
+	assert.Len(t, txResult.Vout, 2)
+	assert.Equal(t, to, txResult.Vout[0].Address)
+	assert.Equal(t, 3, txResult.Vout[0].Value)
+
+	// the coinbase output is spent by the pooled transaction
+	var txOut *GetTxOutResult
+	assert.Nil(t, env.call(t, &txOut, "gettxout", block.Transactions[0].TxID, 0))
+	assert.Nil(t, txOut)
+
+	var template BlockTemplateResult
+	assert.Nil(t, env.call(t, &template, "getblocktemplate"))
+	assert.Equal(t, bestHash, template.PreviousBlockHash)
+	assert.Equal(t, block.Height+1, template.Height)
+	assert.Equal(t, blockchain.MainNetParams.MaxBlockSize, template.SizeLimit)
+	assert.Equal(t, blockchain.MainNetParams.MaxBlockSigOps, template.SigOpLimit)
+	assert.Len(t, template.Transactions, 1)
+
+	txs := []*blockchain.Transaction{blockchain.NewCoinbaseTX(from, "")}
+	for _, templateTx := range template.Transactions {
+		data, errDecode := hex.DecodeString(templateTx.Data)
+		assert.Nil(t, errDecode)
+		tx := blockchain.DeserializeTransaction(data)
+		txs = append(txs, &tx)
+	}
+	newBlock := blockchain.MineBlock(txs, block.Hash)
+	var submitResult *string
+	assert.Nil(t, env.call(t, &submitResult, "submitblock", hex.EncodeToString(newBlock.Serialize())))
+	assert.Nil(t, submitResult)
+	assert.Nil(t, env.call(t, &submitResult, "submitblock", hex.EncodeToString(newBlock.Serialize())))
+	assert.Equal(t, "duplicate", *submitResult)
+	assert.Equal(t, 0, env.node.TxPool().Count())
+
+	assert.Nil(t, env.call(t, &txOut, "gettxout", txID, 0))
+	assert.Equal(t, 3, txOut.Value)
+	assert.Equal(t, to, txOut.Address)
+	assert.Nil(t, env.call(t, &balance, "getbalance", to))
+	assert.Equal(t, 3, balance)
+
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "setlabel", "bad", "x").Code)
+	assert.Nil(t, env.call(t, nil, "setlabel", txID, "payment"))
+	var history []ListTxResult
+	assert.Nil(t, env.call(t, &history, "listtransactions"))
+	assert.Len(t, history, 3)
+	assert.Nil(t, env.call(t, &history, "listtransactions", 1))
+	assert.Len(t, history, 1)
+	assert.Equal(t, txID, history[0].TxID)
+	assert.Equal(t, newBlock.Hash.String(), history[0].BlockHash)
+	assert.EqualValues(t, 1, history[0].Confirmations)
+	// the wallet pays the minimum relay fee of the pool
+	assert.Equal(t, int(mempool.DefaultMinRelayFee), history[0].Fee)
+	assert.Equal(t, -3-history[0].Fee, history[0].Amount)
+	assert.Equal(t, []string{to}, history[0].Counterparties)
+	assert.Equal(t, "payment", history[0].Label)
+}
+
+func TestServer_WalletEncryption(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	from, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	to := blockchain.NewWallet().GetAddress()
+	_, err = env.node.Mine(from)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrCodeWalletWrongEncState, env.call(t, nil, "walletlock").Code)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "encryptwallet", "").Code)
+	assert.Nil(t, env.call(t, nil, "encryptwallet", "pass"))
+	assert.Equal(t, ErrCodeWalletWrongEncState, env.call(t, nil, "encryptwallet", "pass").Code)
+	assert.Equal(t, ErrCodeWalletUnlockNeeded, env.call(t, nil, "sendtoaddress", to, 1).Code)
+
+	assert.Equal(t, ErrCodeWalletPassphraseIncorrect, env.call(t, nil, "walletpassphrase", "wrong", 60).Code)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "walletpassphrase", "pass", 0).Code)
+	assert.Nil(t, env.call(t, nil, "walletpassphrase", "pass", 60))
+	var txID string
+	assert.Nil(t, env.call(t, &txID, "sendtoaddress", to, 3))
+	assert.True(t, env.node.TxPool().HaveTransaction(txID))
+
+	assert.Nil(t, env.call(t, nil, "walletlock"))
+	assert.Equal(t, ErrCodeWalletUnlockNeeded, env.call(t, nil, "sendtoaddress", to, 1).Code)
+	assert.Equal(t, ErrCodeWalletPassphraseIncorrect, env.call(t, nil, "walletpassphrasechange", "wrong", "new").Code)
+	assert.Nil(t, env.call(t, nil, "walletpassphrasechange", "pass", "new"))
+	assert.Equal(t, ErrCodeWalletPassphraseIncorrect, env.call(t, nil, "walletpassphrase", "pass", 60).Code)
+	assert.Nil(t, env.call(t, nil, "walletpassphrase", "new", 60))
+}
+
+func TestServer_WatchOnly(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	watched := blockchain.NewWallet().GetAddress()
+	to := blockchain.NewWallet().GetAddress()
+	_, err := env.node.Mine(watched)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "importaddress", "bad").Code)
+	assert.Nil(t, env.call(t, nil, "importaddress", watched, "cold"))
+	assert.Equal(t, "cold", env.wallets.Label(watched))
+
+	var balance int
+	assert.Nil(t, env.call(t, &balance, "getbalance"))
+	assert.Equal(t, blockchain.Subsidy, balance)
+	var unspent []UnspentResult
+	assert.Nil(t, env.call(t, &unspent, "listunspent"))
+	assert.Len(t, unspent, 1)
+	assert.Equal(t, watched, unspent[0].Address)
+	assert.False(t, unspent[0].Spendable)
+	var history []ListTxResult
+	assert.Nil(t, env.call(t, &history, "listtransactions"))
+	assert.Len(t, history, 1)
+	assert.True(t, history[0].WatchOnly)
+
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "sendtoaddress", to, 1, watched).Code)
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "sendtoaddress", to, 1).Code)
+}
+
+func TestServer_PrivKey(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	key := blockchain.NewWallet()
+	address := key.GetAddress()
+	_, err := env.node.Mine(address)
+	assert.Nil(t, err)
+	w, err := wif.NewWIF(&key.PrivateKey, wif.MainNetVersion, true)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "importprivkey", "bad").Code)
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "dumpprivkey", address).Code)
+	assert.Nil(t, env.call(t, nil, "importprivkey", w.String(), "imported"))
+	assert.Equal(t, "imported", env.wallets.Label(address))
+	var balance int
+	assert.Nil(t, env.call(t, &balance, "getbalance"))
+	assert.Equal(t, blockchain.Subsidy, balance)
+	var history []ListTxResult
+	assert.Nil(t, env.call(t, &history, "listtransactions"))
+	assert.Len(t, history, 1)
+
+	var dumped string
+	assert.Nil(t, env.call(t, &dumped, "dumpprivkey", address))
+	assert.Equal(t, w.String(), dumped)
+
+	assert.Nil(t, env.call(t, nil, "encryptwallet", "pass"))
+	assert.Equal(t, ErrCodeWalletUnlockNeeded, env.call(t, nil, "dumpprivkey", address).Code)
+}
+
+func TestServer_PSBT(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	from, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	to := blockchain.NewWallet().GetAddress()
+	_, err = env.node.Mine(from)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrCodeInsufficientFunds, env.call(t, nil, "createpsbt", from, to, blockchain.Subsidy+1).Code)
+	var psbt string
+	assert.Nil(t, env.call(t, &psbt, "createpsbt", from, to, 3))
+	var finalized FinalizePSBTResult
+	assert.Nil(t, env.call(t, &finalized, "finalizepsbt", psbt))
+	assert.False(t, finalized.Complete)
+	assert.Equal(t, psbt, finalized.PSBT)
+
+	var processed PSBTResult
+	assert.Nil(t, env.call(t, &processed, "walletprocesspsbt", psbt))
+	assert.True(t, processed.Complete)
+	assert.Nil(t, env.call(t, &finalized, "finalizepsbt", processed.PSBT))
+	assert.True(t, finalized.Complete)
+
+	// the signatures end with the hash type they are made with
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "walletprocesspsbt", psbt, "BAD").Code)
+	var single PSBTResult
+	assert.Nil(t, env.call(t, &single, "walletprocesspsbt", psbt, "SINGLE|ANYONECANPAY"))
+	var singleFinal FinalizePSBTResult
+	assert.Nil(t, env.call(t, &singleFinal, "finalizepsbt", single.PSBT))
+	assert.True(t, singleFinal.Complete)
+	raw, err := hex.DecodeString(singleFinal.Hex)
+	assert.Nil(t, err)
+	signature := blockchain.DeserializeTransaction(raw).Vin[0].Signature
+	assert.Equal(t, byte(blockchain.SigHashSingle|blockchain.SigHashAnyOneCanPay), signature[len(signature)-1])
+
+	// the pool keeps the transactions locked past the next block out
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "createpsbt", from, to, 3, -1).Code)
+	var locked PSBTResult
+	assert.Nil(t, env.call(t, &psbt, "createpsbt", from, to, 3, 1000))
+	assert.Nil(t, env.call(t, &locked, "walletprocesspsbt", psbt))
+	var lockedFinal FinalizePSBTResult
+	assert.Nil(t, env.call(t, &lockedFinal, "finalizepsbt", locked.PSBT))
+	assert.Equal(t, ErrCodeVerify, env.call(t, nil, "sendrawtransaction", lockedFinal.Hex).Code)
+
+	assert.Equal(t, ErrCodeDeserialization, env.call(t, nil, "sendrawtransaction", "zz").Code)
+	var txID string
+	assert.Nil(t, env.call(t, &txID, "sendrawtransaction", finalized.Hex))
+	assert.True(t, env.node.TxPool().HaveTransaction(txID))
+	assert.Equal(t, ErrCodeVerify, env.call(t, nil, "sendrawtransaction", finalized.Hex).Code)
+}
+
+func TestServer_SendMany(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	first, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	second, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	to1 := blockchain.NewWallet().GetAddress()
+	to2 := blockchain.NewWallet().GetAddress()
+	_, err = env.node.Mine(first)
+	assert.Nil(t, err)
+	_, err = env.node.Mine(second)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "sendmany", map[string]int{}).Code)
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "sendmany", map[string]int{"bad": 1}).Code)
+	assert.Equal(t, ErrCodeInsufficientFunds, env.call(t, nil, "sendmany", map[string]int{to1: blockchain.Subsidy + 1}, []string{first}).Code)
+
+	var txID string
+	assert.Nil(t, env.call(t, &txID, "sendmany", map[string]int{to1: blockchain.Subsidy - 1, to2: 3}))
+	var txResult TxResult
+	assert.Nil(t, env.call(t, &txResult, "getrawtransaction", txID, true))
+	assert.Len(t, txResult.Vin, 2)
+	assert.Len(t, txResult.Vout, 3)
+	for idx, output := range txResult.Vout {
+		assert.Equal(t, idx, output.N)
+	}
+	change := txResult.Vout[2].Address
+	assert.NotContains(t, []string{first, second, to1, to2}, change)
+	assert.False(t, env.wallets.IsWatchOnly(change))
+
+	// the pooled transaction spends the coins
+	assert.Equal(t, ErrCodeInsufficientFunds, env.call(t, nil, "sendtoaddress", to1, 1).Code)
+}
+
+func TestServer_DataAnchor(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	from, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	_, err = env.node.Mine(from)
+	assert.Nil(t, err)
+	data := strings.Repeat("ab", 32)
+
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "anchordata", "").Code)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "anchordata", "zz").Code)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "anchordata", strings.Repeat("ab", script.MaxDataCarrierSize+1)).Code)
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "anchordata", data, []string{"bad"}).Code)
+
+	var txID string
+	assert.Nil(t, env.call(t, &txID, "anchordata", data, []string{from}))
+	var txResult TxResult
+	assert.Nil(t, env.call(t, &txResult, "getrawtransaction", txID, true))
+	assert.Equal(t, data, txResult.Vout[0].Data)
+	assert.Equal(t, 0, txResult.Vout[0].Value)
+	assert.Empty(t, txResult.Vout[1].Data)
+
+	var anchors []DataAnchorResult
+	assert.Nil(t, env.call(t, &anchors, "getdataanchors", data))
+	assert.Empty(t, anchors)
+	block, err := env.node.Mine(from, env.node.TxPool().FetchTransaction(txID))
+	assert.Nil(t, err)
+	assert.Nil(t, env.call(t, &anchors, "getdataanchors", data))
+	assert.Equal(t, []DataAnchorResult{{TxID: txID, BlockHash: block.Hash.String(), Height: block.Height, Time: block.Timestamp}}, anchors)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "getdataanchors", "zz").Code)
+}
+
+func TestServer_WebSocket(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(testUser+":"+testPassword)))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(env.httpSrv.URL, "http"), header)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	var reply response
+	assert.Nil(t, conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0", "method": "subscribe", "params": []string{"blockconnected"}, "id": 1,
+	}))
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Nil(t, reply.Error)
+	assert.Nil(t, conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0", "method": "subscribe", "params": []string{"nosuchevent"}, "id": 2,
+	}))
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Equal(t, ErrCodeInvalidParameter, reply.Error.Code)
+
+	block, err := env.node.Mine(blockchain.NewWallet().GetAddress())
+	assert.Nil(t, err)
+
+	var ntfn struct {
+		Method string      `json:"method"`
+		Params []BlockNtfn `json:"params"`
+	}
+	assert.Nil(t, conn.ReadJSON(&ntfn))
+	assert.Equal(t, "blockconnected", ntfn.Method)
+	assert.Equal(t, block.Hash.String(), ntfn.Params[0].Hash)
+	assert.Equal(t, block.Height, ntfn.Params[0].Height)
+
+	// plain requests are served over the websocket too
+	assert.Nil(t, conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "method": "getbestblockhash", "id": 3}))
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Equal(t, `"`+block.Hash.String()+`"`, string(reply.Result))
+
+	_, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(env.httpSrv.URL, "http"), nil)
+	assert.NotNil(t, err)
+}
+
+// nolint: funlen
+func TestServer_Wallets(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	var names []string
+	assert.Nil(t, env.call(t, &names, "listwallets"))
+	assert.Equal(t, []string{blockchain.DefaultWalletName}, names)
+
+	var name string
+	assert.Nil(t, env.call(t, &name, "createwallet", "alice"))
+	assert.Equal(t, "alice", name)
+	assert.FileExists(t, filepath.Join(env.dir, "wallets", "alice", "wallet.dat"))
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "createwallet", "alice").Code)
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "createwallet", "../alice").Code)
+	assert.Nil(t, env.call(t, &names, "listwallets"))
+	assert.Equal(t, []string{blockchain.DefaultWalletName, "alice"}, names)
+
+	// with several wallets loaded the calls name theirs
+	assert.Equal(t, ErrCodeWalletNotSpecified, env.call(t, nil, "listtransactions").Code)
+	assert.Equal(t, ErrCodeWalletNotFound, env.callWallet(t, "bob", nil, "listtransactions").Code)
+
+	alice, err := env.manager.Wallet("alice")
+	assert.Nil(t, err)
+	from, err := alice.CreateWallet()
+	assert.Nil(t, err)
+	to, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	_, err = env.node.Mine(from)
+	assert.Nil(t, err)
+
+	var balance int
+	assert.Nil(t, env.callWallet(t, "alice", &balance, "getbalance"))
+	assert.Equal(t, blockchain.Subsidy, balance)
+	assert.Nil(t, env.callWallet(t, blockchain.DefaultWalletName, &balance, "getbalance"))
+	assert.Equal(t, 0, balance)
+
+	var txID string
+	assert.Nil(t, env.callWallet(t, "alice", &txID, "sendtoaddress", to, 3))
+	_, err = env.node.Mine(blockchain.NewWallet().GetAddress(), env.node.TxPool().Transactions()...)
+	assert.Nil(t, err)
+	assert.Nil(t, env.callWallet(t, blockchain.DefaultWalletName, &balance, "getbalance"))
+	assert.Equal(t, 3, balance)
+	assert.Equal(t, ErrCodeInsufficientFunds, env.callWallet(t, blockchain.DefaultWalletName, nil, "sendtoaddress", from, 4).Code)
+
+	// an unloaded wallet catches up with the chain when loaded again
+	var txs []json.RawMessage
+	assert.Nil(t, env.callWallet(t, "alice", &txs, "listtransactions"))
+	aliceTxs := len(txs)
+	assert.Equal(t, ErrCodeWalletNotSpecified, env.call(t, nil, "unloadwallet").Code)
+	assert.Nil(t, env.callWallet(t, "alice", nil, "unloadwallet"))
+	assert.Equal(t, ErrCodeWalletNotFound, env.call(t, nil, "unloadwallet", "alice").Code)
+	assert.Nil(t, env.call(t, &names, "listwallets"))
+	assert.Equal(t, []string{blockchain.DefaultWalletName}, names)
+	assert.Nil(t, env.call(t, &balance, "getbalance"))
+	assert.Equal(t, 3, balance)
+
+	_, err = env.node.Mine(from)
+	assert.Nil(t, err)
+	assert.Nil(t, env.call(t, &names, "listwalletdir"))
+	assert.Equal(t, []string{blockchain.DefaultWalletName, "alice"}, names)
+	assert.Equal(t, ErrCodeWalletNotFound, env.call(t, nil, "loadwallet", "bob").Code)
+	assert.Nil(t, env.call(t, &name, "loadwallet", "alice"))
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "loadwallet", "alice").Code)
+	assert.Nil(t, env.callWallet(t, "alice", &txs, "listtransactions"))
+	assert.Len(t, txs, aliceTxs+1)
+}
+
+func TestServer_Message(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	address, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	var signature string
+	assert.Nil(t, env.call(t, &signature, "signmessage", address, "hello"))
+	assert.Equal(t, ErrCodeWallet, env.call(t, nil, "signmessage", blockchain.NewWallet().GetAddress(), "hello").Code)
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "signmessage", "bad", "hello").Code)
+
+	var ok bool
+	assert.Nil(t, env.call(t, &ok, "verifymessage", address, signature, "hello"))
+	assert.True(t, ok)
+	assert.Nil(t, env.call(t, &ok, "verifymessage", address, signature, "bye"))
+	assert.False(t, ok)
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "verifymessage", address, "bad", "hello").Code)
+}
+
+// nolint: funlen
+func TestServer_MultiSig(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	cosigner, err := env.manager.CreateWallet("cosigner")
+	assert.Nil(t, err)
+	ownAddress, err := env.wallets.CreateWallet()
+	assert.Nil(t, err)
+	cosignerAddress, err := cosigner.CreateWallet()
+	assert.Nil(t, err)
+	own, err := env.wallets.GetWallet(ownAddress)
+	assert.Nil(t, err)
+	other, err := cosigner.GetWallet(cosignerAddress)
+	assert.Nil(t, err)
+	ownKey, otherKey := hex.EncodeToString(own.PublicKey), hex.EncodeToString(other.PublicKey)
+
+	var created MultiSigResult
+	assert.Nil(t, env.call(t, &created, "createmultisig", 2, []string{ownKey, otherKey}))
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "createmultisig", 3, []string{ownKey, otherKey}).Code)
+	assert.Equal(t, ErrCodeInvalidAddressOrKey, env.call(t, nil, "createmultisig", 1, []string{"zz"}).Code)
+	var address string
+	assert.Nil(t, env.callWallet(t, "", &address, "addmultisigaddress", 2, []string{ownAddress, otherKey}, "treasury"))
+	assert.Equal(t, created.Address, address)
+	assert.Nil(t, env.callWallet(t, "cosigner", &address, "addmultisigaddress", 2, []string{ownKey, cosignerAddress}))
+	assert.Equal(t, created.Address, address)
+	assert.Equal(t, ErrCodeWallet, env.callWallet(t, "cosigner", nil, "addmultisigaddress", 2, []string{ownAddress, cosignerAddress}).Code)
+
+	_, err = env.node.Mine(address)
+	assert.Nil(t, err)
+	var unspent []UnspentResult
+	assert.Nil(t, env.callWallet(t, "", &unspent, "listunspent"))
+	assert.Len(t, unspent, 1)
+	assert.Equal(t, address, unspent[0].Address)
+	assert.False(t, unspent[0].Spendable)
+	assert.Equal(t, ErrCodeWallet, env.callWallet(t, "", nil, "sendtoaddress", ownAddress, 1, address).Code)
+
+	var psbt string
+	assert.Nil(t, env.call(t, &psbt, "createpsbt", address, ownAddress, 3))
+	var ownSigned, otherSigned PSBTResult
+	assert.Nil(t, env.callWallet(t, "", &ownSigned, "walletprocesspsbt", psbt))
+	assert.False(t, ownSigned.Complete)
+	assert.Nil(t, env.callWallet(t, "cosigner", &otherSigned, "walletprocesspsbt", psbt))
+	assert.False(t, otherSigned.Complete)
+
+	var combined string
+	assert.Nil(t, env.call(t, &combined, "combinepsbt", []string{ownSigned.PSBT, otherSigned.PSBT}))
+	assert.Equal(t, ErrCodeInvalidParameter, env.call(t, nil, "combinepsbt", []string{}).Code)
+	var finalized FinalizePSBTResult
+	assert.Nil(t, env.call(t, &finalized, "finalizepsbt", combined))
+	assert.True(t, finalized.Complete)
+	var txID string
+	assert.Nil(t, env.call(t, &txID, "sendrawtransaction", finalized.Hex))
+	assert.True(t, env.node.TxPool().HaveTransaction(txID))
+}
@@ -0,0 +1,267 @@
+package rpcserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/notify"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+)
+
+const (
+	wsBufferSize    = 256
+	wsWriteTimeout  = 10 * time.Second
+	wsMaxReadSize   = maxRequestSize
+	wsReplyChanSize = 16
+)
+
+var upgrader = websocket.Upgrader{}
+
+// BlockNtfn is the payload of the blockconnected, blockdisconnected and orphanadded notifications.
+type BlockNtfn struct {
+	Hash              string   `json:"hash"`
+	Height            int64    `json:"height"`
+	PreviousBlockHash string   `json:"previousblockhash"`
+	Time              int64    `json:"time"`
+	Tx                []string `json:"tx"`
+}
+
+// UTXONtfnEntry is an output of the utxochanged notification.
+type UTXONtfnEntry struct {
+	TxID    string `json:"txid"`
+	N       int    `json:"n"`
+	Value   int    `json:"value"`
+	Address string `json:"address"`
+}
+
+// UTXOChangedNtfn is the payload of the utxochanged notification.
+type UTXOChangedNtfn struct {
+	BlockHash string          `json:"blockhash"`
+	Height    int64           `json:"height"`
+	Connected bool            `json:"connected"`
+	Created   []UTXONtfnEntry `json:"created"`
+	Spent     []UTXONtfnEntry `json:"spent"`
+}
+
+type notification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// wsClient serves one websocket connection. Requests are answered like over HTTP, besides subscribe and
+// unsubscribe which select the notifications pushed to the client.
+type wsClient struct {
+	server  *Server
+	cc      *callContext
+	conn    *websocket.Conn
+	sub     *notify.Subscription
+	replies chan interface{}
+	quit    chan interface{}
+
+	lock  sync.Mutex
+	types map[blockchain.NotificationType]bool
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, cc *callContext) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		loge.Warnf(nil, "websocket upgrade from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+	conn.SetReadLimit(wsMaxReadSize)
+
+	c := &wsClient{
+		server:  s,
+		cc:      cc,
+		conn:    conn,
+		sub:     s.node.Notifications().Subscribe(wsBufferSize, notify.Disconnect),
+		replies: make(chan interface{}, wsReplyChanSize),
+		quit:    make(chan interface{}),
+		types:   make(map[blockchain.NotificationType]bool),
+	}
+	s.wsLock.Lock()
+	s.wsClients[c] = true
+	s.wsLock.Unlock()
+	defer func() {
+		s.wsLock.Lock()
+		delete(s.wsClients, c)
+		s.wsLock.Unlock()
+	}()
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+func (c *wsClient) readLoop() {
+	defer func() {
+		close(c.quit)
+		c.sub.Close()
+	}()
+
+	for {
+		_, body, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var reply *response
+		var req request
+		if json.Unmarshal(body, &req) == nil && (req.Method == "subscribe" || req.Method == "unsubscribe") {
+			reply = c.handleSubscribe(&req)
+		} else {
+			reply = c.cc.processSingle(body)
+		}
+		if reply == nil {
+			continue
+		}
+
+		select {
+		case c.replies <- reply:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// handleSubscribe takes the notification names as params, subscribe without params selects them all.
+func (c *wsClient) handleSubscribe(req *request) *response {
+	var names []string
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		err := json.Unmarshal(req.Params, &names)
+		if err != nil {
+			return newResponse(req.ID, nil, newError(ErrCodeInvalidParams, "params must be notification names"))
+		}
+	}
+
+	types := make([]blockchain.NotificationType, 0, len(names))
+	for _, name := range names {
+		typ, ok := notificationTypeByName(name)
+		if !ok {
+			return newResponse(req.ID, nil, newError(ErrCodeInvalidParameter, "unknown notification "+name))
+		}
+		types = append(types, typ)
+	}
+	if len(names) == 0 {
+		types = allNotificationTypes()
+	}
+
+	c.lock.Lock()
+	for _, typ := range types {
+		c.types[typ] = req.Method == "subscribe"
+	}
+	c.lock.Unlock()
+
+	if req.ID == nil {
+		return nil
+	}
+	return newResponse(req.ID, true, nil)
+}
+
+func (c *wsClient) wants(typ blockchain.NotificationType) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.types[typ]
+}
+
+func (c *wsClient) writeLoop() {
+	defer c.conn.Close()
+
+	for {
+		var msg interface{}
+		select {
+		case <-c.quit:
+			return
+		case reply := <-c.replies:
+			msg = reply
+		case n, ok := <-c.sub.C():
+			if !ok {
+				if err := c.sub.Err(); err != nil {
+					c.closeWith(err.Error())
+				}
+				return
+			}
+			if !c.wants(n.Type) {
+				continue
+			}
+			msg = marshalNotification(n)
+		}
+
+		_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		err := c.conn.WriteJSON(msg)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *wsClient) closeWith(reason string) {
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, reason),
+		time.Now().Add(wsWriteTimeout))
+}
+
+func notificationTypeByName(name string) (blockchain.NotificationType, bool) {
+	for _, typ := range allNotificationTypes() {
+		if typ.String() == name {
+			return typ, true
+		}
+	}
+	return 0, false
+}
+
+func allNotificationTypes() []blockchain.NotificationType {
+	return []blockchain.NotificationType{
+		blockchain.NTBlockConnected,
+		blockchain.NTBlockDisconnected,
+		blockchain.NTOrphanAdded,
+		blockchain.NTUTXOChanged,
+	}
+}
+
+func marshalNotification(n *blockchain.Notification) *notification {
+	var param interface{}
+	switch data := n.Data.(type) {
+	case *blockchain.Block:
+		txIDs := make([]string, 0, len(data.Transactions))
+		for _, tx := range data.Transactions {
+			txIDs = append(txIDs, tx.TxID)
+		}
+		param = &BlockNtfn{
+			Hash:              data.Hash.String(),
+			Height:            data.Height,
+			PreviousBlockHash: data.PrevBlockHash.String(),
+			Time:              data.Timestamp,
+			Tx:                txIDs,
+		}
+	case *blockchain.UTXOChange:
+		param = &UTXOChangedNtfn{
+			BlockHash: data.BlockHash.String(),
+			Height:    data.Height,
+			Connected: data.Connected,
+			Created:   utxoNtfnEntries(data.Created),
+			Spent:     utxoNtfnEntries(data.Spent),
+		}
+	}
+	return &notification{
+		JSONRPC: jsonRPCVersion,
+		Method:  n.Type.String(),
+		Params:  []interface{}{param},
+	}
+}
+
+func utxoNtfnEntries(entries []blockchain.UTXOEntry) []UTXONtfnEntry {
+	result := make([]UTXONtfnEntry, 0, len(entries))
+	for idx := range entries {
+		result = append(result, UTXONtfnEntry{
+			TxID:    entries[idx].TxID,
+			N:       entries[idx].Output.Index,
+			Value:   entries[idx].Output.Value,
+			Address: entries[idx].Output.Address(),
+		})
+	}
+	return result
+}
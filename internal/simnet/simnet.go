@@ -0,0 +1,270 @@
+package simnet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/addrmgr"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/memdb"
+	"github.com/jiuzhou-zhao/blockchain.go/internal/p2p"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+)
+
+const pollInterval = 20 * time.Millisecond
+
+type link struct {
+	a, b int
+}
+
+func newLink(a, b int) link {
+	if a > b {
+		a, b = b, a
+	}
+	return link{a: a, b: b}
+}
+
+type linkPeers struct {
+	a, b *p2p.Peer
+}
+
+// Network runs several nodes in one process, each on its own in-memory store, linked over net.Pipe.
+type Network struct {
+	lock  sync.Mutex
+	nodes []*p2p.Node
+	links map[link]*linkPeers
+}
+
+// New starts count nodes which share nothing but the genesis block, no links are made.
+// cfg is shared by all nodes, nil takes the defaults.
+func New(count int, cfg *p2p.Config) (*Network, error) {
+	if count <= 0 {
+		return nil, errors.New("no nodes")
+	}
+	network := &Network{
+		nodes: make([]*p2p.Node, 0, count),
+		links: make(map[link]*linkPeers),
+	}
+	for idx := 0; idx < count; idx++ {
+		stg := memdb.NewDB()
+		chains, err := blockchain.NewBlockChainsWithDB(stg)
+		if err != nil {
+			network.Close()
+			return nil, fmt.Errorf("new chains for node %d failed: %w", idx, err)
+		}
+		banList, err := p2p.NewBanList(stg)
+		if err != nil {
+			network.Close()
+			return nil, fmt.Errorf("new ban list for node %d failed: %w", idx, err)
+		}
+		network.nodes = append(network.nodes, p2p.NewNode(chains, banList, addrmgr.New(""), cfg))
+	}
+	return network, nil
+}
+
+func (network *Network) Size() int {
+	return len(network.nodes)
+}
+
+func (network *Network) Node(idx int) *p2p.Node {
+	return network.nodes[idx]
+}
+
+// Connect links two nodes, it is a no-op when they are linked already. A link one of whose peers was
+// disconnected, on a ban for instance, is made again.
+func (network *Network) Connect(a, b int) error {
+	if a == b || a < 0 || b < 0 || a >= len(network.nodes) || b >= len(network.nodes) {
+		return fmt.Errorf("invalid link %d-%d", a, b)
+	}
+	network.lock.Lock()
+	defer network.lock.Unlock()
+
+	l := newLink(a, b)
+	if peers, ok := network.links[l]; ok {
+		if peers.a.Connected() && peers.b.Connected() {
+			return nil
+		}
+		peers.a.Disconnect()
+		peers.b.Disconnect()
+		delete(network.links, l)
+	}
+	connA, connB := net.Pipe()
+	peerA, err := network.nodes[l.a].AddPeer(connA, fmt.Sprintf("simnet-%d", l.b), false)
+	if err != nil {
+		_ = connB.Close()
+		return fmt.Errorf("link %d-%d failed: %w", l.a, l.b, err)
+	}
+	peerB, err := network.nodes[l.b].AddPeer(connB, fmt.Sprintf("simnet-%d", l.a), true)
+	if err != nil {
+		peerA.Disconnect()
+		return fmt.Errorf("link %d-%d failed: %w", l.a, l.b, err)
+	}
+	network.links[l] = &linkPeers{
+		a: peerA,
+		b: peerB,
+	}
+	return nil
+}
+
+// ConnectAll links every pair of nodes.
+func (network *Network) ConnectAll() error {
+	for a := 0; a < len(network.nodes); a++ {
+		for b := a + 1; b < len(network.nodes); b++ {
+			err := network.Connect(a, b)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (network *Network) Disconnect(a, b int) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+
+	l := newLink(a, b)
+	if peers, ok := network.links[l]; ok {
+		peers.a.Disconnect()
+		peers.b.Disconnect()
+		delete(network.links, l)
+	}
+}
+
+// Partition cuts every link between nodes of different groups, nodes not listed are cut from everyone.
+func (network *Network) Partition(groups ...[]int) {
+	groupOf := make(map[int]int)
+	for gIdx, group := range groups {
+		for _, idx := range group {
+			groupOf[idx] = gIdx
+		}
+	}
+
+	network.lock.Lock()
+	cut := make([]link, 0)
+	for l := range network.links {
+		ga, okA := groupOf[l.a]
+		gb, okB := groupOf[l.b]
+		if !okA || !okB || ga != gb {
+			cut = append(cut, l)
+		}
+	}
+	network.lock.Unlock()
+
+	for _, l := range cut {
+		network.Disconnect(l.a, l.b)
+	}
+}
+
+// Heal links every pair of nodes again, nodes sync up on the version handshake.
+func (network *Network) Heal() error {
+	return network.ConnectAll()
+}
+
+// Mine mines count blocks in a row on the node, paying the coinbases to address.
+func (network *Network) Mine(idx int, address string, count int) ([]*blockchain.Block, error) {
+	blocks := make([]*blockchain.Block, 0, count)
+	for i := 0; i < count; i++ {
+		block, err := network.nodes[idx].Mine(address)
+		if err != nil {
+			return blocks, fmt.Errorf("mine on node %d failed: %w", idx, err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// Tip returns the best block hash and height of the node.
+func (network *Network) Tip(idx int) (hash chainhash.Hash, height int64) {
+	_ = network.nodes[idx].View(func(chains *blockchain.BlockChains) error {
+		latestBlock := chains.GetLatestBlock()
+		hash, height = latestBlock.Hash, latestBlock.Height
+		return nil
+	})
+	return
+}
+
+// UTXOSet returns the node's unspent outputs as sorted "txid:index:value:pubkeyhash" entries.
+func (network *Network) UTXOSet(idx int) []string {
+	uTXOs := make([]string, 0)
+	_ = network.nodes[idx].View(func(chains *blockchain.BlockChains) error {
+		chains.ScanUTXO(nil, func(txID string, output blockchain.TXOutput) bool {
+			uTXOs = append(uTXOs, fmt.Sprintf("%s:%d:%d:%s", txID, output.Index, output.Value,
+				hex.EncodeToString(output.PubKeyHash)))
+			return true
+		})
+		return nil
+	})
+	sort.Strings(uTXOs)
+	return uTXOs
+}
+
+// Converged checks that the nodes, all of them when none are given, agree on the tip and the UTXO set.
+func (network *Network) Converged(nodes ...int) error {
+	if len(nodes) == 0 {
+		for idx := range network.nodes {
+			nodes = append(nodes, idx)
+		}
+	}
+
+	tip, height := network.Tip(nodes[0])
+	uTXOs := network.UTXOSet(nodes[0])
+	for _, idx := range nodes[1:] {
+		h, hHeight := network.Tip(idx)
+		if !h.IsEqual(&tip) {
+			return fmt.Errorf("node %d tip %s@%d, node %d tip %s@%d", nodes[0], tip, height, idx, h, hHeight)
+		}
+		if !equalStrings(uTXOs, network.UTXOSet(idx)) {
+			return fmt.Errorf("node %d and node %d utxo sets differ at tip %s", nodes[0], idx, tip)
+		}
+	}
+	return nil
+}
+
+// WaitConverged polls Converged until it succeeds or the timeout expires.
+func (network *Network) WaitConverged(timeout time.Duration, nodes ...int) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := network.Converged(nodes...)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func (network *Network) Close() {
+	network.lock.Lock()
+	links := network.links
+	network.links = make(map[link]*linkPeers)
+	network.lock.Unlock()
+
+	for _, peers := range links {
+		peers.a.Disconnect()
+		peers.b.Disconnect()
+	}
+	for _, node := range network.nodes {
+		node.Close()
+		_ = node.View(func(chains *blockchain.BlockChains) error {
+			chains.Close()
+			return nil
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package simnet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
+	"github.com/jiuzhou-zhao/go-fundamental/loge"
+	"github.com/sgostarter/liblog"
+	"github.com/stretchr/testify/assert"
+)
+
+const convergeTimeout = 10 * time.Second
+
+func TestMain(m *testing.M) {
+	logger, err := liblog.NewZapLogger()
+	if err != nil {
+		panic(err)
+	}
+	loge.SetGlobalLogger(loge.NewLogger(logger))
+
+	m.Run()
+}
+
+func newPayTransaction(t *testing.T, network *Network, idx int, from *blockchain.Wallet, to string,
+	amount int) *blockchain.Transaction {
+	var tx *blockchain.Transaction
+	err := network.Node(idx).View(func(chains *blockchain.BlockChains) (err error) {
+		tx, err = blockchain.NewTransaction(from.PublicKey, from.GetAddress(), amount, to, nil, chains)
+		if err != nil {
+			return
+		}
+		return tx.DefSign(chains, from.PrivateKey)
+	})
+	assert.Nil(t, err)
+	return tx
+}
+
+func TestNetwork_Sync(t *testing.T) {
+	network, err := New(3, nil)
+	assert.Nil(t, err)
+	defer network.Close()
+
+	miner := blockchain.NewWallet()
+	_, err = network.Mine(0, miner.GetAddress(), 3)
+	assert.Nil(t, err)
+
+	assert.Nil(t, network.ConnectAll())
+	assert.Nil(t, network.WaitConverged(convergeTimeout))
+
+	_, err = network.Mine(1, miner.GetAddress(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, network.WaitConverged(convergeTimeout))
+
+	_, height := network.Tip(2)
+	assert.EqualValues(t, 5, height)
+}
+
+func TestNetwork_HealDeadLink(t *testing.T) {
+	network, err := New(2, nil)
+	assert.Nil(t, err)
+	defer network.Close()
+	assert.Nil(t, network.ConnectAll())
+
+	// the peers of the link die without the network knowing
+	for _, peer := range network.Node(0).Peers() {
+		peer.Disconnect()
+	}
+	assert.Eventually(t, func() bool {
+		return len(network.Node(0).Peers()) == 0 && len(network.Node(1).Peers()) == 0
+	}, convergeTimeout, pollInterval)
+
+	miner := blockchain.NewWallet()
+	_, err = network.Mine(0, miner.GetAddress(), 2)
+	assert.Nil(t, err)
+	assert.Nil(t, network.Heal())
+	assert.Nil(t, network.WaitConverged(convergeTimeout))
+}
+
+// nolint: funlen
+func TestNetwork_PartitionAndHeal(t *testing.T) {
+	/*
+		all		G	[1]
+		{0,1}			[a1]	[a2]-pay
+		{2,3}			[b1]	[b2]-pay	[b3]
+		---
+		all		G	[1]	[b1]	[b2]	[b3]
+	*/
+	network, err := New(4, nil)
+	assert.Nil(t, err)
+	defer network.Close()
+	assert.Nil(t, network.ConnectAll())
+
+	miner := blockchain.NewWallet()
+	payee := blockchain.NewWallet()
+
+	_, err = network.Mine(0, miner.GetAddress(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, network.WaitConverged(convergeTimeout))
+
+	network.Partition([]int{0, 1}, []int{2, 3})
+
+	_, err = network.Mine(0, miner.GetAddress(), 1)
+	assert.Nil(t, err)
+	_, err = network.Node(0).Mine(miner.GetAddress(), newPayTransaction(t, network, 0, miner, payee.GetAddress(), 3))
+	assert.Nil(t, err)
+
+	_, err = network.Mine(2, miner.GetAddress(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, network.WaitConverged(convergeTimeout, 2, 3))
+	_, err = network.Node(3).Mine(miner.GetAddress(), newPayTransaction(t, network, 3, miner, payee.GetAddress(), 4))
+	assert.Nil(t, err)
+	blocks, err := network.Mine(3, miner.GetAddress(), 1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, network.WaitConverged(convergeTimeout, 0, 1))
+	assert.Nil(t, network.WaitConverged(convergeTimeout, 2, 3))
+	assert.NotNil(t, network.Converged())
+
+	assert.Nil(t, network.Heal())
+	assert.Nil(t, network.WaitConverged(convergeTimeout))
+
+	tip, height := network.Tip(0)
+	assert.True(t, tip.IsEqual(&blocks[0].Hash))
+	assert.EqualValues(t, 5, height)
+
+	_ = network.Node(1).View(func(chains *blockchain.BlockChains) error {
+		assert.Equal(t, 4, chains.GetBalance(payee.GetAddress()))
+		assert.Equal(t, 36, chains.GetBalance(miner.GetAddress()))
+		return nil
+	})
+}
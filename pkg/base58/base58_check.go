@@ -10,7 +10,10 @@ const (
 	checksumLen = 4
 )
 
-var errChecksum = errors.New("checksum failed")
+var (
+	errChecksum = errors.New("checksum failed")
+	errTooShort = errors.New("payload too short")
+)
 
 func checksum(payload []byte) []byte {
 	firstSHA := sha256.Sum256(payload)
@@ -29,6 +32,10 @@ func DecodeWithCheck(payload string, alphabet *Alphabet) (d []byte, err error) {
 	if err != nil {
 		return
 	}
+	if len(pubKeyHash) <= checksumLen {
+		err = errTooShort
+		return
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-checksumLen:]
 	d = pubKeyHash[0 : len(pubKeyHash)-checksumLen]
 	targetChecksum := checksum(d)
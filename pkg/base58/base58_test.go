@@ -30,3 +30,8 @@ func TestBase58WithCheck(t *testing.T) {
 
 	t.Log(s, e, d)
 }
+
+func TestBase58WithCheckTooShort(t *testing.T) {
+	_, err := DecodeWithCheck("bad", BitcoinAlphabet)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,122 @@
+// Package bip39 encodes wallet seeds as BIP39 mnemonic sentences of the English wordlist.
+package bip39
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	seedIterations = 2048
+	seedLen        = 64
+	bitsPerWord    = 11
+)
+
+var (
+	ErrEntropyLength = errors.New("entropy length must be 128 to 256 bits in steps of 32")
+	ErrMnemonicWords = errors.New("mnemonic must have 12 to 24 words in steps of 3")
+	ErrChecksum      = errors.New("mnemonic checksum mismatch")
+)
+
+var wordIndex = func() map[string]int {
+	m := make(map[string]int, len(englishWords))
+	for idx, word := range englishWords {
+		m[word] = idx
+	}
+	return m
+}()
+
+func checkEntropyBits(bits int) error {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return ErrEntropyLength
+	}
+	return nil
+}
+
+// NewEntropy returns bits of random entropy.
+func NewEntropy(bits int) ([]byte, error) {
+	err := checkEntropyBits(bits)
+	if err != nil {
+		return nil, err
+	}
+	entropy := make([]byte, bits/8)
+	_, err = rand.Read(entropy)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return entropy, nil
+}
+
+// NewMnemonic encodes entropy followed by its checksum bits as words.
+func NewMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	err := checkEntropyBits(bits)
+	if err != nil {
+		return "", err
+	}
+	checksumBits := bits / 32
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, uint(checksumBits))
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	words := make([]string, (bits+checksumBits)/bitsPerWord)
+	mask := big.NewInt(1<<bitsPerWord - 1)
+	index := new(big.Int)
+	for idx := len(words) - 1; idx >= 0; idx-- {
+		index.And(data, mask)
+		words[idx] = englishWords[index.Int64()]
+		data.Rsh(data, bitsPerWord)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes the entropy of mnemonic and checks its checksum.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrMnemonicWords
+	}
+
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("unknown mnemonic word %q", word)
+		}
+		data.Lsh(data, bitsPerWord)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * bitsPerWord / 33
+	checksum := new(big.Int).And(data, big.NewInt(1<<checksumBits-1))
+	data.Rsh(data, uint(checksumBits))
+
+	entropy := make([]byte, checksumBits*4)
+	data.FillBytes(entropy)
+	hash := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(hash[0]>>(8-checksumBits)) {
+		return nil, ErrChecksum
+	}
+	return entropy, nil
+}
+
+// IsMnemonicValid tells if mnemonic consists of known words and has a valid checksum.
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// NewSeed stretches mnemonic and the optional passphrase into a 64 byte seed, the mnemonic is not checked.
+func NewSeed(mnemonic, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), seedIterations, seedLen, sha512.New)
+}
@@ -0,0 +1,82 @@
+package bip39
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordlist(t *testing.T) {
+	assert.Len(t, englishWords, 2048)
+	hash := sha256.Sum256([]byte(strings.Join(englishWords, "\n") + "\n"))
+	assert.Equal(t, "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda", hex.EncodeToString(hash[:]))
+}
+
+func TestMnemonic_Vectors(t *testing.T) {
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed: "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e5349553" +
+				"1f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed: "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6f" +
+				"a457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "80808080808080808080808080808080",
+			mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		},
+		{
+			entropy: "0000000000000000000000000000000000000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon " +
+				"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		},
+	}
+
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		assert.Nil(t, err)
+		assert.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := EntropyFromMnemonic(mnemonic)
+		assert.Nil(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		if v.seed != "" {
+			assert.Equal(t, v.seed, hex.EncodeToString(NewSeed(mnemonic, "TREZOR")))
+		}
+	}
+}
+
+func TestMnemonic_Invalid(t *testing.T) {
+	_, err := NewMnemonic(make([]byte, 15))
+	assert.ErrorIs(t, err, ErrEntropyLength)
+
+	assert.False(t, IsMnemonicValid("abandon abandon abandon"))
+	assert.False(t, IsMnemonicValid("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon zzz"))
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	entropy, err := NewEntropy(256)
+	assert.Nil(t, err)
+	mnemonic, err := NewMnemonic(entropy)
+	assert.Nil(t, err)
+	assert.Len(t, strings.Fields(mnemonic), 24)
+	assert.True(t, IsMnemonicValid(mnemonic))
+}
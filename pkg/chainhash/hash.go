@@ -79,7 +79,7 @@ func Decode(dst *Hash, src string) error {
 		copy(srcBytes[1:], src)
 	}
 	var reversedHash Hash
-	_, err := hex.Decode(reversedHash[HashSize-hex.DecodedLen(len(srcBytes)):1], srcBytes)
+	_, err := hex.Decode(reversedHash[HashSize-hex.DecodedLen(len(srcBytes)):], srcBytes)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
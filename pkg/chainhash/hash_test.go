@@ -11,3 +11,16 @@ func TestHashCopy(t *testing.T) {
 	t.Log(h1)
 	t.Log(h2)
 }
+
+func TestNewHashFromStr(t *testing.T) {
+	h := HashH([]byte("hash"))
+	decoded, err := NewHashFromStr(h.String())
+	if err != nil || !decoded.IsEqual(&h) {
+		t.Fatalf("decode %s: %v %v", h, decoded, err)
+	}
+
+	decoded, err = NewHashFromStr("1")
+	if err != nil || !decoded.IsEqual(&Hash{0x01}) {
+		t.Fatalf("decode 1: %v %v", decoded, err)
+	}
+}
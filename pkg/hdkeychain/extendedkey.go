@@ -0,0 +1,329 @@
+// Package hdkeychain derives hierarchical deterministic keys the BIP32 way on the P256 curve of the
+// wallet, following SLIP-0010 for the curve specific parts.
+package hdkeychain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart = uint32(0x80000000)
+
+	MinSeedBytes = 16
+	MaxSeedBytes = 64
+
+	serializedKeyLen = 4 + 1 + 4 + 4 + 32 + 33
+)
+
+var (
+	masterKey = []byte("Nist256p1 seed")
+
+	privateVersion = []byte{0x04, 0x88, 0xad, 0xe4}
+	publicVersion  = []byte{0x04, 0x88, 0xb2, 0x1e}
+)
+
+var (
+	ErrInvalidSeedLen     = fmt.Errorf("seed length must be between %d and %d bytes", MinSeedBytes, MaxSeedBytes)
+	ErrDeriveHardFromPub  = errors.New("cannot derive a hardened key from a public key")
+	ErrNotPrivExtKey      = errors.New("unable to create private keys from a public extended key")
+	ErrDeriveBeyondMaxDep = errors.New("cannot derive a key with more than 255 indices in its path")
+	ErrInvalidKey         = errors.New("the extended key is invalid")
+)
+
+func curve() elliptic.Curve {
+	return elliptic.P256()
+}
+
+// ExtendedKey is a private or public key with the chain code to derive its children.
+type ExtendedKey struct {
+	key       []byte // the 32 bytes private scalar or the compressed public key
+	chainCode []byte
+	parentFP  []byte
+	depth     uint8
+	childNum  uint32
+	isPrivate bool
+}
+
+// NewMaster derives the master private key of seed.
+func NewMaster(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < MinSeedBytes || len(seed) > MaxSeedBytes {
+		return nil, ErrInvalidSeedLen
+	}
+
+	data := seed
+	for {
+		mac := hmac.New(sha512.New, masterKey)
+		_, _ = mac.Write(data)
+		i := mac.Sum(nil)
+		il, ir := i[:32], i[32:]
+
+		k := new(big.Int).SetBytes(il)
+		if k.Sign() != 0 && k.Cmp(curve().Params().N) < 0 {
+			return &ExtendedKey{
+				key:       il,
+				chainCode: ir,
+				parentFP:  []byte{0, 0, 0, 0},
+				isPrivate: true,
+			}, nil
+		}
+		data = i
+	}
+}
+
+// IsPrivate tells if the key is a private extended key.
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.isPrivate
+}
+
+// Depth is the number of derivations from the master key.
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+// ChildIndex is the index the key was derived at.
+func (k *ExtendedKey) ChildIndex() uint32 {
+	return k.childNum
+}
+
+func (k *ExtendedKey) pubKeyBytes() []byte {
+	if !k.isPrivate {
+		return k.key
+	}
+	x, y := curve().ScalarBaseMult(k.key)
+	return elliptic.MarshalCompressed(curve(), x, y)
+}
+
+// Derive returns the child key at index i, hardened for indices from HardenedKeyStart on.
+func (k *ExtendedKey) Derive(i uint32) (*ExtendedKey, error) {
+	if k.depth == 255 {
+		return nil, ErrDeriveBeyondMaxDep
+	}
+	isHardened := i >= HardenedKeyStart
+	if isHardened && !k.isPrivate {
+		return nil, ErrDeriveHardFromPub
+	}
+
+	data := make([]byte, 0, 37)
+	if isHardened {
+		data = append(data, 0)
+		data = append(data, k.key...)
+	} else {
+		data = append(data, k.pubKeyBytes()...)
+	}
+	data = appendUint32(data, i)
+
+	n := curve().Params().N
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		_, _ = mac.Write(data)
+		ilr := mac.Sum(nil)
+		il, ir := ilr[:32], ilr[32:]
+
+		childKey, ok := k.childKey(il, n)
+		if ok {
+			return &ExtendedKey{
+				key:       childKey,
+				chainCode: ir,
+				parentFP:  utils.HashPubKey(k.pubKeyBytes())[:4],
+				depth:     k.depth + 1,
+				childNum:  i,
+				isPrivate: k.isPrivate,
+			}, nil
+		}
+
+		// SLIP-0010: retry with the right half when il does not give a valid key
+		data = append([]byte{1}, ir...)
+		data = appendUint32(data, i)
+	}
+}
+
+func (k *ExtendedKey) childKey(il []byte, n *big.Int) ([]byte, bool) {
+	ilNum := new(big.Int).SetBytes(il)
+	if ilNum.Cmp(n) >= 0 {
+		return nil, false
+	}
+
+	if k.isPrivate {
+		keyNum := new(big.Int).SetBytes(k.key)
+		keyNum.Add(keyNum, ilNum)
+		keyNum.Mod(keyNum, n)
+		if keyNum.Sign() == 0 {
+			return nil, false
+		}
+		return keyNum.FillBytes(make([]byte, 32)), true
+	}
+
+	if ilNum.Sign() == 0 {
+		return nil, false
+	}
+	ilx, ily := curve().ScalarBaseMult(il)
+	px, py := elliptic.UnmarshalCompressed(curve(), k.key)
+	x, y := curve().Add(ilx, ily, px, py)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, false
+	}
+	return elliptic.MarshalCompressed(curve(), x, y), true
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// DerivePath derives the keys along path from k.
+func (k *ExtendedKey) DerivePath(path []uint32) (*ExtendedKey, error) {
+	var err error
+	key := k
+	for _, i := range path {
+		key, err = key.Derive(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Neuter returns the public extended key of k.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.isPrivate {
+		return k
+	}
+	return &ExtendedKey{
+		key:       k.pubKeyBytes(),
+		chainCode: k.chainCode,
+		parentFP:  k.parentFP,
+		depth:     k.depth,
+		childNum:  k.childNum,
+	}
+}
+
+// ECPubKey returns the public key of k.
+func (k *ExtendedKey) ECPubKey() *ecdsa.PublicKey {
+	x, y := elliptic.UnmarshalCompressed(curve(), k.pubKeyBytes())
+	return &ecdsa.PublicKey{Curve: curve(), X: x, Y: y}
+}
+
+// ECPrivKey returns the private key of a private extended key.
+func (k *ExtendedKey) ECPrivKey() (*ecdsa.PrivateKey, error) {
+	if !k.isPrivate {
+		return nil, ErrNotPrivExtKey
+	}
+	return &ecdsa.PrivateKey{
+		PublicKey: *k.ECPubKey(),
+		D:         new(big.Int).SetBytes(k.key),
+	}, nil
+}
+
+// String serializes k in the base58 xprv/xpub format.
+func (k *ExtendedKey) String() string {
+	buf := make([]byte, 0, serializedKeyLen)
+	if k.isPrivate {
+		buf = append(buf, privateVersion...)
+	} else {
+		buf = append(buf, publicVersion...)
+	}
+	buf = append(buf, k.depth)
+	buf = append(buf, k.parentFP...)
+	buf = appendUint32(buf, k.childNum)
+	buf = append(buf, k.chainCode...)
+	if k.isPrivate {
+		buf = append(buf, 0)
+	}
+	buf = append(buf, k.key...)
+	return utils.Base58EncodeWithCheck(buf)
+}
+
+// NewKeyFromString parses a key serialized by String.
+func NewKeyFromString(key string) (*ExtendedKey, error) {
+	payload, err := utils.Base58DecodeWithCheck(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if len(payload) != serializedKeyLen {
+		return nil, ErrInvalidKey
+	}
+
+	k := &ExtendedKey{
+		depth:     payload[4],
+		parentFP:  payload[5:9],
+		childNum:  binary.BigEndian.Uint32(payload[9:13]),
+		chainCode: payload[13:45],
+	}
+	keyData := payload[45:]
+	switch {
+	case bytes.Equal(payload[:4], privateVersion):
+		if keyData[0] != 0 {
+			return nil, ErrInvalidKey
+		}
+		k.key = keyData[1:]
+		k.isPrivate = true
+		num := new(big.Int).SetBytes(k.key)
+		if num.Sign() == 0 || num.Cmp(curve().Params().N) >= 0 {
+			return nil, ErrInvalidKey
+		}
+	case bytes.Equal(payload[:4], publicVersion):
+		k.key = keyData
+		x, _ := elliptic.UnmarshalCompressed(curve(), k.key)
+		if x == nil {
+			return nil, ErrInvalidKey
+		}
+	default:
+		return nil, ErrInvalidKey
+	}
+	return k, nil
+}
+
+// ParsePath parses a derivation path like m/44'/0'/0'/0/1, h marks hardened indices as well.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+
+	indices := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		i, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("derivation path %q: %w", path, err)
+		}
+		if hardened {
+			i += uint64(HardenedKeyStart)
+		}
+		indices = append(indices, uint32(i))
+	}
+	return indices, nil
+}
+
+// PathString formats path like ParsePath reads it.
+func PathString(path []uint32) string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, i := range path {
+		sb.WriteString("/")
+		if i >= HardenedKeyStart {
+			sb.WriteString(strconv.FormatUint(uint64(i-HardenedKeyStart), 10))
+			sb.WriteString("'")
+		} else {
+			sb.WriteString(strconv.FormatUint(uint64(i), 10))
+		}
+	}
+	return sb.String()
+}
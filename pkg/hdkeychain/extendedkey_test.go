@@ -0,0 +1,111 @@
+package hdkeychain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SLIP-0010 test vector 1 for nist256p1.
+func TestExtendedKey_Vectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMaster(seed)
+	assert.Nil(t, err)
+
+	vectors := []struct {
+		path      string
+		fp        string
+		chainCode string
+		private   string
+		public    string
+	}{
+		{
+			path:      "m",
+			fp:        "00000000",
+			chainCode: "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			private:   "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			public:    "0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8",
+		},
+		{
+			path:      "m/0'",
+			fp:        "be6105b5",
+			chainCode: "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			private:   "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+			public:    "0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c",
+		},
+		{
+			path:      "m/0'/1",
+			fp:        "9b02312f",
+			chainCode: "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			private:   "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+			public:    "03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844",
+		},
+	}
+
+	for _, v := range vectors {
+		path, err := ParsePath(v.path)
+		assert.Nil(t, err)
+		key, err := master.DerivePath(path)
+		assert.Nil(t, err)
+		assert.Equal(t, v.fp, hex.EncodeToString(key.parentFP), v.path)
+		assert.Equal(t, v.chainCode, hex.EncodeToString(key.chainCode), v.path)
+		assert.Equal(t, v.private, hex.EncodeToString(key.key), v.path)
+		assert.Equal(t, v.public, hex.EncodeToString(key.pubKeyBytes()), v.path)
+	}
+}
+
+func TestExtendedKey_PublicDerivation(t *testing.T) {
+	seed, _ := hex.DecodeString("fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a2")
+	master, err := NewMaster(seed)
+	assert.Nil(t, err)
+	account, err := master.DerivePath([]uint32{44 + HardenedKeyStart, HardenedKeyStart})
+	assert.Nil(t, err)
+
+	private, err := account.DerivePath([]uint32{0, 7})
+	assert.Nil(t, err)
+	public, err := account.Neuter().DerivePath([]uint32{0, 7})
+	assert.Nil(t, err)
+	assert.False(t, public.IsPrivate())
+	assert.Equal(t, private.Neuter().String(), public.String())
+
+	privKey, err := private.ECPrivKey()
+	assert.Nil(t, err)
+	assert.Equal(t, public.ECPubKey(), &privKey.PublicKey)
+	_, err = public.ECPrivKey()
+	assert.ErrorIs(t, err, ErrNotPrivExtKey)
+	_, err = account.Neuter().Derive(HardenedKeyStart)
+	assert.ErrorIs(t, err, ErrDeriveHardFromPub)
+}
+
+func TestExtendedKey_String(t *testing.T) {
+	master, err := NewMaster(make([]byte, 32))
+	assert.Nil(t, err)
+	child, err := master.DerivePath([]uint32{HardenedKeyStart + 1, 2})
+	assert.Nil(t, err)
+
+	for _, key := range []*ExtendedKey{child, child.Neuter()} {
+		parsed, err := NewKeyFromString(key.String())
+		assert.Nil(t, err)
+		assert.Equal(t, key, parsed)
+	}
+	assert.Equal(t, "xprv", child.String()[:4])
+	assert.Equal(t, "xpub", child.Neuter().String()[:4])
+
+	_, err = NewKeyFromString("xpub")
+	assert.NotNil(t, err)
+	_, err = NewMaster(make([]byte, 8))
+	assert.ErrorIs(t, err, ErrInvalidSeedLen)
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath("m/44'/0h/0'/1/5")
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{44 + HardenedKeyStart, HardenedKeyStart, HardenedKeyStart, 1, 5}, path)
+	assert.Equal(t, "m/44'/0'/0'/1/5", PathString(path))
+
+	for _, bad := range []string{"", "44/0", "m/x", "m/2147483648"} {
+		_, err = ParsePath(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
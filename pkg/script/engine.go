@@ -0,0 +1,273 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotPushOnly           = errors.New("unlocking script does not only push data")
+	ErrStackUnderflow        = errors.New("script stack underflow")
+	ErrStackOverflow         = errors.New("script stack is too large")
+	ErrTooManyOps            = errors.New("script has too many operations")
+	ErrUnknownOpcode         = errors.New("unknown opcode")
+	ErrUnbalancedConditional = errors.New("unbalanced conditional")
+	ErrEarlyReturn           = errors.New("script returned early")
+	ErrVerify                = errors.New("verify failed")
+	ErrEvalFalse             = errors.New("script evaluated to false")
+	ErrInvalidPubKeyCount    = errors.New("invalid multisig public key count")
+	ErrInvalidSigCount       = errors.New("invalid multisig signature count")
+	ErrSigNullDummy          = errors.New("multisig dummy element is not empty")
+	ErrNegativeLockTime      = errors.New("negative lock time")
+	ErrUnsatisfiedLockTime   = errors.New("lock time requirement not satisfied")
+)
+
+// Checker checks what the scripts can not by themselves, against the transaction they run for.
+type Checker interface {
+	// CheckSig tells if sig is a valid signature by pubKey of the spending transaction, script is the one
+	// running the check: the locking script, or the redeem script of a pay to script hash output.
+	CheckSig(sig, pubKey, script []byte) bool
+	// CheckLockTime tells if the lock time of the spending transaction reached lockTime, a height or a time.
+	CheckLockTime(lockTime int64) bool
+	// CheckSequence tells if the sequence number of the input reached the relative lock sequence.
+	CheckSequence(sequence int64) bool
+}
+
+// Engine runs the unlocking script of an input, then the locking script of the output it spends on the
+// stack the unlocking script left. When the locking script is a pay to script hash one, the redeem script
+// the unlocking script pushed last runs then, on what the unlocking script left below it.
+type Engine struct {
+	scripts    [][]parsedOp
+	rawScripts [][]byte
+	scriptIdx  int
+	checker    Checker
+	stack      stack
+	condStack  []bool
+	numOps     int
+	scriptHash bool
+	savedStack stack
+}
+
+// NewEngine returns the engine checking that unlockingScript satisfies lockingScript.
+func NewEngine(unlockingScript, lockingScript []byte, checker Checker) (*Engine, error) {
+	if !IsPushOnly(unlockingScript) {
+		return nil, ErrNotPushOnly
+	}
+	vm := &Engine{checker: checker}
+	for _, script := range [][]byte{unlockingScript, lockingScript} {
+		err := vm.addScript(script)
+		if err != nil {
+			return nil, err
+		}
+	}
+	vm.scriptHash = isScriptHash(vm.scripts[1])
+	return vm, nil
+}
+
+func (vm *Engine) addScript(script []byte) error {
+	ops, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+	vm.scripts = append(vm.scripts, ops)
+	vm.rawScripts = append(vm.rawScripts, script)
+	return nil
+}
+
+// Verify runs the engine of unlockingScript and lockingScript.
+func Verify(unlockingScript, lockingScript []byte, checker Checker) error {
+	vm, err := NewEngine(unlockingScript, lockingScript, checker)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+// Execute runs the scripts, they succeed when they leave a true value on top of the stack.
+func (vm *Engine) Execute() error {
+	for vm.scriptIdx = 0; vm.scriptIdx < len(vm.scripts); vm.scriptIdx++ {
+		if vm.scriptHash && vm.scriptIdx == 1 {
+			vm.savedStack = append(stack(nil), vm.stack...)
+		}
+		vm.numOps = 0
+		for _, pop := range vm.scripts[vm.scriptIdx] {
+			err := vm.step(pop)
+			if err != nil {
+				return err
+			}
+		}
+		if len(vm.condStack) != 0 {
+			return ErrUnbalancedConditional
+		}
+		if vm.scriptHash && vm.scriptIdx == 1 {
+			err := vm.addRedeemScript()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return vm.checkTop()
+}
+
+func (vm *Engine) checkTop() error {
+	top, err := vm.stack.peek(0)
+	if err != nil || !asBool(top) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+// addRedeemScript queues the redeem script once the locking script checked its hash, and restores the
+// stack the unlocking script left without it.
+func (vm *Engine) addRedeemScript() error {
+	err := vm.checkTop()
+	if err != nil {
+		return err
+	}
+	vm.stack = vm.savedStack
+	redeemScript, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	return vm.addScript(redeemScript)
+}
+
+// checkSig asks the checker about sig, for the running script.
+func (vm *Engine) checkSig(sig, pubKey []byte) bool {
+	return len(sig) > 0 && vm.checker != nil && vm.checker.CheckSig(sig, pubKey, vm.rawScripts[vm.scriptIdx])
+}
+
+func (vm *Engine) executing() bool {
+	for _, cond := range vm.condStack {
+		if !cond {
+			return false
+		}
+	}
+	return true
+}
+
+func (vm *Engine) step(pop parsedOp) error {
+	if len(pop.data) > MaxScriptElementSize {
+		return ErrElementTooLarge
+	}
+	if !isPush(pop.op) {
+		vm.numOps++
+		if vm.numOps > MaxOpsPerScript {
+			return ErrTooManyOps
+		}
+	}
+
+	var err error
+	switch {
+	case pop.op >= OpIf && pop.op <= OpEndIf:
+		err = vm.conditional(pop.op)
+	case !vm.executing():
+		return nil
+	case isPush(pop.op):
+		vm.stack.push(pushedValue(pop))
+	default:
+		handler, ok := opHandlers[pop.op]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownOpcode, OpcodeName(pop.op))
+		}
+		err = handler(vm)
+	}
+	if err != nil {
+		return err
+	}
+	if len(vm.stack) > MaxStackSize {
+		return ErrStackOverflow
+	}
+	return nil
+}
+
+// conditional runs OP_IF, OP_NOTIF, OP_ELSE and OP_ENDIF, which run in the branches not taken too.
+func (vm *Engine) conditional(op byte) error {
+	switch op {
+	case OpIf, OpNotIf:
+		cond := false
+		if vm.executing() {
+			v, err := vm.stack.pop()
+			if err != nil {
+				return err
+			}
+			cond = asBool(v) == (op == OpIf)
+		}
+		vm.condStack = append(vm.condStack, cond)
+	case OpElse:
+		if len(vm.condStack) == 0 {
+			return ErrUnbalancedConditional
+		}
+		vm.condStack[len(vm.condStack)-1] = !vm.condStack[len(vm.condStack)-1]
+	case OpEndIf:
+		if len(vm.condStack) == 0 {
+			return ErrUnbalancedConditional
+		}
+		vm.condStack = vm.condStack[:len(vm.condStack)-1]
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownOpcode, OpcodeName(op))
+	}
+	return nil
+}
+
+func pushedValue(pop parsedOp) []byte {
+	switch {
+	case pop.op == Op0:
+		return []byte{}
+	case pop.op == Op1Negate:
+		return scriptNum(-1).Bytes()
+	case pop.op >= Op1 && pop.op <= Op16:
+		return scriptNum(pop.op - Op1 + 1).Bytes()
+	}
+	return pop.data
+}
+
+// stack is the data stack of the engine, its top is the end of the slice.
+type stack [][]byte
+
+func (s *stack) push(v []byte) {
+	*s = append(*s, v)
+}
+
+func (s *stack) pop() ([]byte, error) {
+	v, err := s.peek(0)
+	if err != nil {
+		return nil, err
+	}
+	*s = (*s)[:len(*s)-1]
+	return v, nil
+}
+
+// peek returns the value idx below the top.
+func (s stack) peek(idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(s) {
+		return nil, ErrStackUnderflow
+	}
+	return s[len(s)-1-idx], nil
+}
+
+// popN pops n values, they are returned in the order they were pushed.
+func (s *stack) popN(n int) ([][]byte, error) {
+	if n < 0 || n > len(*s) {
+		return nil, ErrStackUnderflow
+	}
+	values := append([][]byte(nil), (*s)[len(*s)-n:]...)
+	*s = (*s)[:len(*s)-n]
+	return values, nil
+}
+
+func (s *stack) popInt() (scriptNum, error) {
+	v, err := s.pop()
+	if err != nil {
+		return 0, err
+	}
+	return makeScriptNum(v, maxNumSize)
+}
+
+func (s *stack) popBool() (bool, error) {
+	v, err := s.pop()
+	if err != nil {
+		return false, err
+	}
+	return asBool(v), nil
+}
@@ -0,0 +1,257 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func build(t *testing.T, b *Builder) []byte {
+	s, err := b.Script()
+	assert.Nil(t, err)
+	return s
+}
+
+// fixedChecker accepts the signature sig of pubKey.
+type fixedChecker struct {
+	sig, pubKey []byte
+}
+
+func (c *fixedChecker) CheckSig(sig, pubKey, _ []byte) bool {
+	return bytes.Equal(sig, c.sig) && bytes.Equal(pubKey, c.pubKey)
+}
+
+func (c *fixedChecker) CheckLockTime(int64) bool { return false }
+
+func (c *fixedChecker) CheckSequence(int64) bool { return false }
+
+// nolint: funlen
+func TestEngine(t *testing.T) {
+	hash := sha256.Sum256([]byte("secret"))
+	tests := []struct {
+		name   string
+		unlock *Builder
+		lock   *Builder
+		err    error
+	}{
+		{"true", NewBuilder(), NewBuilder().AddOp(OpTrue), nil},
+		{"false", NewBuilder(), NewBuilder().AddOp(OpFalse), ErrEvalFalse},
+		{"empty", NewBuilder(), NewBuilder(), ErrEvalFalse},
+		{"add", NewBuilder().AddInt64(2).AddInt64(3), NewBuilder().AddOp(OpAdd).AddInt64(5).AddOp(OpNumEqual), nil},
+		{"sub negative", NewBuilder().AddInt64(2).AddInt64(300), NewBuilder().AddOp(OpSub).AddInt64(-298).AddOp(OpNumEqual), nil},
+		{"number too big", NewBuilder().AddData([]byte{1, 2, 3, 4, 5}), NewBuilder().AddOp(Op1Add), ErrNumberTooBig},
+		{"hash lock", NewBuilder().AddData([]byte("secret")), NewBuilder().AddOp(OpSHA256).AddData(hash[:]).AddOp(OpEqual), nil},
+		{"hash lock wrong", NewBuilder().AddData([]byte("guess")), NewBuilder().AddOp(OpSHA256).AddData(hash[:]).AddOp(OpEqualVerify).AddOp(OpTrue), ErrVerify},
+		{"if", NewBuilder().AddOp(OpTrue), NewBuilder().AddOp(OpIf).AddOp(OpTrue).AddOp(OpElse).AddOp(OpFalse).AddOp(OpEndIf), nil},
+		{"else", NewBuilder().AddOp(OpFalse), NewBuilder().AddOp(OpIf).AddOp(OpFalse).AddOp(OpElse).AddOp(OpTrue).AddOp(OpEndIf), nil},
+		{"notif", NewBuilder().AddOp(OpFalse), NewBuilder().AddOp(OpNotIf).AddOp(OpTrue).AddOp(OpEndIf), nil},
+		{"unknown in skipped branch", NewBuilder().AddOp(OpFalse), NewBuilder().AddOp(OpIf).AddOp(0xff).AddOp(OpEndIf).AddOp(OpTrue), nil},
+		{"unknown", NewBuilder(), NewBuilder().AddOp(0xff), ErrUnknownOpcode},
+		{"unbalanced", NewBuilder().AddOp(OpTrue), NewBuilder().AddOp(OpIf).AddOp(OpTrue), ErrUnbalancedConditional},
+		{"no if", NewBuilder(), NewBuilder().AddOp(OpTrue).AddOp(OpEndIf), ErrUnbalancedConditional},
+		{"return", NewBuilder(), NewBuilder().AddOp(OpTrue).AddOp(OpReturn), ErrEarlyReturn},
+		{"underflow", NewBuilder(), NewBuilder().AddOp(OpDup), ErrStackUnderflow},
+		{"not push only", NewBuilder().AddOp(OpTrue).AddOp(OpDup), NewBuilder().AddOp(OpTrue), ErrNotPushOnly},
+		{"stack ops", NewBuilder().AddInt64(1).AddInt64(2), NewBuilder().AddOp(OpSwap).AddOp(OpOver).AddOp(OpNip).AddOp(OpSub).AddInt64(0).AddOp(OpNumEqual), nil},
+		{"size", NewBuilder().AddData([]byte("abc")), NewBuilder().AddOp(OpSize).AddInt64(3).AddOp(OpEqualVerify).AddOp(OpDrop).AddOp(OpDepth).AddOp(Op0NotEqual).AddOp(OpNot), nil},
+		{"checksig", NewBuilder().AddData([]byte("sig")), NewBuilder().AddData([]byte("key")).AddOp(OpCheckSig), nil},
+		{"checksig wrong", NewBuilder().AddData([]byte("bad")), NewBuilder().AddData([]byte("key")).AddOp(OpCheckSig), ErrEvalFalse},
+		{"checksigverify", NewBuilder().AddData([]byte("bad")), NewBuilder().AddData([]byte("key")).AddOp(OpCheckSigVerify).AddOp(OpTrue), ErrVerify},
+	}
+	checker := &fixedChecker{sig: []byte("sig"), pubKey: []byte("key")}
+	for _, test := range tests {
+		err := Verify(build(t, test.unlock), build(t, test.lock), checker)
+		if test.err == nil {
+			assert.Nil(t, err, test.name)
+		} else {
+			assert.ErrorIs(t, err, test.err, test.name)
+		}
+	}
+}
+
+func TestEngine_Limits(t *testing.T) {
+	b := NewBuilder().AddOp(OpTrue)
+	for i := 0; i < MaxOpsPerScript; i++ {
+		b.AddOp(OpNop)
+	}
+	assert.Nil(t, Verify(nil, build(t, b), nil))
+	assert.ErrorIs(t, Verify(nil, build(t, b.AddOp(OpNop)), nil), ErrTooManyOps)
+
+	b = NewBuilder()
+	for i := 0; i < MaxStackSize+1; i++ {
+		b.AddOp(OpTrue)
+	}
+	assert.ErrorIs(t, Verify(build(t, b), []byte{OpTrue}, nil), ErrStackOverflow)
+
+	_, err := NewBuilder().AddData(make([]byte, MaxScriptElementSize+1)).Script()
+	assert.ErrorIs(t, err, ErrElementTooLarge)
+	large := append([]byte{OpPushData2, 0x09, 0x02}, make([]byte, MaxScriptElementSize+1)...)
+	assert.ErrorIs(t, Verify(large, []byte{OpTrue}, nil), ErrElementTooLarge)
+	assert.ErrorIs(t, Verify(nil, make([]byte, MaxScriptSize+1), nil), ErrScriptTooLong)
+	assert.ErrorIs(t, Verify([]byte{OpData20, 1}, []byte{OpTrue}, nil), ErrNotPushOnly)
+	assert.ErrorIs(t, Verify(nil, []byte{OpData20, 1}, nil), ErrMalformedPush)
+}
+
+func TestScriptNum(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 256, -32768, 1 << 30, -(1 << 31) + 1} {
+		v, err := makeScriptNum(scriptNum(n).Bytes(), 8)
+		assert.Nil(t, err)
+		assert.EqualValues(t, n, v)
+	}
+	assert.Equal(t, []byte{0x80, 0x00}, scriptNum(128).Bytes())
+	assert.Equal(t, []byte{0x80, 0x80}, scriptNum(-128).Bytes())
+	assert.False(t, asBool([]byte{0, 0x80}))
+	assert.True(t, asBool([]byte{0x80, 0}))
+}
+
+func TestPayToPubKey(t *testing.T) {
+	_, pubKey := utils.NewKeyPair()
+	pkScript, err := PayToPubKeyScript(pubKey)
+	assert.Nil(t, err)
+	checker := &fixedChecker{sig: []byte("sig"), pubKey: pubKey}
+	assert.Nil(t, Verify(build(t, NewBuilder().AddData([]byte("sig"))), pkScript, checker))
+
+	pkhScript, err := PayToPubKeyHashScript(utils.HashPubKey(pubKey))
+	assert.Nil(t, err)
+	assert.Nil(t, Verify(build(t, NewBuilder().AddData([]byte("sig")).AddData(pubKey)), pkhScript, checker))
+	_, other := utils.NewKeyPair()
+	assert.ErrorIs(t, Verify(build(t, NewBuilder().AddData([]byte("sig")).AddData(other)), pkhScript, checker), ErrVerify)
+}
+
+// scriptChecker accepts the signatures made of their key and of the script running the check.
+type scriptChecker struct{}
+
+func (scriptChecker) CheckSig(sig, pubKey, script []byte) bool {
+	return bytes.Equal(sig, fakeSig(pubKey, script))
+}
+
+func (scriptChecker) CheckLockTime(int64) bool { return false }
+
+func (scriptChecker) CheckSequence(int64) bool { return false }
+
+func fakeSig(pubKey, script []byte) []byte {
+	return utils.HashPubKey(append(append([]byte(nil), pubKey...), script...))
+}
+
+func TestCheckMultiSig(t *testing.T) {
+	pubKeys := make([][]byte, 3)
+	for idx := range pubKeys {
+		_, pubKeys[idx] = utils.NewKeyPair()
+	}
+	lock, err := MultiSigScript(2, pubKeys)
+	assert.Nil(t, err)
+	assert.Equal(t, MultiSigTy, GetClass(lock))
+	m, keys, err := ExtractMultiSig(lock)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, m)
+	assert.Equal(t, pubKeys, keys)
+
+	sig := func(idx int) []byte { return fakeSig(pubKeys[idx], lock) }
+	tests := []struct {
+		name   string
+		unlock *Builder
+		err    error
+	}{
+		{"first and second", NewBuilder().AddOp(Op0).AddData(sig(0)).AddData(sig(1)), nil},
+		{"first and third", NewBuilder().AddOp(Op0).AddData(sig(0)).AddData(sig(2)), nil},
+		{"out of order", NewBuilder().AddOp(Op0).AddData(sig(2)).AddData(sig(0)), ErrEvalFalse},
+		{"twice the same", NewBuilder().AddOp(Op0).AddData(sig(1)).AddData(sig(1)), ErrEvalFalse},
+		{"one signature", NewBuilder().AddOp(Op0).AddData(sig(1)), ErrStackUnderflow},
+		{"no dummy", NewBuilder().AddData(sig(0)).AddData(sig(1)), ErrStackUnderflow},
+		{"dummy not empty", NewBuilder().AddOp(Op1).AddData(sig(0)).AddData(sig(1)), ErrSigNullDummy},
+	}
+	for _, test := range tests {
+		err = Verify(build(t, test.unlock), lock, scriptChecker{})
+		if test.err == nil {
+			assert.Nil(t, err, test.name)
+		} else {
+			assert.ErrorIs(t, err, test.err, test.name)
+		}
+	}
+
+	_, err = MultiSigScript(4, pubKeys)
+	assert.ErrorIs(t, err, ErrInvalidSigCount)
+	_, err = MultiSigScript(1, nil)
+	assert.ErrorIs(t, err, ErrInvalidPubKeyCount)
+	tooMany := build(t, NewBuilder().AddOp(Op0).AddOp(Op0).AddInt64(MaxPubKeysPerMultiSig+1).AddOp(OpCheckMultiSig))
+	assert.ErrorIs(t, Verify(nil, tooMany, nil), ErrInvalidPubKeyCount)
+}
+
+func TestPayToScriptHash(t *testing.T) {
+	pubKeys := make([][]byte, 3)
+	for idx := range pubKeys {
+		_, pubKeys[idx] = utils.NewKeyPair()
+	}
+	redeemScript, err := MultiSigScript(2, pubKeys)
+	assert.Nil(t, err)
+	lock, err := PayToScriptHashScript(utils.HashPubKey(redeemScript))
+	assert.Nil(t, err)
+	assert.Equal(t, ScriptHashTy, GetClass(lock))
+	assert.Equal(t, utils.HashPubKey(redeemScript), ExtractPubKeyHash(lock))
+
+	// the signatures commit to the redeem script, which runs the check
+	unlock := build(t, NewBuilder().AddOp(Op0).AddData(fakeSig(pubKeys[0], redeemScript)).
+		AddData(fakeSig(pubKeys[2], redeemScript)).AddData(redeemScript))
+	assert.Nil(t, Verify(unlock, lock, scriptChecker{}))
+
+	wrongSigs := build(t, NewBuilder().AddOp(Op0).AddData(fakeSig(pubKeys[0], lock)).
+		AddData(fakeSig(pubKeys[2], lock)).AddData(redeemScript))
+	assert.ErrorIs(t, Verify(wrongSigs, lock, scriptChecker{}), ErrEvalFalse)
+
+	otherScript := build(t, NewBuilder().AddOp(OpTrue))
+	assert.ErrorIs(t, Verify(build(t, NewBuilder().AddData(otherScript)), lock, scriptChecker{}), ErrEvalFalse)
+	assert.ErrorIs(t, Verify(nil, lock, scriptChecker{}), ErrStackUnderflow)
+
+	// the redeem script runs on what the unlocking script left below it
+	trueLock, err := PayToScriptHashScript(utils.HashPubKey(otherScript))
+	assert.Nil(t, err)
+	assert.Nil(t, Verify(build(t, NewBuilder().AddData(otherScript)), trueLock, nil))
+	falseScript := build(t, NewBuilder().AddOp(OpVerify).AddOp(OpTrue))
+	falseLock, err := PayToScriptHashScript(utils.HashPubKey(falseScript))
+	assert.Nil(t, err)
+	assert.ErrorIs(t, Verify(build(t, NewBuilder().AddOp(OpFalse).AddData(falseScript)), falseLock, nil), ErrVerify)
+	assert.Nil(t, Verify(build(t, NewBuilder().AddOp(OpTrue).AddData(falseScript)), falseLock, nil))
+}
+
+// lockChecker accepts the locks up to those of its transaction input.
+type lockChecker struct {
+	lockTime, sequence int64
+}
+
+func (lockChecker) CheckSig(_, _, _ []byte) bool { return false }
+
+func (c lockChecker) CheckLockTime(lockTime int64) bool { return lockTime <= c.lockTime }
+
+func (c lockChecker) CheckSequence(sequence int64) bool { return sequence <= c.sequence }
+
+func TestCheckLockTime(t *testing.T) {
+	checker := lockChecker{lockTime: 1700000000, sequence: 10}
+	tests := []struct {
+		name string
+		lock *Builder
+		err  error
+	}{
+		{"reached", NewBuilder().AddInt64(1600000000).AddOp(OpCheckLockTimeVerify), nil},
+		{"equal", NewBuilder().AddInt64(1700000000).AddOp(OpCheckLockTimeVerify), nil},
+		{"not reached", NewBuilder().AddInt64(1700000001).AddOp(OpCheckLockTimeVerify), ErrUnsatisfiedLockTime},
+		{"five bytes", NewBuilder().AddInt64(5000000000).AddOp(OpCheckLockTimeVerify), ErrUnsatisfiedLockTime},
+		{"negative", NewBuilder().AddInt64(-1).AddOp(OpCheckLockTimeVerify), ErrNegativeLockTime},
+		{"empty stack", NewBuilder().AddOp(OpCheckLockTimeVerify), ErrStackUnderflow},
+		{"sequence", NewBuilder().AddInt64(10).AddOp(OpCheckSequenceVerify), nil},
+		{"sequence not reached", NewBuilder().AddInt64(11).AddOp(OpCheckSequenceVerify), ErrUnsatisfiedLockTime},
+		{"drop", NewBuilder().AddInt64(0).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).AddOp(OpTrue), nil},
+	}
+	for _, test := range tests {
+		err := Verify(nil, build(t, test.lock), checker)
+		if test.err == nil {
+			assert.Nil(t, err, test.name)
+		} else {
+			assert.ErrorIs(t, err, test.err, test.name)
+		}
+	}
+	assert.ErrorIs(t, Verify(nil, build(t, NewBuilder().AddInt64(0).AddOp(OpCheckLockTimeVerify)), nil),
+		ErrUnsatisfiedLockTime)
+}
@@ -0,0 +1,72 @@
+package script
+
+import "errors"
+
+// maxNumSize is the size limit of the numbers arithmetic takes.
+const maxNumSize = 4
+
+var ErrNumberTooBig = errors.New("script number is too big")
+
+// scriptNum is a number of the stack, encoded little endian with the sign in the top bit of the last byte.
+type scriptNum int64
+
+func makeScriptNum(b []byte, maxSize int) (scriptNum, error) {
+	if len(b) > maxSize {
+		return 0, ErrNumberTooBig
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var n int64
+	for idx, c := range b {
+		n |= int64(c) << uint(8*idx)
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << uint(8*(len(b)-1)))
+		return scriptNum(-n), nil
+	}
+	return scriptNum(n), nil
+}
+
+// Bytes encodes n the shortest way, zero is the empty array.
+func (n scriptNum) Bytes() []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	negative := n < 0
+	abs := int64(n)
+	if negative {
+		abs = -abs
+	}
+	result := make([]byte, 0, 9)
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+	switch {
+	case result[len(result)-1]&0x80 != 0 && negative:
+		result = append(result, 0x80)
+	case result[len(result)-1]&0x80 != 0:
+		result = append(result, 0x00)
+	case negative:
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// asBool tells if b is true: any non zero byte but the sign bit of the last byte.
+func asBool(b []byte) bool {
+	for idx, c := range b {
+		if c != 0 {
+			return idx != len(b)-1 || c != 0x80
+		}
+	}
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{}
+}
@@ -0,0 +1,133 @@
+package script
+
+import "fmt"
+
+// The opcodes of the script language, with the values of Bitcoin's. The opcodes from OpData1 to OpData75 push
+// the next that many bytes.
+const (
+	Op0              = 0x00
+	OpFalse          = Op0
+	OpData1          = 0x01
+	OpData20         = 0x14
+	OpData75         = 0x4b
+	OpPushData1      = 0x4c
+	OpPushData2      = 0x4d
+	OpPushData4      = 0x4e
+	Op1Negate        = 0x4f
+	OpReserved       = 0x50
+	Op1              = 0x51
+	OpTrue           = Op1
+	Op16             = 0x60
+	OpNop            = 0x61
+	OpIf             = 0x63
+	OpNotIf          = 0x64
+	OpElse           = 0x67
+	OpEndIf          = 0x68
+	OpVerify         = 0x69
+	OpReturn         = 0x6a
+	Op2Drop          = 0x6d
+	Op2Dup           = 0x6e
+	OpDepth          = 0x74
+	OpDrop           = 0x75
+	OpDup            = 0x76
+	OpNip            = 0x77
+	OpOver           = 0x78
+	OpSwap           = 0x7c
+	OpSize           = 0x82
+	OpEqual          = 0x87
+	OpEqualVerify    = 0x88
+	Op1Add           = 0x8b
+	Op1Sub           = 0x8c
+	OpNegate         = 0x8f
+	OpNot            = 0x91
+	Op0NotEqual      = 0x92
+	OpAdd            = 0x93
+	OpSub            = 0x94
+	OpBoolAnd        = 0x9a
+	OpBoolOr         = 0x9b
+	OpNumEqual       = 0x9c
+	OpNumEqualVerify = 0x9d
+	OpLessThan       = 0x9f
+	OpGreaterThan    = 0xa0
+	OpSHA256         = 0xa8
+	OpHash160        = 0xa9
+	OpHash256        = 0xaa
+	OpCheckSig       = 0xac
+	OpCheckSigVerify = 0xad
+
+	OpCheckMultiSig       = 0xae
+	OpCheckMultiSigVerify = 0xaf
+
+	OpCheckLockTimeVerify = 0xb1
+	OpCheckSequenceVerify = 0xb2
+)
+
+var opcodeNames = map[byte]string{
+	Op0:              "OP_0",
+	OpPushData1:      "OP_PUSHDATA1",
+	OpPushData2:      "OP_PUSHDATA2",
+	OpPushData4:      "OP_PUSHDATA4",
+	Op1Negate:        "OP_1NEGATE",
+	OpReserved:       "OP_RESERVED",
+	OpNop:            "OP_NOP",
+	OpIf:             "OP_IF",
+	OpNotIf:          "OP_NOTIF",
+	OpElse:           "OP_ELSE",
+	OpEndIf:          "OP_ENDIF",
+	OpVerify:         "OP_VERIFY",
+	OpReturn:         "OP_RETURN",
+	Op2Drop:          "OP_2DROP",
+	Op2Dup:           "OP_2DUP",
+	OpDepth:          "OP_DEPTH",
+	OpDrop:           "OP_DROP",
+	OpDup:            "OP_DUP",
+	OpNip:            "OP_NIP",
+	OpOver:           "OP_OVER",
+	OpSwap:           "OP_SWAP",
+	OpSize:           "OP_SIZE",
+	OpEqual:          "OP_EQUAL",
+	OpEqualVerify:    "OP_EQUALVERIFY",
+	Op1Add:           "OP_1ADD",
+	Op1Sub:           "OP_1SUB",
+	OpNegate:         "OP_NEGATE",
+	OpNot:            "OP_NOT",
+	Op0NotEqual:      "OP_0NOTEQUAL",
+	OpAdd:            "OP_ADD",
+	OpSub:            "OP_SUB",
+	OpBoolAnd:        "OP_BOOLAND",
+	OpBoolOr:         "OP_BOOLOR",
+	OpNumEqual:       "OP_NUMEQUAL",
+	OpNumEqualVerify: "OP_NUMEQUALVERIFY",
+	OpLessThan:       "OP_LESSTHAN",
+	OpGreaterThan:    "OP_GREATERTHAN",
+	OpSHA256:         "OP_SHA256",
+	OpHash160:        "OP_HASH160",
+	OpHash256:        "OP_HASH256",
+	OpCheckSig:       "OP_CHECKSIG",
+	OpCheckSigVerify: "OP_CHECKSIGVERIFY",
+
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+}
+
+// OpcodeName returns the name of the opcode op the way Bitcoin spells it.
+func OpcodeName(op byte) string {
+	switch {
+	case op >= OpData1 && op <= OpData75:
+		return fmt.Sprintf("OP_DATA_%d", op)
+	case op >= Op1 && op <= Op16:
+		return fmt.Sprintf("OP_%d", op-Op1+1)
+	}
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OP_UNKNOWN%d", op)
+}
+
+// isPush tells if op only pushes data, pushes do not count toward MaxOpsPerScript.
+func isPush(op byte) bool {
+	return op <= Op16 && op != OpReserved
+}
@@ -0,0 +1,299 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// opHandlers run the opcodes which are neither pushes nor conditionals.
+var opHandlers = map[byte]func(vm *Engine) error{
+	OpNop:            func(vm *Engine) error { return nil },
+	OpVerify:         opVerify,
+	OpReturn:         func(vm *Engine) error { return ErrEarlyReturn },
+	Op2Drop:          op2Drop,
+	Op2Dup:           op2Dup,
+	OpDepth:          opDepth,
+	OpDrop:           opDrop,
+	OpDup:            opDup,
+	OpNip:            opNip,
+	OpOver:           opOver,
+	OpSwap:           opSwap,
+	OpSize:           opSize,
+	OpEqual:          opEqual,
+	OpEqualVerify:    withVerify(opEqual),
+	Op1Add:           unaryNum(func(a scriptNum) scriptNum { return a + 1 }),
+	Op1Sub:           unaryNum(func(a scriptNum) scriptNum { return a - 1 }),
+	OpNegate:         unaryNum(func(a scriptNum) scriptNum { return -a }),
+	OpNot:            unaryNum(func(a scriptNum) scriptNum { return boolNum(a == 0) }),
+	Op0NotEqual:      unaryNum(func(a scriptNum) scriptNum { return boolNum(a != 0) }),
+	OpAdd:            binaryNum(func(a, b scriptNum) scriptNum { return a + b }),
+	OpSub:            binaryNum(func(a, b scriptNum) scriptNum { return a - b }),
+	OpBoolAnd:        binaryNum(func(a, b scriptNum) scriptNum { return boolNum(a != 0 && b != 0) }),
+	OpBoolOr:         binaryNum(func(a, b scriptNum) scriptNum { return boolNum(a != 0 || b != 0) }),
+	OpNumEqual:       binaryNum(func(a, b scriptNum) scriptNum { return boolNum(a == b) }),
+	OpNumEqualVerify: withVerify(binaryNum(func(a, b scriptNum) scriptNum { return boolNum(a == b) })),
+	OpLessThan:       binaryNum(func(a, b scriptNum) scriptNum { return boolNum(a < b) }),
+	OpGreaterThan:    binaryNum(func(a, b scriptNum) scriptNum { return boolNum(a > b) }),
+	OpSHA256: hashOp(func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		return h[:]
+	}),
+	OpHash160:        hashOp(utils.HashPubKey),
+	OpHash256:        hashOp(chainhash.DoubleHashB),
+	OpCheckSig:       opCheckSig,
+	OpCheckSigVerify: withVerify(opCheckSig),
+
+	OpCheckMultiSig:       opCheckMultiSig,
+	OpCheckMultiSigVerify: withVerify(opCheckMultiSig),
+
+	OpCheckLockTimeVerify: checkLock(func(c Checker, n int64) bool { return c.CheckLockTime(n) }),
+	OpCheckSequenceVerify: checkLock(func(c Checker, n int64) bool { return c.CheckSequence(n) }),
+}
+
+func opVerify(vm *Engine) error {
+	v, err := vm.stack.popBool()
+	if err != nil {
+		return err
+	}
+	if !v {
+		return ErrVerify
+	}
+	return nil
+}
+
+// withVerify runs op then OP_VERIFY.
+func withVerify(op func(vm *Engine) error) func(vm *Engine) error {
+	return func(vm *Engine) error {
+		err := op(vm)
+		if err != nil {
+			return err
+		}
+		return opVerify(vm)
+	}
+}
+
+func op2Drop(vm *Engine) error {
+	if len(vm.stack) < 2 {
+		return ErrStackUnderflow
+	}
+	vm.stack = vm.stack[:len(vm.stack)-2]
+	return nil
+}
+
+func op2Dup(vm *Engine) error {
+	if len(vm.stack) < 2 {
+		return ErrStackUnderflow
+	}
+	vm.stack = append(vm.stack, vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1])
+	return nil
+}
+
+func opDepth(vm *Engine) error {
+	vm.stack.push(scriptNum(len(vm.stack)).Bytes())
+	return nil
+}
+
+func opDrop(vm *Engine) error {
+	_, err := vm.stack.pop()
+	return err
+}
+
+func opDup(vm *Engine) error {
+	v, err := vm.stack.peek(0)
+	if err != nil {
+		return err
+	}
+	vm.stack.push(v)
+	return nil
+}
+
+func opNip(vm *Engine) error {
+	top, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	_, err = vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	vm.stack.push(top)
+	return nil
+}
+
+func opOver(vm *Engine) error {
+	v, err := vm.stack.peek(1)
+	if err != nil {
+		return err
+	}
+	vm.stack.push(v)
+	return nil
+}
+
+func opSwap(vm *Engine) error {
+	if len(vm.stack) < 2 {
+		return ErrStackUnderflow
+	}
+	n := len(vm.stack)
+	vm.stack[n-1], vm.stack[n-2] = vm.stack[n-2], vm.stack[n-1]
+	return nil
+}
+
+func opSize(vm *Engine) error {
+	v, err := vm.stack.peek(0)
+	if err != nil {
+		return err
+	}
+	vm.stack.push(scriptNum(len(v)).Bytes())
+	return nil
+}
+
+func opEqual(vm *Engine) error {
+	a, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	b, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	vm.stack.push(fromBool(bytes.Equal(a, b)))
+	return nil
+}
+
+func boolNum(v bool) scriptNum {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func unaryNum(f func(a scriptNum) scriptNum) func(vm *Engine) error {
+	return func(vm *Engine) error {
+		a, err := vm.stack.popInt()
+		if err != nil {
+			return err
+		}
+		vm.stack.push(f(a).Bytes())
+		return nil
+	}
+}
+
+// binaryNum pops b then a and pushes f(a, b).
+func binaryNum(f func(a, b scriptNum) scriptNum) func(vm *Engine) error {
+	return func(vm *Engine) error {
+		b, err := vm.stack.popInt()
+		if err != nil {
+			return err
+		}
+		a, err := vm.stack.popInt()
+		if err != nil {
+			return err
+		}
+		vm.stack.push(f(a, b).Bytes())
+		return nil
+	}
+}
+
+func hashOp(hash func(b []byte) []byte) func(vm *Engine) error {
+	return func(vm *Engine) error {
+		v, err := vm.stack.pop()
+		if err != nil {
+			return err
+		}
+		vm.stack.push(hash(v))
+		return nil
+	}
+}
+
+// opCheckSig pops the public key then the signature, and pushes whether the signature is valid.
+func opCheckSig(vm *Engine) error {
+	pubKey, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	sig, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	vm.stack.push(fromBool(vm.checkSig(sig, pubKey)))
+	return nil
+}
+
+// opCheckMultiSig pops the key count n, the n public keys, the signature count m, the m signatures and an
+// empty dummy element. It pushes whether the signatures are valid for m of the keys, in the order of the keys.
+func opCheckMultiSig(vm *Engine) error {
+	n, err := vm.stack.popInt()
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > MaxPubKeysPerMultiSig {
+		return ErrInvalidPubKeyCount
+	}
+	// every key counts as an operation
+	vm.numOps += int(n)
+	if vm.numOps > MaxOpsPerScript {
+		return ErrTooManyOps
+	}
+	pubKeys, err := vm.stack.popN(int(n))
+	if err != nil {
+		return err
+	}
+	m, err := vm.stack.popInt()
+	if err != nil {
+		return err
+	}
+	if m < 0 || m > n {
+		return ErrInvalidSigCount
+	}
+	sigs, err := vm.stack.popN(int(m))
+	if err != nil {
+		return err
+	}
+	dummy, err := vm.stack.pop()
+	if err != nil {
+		return err
+	}
+	if len(dummy) != 0 {
+		return ErrSigNullDummy
+	}
+
+	keyIdx := 0
+	valid := true
+	for _, sig := range sigs {
+		for keyIdx < len(pubKeys) && !vm.checkSig(sig, pubKeys[keyIdx]) {
+			keyIdx++
+		}
+		if keyIdx == len(pubKeys) {
+			valid = false
+			break
+		}
+		keyIdx++
+	}
+	vm.stack.push(fromBool(valid))
+	return nil
+}
+
+// checkLock fails unless check accepts the lock on top of the stack, which is left there. Lock times take 5
+// bytes, times past 2038 do not fit 4.
+func checkLock(check func(c Checker, n int64) bool) func(vm *Engine) error {
+	return func(vm *Engine) error {
+		v, err := vm.stack.peek(0)
+		if err != nil {
+			return err
+		}
+		n, err := makeScriptNum(v, 5)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return ErrNegativeLockTime
+		}
+		if vm.checker == nil || !check(vm.checker, int64(n)) {
+			return ErrUnsatisfiedLockTime
+		}
+		return nil
+	}
+}
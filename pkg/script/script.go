@@ -0,0 +1,188 @@
+// Package script is a small stack based language in the spirit of Bitcoin's: a locking script on an output
+// tells what an unlocking script on the input spending it has to provide.
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The limits of the engine.
+const (
+	MaxScriptSize        = 10000
+	MaxScriptElementSize = 520
+	MaxOpsPerScript      = 201
+	MaxStackSize         = 1000
+
+	MaxPubKeysPerMultiSig = 20
+)
+
+var (
+	ErrMalformedPush   = errors.New("script push exceeds the script")
+	ErrScriptTooLong   = errors.New("script is too long")
+	ErrElementTooLarge = errors.New("script element is too large")
+)
+
+// parsedOp is an opcode with the data it pushes.
+type parsedOp struct {
+	op   byte
+	data []byte
+}
+
+// parseScript splits script into its opcodes, on a malformed push the opcodes before it are returned with the error.
+func parseScript(script []byte) ([]parsedOp, error) {
+	if len(script) > MaxScriptSize {
+		return nil, ErrScriptTooLong
+	}
+	ops := make([]parsedOp, 0, len(script))
+	for idx := 0; idx < len(script); {
+		op := script[idx]
+		idx++
+
+		var size int
+		switch {
+		case op >= OpData1 && op <= OpData75:
+			size = int(op)
+		case op == OpPushData1:
+			if idx+1 > len(script) {
+				return ops, ErrMalformedPush
+			}
+			size = int(script[idx])
+			idx++
+		case op == OpPushData2:
+			if idx+2 > len(script) {
+				return ops, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint16(script[idx:]))
+			idx += 2
+		case op == OpPushData4:
+			if idx+4 > len(script) {
+				return ops, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint32(script[idx:]))
+			idx += 4
+		default:
+			ops = append(ops, parsedOp{op: op})
+			continue
+		}
+		if size < 0 || size > len(script)-idx {
+			return ops, ErrMalformedPush
+		}
+		ops = append(ops, parsedOp{op: op, data: script[idx : idx+size]})
+		idx += size
+	}
+	return ops, nil
+}
+
+// IsPushOnly tells if script only pushes data, it is false for malformed scripts.
+func IsPushOnly(script []byte) bool {
+	ops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+	for _, pop := range ops {
+		if !isPush(pop.op) {
+			return false
+		}
+	}
+	return true
+}
+
+// PushedData returns the data pushed by script, not counting the small integers.
+func PushedData(script []byte) ([][]byte, error) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	data := make([][]byte, 0, len(ops))
+	for _, pop := range ops {
+		if pop.data != nil {
+			data = append(data, pop.data)
+		}
+	}
+	return data, nil
+}
+
+// Disasm returns script in a readable form, the pushed data in hex.
+func Disasm(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return "[error: " + err.Error() + "]"
+	}
+	words := make([]string, 0, len(ops))
+	for _, pop := range ops {
+		if pop.data != nil || (pop.op >= OpData1 && pop.op <= OpPushData4) {
+			words = append(words, hex.EncodeToString(pop.data))
+			continue
+		}
+		words = append(words, OpcodeName(pop.op))
+	}
+	return strings.Join(words, " ")
+}
+
+// Builder assembles scripts, the first error sticks and is returned by Script.
+type Builder struct {
+	script []byte
+	err    error
+}
+
+func NewBuilder() *Builder {
+	return &Builder{script: make([]byte, 0, 32)}
+}
+
+// AddOp appends the opcode op.
+func (b *Builder) AddOp(op byte) *Builder {
+	b.script = append(b.script, op)
+	return b
+}
+
+// AddData appends the shortest push of data.
+func (b *Builder) AddData(data []byte) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(data) > MaxScriptElementSize {
+		b.err = fmt.Errorf("%w: %d bytes", ErrElementTooLarge, len(data))
+		return b
+	}
+	switch size := len(data); {
+	case size == 0:
+		b.script = append(b.script, Op0)
+	case size <= OpData75:
+		b.script = append(b.script, byte(size))
+	case size <= 0xff:
+		b.script = append(b.script, OpPushData1, byte(size))
+	default:
+		b.script = append(b.script, OpPushData2, 0, 0)
+		binary.LittleEndian.PutUint16(b.script[len(b.script)-2:], uint16(size))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt64 appends the push of n, the small integers with their opcodes.
+func (b *Builder) AddInt64(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n == -1:
+		return b.AddOp(Op1Negate)
+	case n >= 1 && n <= 16:
+		return b.AddOp(byte(Op1 - 1 + n))
+	}
+	return b.AddData(scriptNum(n).Bytes())
+}
+
+// Script returns the assembled script.
+func (b *Builder) Script() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.script) > MaxScriptSize {
+		return nil, ErrScriptTooLong
+	}
+	return b.script, nil
+}
@@ -0,0 +1,47 @@
+package script
+
+// GetSigOpCount returns the signature operations of script as counted without knowing the keys a multisig
+// checks, every OP_CHECKMULTISIG counts MaxPubKeysPerMultiSig. A malformed script counts up to the bad push.
+func GetSigOpCount(script []byte) int {
+	ops, _ := parseScript(script)
+	return countSigOps(ops, false)
+}
+
+// GetPreciseSigOpCount returns the signature operations the redeem script, the last push of sigScript, runs
+// when pkScript is pay to script hash, a multisig counts the keys pushed before it. It is 0 for other scripts.
+func GetPreciseSigOpCount(sigScript, pkScript []byte) int {
+	pkOps, err := parseScript(pkScript)
+	if err != nil || !isScriptHash(pkOps) {
+		return 0
+	}
+	sigOps, err := parseScript(sigScript)
+	if err != nil || len(sigOps) == 0 {
+		return 0
+	}
+	for _, pop := range sigOps {
+		if !isPush(pop.op) {
+			return 0
+		}
+	}
+	redeemOps, _ := parseScript(sigOps[len(sigOps)-1].data)
+	return countSigOps(redeemOps, true)
+}
+
+func countSigOps(ops []parsedOp, precise bool) int {
+	count := 0
+	var lastOp byte
+	for _, pop := range ops {
+		switch pop.op {
+		case OpCheckSig, OpCheckSigVerify:
+			count++
+		case OpCheckMultiSig, OpCheckMultiSigVerify:
+			if n := smallInt(lastOp); precise && n > 0 {
+				count += n
+			} else {
+				count += MaxPubKeysPerMultiSig
+			}
+		}
+		lastOp = pop.op
+	}
+	return count
+}
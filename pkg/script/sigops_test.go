@@ -0,0 +1,49 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSigOpCount(t *testing.T) {
+	_, pubKey := utils.NewKeyPair()
+	pkhScript, err := PayToPubKeyHashScript(utils.HashPubKey(pubKey))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, GetSigOpCount(pkhScript))
+
+	multiSig, err := MultiSigScript(1, [][]byte{pubKey, pubKey})
+	assert.Nil(t, err)
+	assert.Equal(t, MaxPubKeysPerMultiSig, GetSigOpCount(multiSig))
+
+	checks := build(t, NewBuilder().AddOp(OpCheckSigVerify).AddOp(OpCheckSig).AddOp(OpCheckMultiSigVerify))
+	assert.Equal(t, 2+MaxPubKeysPerMultiSig, GetSigOpCount(checks))
+
+	// pushed bytes are not opcodes, a malformed script counts up to the bad push
+	assert.Equal(t, 0, GetSigOpCount(build(t, NewBuilder().AddData([]byte{OpCheckSig, OpCheckSig}))))
+	assert.Equal(t, 2, GetSigOpCount([]byte{OpCheckSig, OpCheckSig, OpPushData1}))
+}
+
+func TestGetPreciseSigOpCount(t *testing.T) {
+	_, pubKey := utils.NewKeyPair()
+	redeem, err := MultiSigScript(2, [][]byte{pubKey, pubKey, pubKey})
+	assert.Nil(t, err)
+	p2sh, err := PayToScriptHashScript(utils.HashPubKey(redeem))
+	assert.Nil(t, err)
+	sigScript := build(t, NewBuilder().AddOp(Op0).AddData([]byte{1}).AddData([]byte{2}).AddData(redeem))
+	assert.Equal(t, 3, GetPreciseSigOpCount(sigScript, p2sh))
+	assert.Equal(t, 0, GetSigOpCount(p2sh))
+
+	// not pay to script hash, or an unlocking script which is not push only
+	pkhScript, err := PayToPubKeyHashScript(utils.HashPubKey(pubKey))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, GetPreciseSigOpCount(sigScript, pkhScript))
+	notPushOnly := build(t, NewBuilder().AddOp(OpDup).AddData(redeem))
+	assert.Equal(t, 0, GetPreciseSigOpCount(notPushOnly, p2sh))
+	assert.Equal(t, 0, GetPreciseSigOpCount(nil, p2sh))
+
+	// a multisig without the count of keys before it counts the most keys
+	unknown := build(t, NewBuilder().AddData(build(t, NewBuilder().AddOp(OpCheckSig).AddOp(OpCheckMultiSig))))
+	assert.Equal(t, 1+MaxPubKeysPerMultiSig, GetPreciseSigOpCount(unknown, p2sh))
+}
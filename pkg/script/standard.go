@@ -0,0 +1,221 @@
+package script
+
+import (
+	"errors"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+// MaxDataCarrierSize is the most data a data carrier script holds.
+const MaxDataCarrierSize = 80
+
+const pubKeyHashLen = 20
+
+var ErrDataTooLarge = errors.New("data carrier is too large")
+
+// Class is the kind of a standard locking script.
+type Class byte
+
+const (
+	NonStandardTy Class = iota
+	PubKeyHashTy
+	PubKeyTy
+	NullDataTy
+	MultiSigTy
+	ScriptHashTy
+)
+
+var classNames = map[Class]string{
+	NonStandardTy: "nonstandard",
+	PubKeyHashTy:  "pubkeyhash",
+	PubKeyTy:      "pubkey",
+	NullDataTy:    "nulldata",
+	MultiSigTy:    "multisig",
+	ScriptHashTy:  "scripthash",
+}
+
+func (c Class) String() string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return classNames[NonStandardTy]
+}
+
+// PayToPubKeyHashScript locks to the key hashing to pubKeyHash:
+// OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG, unlocked by <sig> <pubKey>.
+func PayToPubKeyHashScript(pubKeyHash []byte) ([]byte, error) {
+	if len(pubKeyHash) != pubKeyHashLen {
+		return nil, errors.New("invalid public key hash")
+	}
+	return NewBuilder().AddOp(OpDup).AddOp(OpHash160).AddData(pubKeyHash).AddOp(OpEqualVerify).AddOp(OpCheckSig).Script()
+}
+
+// PayToPubKeyScript locks to pubKey: <pubKey> OP_CHECKSIG, unlocked by <sig>.
+func PayToPubKeyScript(pubKey []byte) ([]byte, error) {
+	if _, err := utils.ParsePubKey(pubKey); err != nil {
+		return nil, err
+	}
+	return NewBuilder().AddData(pubKey).AddOp(OpCheckSig).Script()
+}
+
+// MultiSigScript locks to m of pubKeys: <m> <pubKey>... <n> OP_CHECKMULTISIG, unlocked by OP_0 <sig>... with
+// the signatures in the order of their keys.
+func MultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxPubKeysPerMultiSig {
+		return nil, ErrInvalidPubKeyCount
+	}
+	if m <= 0 || m > len(pubKeys) {
+		return nil, ErrInvalidSigCount
+	}
+	b := NewBuilder().AddInt64(int64(m))
+	for _, pubKey := range pubKeys {
+		if _, err := utils.ParsePubKey(pubKey); err != nil {
+			return nil, err
+		}
+		b.AddData(pubKey)
+	}
+	return b.AddInt64(int64(len(pubKeys))).AddOp(OpCheckMultiSig).Script()
+}
+
+// PayToScriptHashScript locks to the redeem script hashing to scriptHash: OP_HASH160 <scriptHash> OP_EQUAL,
+// unlocked by the unlocking data of the redeem script followed by <redeemScript>.
+func PayToScriptHashScript(scriptHash []byte) ([]byte, error) {
+	if len(scriptHash) != pubKeyHashLen {
+		return nil, errors.New("invalid script hash")
+	}
+	return NewBuilder().AddOp(OpHash160).AddData(scriptHash).AddOp(OpEqual).Script()
+}
+
+// NullDataScript carries data in an output nobody can spend: OP_RETURN <data>.
+func NullDataScript(data []byte) ([]byte, error) {
+	if len(data) > MaxDataCarrierSize {
+		return nil, ErrDataTooLarge
+	}
+	return NewBuilder().AddOp(OpReturn).AddData(data).Script()
+}
+
+// GetClass returns the class of the locking script.
+func GetClass(script []byte) Class {
+	ops, err := parseScript(script)
+	if err != nil {
+		return NonStandardTy
+	}
+	switch {
+	case isPubKeyHash(ops):
+		return PubKeyHashTy
+	case isPubKey(ops):
+		return PubKeyTy
+	case isNullData(ops):
+		return NullDataTy
+	case isMultiSig(ops):
+		return MultiSigTy
+	case isScriptHash(ops):
+		return ScriptHashTy
+	}
+	return NonStandardTy
+}
+
+func isPubKeyHash(ops []parsedOp) bool {
+	return len(ops) == 5 && ops[0].op == OpDup && ops[1].op == OpHash160 && ops[2].op == OpData20 &&
+		ops[3].op == OpEqualVerify && ops[4].op == OpCheckSig
+}
+
+func isPubKey(ops []parsedOp) bool {
+	if len(ops) != 2 || ops[1].op != OpCheckSig || ops[0].op < OpData1 || ops[0].op > OpData75 {
+		return false
+	}
+	_, err := utils.ParsePubKey(ops[0].data)
+	return err == nil
+}
+
+func isNullData(ops []parsedOp) bool {
+	if len(ops) == 1 {
+		return ops[0].op == OpReturn
+	}
+	return len(ops) == 2 && ops[0].op == OpReturn && isPush(ops[1].op) && len(ops[1].data) <= MaxDataCarrierSize
+}
+
+// smallInt returns the number pushed by OP_1 to OP_16, -1 for the other opcodes.
+func smallInt(op byte) int {
+	if op < Op1 || op > Op16 {
+		return -1
+	}
+	return int(op-Op1) + 1
+}
+
+func isMultiSig(ops []parsedOp) bool {
+	if len(ops) < 4 || ops[len(ops)-1].op != OpCheckMultiSig {
+		return false
+	}
+	m, n := smallInt(ops[0].op), smallInt(ops[len(ops)-2].op)
+	if m <= 0 || m > n || n != len(ops)-3 {
+		return false
+	}
+	for _, pop := range ops[1 : len(ops)-2] {
+		if pop.op < OpData1 || pop.op > OpData75 {
+			return false
+		}
+		if _, err := utils.ParsePubKey(pop.data); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func isScriptHash(ops []parsedOp) bool {
+	return len(ops) == 3 && ops[0].op == OpHash160 && ops[1].op == OpData20 && ops[2].op == OpEqual
+}
+
+// ExtractPubKeyHash returns the hash of the key a pay to public key hash or pay to public key script locks
+// to, or the hash of the redeem script of a pay to script hash script. It is nil for the other scripts.
+func ExtractPubKeyHash(script []byte) []byte {
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case isPubKeyHash(ops):
+		return ops[2].data
+	case isPubKey(ops):
+		return utils.HashPubKey(ops[0].data)
+	case isScriptHash(ops):
+		return ops[1].data
+	}
+	return nil
+}
+
+// ExtractNullData returns the data a data carrier script holds, nil for a bare OP_RETURN.
+func ExtractNullData(script []byte) ([]byte, error) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	if !isNullData(ops) {
+		return nil, errors.New("not a data carrier script")
+	}
+	if len(ops) == 1 {
+		return nil, nil
+	}
+	return ops[1].data, nil
+}
+
+// ExtractMultiSig returns how many signatures a multisig script needs and its public keys.
+func ExtractMultiSig(script []byte) (int, [][]byte, error) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !isMultiSig(ops) {
+		return 0, nil, errors.New("not a multisig script")
+	}
+	pubKeys := make([][]byte, 0, len(ops)-3)
+	for _, pop := range ops[1 : len(ops)-2] {
+		pubKeys = append(pubKeys, pop.data)
+	}
+	return smallInt(ops[0].op), pubKeys, nil
+}
+
+// IsUnspendable tells if no unlocking script can satisfy script, those outputs are left out of the UTXO set.
+func IsUnspendable(script []byte) bool {
+	return len(script) > MaxScriptSize || (len(script) > 0 && script[0] == OpReturn)
+}
@@ -0,0 +1,57 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClass(t *testing.T) {
+	_, pubKey := utils.NewKeyPair()
+	pubKeyHash := utils.HashPubKey(pubKey)
+
+	pkhScript, err := PayToPubKeyHashScript(pubKeyHash)
+	assert.Nil(t, err)
+	assert.Equal(t, PubKeyHashTy, GetClass(pkhScript))
+	assert.Equal(t, pubKeyHash, ExtractPubKeyHash(pkhScript))
+	assert.Equal(t, "OP_DUP OP_HASH160 "+hex.EncodeToString(pubKeyHash)+" OP_EQUALVERIFY OP_CHECKSIG", Disasm(pkhScript))
+	_, err = PayToPubKeyHashScript(pubKey)
+	assert.NotNil(t, err)
+
+	pkScript, err := PayToPubKeyScript(pubKey)
+	assert.Nil(t, err)
+	assert.Equal(t, PubKeyTy, GetClass(pkScript))
+	assert.Equal(t, pubKeyHash, ExtractPubKeyHash(pkScript))
+	_, err = PayToPubKeyScript([]byte("key"))
+	assert.NotNil(t, err)
+
+	dataScript, err := NullDataScript([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, NullDataTy, GetClass(dataScript))
+	assert.Nil(t, ExtractPubKeyHash(dataScript))
+	assert.True(t, IsUnspendable(dataScript))
+	assert.False(t, IsUnspendable(pkhScript))
+	data, err := PushedData(dataScript)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("hello")}, data)
+	carried, err := ExtractNullData(dataScript)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), carried)
+	carried, err = ExtractNullData([]byte{OpReturn})
+	assert.Nil(t, err)
+	assert.Nil(t, carried)
+	_, err = ExtractNullData(pkhScript)
+	assert.NotNil(t, err)
+	tooLarge := append([]byte{OpReturn, OpPushData1, MaxDataCarrierSize + 1}, make([]byte, MaxDataCarrierSize+1)...)
+	assert.Equal(t, NonStandardTy, GetClass(tooLarge))
+	assert.True(t, IsUnspendable(tooLarge))
+	_, err = NullDataScript(make([]byte, MaxDataCarrierSize+1))
+	assert.ErrorIs(t, err, ErrDataTooLarge)
+	assert.Equal(t, NullDataTy, GetClass([]byte{OpReturn}))
+
+	assert.Equal(t, NonStandardTy, GetClass([]byte{OpTrue}))
+	assert.Equal(t, NonStandardTy, GetClass([]byte{OpData20}))
+	assert.Equal(t, "nonstandard", GetClass(nil).String())
+}
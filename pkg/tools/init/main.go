@@ -1,9 +1,6 @@
 package main
 
 import (
-	"bytes"
-	"crypto/elliptic"
-	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -11,6 +8,7 @@ import (
 	"github.com/jiuzhou-zhao/blockchain.go/internal/blockchain"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/chainhash"
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/wif"
 )
 
 const (
@@ -19,32 +17,21 @@ const (
 )
 
 func main() {
-	gob.Register(elliptic.P256())
-
 	priKey, pubKey := utils.NewKeyPair()
-
-	var priKeyBuf bytes.Buffer
-	encoder := gob.NewEncoder(&priKeyBuf)
-	err := encoder.Encode(priKey)
-	if err != nil {
-		log.Panic(err)
-	}
-	var pubKeyBuf bytes.Buffer
-	encoder = gob.NewEncoder(&pubKeyBuf)
-	err = encoder.Encode(pubKey)
+	key, err := wif.NewWIF(&priKey, wif.MainNetVersion, true)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	address := utils.Pubkey2Address(pubKeyBuf.Bytes(), version)
+	address := utils.Pubkey2Address(pubKey, version)
 
 	genesis := blockchain.MineBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(address, genesisCoinbaseData)},
 		chainhash.ZeroHash)
 	genesis.Height = 1
 
 	fmt.Println("-------------------------")
-	fmt.Printf("wallet private key: %s\n", hex.EncodeToString(priKeyBuf.Bytes()))
-	fmt.Printf("wallet public key: %s\n", hex.EncodeToString(pubKeyBuf.Bytes()))
+	fmt.Printf("wallet private key: %s\n", key.String())
+	fmt.Printf("wallet public key: %s\n", hex.EncodeToString(pubKey))
 	fmt.Printf("wallet address: %s\n", address)
 	fmt.Printf("genesis block hash: %s\n", hex.EncodeToString(genesis.Hash[:]))
 	fmt.Printf("genesis block data: %s\n", hex.EncodeToString(genesis.Serialize()))
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	// CompactSignatureLen is the length of a compact signature: the header byte, then r and s on 32 bytes each.
+	CompactSignatureLen = 1 + 2*compactScalarLen
+
+	compactScalarLen = 32
+	compactMagic     = byte(27)
+)
+
+var (
+	ErrMalformedSignature = errors.New("malformed signature")
+	ErrRecoverPubKey      = errors.New("public key can not be recovered from the signature")
+)
+
+// SignCompact signs hash with priKey, the header byte of the signature tells which of the candidate public keys
+// signed, so RecoverCompact gets it back from the signature and hash alone.
+func SignCompact(priKey *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, priKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, CompactSignatureLen)
+	r.FillBytes(sig[1 : 1+compactScalarLen])
+	normalizeS(priKey.Curve, s).FillBytes(sig[1+compactScalarLen:])
+	for recID := byte(0); recID < 4; recID++ {
+		sig[0] = compactMagic + recID
+		pubKey, errRecover := RecoverCompact(sig, hash)
+		if errRecover == nil && pubKey.X.Cmp(priKey.X) == 0 && pubKey.Y.Cmp(priKey.Y) == 0 {
+			return sig, nil
+		}
+	}
+	return nil, ErrRecoverPubKey
+}
+
+// RecoverCompact returns the public key which made the compact signature sig of hash.
+func RecoverCompact(sig, hash []byte) (*ecdsa.PublicKey, error) {
+	if len(sig) != CompactSignatureLen || sig[0] < compactMagic || sig[0] >= compactMagic+4 {
+		return nil, ErrMalformedSignature
+	}
+	curve := elliptic.P256()
+	params := curve.Params()
+	recID := uint(sig[0] - compactMagic)
+	r := new(big.Int).SetBytes(sig[1 : 1+compactScalarLen])
+	s := new(big.Int).SetBytes(sig[1+compactScalarLen:])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(params.N) >= 0 || s.Cmp(params.N) >= 0 {
+		return nil, ErrMalformedSignature
+	}
+
+	// R is the point of the signing nonce, its x is r or r+N and recID tells the parity of its y
+	x := new(big.Int).Set(r)
+	if recID&2 != 0 {
+		x.Add(x, params.N)
+	}
+	if x.Cmp(params.P) >= 0 {
+		return nil, ErrRecoverPubKey
+	}
+	y := curveY(params, x)
+	if y == nil {
+		return nil, ErrRecoverPubKey
+	}
+	if y.Bit(0) != recID&1 {
+		y.Sub(params.P, y)
+	}
+
+	// Q = r^-1 (sR - eG)
+	e := hashToInt(hash, params.N)
+	e.Neg(e).Mod(e, params.N)
+	sRx, sRy := curve.ScalarMult(x, y, s.Bytes())
+	eGx, eGy := curve.ScalarBaseMult(e.Bytes())
+	qx, qy := curve.Add(sRx, sRy, eGx, eGy)
+	rInv := new(big.Int).ModInverse(r, params.N)
+	qx, qy = curve.ScalarMult(qx, qy, rInv.Bytes())
+
+	pubKey := &ecdsa.PublicKey{Curve: curve, X: qx, Y: qy}
+	if !curve.IsOnCurve(qx, qy) || !ecdsa.Verify(pubKey, hash, r, s) {
+		return nil, ErrRecoverPubKey
+	}
+	return pubKey, nil
+}
+
+// curveY returns a y of the point at x, nil when there is none.
+func curveY(params *elliptic.CurveParams, x *big.Int) *big.Int {
+	// y² = x³ - 3x + b
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y2.Sub(y2, threeX)
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+	return new(big.Int).ModSqrt(y2, params.P)
+}
+
+// hashToInt converts hash to an integer the way ecdsa does, keeping its leftmost bits up to the size of n.
+func hashToInt(hash []byte, n *big.Int) *big.Int {
+	orderBits := n.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+	e := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - orderBits; excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+	return e
+}
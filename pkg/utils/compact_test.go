@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignCompact(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		priKey, pubKey := NewKeyPair()
+		hash := sha256.Sum256([]byte{byte(i)})
+
+		sig, err := SignCompact(&priKey, hash[:])
+		assert.Nil(t, err)
+		assert.Len(t, sig, CompactSignatureLen)
+		recovered, err := RecoverCompact(sig, hash[:])
+		assert.Nil(t, err)
+		assert.Equal(t, pubKey, MarshalPubKey(recovered))
+		r, s := new(big.Int).SetBytes(sig[1:33]), new(big.Int).SetBytes(sig[33:])
+		assert.True(t, VerifySign(SerializeSignature(r, s), pubKey, hash[:]))
+
+		// another hash recovers another key, if any
+		other := sha256.Sum256([]byte{byte(i), 1})
+		recovered, err = RecoverCompact(sig, other[:])
+		if err == nil {
+			assert.NotEqual(t, pubKey, MarshalPubKey(recovered))
+		}
+	}
+}
+
+func TestRecoverCompact_Malformed(t *testing.T) {
+	priKey, _ := NewKeyPair()
+	hash := sha256.Sum256([]byte("message"))
+	sig, err := SignCompact(&priKey, hash[:])
+	assert.Nil(t, err)
+
+	_, err = RecoverCompact(sig[1:], hash[:])
+	assert.ErrorIs(t, err, ErrMalformedSignature)
+	bad := append([]byte{}, sig...)
+	bad[0] = compactMagic + 4
+	_, err = RecoverCompact(bad, hash[:])
+	assert.ErrorIs(t, err, ErrMalformedSignature)
+	bad = make([]byte, CompactSignatureLen)
+	bad[0] = compactMagic
+	_, err = RecoverCompact(bad, hash[:])
+	assert.ErrorIs(t, err, ErrMalformedSignature)
+}
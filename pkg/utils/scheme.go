@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcecdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// The public keys of the schemes other than P256 start with the tag of their scheme, so a key, and the
+// address hashed from it, tells how its signatures are checked. The P256 keys are untagged SEC1 keys, they
+// start with 0x02, 0x03 or 0x04, or the legacy raw coordinates told by their length.
+const (
+	secp256k1KeyTag = byte(0x10)
+	schnorrKeyTag   = byte(0x11)
+
+	scalarLen        = 32
+	schnorrPubKeyLen = 32
+
+	// LegacyPubKeyLen is the length of the P256 keys of the first wallets, x then y. The coordinates were
+	// not padded so a few keys are shorter.
+	LegacyPubKeyLen = 64
+	minLegacyKeyLen = 1 + PubKeyBytesLenCompressed + 1
+)
+
+var (
+	ErrUnknownScheme     = errors.New("unknown signature scheme")
+	ErrUnsupportedScheme = errors.New("signature scheme not supported")
+)
+
+// Scheme is a signature scheme: a curve, the encoding of its public keys and the signatures.
+type Scheme interface {
+	Name() string
+	Curve() elliptic.Curve
+	// MarshalPubKey encodes pubKey tagged with the scheme.
+	MarshalPubKey(pubKey *ecdsa.PublicKey) []byte
+	// ParsePubKey decodes a key MarshalPubKey encoded, and checks it is on the curve.
+	ParsePubKey(key []byte) (*ecdsa.PublicKey, error)
+	Sign(priKey *ecdsa.PrivateKey, d []byte) ([]byte, error)
+	Verify(signature []byte, pubKey *ecdsa.PublicKey, d []byte) bool
+}
+
+var (
+	// SchemeP256 is ECDSA over P256 with DER signatures, the scheme of the keys made before the others.
+	SchemeP256 Scheme = p256Scheme{}
+	// SchemeSecp256k1 is ECDSA over secp256k1 with DER signatures, its keys are the tag then SEC1 compressed.
+	SchemeSecp256k1 Scheme = secp256k1Scheme{}
+	// SchemeSchnorr is BIP340 over secp256k1 with 64 bytes signatures, its keys are the tag then x-only.
+	SchemeSchnorr Scheme = schnorrScheme{}
+)
+
+// Schemes returns the supported schemes.
+func Schemes() []Scheme {
+	return []Scheme{SchemeP256, SchemeSecp256k1, SchemeSchnorr}
+}
+
+// ParseScheme returns the scheme of the name, case insensitive.
+func ParseScheme(name string) (Scheme, error) {
+	for _, scheme := range Schemes() {
+		if strings.EqualFold(scheme.Name(), name) {
+			return scheme, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownScheme, name)
+}
+
+// SchemeOf returns the scheme of the public key from its first byte.
+func SchemeOf(key []byte) (Scheme, error) {
+	if len(key) == 0 {
+		return nil, ErrInvalidPubKey
+	}
+	if IsLegacyPubKey(key) {
+		return SchemeP256, nil
+	}
+	switch key[0] {
+	case 0x02, 0x03, 0x04:
+		return SchemeP256, nil
+	case secp256k1KeyTag:
+		return SchemeSecp256k1, nil
+	case schnorrKeyTag:
+		return SchemeSchnorr, nil
+	}
+	return nil, ErrInvalidPubKey
+}
+
+// NewSchemeKeyPair generates a key of scheme and returns it with its encoded public key.
+func NewSchemeKeyPair(scheme Scheme) (ecdsa.PrivateKey, []byte, error) {
+	var private *ecdsa.PrivateKey
+	if scheme.Curve() == btcec.S256() {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			return ecdsa.PrivateKey{}, nil, fmt.Errorf("%w", err)
+		}
+		private = key.ToECDSA()
+	} else {
+		key, err := ecdsa.GenerateKey(scheme.Curve(), rand.Reader)
+		if err != nil {
+			return ecdsa.PrivateKey{}, nil, fmt.Errorf("%w", err)
+		}
+		private = key
+	}
+	return *private, scheme.MarshalPubKey(&private.PublicKey), nil
+}
+
+// PrivateKeyFromScalar rebuilds the private key of the scalar d on curve.
+func PrivateKeyFromScalar(curve elliptic.Curve, d []byte) (ecdsa.PrivateKey, error) {
+	var key ecdsa.PrivateKey
+	key.Curve = curve
+	key.D = new(big.Int).SetBytes(d)
+	if key.D.Sign() == 0 || key.D.Cmp(curve.Params().N) >= 0 {
+		return ecdsa.PrivateKey{}, errors.New("invalid private key")
+	}
+	if curve == btcec.S256() {
+		return *btcecPrivKey(&key).ToECDSA(), nil
+	}
+	key.X, key.Y = curve.ScalarBaseMult(d)
+	return key, nil
+}
+
+// btcecPrivKey and btcecPubKey convert the secp256k1 keys for btcec, which does the curve arithmetic in
+// constant time.
+func btcecPrivKey(priKey *ecdsa.PrivateKey) *btcec.PrivateKey {
+	key, _ := btcec.PrivKeyFromBytes(priKey.D.FillBytes(make([]byte, scalarLen)))
+	return key
+}
+
+func btcecPubKey(pubKey *ecdsa.PublicKey) *btcec.PublicKey {
+	var x, y btcec.FieldVal
+	x.SetByteSlice(pubKey.X.Bytes())
+	y.SetByteSlice(pubKey.Y.Bytes())
+	return btcec.NewPublicKey(&x, &y)
+}
+
+type p256Scheme struct{}
+
+func (p256Scheme) Name() string { return "p256" }
+
+func (p256Scheme) Curve() elliptic.Curve { return elliptic.P256() }
+
+func (p256Scheme) MarshalPubKey(pubKey *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(elliptic.P256(), pubKey.X, pubKey.Y)
+}
+
+// ParsePubKey decodes a SEC1 key, compressed or uncompressed, or a legacy key.
+func (p256Scheme) ParsePubKey(key []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+	switch {
+	case len(key) == PubKeyBytesLenCompressed && (key[0] == 0x02 || key[0] == 0x03):
+		x, y = elliptic.UnmarshalCompressed(curve, key)
+	case len(key) == PubKeyBytesLenUncompressed && key[0] == 0x04:
+		x, y = elliptic.Unmarshal(curve, key)
+	case IsLegacyPubKey(key):
+		x, y = unmarshalLegacyPubKey(curve, key)
+	}
+	if x == nil {
+		return nil, ErrInvalidPubKey
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// IsLegacyPubKey reports whether key has the length of the raw coordinates of a legacy P256 key.
+func IsLegacyPubKey(key []byte) bool {
+	return len(key) >= minLegacyKeyLen && len(key) <= LegacyPubKeyLen
+}
+
+// MarshalLegacyPubKey encodes the P256 key the way the first wallets did, the unpadded x then y.
+func MarshalLegacyPubKey(pubKey *ecdsa.PublicKey) []byte {
+	return append(pubKey.X.Bytes(), pubKey.Y.Bytes()...)
+}
+
+// unmarshalLegacyPubKey splits key where both coordinates have no leading zero and make a point of the curve.
+func unmarshalLegacyPubKey(curve elliptic.Curve, key []byte) (x, y *big.Int) {
+	if key[0] == 0 {
+		return nil, nil
+	}
+	for xLen := len(key) - LegacyPubKeyLen/2; xLen <= LegacyPubKeyLen/2; xLen++ {
+		if key[xLen] == 0 {
+			continue
+		}
+		x, y = new(big.Int).SetBytes(key[:xLen]), new(big.Int).SetBytes(key[xLen:])
+		if curve.IsOnCurve(x, y) {
+			return x, y
+		}
+	}
+	return nil, nil
+}
+
+func (p256Scheme) Sign(priKey *ecdsa.PrivateKey, d []byte) ([]byte, error) {
+	return Sign(priKey, d)
+}
+
+func (p256Scheme) Verify(signature []byte, pubKey *ecdsa.PublicKey, d []byte) bool {
+	return verifyECDSA(signature, pubKey, d)
+}
+
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) Name() string { return "secp256k1" }
+
+func (secp256k1Scheme) Curve() elliptic.Curve { return btcec.S256() }
+
+func (secp256k1Scheme) MarshalPubKey(pubKey *ecdsa.PublicKey) []byte {
+	return append([]byte{secp256k1KeyTag}, btcecPubKey(pubKey).SerializeCompressed()...)
+}
+
+func (secp256k1Scheme) ParsePubKey(key []byte) (*ecdsa.PublicKey, error) {
+	if len(key) != 1+PubKeyBytesLenCompressed || key[0] != secp256k1KeyTag {
+		return nil, ErrInvalidPubKey
+	}
+	pubKey, err := btcec.ParsePubKey(key[1:])
+	if err != nil {
+		return nil, ErrInvalidPubKey
+	}
+	return pubKey.ToECDSA(), nil
+}
+
+// Sign signs with the RFC6979 nonce, the signature has a low S.
+func (secp256k1Scheme) Sign(priKey *ecdsa.PrivateKey, d []byte) ([]byte, error) {
+	return btcecdsa.Sign(btcecPrivKey(priKey), d).Serialize(), nil
+}
+
+func (secp256k1Scheme) Verify(signature []byte, pubKey *ecdsa.PublicKey, d []byte) bool {
+	r, s, err := ParseSignature(signature)
+	if err != nil || s.Cmp(halfOrder(btcec.S256())) > 0 {
+		return false
+	}
+	var rs, ss btcec.ModNScalar
+	if rs.SetByteSlice(r.Bytes()) || ss.SetByteSlice(s.Bytes()) {
+		return false
+	}
+	return btcecdsa.NewSignature(&rs, &ss).Verify(d, btcecPubKey(pubKey))
+}
+
+type schnorrScheme struct{}
+
+func (schnorrScheme) Name() string { return "schnorr" }
+
+func (schnorrScheme) Curve() elliptic.Curve { return btcec.S256() }
+
+func (schnorrScheme) MarshalPubKey(pubKey *ecdsa.PublicKey) []byte {
+	return append([]byte{schnorrKeyTag}, pubKey.X.FillBytes(make([]byte, schnorrPubKeyLen))...)
+}
+
+// ParsePubKey decodes an x-only key to the point of even y.
+func (schnorrScheme) ParsePubKey(key []byte) (*ecdsa.PublicKey, error) {
+	if len(key) != 1+schnorrPubKeyLen || key[0] != schnorrKeyTag {
+		return nil, ErrInvalidPubKey
+	}
+	pubKey, err := schnorr.ParsePubKey(key[1:])
+	if err != nil {
+		return nil, ErrInvalidPubKey
+	}
+	return pubKey.ToECDSA(), nil
+}
+
+func (schnorrScheme) Sign(priKey *ecdsa.PrivateKey, d []byte) ([]byte, error) {
+	var aux [32]byte
+	if _, err := rand.Read(aux[:]); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return signSchnorr(priKey, d, aux)
+}
+
+// signSchnorr signs d the BIP340 way with the auxiliary randomness aux.
+func signSchnorr(priKey *ecdsa.PrivateKey, d []byte, aux [32]byte) ([]byte, error) {
+	sig, err := schnorr.Sign(btcecPrivKey(priKey), d, schnorr.CustomNonce(aux))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return sig.Serialize(), nil
+}
+
+func (schnorrScheme) Verify(signature []byte, pubKey *ecdsa.PublicKey, d []byte) bool {
+	sig, err := schnorr.ParseSignature(signature)
+	if err != nil {
+		return false
+	}
+	key, err := schnorr.ParsePubKey(pubKey.X.FillBytes(make([]byte, schnorrPubKeyLen)))
+	if err != nil {
+		return false
+	}
+	return sig.Verify(d, key)
+}
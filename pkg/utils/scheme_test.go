@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheme_SignAndVerify(t *testing.T) {
+	hash := sha256.Sum256([]byte("scheme"))
+	for _, scheme := range Schemes() {
+		for i := 0; i < 8; i++ {
+			priKey, pubKey, err := NewSchemeKeyPair(scheme)
+			assert.Nil(t, err)
+			found, err := SchemeOf(pubKey)
+			assert.Nil(t, err)
+			assert.Equal(t, scheme, found, scheme.Name())
+
+			parsed, err := ParsePubKey(pubKey)
+			assert.Nil(t, err)
+			assert.Equal(t, priKey.X, parsed.X)
+			assert.Equal(t, pubKey, scheme.MarshalPubKey(parsed))
+
+			sig, err := scheme.Sign(&priKey, hash[:])
+			assert.Nil(t, err)
+			assert.True(t, VerifySign(sig, pubKey, hash[:]), scheme.Name())
+			tampered := append([]byte{}, hash[:]...)
+			tampered[0] ^= 1
+			assert.False(t, VerifySign(sig, pubKey, tampered), scheme.Name())
+		}
+	}
+}
+
+// a signature of a scheme does not pass for another one of the same key
+func TestScheme_CrossVerify(t *testing.T) {
+	hash := sha256.Sum256([]byte("cross"))
+	priKey, ecdsaKey, err := NewSchemeKeyPair(SchemeSecp256k1)
+	assert.Nil(t, err)
+	schnorrKey := SchemeSchnorr.MarshalPubKey(&priKey.PublicKey)
+
+	ecdsaSig, err := SchemeSecp256k1.Sign(&priKey, hash[:])
+	assert.Nil(t, err)
+	schnorrSig, err := SchemeSchnorr.Sign(&priKey, hash[:])
+	assert.Nil(t, err)
+	assert.True(t, VerifySign(ecdsaSig, ecdsaKey, hash[:]))
+	assert.True(t, VerifySign(schnorrSig, schnorrKey, hash[:]))
+	assert.False(t, VerifySign(schnorrSig, ecdsaKey, hash[:]))
+	assert.False(t, VerifySign(ecdsaSig, schnorrKey, hash[:]))
+
+	// the same scalar on P256 is another key
+	p256Key, err := PrivateKeyFromScalar(elliptic.P256(), priKey.D.Bytes())
+	assert.Nil(t, err)
+	assert.False(t, VerifySign(ecdsaSig, MarshalPubKey(&p256Key.PublicKey), hash[:]))
+}
+
+func TestScheme_HighS(t *testing.T) {
+	priKey, pubKey, err := NewSchemeKeyPair(SchemeSecp256k1)
+	assert.Nil(t, err)
+	hash := sha256.Sum256([]byte("high s"))
+	sig, err := SchemeSecp256k1.Sign(&priKey, hash[:])
+	assert.Nil(t, err)
+	r, s, err := ParseSignature(sig)
+	assert.Nil(t, err)
+	assert.True(t, s.Cmp(halfOrder(btcec.S256())) <= 0)
+
+	highS := new(big.Int).Sub(btcec.S256().N, s)
+	assert.False(t, VerifySign(SerializeSignature(r, highS), pubKey, hash[:]))
+}
+
+func TestParseScheme(t *testing.T) {
+	for _, scheme := range Schemes() {
+		parsed, err := ParseScheme(scheme.Name())
+		assert.Nil(t, err)
+		assert.Equal(t, scheme, parsed)
+	}
+	parsed, err := ParseScheme("Schnorr")
+	assert.Nil(t, err)
+	assert.Equal(t, SchemeSchnorr, parsed)
+	_, err = ParseScheme("ed25519")
+	assert.ErrorIs(t, err, ErrUnknownScheme)
+}
+
+func TestSchemeOf_Invalid(t *testing.T) {
+	_, pubKey, err := NewSchemeKeyPair(SchemeSecp256k1)
+	assert.Nil(t, err)
+	_, schnorrKey, err := NewSchemeKeyPair(SchemeSchnorr)
+	assert.Nil(t, err)
+
+	offCurve := append([]byte{schnorrKeyTag}, make([]byte, 32)...)
+	offCurve[32] = 5
+	for _, key := range [][]byte{nil, {0x12}, pubKey[:33], append(pubKey, 0), schnorrKey[:32], offCurve,
+		append([]byte{secp256k1KeyTag, 0x04}, pubKey[2:]...)} {
+		_, err = ParsePubKey(key)
+		assert.ErrorIs(t, err, ErrInvalidPubKey)
+		assert.False(t, VerifySign([]byte{0x30}, key, make([]byte, 32)))
+	}
+}
+
+// the signing vectors of BIP340
+var schnorrVectors = []struct {
+	sk, pk, aux, msg, sig string
+}{
+	{
+		sk:  "0000000000000000000000000000000000000000000000000000000000000003",
+		pk:  "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		aux: "0000000000000000000000000000000000000000000000000000000000000000",
+		msg: "0000000000000000000000000000000000000000000000000000000000000000",
+		sig: "E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA8215" +
+			"25F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+	},
+	{
+		sk:  "B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+		pk:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		aux: "0000000000000000000000000000000000000000000000000000000000000001",
+		msg: "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+		sig: "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE3341" +
+			"8906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+	},
+	{
+		sk:  "C90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B14E5C9",
+		pk:  "DD308AFEC5777E13121FA72B9CC1B7CC0139715309B086C960E18FD969774EB8",
+		aux: "C87AA53824B4D7AE2EB035A2B5BBBCCC080E76CDC6D1692C4B0B62D798E6D906",
+		msg: "7E2D58D8B3BCDF1ABADEC7829054F90DDA9805AAB56C77333024B9D0A508B75C",
+		sig: "5831AAEED7B44BB74E5EAB94BA9D4294C49BCF2A60728D8B4C200F50DD313C1B" +
+			"AB745879A5AD954A72C45A91C3A51D3C7ADEA98D82F8481E0E1E03674A6F3FB7",
+	},
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.Nil(t, err)
+	return b
+}
+
+func TestSchemeSchnorr_Vectors(t *testing.T) {
+	for _, v := range schnorrVectors {
+		priKey, err := PrivateKeyFromScalar(SchemeSchnorr.Curve(), decodeHex(t, v.sk))
+		assert.Nil(t, err)
+		pubKey := SchemeSchnorr.MarshalPubKey(&priKey.PublicKey)
+		assert.Equal(t, v.pk, strings.ToUpper(hex.EncodeToString(pubKey[1:])))
+
+		var aux [32]byte
+		copy(aux[:], decodeHex(t, v.aux))
+		msg := decodeHex(t, v.msg)
+		sig, err := signSchnorr(&priKey, msg, aux)
+		assert.Nil(t, err)
+		assert.Equal(t, v.sig, strings.ToUpper(hex.EncodeToString(sig)))
+		assert.True(t, VerifySign(sig, pubKey, msg))
+
+		tampered := append([]byte{}, sig...)
+		tampered[63] ^= 1
+		assert.False(t, VerifySign(tampered, pubKey, msg))
+		assert.False(t, VerifySign(sig[:63], pubKey, msg))
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// PubKeyBytesLenCompressed is the length of a SEC1 compressed public key: 0x02 or 0x03 by the parity of y,
+	// then x.
+	PubKeyBytesLenCompressed = 33
+	// PubKeyBytesLenUncompressed is the length of a SEC1 uncompressed public key: 0x04, then x and y.
+	PubKeyBytesLenUncompressed = 65
+
+	// MaxSignatureLen is the length of the longest DER signature, both integers of 33 bytes.
+	MaxSignatureLen = 6 + 2*(compactScalarLen+1)
+	minSignatureLen = 8
+
+	derSequence = byte(0x30)
+	derInteger  = byte(0x02)
+)
+
+var ErrInvalidPubKey = errors.New("invalid public key")
+
+// MarshalPubKey encodes the P256 public key SEC1 compressed, the way addresses are hashed from.
+func MarshalPubKey(pubKey *ecdsa.PublicKey) []byte {
+	return SchemeP256.MarshalPubKey(pubKey)
+}
+
+// ParsePubKey decodes a public key of any scheme, see SchemeOf, and checks it is on the curve.
+func ParsePubKey(key []byte) (*ecdsa.PublicKey, error) {
+	scheme, err := SchemeOf(key)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.ParsePubKey(key)
+}
+
+// Sign signs d with priKey the ECDSA way, the signature is DER encoded with a low S.
+func Sign(priKey *ecdsa.PrivateKey, d []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, priKey, d)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return SerializeSignature(r, normalizeS(priKey.Curve, s)), nil
+}
+
+// VerifySign checks the signature of d by pubKey with the scheme of the key. The ECDSA signatures with a high
+// S, or not strictly DER encoded, fail.
+func VerifySign(signature []byte, pubKey []byte, d []byte) bool {
+	scheme, err := SchemeOf(pubKey)
+	if err != nil {
+		return false
+	}
+	key, err := scheme.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return scheme.Verify(signature, key, d)
+}
+
+// verifyECDSA checks the DER signature of d by key, which has to have a low S.
+func verifyECDSA(signature []byte, key *ecdsa.PublicKey, d []byte) bool {
+	r, s, err := ParseSignature(signature)
+	if err != nil || s.Cmp(halfOrder(key.Curve)) > 0 {
+		return false
+	}
+	return ecdsa.Verify(key, d, r, s)
+}
+
+// halfOrder is N/2, the low S signatures have s at most halfOrder.
+func halfOrder(curve elliptic.Curve) *big.Int {
+	return new(big.Int).Rsh(curve.Params().N, 1)
+}
+
+func normalizeS(curve elliptic.Curve, s *big.Int) *big.Int {
+	if s.Cmp(halfOrder(curve)) > 0 {
+		return new(big.Int).Sub(curve.Params().N, s)
+	}
+	return s
+}
+
+// SerializeSignature DER encodes the signature (r, s): 0x30 len 0x02 len(r) r 0x02 len(s) s, the integers
+// big endian on as few bytes as they need, with a leading zero byte when their top bit is set.
+func SerializeSignature(r, s *big.Int) []byte {
+	rb, sb := derInt(r), derInt(s)
+	sig := make([]byte, 0, 6+len(rb)+len(sb))
+	sig = append(sig, derSequence, byte(4+len(rb)+len(sb)))
+	sig = append(sig, derInteger, byte(len(rb)))
+	sig = append(sig, rb...)
+	sig = append(sig, derInteger, byte(len(sb)))
+	return append(sig, sb...)
+}
+
+func derInt(v *big.Int) []byte {
+	b := v.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// ParseSignature decodes the DER signature sig, strictly: the shortest encodings, nothing after the sequence
+// and both integers positive. The range of the curve is checked by the verification.
+func ParseSignature(sig []byte) (r, s *big.Int, err error) {
+	if len(sig) < minSignatureLen || len(sig) > MaxSignatureLen || sig[0] != derSequence ||
+		int(sig[1]) != len(sig)-2 {
+		return nil, nil, ErrMalformedSignature
+	}
+	r, rest, err := parseDERInt(sig[2:])
+	if err != nil {
+		return nil, nil, err
+	}
+	s, rest, err = parseDERInt(rest)
+	if err != nil || len(rest) != 0 {
+		return nil, nil, ErrMalformedSignature
+	}
+	return r, s, nil
+}
+
+// parseDERInt decodes the DER integer b starts with, which has to be positive.
+func parseDERInt(b []byte) (*big.Int, []byte, error) {
+	if len(b) < 3 || b[0] != derInteger {
+		return nil, nil, ErrMalformedSignature
+	}
+	size := int(b[1])
+	if size == 0 || size > len(b)-2 {
+		return nil, nil, ErrMalformedSignature
+	}
+	v := b[2 : 2+size]
+	// negative, or padded with a zero byte the next byte does not need
+	if v[0]&0x80 != 0 || (size > 1 && v[0] == 0 && v[1]&0x80 == 0) {
+		return nil, nil, ErrMalformedSignature
+	}
+	n := new(big.Int).SetBytes(v)
+	if n.Sign() == 0 {
+		return nil, nil, ErrMalformedSignature
+	}
+	return n, b[2+size:], nil
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// leading zero coordinates and scalars used to break one signature or key out of 128
+func TestSignAndVerify_Many(t *testing.T) {
+	for i := 0; i < 512; i++ {
+		priKey, pubKey := NewKeyPair()
+		assert.Len(t, pubKey, PubKeyBytesLenCompressed)
+		hash := sha256.Sum256([]byte{byte(i), byte(i >> 8)})
+		sig, err := Sign(&priKey, hash[:])
+		assert.Nil(t, err)
+		_, s, err := ParseSignature(sig)
+		assert.Nil(t, err)
+		assert.True(t, s.Cmp(halfOrder(elliptic.P256())) <= 0)
+		assert.True(t, VerifySign(sig, pubKey, hash[:]))
+	}
+}
+
+func TestVerifySign_HighS(t *testing.T) {
+	priKey, pubKey := NewKeyPair()
+	hash := sha256.Sum256([]byte("high s"))
+	sig, err := Sign(&priKey, hash[:])
+	assert.Nil(t, err)
+	r, s, err := ParseSignature(sig)
+	assert.Nil(t, err)
+
+	// (r, N-s) is as valid to ECDSA but is not accepted
+	highS := new(big.Int).Sub(elliptic.P256().Params().N, s)
+	assert.False(t, VerifySign(SerializeSignature(r, highS), pubKey, hash[:]))
+	assert.False(t, VerifySign(SerializeSignature(r, elliptic.P256().Params().N), pubKey, hash[:]))
+	assert.True(t, VerifySign(SerializeSignature(r, s), pubKey, hash[:]))
+}
+
+func TestParseSignature_Strict(t *testing.T) {
+	r, s := big.NewInt(0x7f), big.NewInt(0x80)
+	sig := SerializeSignature(r, s)
+	assert.Equal(t, "300702017f02020080", hex.EncodeToString(sig))
+	parsedR, parsedS, err := ParseSignature(sig)
+	assert.Nil(t, err)
+	assert.Equal(t, r, parsedR)
+	assert.Equal(t, s, parsedS)
+
+	for _, malformed := range []string{
+		"",
+		"300702017f02020080" + "00", // trailing data
+		"300802017f02020080",        // sequence length
+		"310702017f02020080",        // not a sequence
+		"300703017f02020080",        // not an integer
+		"3007020200f0200080",        // integer lengths
+		"300602017f020180",          // negative s
+		"300702020080020180",        // negative s after a padded r
+		"30080202007f02020080",      // padded r
+		"300702010002020080",        // zero r
+		"3006020102020100",          // zero s
+		"30050201010201",            // too short
+	} {
+		b, _ := hex.DecodeString(malformed)
+		_, _, err = ParseSignature(b)
+		assert.ErrorIs(t, err, ErrMalformedSignature, malformed)
+	}
+}
+
+func TestParsePubKey_SEC1(t *testing.T) {
+	priKey, compressed := NewKeyPair()
+	assert.Contains(t, []byte{0x02, 0x03}, compressed[0])
+	uncompressed := elliptic.Marshal(elliptic.P256(), priKey.X, priKey.Y)
+	for _, key := range [][]byte{compressed, uncompressed} {
+		parsed, err := ParsePubKey(key)
+		assert.Nil(t, err)
+		assert.Equal(t, &priKey.PublicKey, parsed)
+	}
+
+	// a prefix of the wrong kind, a point off the curve
+	wrongPrefix := append([]byte{0x04}, compressed[1:]...)
+	offCurve := append([]byte{}, uncompressed...)
+	offCurve[64] ^= 1
+	for _, key := range [][]byte{wrongPrefix, offCurve, compressed[:32], append(compressed, 0)} {
+		_, err := ParsePubKey(key)
+		assert.ErrorIs(t, err, ErrInvalidPubKey)
+	}
+}
+
+func TestParsePubKey_Legacy(t *testing.T) {
+	priKey, _ := NewKeyPair()
+	raw := append(priKey.X.FillBytes(make([]byte, 32)), priKey.Y.FillBytes(make([]byte, 32))...)
+	parsed, err := ParsePubKey(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, &priKey.PublicKey, parsed)
+	raw[63] ^= 1
+	_, err = ParsePubKey(raw)
+	assert.ErrorIs(t, err, ErrInvalidPubKey)
+
+	// the coordinates were not padded, a short key is split where it makes a point
+	for priKey.X.BitLen() > 248 && priKey.Y.BitLen() > 248 {
+		priKey, _ = NewKeyPair()
+	}
+	short := MarshalLegacyPubKey(&priKey.PublicKey)
+	assert.Less(t, len(short), LegacyPubKeyLen)
+	parsed, err = ParsePubKey(short)
+	assert.Nil(t, err)
+	assert.Equal(t, &priKey.PublicKey, parsed)
+}
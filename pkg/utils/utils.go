@@ -7,9 +7,8 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
-	"fmt"
+	"errors"
 	"log"
-	"math/big"
 
 	"github.com/jiuzhou-zhao/blockchain.go/pkg/base58"
 	"golang.org/x/crypto/ripemd160"
@@ -37,8 +36,7 @@ func NewKeyPair() (ecdsa.PrivateKey, []byte) {
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-	return *private, pubKey
+	return *private, MarshalPubKey(&private.PublicKey)
 }
 
 func HashPubKey(pubKey []byte) []byte {
@@ -68,46 +66,33 @@ func Base58DecodeWithCheck(payload string) ([]byte, error) {
 }
 
 func Pubkey2Address(key []byte, version byte) string {
-	payload := HashPubKey(key)
-	versionedPayload := append([]byte{version}, payload...)
+	return PubkeyHash2Address(HashPubKey(key), version)
+}
+
+func PubkeyHash2Address(pubkeyHash []byte, version byte) string {
+	versionedPayload := append([]byte{version}, pubkeyHash...)
 	return Base58EncodeWithCheck(versionedPayload)
 }
 
 func Address2PubkeyHash(address string) (pubkeyHash []byte, err error) {
+	_, pubkeyHash, err = DecodeAddress(address)
+	return
+}
+
+// DecodeAddress returns the version byte of address and the hash it carries, of a key or of a script.
+func DecodeAddress(address string) (version byte, hash []byte, err error) {
 	payload, err := Base58DecodeWithCheck(address)
 	if err != nil {
 		return
 	}
-	pubkeyHash = payload[1:]
-	return
+	if len(payload) == 0 {
+		err = errors.New("empty address")
+		return
+	}
+	return payload[0], payload[1:], nil
 }
 
 func IsValidAddress(address string) bool {
 	_, err := Address2PubkeyHash(address)
 	return err == nil
 }
-
-func Sign(priKey *ecdsa.PrivateKey, d []byte) ([]byte, error) {
-	r, s, err := ecdsa.Sign(rand.Reader, priKey, d)
-	if err != nil {
-		return nil, fmt.Errorf("%w", err)
-	}
-	return append(r.Bytes(), s.Bytes()...), nil
-}
-
-func VerifySign(signature []byte, pubKey []byte, d []byte) bool {
-	r := big.Int{}
-	s := big.Int{}
-	sigLen := len(signature)
-	r.SetBytes(signature[:(sigLen / 2)])
-	s.SetBytes(signature[(sigLen / 2):])
-
-	x := big.Int{}
-	y := big.Int{}
-	keyLen := len(pubKey)
-	x.SetBytes(pubKey[:(keyLen / 2)])
-	y.SetBytes(pubKey[(keyLen / 2):])
-
-	rawPubKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
-	return ecdsa.Verify(&rawPubKey, d, &r, &s)
-}
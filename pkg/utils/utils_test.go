@@ -77,3 +77,32 @@ func TestSignAndVerify(t *testing.T) {
 	ok := VerifySign(signature, pubKey, data)
 	assert.True(t, ok)
 }
+
+func TestParsePubKey(t *testing.T) {
+	priKey, pubKey := NewKeyPair()
+	parsed, err := ParsePubKey(pubKey)
+	assert.Nil(t, err)
+	assert.Equal(t, &priKey.PublicKey, parsed)
+
+	pubKey[0] ^= 0xff
+	_, err = ParsePubKey(pubKey)
+	assert.NotNil(t, err)
+	_, err = ParsePubKey(nil)
+	assert.NotNil(t, err)
+}
+
+func TestDecodeAddress(t *testing.T) {
+	_, pubKey := NewKeyPair()
+	for _, version := range []byte{0x00, 0x05} {
+		address := Pubkey2Address(pubKey, version)
+		decodedVersion, hash, err := DecodeAddress(address)
+		assert.Nil(t, err)
+		assert.Equal(t, version, decodedVersion)
+		assert.Equal(t, HashPubKey(pubKey), hash)
+	}
+	assert.NotEqual(t, Pubkey2Address(pubKey, 0x00), Pubkey2Address(pubKey, 0x05))
+
+	_, _, err := DecodeAddress(Base58EncodeWithCheck(nil))
+	assert.NotNil(t, err)
+	assert.False(t, IsValidAddress("bad"))
+}
@@ -0,0 +1,93 @@
+// Package wif encodes private keys in the Wallet Import Format: base58check of a version byte, the 32 bytes
+// private scalar and, when the public key is used compressed, the byte 0x01.
+package wif
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+)
+
+const (
+	// MainNetVersion is the version byte of the private keys of the main network.
+	MainNetVersion = byte(0x80)
+
+	privKeyLen    = 32
+	compressMagic = byte(0x01)
+)
+
+var (
+	ErrMalformedPrivateKey = errors.New("malformed private key")
+	ErrWrongVersion        = errors.New("private key is for another network")
+)
+
+// WIF is a private key with the network it is for and whether its public key is used compressed.
+type WIF struct {
+	PrivKey        *ecdsa.PrivateKey
+	Version        byte
+	CompressPubKey bool
+}
+
+// NewWIF wraps the P256 private key privKey.
+func NewWIF(privKey *ecdsa.PrivateKey, version byte, compress bool) (*WIF, error) {
+	if privKey == nil || privKey.D == nil || privKey.D.Sign() <= 0 || privKey.D.Cmp(elliptic.P256().Params().N) >= 0 {
+		return nil, ErrMalformedPrivateKey
+	}
+	return &WIF{PrivKey: privKey, Version: version, CompressPubKey: compress}, nil
+}
+
+// Decode parses the WIF string s.
+func Decode(s string) (*WIF, error) {
+	payload, err := utils.Base58DecodeWithCheck(s)
+	if err != nil {
+		return nil, ErrMalformedPrivateKey
+	}
+	compress := false
+	switch len(payload) {
+	case 1 + privKeyLen:
+	case 1 + privKeyLen + 1:
+		if payload[len(payload)-1] != compressMagic {
+			return nil, ErrMalformedPrivateKey
+		}
+		compress = true
+	default:
+		return nil, ErrMalformedPrivateKey
+	}
+
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(payload[1 : 1+privKeyLen])
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrMalformedPrivateKey
+	}
+	privKey := &ecdsa.PrivateKey{D: d}
+	privKey.Curve = curve
+	privKey.X, privKey.Y = curve.ScalarBaseMult(payload[1 : 1+privKeyLen])
+	return &WIF{PrivKey: privKey, Version: payload[0], CompressPubKey: compress}, nil
+}
+
+// DecodeForVersion parses s and checks it is a key of the network of version.
+func DecodeForVersion(s string, version byte) (*WIF, error) {
+	w, err := Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if w.Version != version {
+		return nil, ErrWrongVersion
+	}
+	return w, nil
+}
+
+// String encodes the key.
+func (w *WIF) String() string {
+	payload := make([]byte, 1+privKeyLen, 1+privKeyLen+1)
+	payload[0] = w.Version
+	d := w.PrivKey.D.Bytes()
+	copy(payload[1+privKeyLen-len(d):], d)
+	if w.CompressPubKey {
+		payload = append(payload, compressMagic)
+	}
+	return utils.Base58EncodeWithCheck(payload)
+}
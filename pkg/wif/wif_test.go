@@ -0,0 +1,63 @@
+package wif
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jiuzhou-zhao/blockchain.go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// The encoding vectors of the Bitcoin wiki, the scalar is a valid P256 key as well.
+func TestWIF_Vectors(t *testing.T) {
+	d, _ := hex.DecodeString("0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d")
+
+	for _, vector := range []struct {
+		wif      string
+		compress bool
+	}{
+		{"5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", false},
+		{"KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617", true},
+	} {
+		w, err := DecodeForVersion(vector.wif, MainNetVersion)
+		assert.Nil(t, err)
+		assert.Equal(t, d, w.PrivKey.D.Bytes())
+		assert.Equal(t, vector.compress, w.CompressPubKey)
+		assert.True(t, w.PrivKey.Curve.IsOnCurve(w.PrivKey.X, w.PrivKey.Y))
+		assert.Equal(t, vector.wif, w.String())
+	}
+}
+
+func TestWIF_RoundTrip(t *testing.T) {
+	privKey, _ := utils.NewKeyPair()
+	w, err := NewWIF(&privKey, 0xef, false)
+	assert.Nil(t, err)
+
+	decoded, err := Decode(w.String())
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0xef), decoded.Version)
+	assert.Equal(t, privKey.D, decoded.PrivKey.D)
+	assert.Equal(t, privKey.X, decoded.PrivKey.X)
+	assert.Equal(t, privKey.Y, decoded.PrivKey.Y)
+
+	_, err = DecodeForVersion(w.String(), MainNetVersion)
+	assert.ErrorIs(t, err, ErrWrongVersion)
+}
+
+func TestWIF_Malformed(t *testing.T) {
+	_, err := NewWIF(nil, MainNetVersion, false)
+	assert.ErrorIs(t, err, ErrMalformedPrivateKey)
+
+	for _, payload := range [][]byte{
+		append([]byte{MainNetVersion}, make([]byte, privKeyLen)...),
+		append([]byte{MainNetVersion}, make([]byte, privKeyLen-1)...),
+		append(append([]byte{MainNetVersion, 1}, make([]byte, privKeyLen-1)...), 0x02),
+	} {
+		_, err = Decode(utils.Base58EncodeWithCheck(payload))
+		assert.ErrorIs(t, err, ErrMalformedPrivateKey)
+	}
+
+	// a typo breaks the checksum
+	_, err = Decode("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTK")
+	assert.ErrorIs(t, err, ErrMalformedPrivateKey)
+}